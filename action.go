@@ -0,0 +1,234 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrOperationFailed is matched by the OperationError of an operation that
+// an Action started and that failed.
+var ErrOperationFailed = errors.New("operation failed")
+
+// ActionOptions configures Action.
+type ActionOptions struct {
+	// Method is the method of the action. Defaults to POST.
+	Method string
+
+	// Status lists the status codes accepting the action. Defaults to any
+	// 2xx; other 2xx codes fail with an UnexpectedStatusError.
+	Status []int
+
+	// Poll, when set, waits for the operation the action started.
+	Poll *OperationPoll
+
+	// Options are applied to the action and to every poll request.
+	Options []RequestOption
+}
+
+// OperationPoll describes how to wait for the operation started by an
+// action.
+type OperationPoll struct {
+	// URI is the operation to poll. Defaults to the Operation-Location or
+	// Location header of the action's response; without any, the action
+	// is not polled.
+	URI string
+
+	// Interval is the time between polls. Defaults to DefaultPollInterval.
+	Interval time.Duration
+
+	// Done reports whether the operation whose body is msg finished, and
+	// the error it failed with. Defaults to OperationDone.
+	Done func(msg json.RawMessage) (bool, error)
+}
+
+// UnexpectedStatusError reports a successful response whose status is not
+// among those a call expects.
+type UnexpectedStatusError struct {
+	StatusCode int
+	Expected   []int
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d, expected one of %v", e.StatusCode, e.Expected)
+}
+
+// OperationError reports an operation that ended in failure.
+type OperationError struct {
+	URI    string
+	Status string
+	Body   json.RawMessage
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("%s: %s is %s", ErrOperationFailed, e.URI, e.Status)
+}
+
+// Is reports whether target is ErrOperationFailed.
+func (e *OperationError) Is(target error) bool {
+	return target == ErrOperationFailed
+}
+
+// OperationDone is the default OperationPoll.Done. It understands the
+// common operation resources: a top-level "done" true, or a "status" or
+// "state" of succeeded, success, completed or done finish the operation; a
+// "status" or "state" of failed, error, canceled or cancelled fail it.
+func OperationDone(msg json.RawMessage) (bool, error) {
+	var op struct {
+		Done   bool   `json:"done"`
+		Status string `json:"status"`
+		State  string `json:"state"`
+	}
+	if err := json.Unmarshal(msg, &op); err != nil {
+		return false, err
+	}
+	status := op.Status
+	if status == "" {
+		status = op.State
+	}
+	switch strings.ToLower(status) {
+	case "succeeded", "success", "completed", "done":
+		return true, nil
+	case "failed", "error", "canceled", "cancelled":
+		return true, &OperationError{Status: status, Body: msg}
+	}
+	return op.Done, nil
+}
+
+// Action triggers an action endpoint, like POST /servers/{id}/actions/restart,
+// that takes no body, and decodes the response into response, which may be
+// nil. With opts.Poll it then waits for the operation the action started
+// and decodes its final state into response instead.
+func (c *Client) Action(ctx context.Context, uri string, response interface{}, opts *ActionOptions) error {
+	if opts == nil {
+		opts = &ActionOptions{}
+	}
+	method := opts.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	var result Result
+	var body json.RawMessage
+	target := interface{}(&body)
+	if opts.Poll == nil {
+		target = response
+	}
+	err := c.DoContext(ctx, method, uri, nil, target, append(opts.Options[:len(opts.Options):len(opts.Options)], WithResult(&result))...)
+	if err != nil {
+		return err
+	}
+	if !expectedStatus(result.StatusCode, opts.Status) {
+		return &UnexpectedStatusError{StatusCode: result.StatusCode, Expected: opts.Status}
+	}
+	if opts.Poll == nil {
+		return nil
+	}
+
+	op, err := c.operationURL(result, opts.Poll.URI)
+	if err != nil {
+		return err
+	}
+	if op == nil {
+		return decodeRaw(body, response)
+	}
+	return c.pollOperation(ctx, op, opts, response)
+}
+
+func expectedStatus(code int, expected []int) bool {
+	if len(expected) == 0 {
+		return true
+	}
+	for _, s := range expected {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+// operationURL returns the URL of the operation to poll, or nil if there
+// is none.
+func (c *Client) operationURL(result Result, uri string) (*url.URL, error) {
+	if uri != "" {
+		query, err := c.GetQuery(uri)
+		if err != nil {
+			return nil, err
+		}
+		return url.Parse(query)
+	}
+	loc := result.Header.Get("Operation-Location")
+	if loc == "" {
+		loc = result.Header.Get("Location")
+	}
+	if loc == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, err
+	}
+	if result.Response != nil && result.Response.Request != nil {
+		u = result.Response.Request.URL.ResolveReference(u)
+	}
+	return u, nil
+}
+
+// pollOperation GETs op until it is done and decodes its final state into
+// response.
+func (c *Client) pollOperation(ctx context.Context, op *url.URL, opts *ActionOptions, response interface{}) error {
+	done := opts.Poll.Done
+	if done == nil {
+		done = OperationDone
+	}
+	interval := opts.Poll.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, op.String(), nil)
+		if err != nil {
+			return err
+		}
+		var msg json.RawMessage
+		if err := c.jsonResponse(req, &msg, newCallOptions(opts.Options)); err != nil {
+			return err
+		}
+		finished, err := done(msg)
+		var opErr *OperationError
+		if errors.As(err, &opErr) && opErr.URI == "" {
+			opErr.URI = redactURL(op, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if finished {
+			return decodeRaw(msg, response)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// decodeRaw decodes the JSON msg into v, if both are set.
+func decodeRaw(msg json.RawMessage, v interface{}) error {
+	if v == nil || isEmptyBody(msg) {
+		return nil
+	}
+	return json.Unmarshal(msg, v)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Action(t *testing.T) {
+	var method, body string
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/servers/1/actions/restart":
+			b, _ := ioutil.ReadAll(r.Body)
+			method, body = r.Method, string(b)
+			w.WriteHeader(http.StatusNoContent)
+		case "/servers/1/actions/resize":
+			w.Header().Set("Location", "/operations/7")
+			w.WriteHeader(http.StatusAccepted)
+		case "/servers/1/actions/wipe":
+			w.Header().Set("Operation-Location", "/operations/8")
+			w.WriteHeader(http.StatusAccepted)
+		case "/operations/7":
+			if atomic.AddInt32(&polls, 1) < 3 {
+				w.Write([]byte(`{"status": "running"}`))
+				return
+			}
+			w.Write([]byte(`{"status": "succeeded", "Foo": "resized"}`))
+		case "/operations/8":
+			w.Write([]byte(`{"state": "FAILED", "error": "disk busy"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	ctx := context.Background()
+
+	if err := c.Action(ctx, "/servers/1/actions/restart", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || body != "" {
+		t.Errorf("Expected an empty POST, got %s %q", method, body)
+	}
+
+	err := c.Action(ctx, "/servers/1/actions/restart", nil, &ActionOptions{Method: http.MethodPatch, Status: []int{http.StatusAccepted}})
+	var statusErr *UnexpectedStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNoContent || method != http.MethodPatch {
+		t.Errorf("Expected an UnexpectedStatusError for a PATCH, got %v", err)
+	}
+
+	var response Response
+	poll := &OperationPoll{Interval: time.Millisecond}
+	if err := c.Action(ctx, "/servers/1/actions/resize", &response, &ActionOptions{Poll: poll}); err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "resized" || atomic.LoadInt32(&polls) != 3 {
+		t.Errorf("Expected the final operation state after 3 polls, got %+v after %d", response, polls)
+	}
+
+	err = c.Action(ctx, "/servers/1/actions/wipe", nil, &ActionOptions{Poll: poll})
+	var opErr *OperationError
+	if !errors.Is(err, ErrOperationFailed) || !errors.As(err, &opErr) || !strings.HasSuffix(opErr.URI, "/operations/8") || opErr.Status != "FAILED" {
+		t.Errorf("Expected a failed operation, got %v", err)
+	}
+}
+
+func TestOperationDone(t *testing.T) {
+	for body, want := range map[string]bool{
+		`{"status": "running"}`:   false,
+		`{"status": "Completed"}`: true,
+		`{"state": "done"}`:       true,
+		`{"done": true}`:          true,
+		`{}`:                      false,
+	} {
+		if done, err := OperationDone([]byte(body)); done != want || err != nil {
+			t.Errorf("Expected %s to be done %v, got %v, %v", body, want, done, err)
+		}
+	}
+	if done, err := OperationDone([]byte(`{"status": "cancelled"}`)); !done || !errors.Is(err, ErrOperationFailed) {
+		t.Errorf("Expected a cancelled operation to fail, got %v, %v", done, err)
+	}
+}
+
+func TestClient_ActionSharedOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	options := make([]RequestOption, 1, 4)
+	options[0] = WithHeader("X-Trace", "1")
+	opts := &ActionOptions{Options: options}
+
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			done <- c.Action(context.Background(), "/servers/1/actions/restart", nil, opts)
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spare := options[:2]; spare[1] != nil {
+		t.Errorf("Expected Action not to write into the caller's options")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+)
+
+// KeySlot identifies which of the client's API keys was used.
+type KeySlot int32
+
+const (
+	// PrimaryKey is the key given to NewClient.
+	PrimaryKey KeySlot = iota
+
+	// SecondaryKey is Client.SecondaryAPIKey.
+	SecondaryKey
+)
+
+func (k KeySlot) String() string {
+	if k == SecondaryKey {
+		return "secondary"
+	}
+	return "primary"
+}
+
+func (k KeySlot) other() KeySlot {
+	return 1 - k
+}
+
+func (c *Client) apiKeyFor(slot KeySlot) string {
+	if slot == SecondaryKey {
+		return c.SecondaryAPIKey
+	}
+	return c.apiKey
+}
+
+// preferredKey is the slot that last succeeded, so once a rotation has
+// happened requests stop paying for a rejected first attempt.
+func (c *Client) preferredKey() KeySlot {
+	if c.Auth != nil || c.SecondaryAPIKey == "" {
+		return PrimaryKey
+	}
+	return KeySlot(atomic.LoadInt32(&c.keySlot))
+}
+
+func isAuthFailure(res *http.Response) bool {
+	return res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden
+}
+
+// sendWithKeys sends an authorized copy of r with the preferred API key
+// and, when it is rejected with 401 or 403 and a secondary key is
+// configured, once more with the other key. The key that was last used is
+// recorded in the call's Result.
+func (c *Client) sendWithKeys(r *http.Request) (*http.Response, error) {
+	result := callResult(r)
+	slot := c.preferredKey()
+	if result != nil {
+		result.Key = slot
+	}
+
+	res, err := c.sendAuthorized(r, slot)
+	if err != nil || c.Auth != nil || c.SecondaryAPIKey == "" || !isAuthFailure(res) {
+		return res, err
+	}
+
+	if rewindBody(r) != nil {
+		return res, nil
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	slot = slot.other()
+	if result != nil {
+		result.Key = slot
+	}
+	if c.CollectStats {
+		c.stats.retry(routeKey(r))
+	}
+
+	res, err = c.sendAuthorized(r, slot)
+	if err == nil && !isAuthFailure(res) {
+		atomic.StoreInt32(&c.keySlot, int32(slot))
+	}
+	return res, err
+}
+
+func (c *Client) sendAuthorized(r *http.Request, slot KeySlot) (*http.Response, error) {
+	out := r.Clone(r.Context())
+	if err := c.authorize(out, slot); err != nil {
+		return nil, err
+	}
+	out = withCredentials(r, out)
+	if err := c.checkPolicies(out); err != nil {
+		return nil, err
+	}
+	res, err := c.sendRequest(out)
+	if ta, ok := c.Auth.(*TokenAuthenticator); ok && err == nil && res.StatusCode == http.StatusUnauthorized {
+		ta.rejected(out)
+	}
+	return res, err
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_SecondaryAPIKey(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "Token token=\"new\"" {
+			http.Error(w, "revoked", http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("{\"Foo\": " + string(body) + "}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, "old")
+	c.SecondaryAPIKey = "new"
+
+	var response Response
+	if err := c.CreateJson("/api/foo", "bar", &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "bar" {
+		t.Errorf("Expected resent body to be intact, got %q", response.Foo)
+	}
+	if c.LastKey != SecondaryKey {
+		t.Errorf("Expected secondary key to succeed, got %s", c.LastKey)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 calls, got %d", n)
+	}
+
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected the secondary key to be used first after rotation, got %d calls", n)
+	}
+}
+
+func TestClient_PrimaryAPIKeyOnly(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	res, err := c.GetResponse(mustRequest(t, c, http.MethodGet, "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusForbidden || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected a single rejected call without a secondary key")
+	}
+	if c.LastKey != PrimaryKey {
+		t.Errorf("Expected primary key, got %s", c.LastKey)
+	}
+}
+
+func mustRequest(t *testing.T, c *Client, method, uri string) *http.Request {
+	req, err := c.MakeRequest(method, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ArchiveRecord describes an archived response.
+type ArchiveRecord struct {
+	Time   time.Time
+	Method string
+
+	// URL has its user info removed.
+	URL        string
+	StatusCode int
+	Header     http.Header
+
+	// Truncated is set before the sink's writer is closed when the body
+	// was closed before it was read to the end.
+	Truncated bool
+}
+
+// ArchiveSink stores raw response bodies, e.g. on disk or in object
+// storage. An S3 sink can hand the write end of an io.Pipe to an upload.
+type ArchiveSink interface {
+	// Open returns the writer receiving the body of the response rec
+	// describes. The body is written as the caller reads it and the
+	// writer is closed with the body.
+	Open(ctx context.Context, rec *ArchiveRecord) (io.WriteCloser, error)
+}
+
+// ArchiveConfig copies response bodies to a sink while they are decoded
+// for the caller. Archiving never fails a call; a sink that cannot keep up
+// or fails only stops receiving that body.
+type ArchiveConfig struct {
+	Sink ArchiveSink
+
+	// Match, when set, selects the responses to archive. Defaults to all.
+	Match func(r *http.Request, res *http.Response) bool
+
+	// OnError, when set, is told about sink failures.
+	OnError func(err error)
+}
+
+// WithArchive archives response bodies as configured by cfg.
+func WithArchive(cfg *ArchiveConfig) Option {
+	return func(c *Client) {
+		c.Archive = cfg
+	}
+}
+
+// wrap makes the body of res flow to the sink as it is read.
+func (a *ArchiveConfig) wrap(r *http.Request, res *http.Response, now time.Time) *http.Response {
+	if a.Match != nil && !a.Match(r, res) {
+		return res
+	}
+	rec := &ArchiveRecord{
+		Time:       now,
+		Method:     r.Method,
+		URL:        redactRequestURL(r, nil),
+		StatusCode: res.StatusCode,
+		Header:     res.Header.Clone(),
+	}
+	w, err := a.Sink.Open(r.Context(), rec)
+	if err != nil {
+		a.failed(err)
+		return res
+	}
+	res.Body = &archiveBody{ReadCloser: res.Body, a: a, rec: rec, w: w}
+	return res
+}
+
+func (a *ArchiveConfig) failed(err error) {
+	if a.OnError != nil {
+		a.OnError(fmt.Errorf("archiving response: %w", err))
+	}
+}
+
+type archiveBody struct {
+	io.ReadCloser
+	a   *ArchiveConfig
+	rec *ArchiveRecord
+	w   io.WriteCloser // nil once failed or closed
+	eof bool
+}
+
+func (b *archiveBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.w != nil {
+		if _, werr := b.w.Write(p[:n]); werr != nil {
+			b.a.failed(werr)
+			b.w.Close()
+			b.w = nil
+		}
+	}
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *archiveBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.w != nil {
+		b.rec.Truncated = !b.eof
+		if werr := b.w.Close(); werr != nil {
+			b.a.failed(werr)
+		}
+		b.w = nil
+	}
+	return err
+}
+
+// DirArchive returns a sink saving each body to a file in dir, next to a
+// JSON file holding its ArchiveRecord. Files are named after the time,
+// method and path of the request, like
+// 20240102T150405.000000001Z-000001-GET-api_users_42.body.
+func DirArchive(dir string) ArchiveSink {
+	return &dirArchive{dir: dir}
+}
+
+type dirArchive struct {
+	dir string
+	seq int64
+}
+
+func (d *dirArchive) Open(ctx context.Context, rec *ArchiveRecord) (io.WriteCloser, error) {
+	path := rec.URL
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+	}
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		path = path[i+1:]
+	}
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, path)
+
+	name := fmt.Sprintf("%s-%06d-%s-%s", rec.Time.UTC().Format("20060102T150405.000000000Z"), atomic.AddInt64(&d.seq, 1), rec.Method, path)
+	base := filepath.Join(d.dir, name)
+	f, err := os.Create(base + ".body.part")
+	if err != nil {
+		return nil, err
+	}
+	return &archiveFile{File: f, base: base, rec: rec}, nil
+}
+
+// archiveFile renames its body into place and writes the record once
+// closed.
+type archiveFile struct {
+	*os.File
+	base string
+	rec  *ArchiveRecord
+}
+
+func (f *archiveFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.File.Name(), f.base+".body"); err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(f.rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.base+".json", meta)
+}
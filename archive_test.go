@@ -0,0 +1,144 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type bufferSink struct {
+	records []*ArchiveRecord
+	bodies  []*closingBuffer
+	err     error
+}
+
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+	err    error
+}
+
+func (b *closingBuffer) Write(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (s *bufferSink) Open(ctx context.Context, rec *ArchiveRecord) (io.WriteCloser, error) {
+	b := &closingBuffer{err: s.err}
+	s.records = append(s.records, rec)
+	s.bodies = append(s.bodies, b)
+	return b, nil
+}
+
+func TestClient_Archive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	sink := &bufferSink{}
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Archive = &ArchiveConfig{
+		Sink:  sink,
+		Match: func(r *http.Request, res *http.Response) bool { return !strings.HasPrefix(r.URL.Path, "/health") },
+	}
+
+	var response Response
+	if err := c.ReadJson("/api/foo?page=2", &response); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReadJson("/health", &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected one archived response, got %d", len(sink.records))
+	}
+	rec := sink.records[0]
+	if rec.Method != "GET" || rec.URL != server.URL+"/api/foo?page=2" || rec.StatusCode != 200 || rec.Truncated {
+		t.Errorf("Unexpected record %+v", rec)
+	}
+	if body := sink.bodies[0]; body.String() != `{"Foo": "bar"}` || !body.closed {
+		t.Errorf("Expected the whole body to be archived, got %q", body.String())
+	}
+	if response.Foo != "bar" {
+		t.Errorf("Expected the body to still be decoded, got %q", response.Foo)
+	}
+}
+
+func TestClient_ArchiveFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	var sinkErr error
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Archive = &ArchiveConfig{
+		Sink:    &bufferSink{err: errors.New("disk full")},
+		OnError: func(err error) { sinkErr = err },
+	}
+
+	var response Response
+	if err := c.ReadJson("/api/foo", &response); err != nil || response.Foo != "bar" {
+		t.Fatalf("Expected the call to succeed despite the sink, got %v", err)
+	}
+	if sinkErr == nil || !strings.Contains(sinkErr.Error(), "disk full") {
+		t.Errorf("Expected the sink failure to be reported, got %v", sinkErr)
+	}
+}
+
+func TestDirArchive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "relax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Archive = &ArchiveConfig{Sink: DirArchive(dir)}
+	if err := c.ReadJson("/api/users/42", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	bodies, _ := filepath.Glob(filepath.Join(dir, "*-GET-api_users_42.body"))
+	if len(bodies) != 1 {
+		t.Fatalf("Expected an archived body, got %v", bodies)
+	}
+	if b, _ := ioutil.ReadFile(bodies[0]); string(b) != `{"Foo": "bar"}` {
+		t.Errorf("Unexpected archived body %q", b)
+	}
+	meta, err := ioutil.ReadFile(strings.TrimSuffix(bodies[0], ".body") + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec ArchiveRecord
+	if err := json.Unmarshal(meta, &rec); err != nil || rec.StatusCode != 200 {
+		t.Errorf("Unexpected record %s", meta)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Authenticator adds credentials to an outgoing request. It is applied to a
+// copy of the request right before it is sent, so credentials never end up
+// in the request passed to GetResponse or in errors.
+type Authenticator interface {
+	Apply(r *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface.
+type AuthenticatorFunc func(r *http.Request) error
+
+func (f AuthenticatorFunc) Apply(r *http.Request) error {
+	return f(r)
+}
+
+// Identifier is implemented by Authenticators that can name whom they
+// authenticate as, e.g. a user ID or the credentials themselves, which
+// are only kept as a hash. Cache keeps the entries of each identity apart;
+// without one, see Client.CacheScope.
+type Identifier interface {
+	Identity() string
+}
+
+// identifiedAuth is an Authenticator identified by the credentials it
+// sends.
+type identifiedAuth struct {
+	AuthenticatorFunc
+	id string
+}
+
+func (a identifiedAuth) Identity() string {
+	return a.id
+}
+
+// TokenAuth sends key as `Authorization: Token token="key"`. It is the
+// default way API keys are sent.
+func TokenAuth(key string) Authenticator {
+	return HeaderAuth("Authorization", fmt.Sprintf("Token token=\"%s\"", key))
+}
+
+// BearerAuth sends token as `Authorization: Bearer token`.
+func BearerAuth(token string) Authenticator {
+	return HeaderAuth("Authorization", "Bearer "+token)
+}
+
+// BasicAuth sends HTTP Basic credentials.
+func BasicAuth(username, password string) Authenticator {
+	return identifiedAuth{func(r *http.Request) error {
+		r.SetBasicAuth(username, password)
+		return nil
+	}, "basic " + username + ":" + password}
+}
+
+// HeaderAuth sends value in the named header, e.g. X-API-Key.
+func HeaderAuth(header, value string) Authenticator {
+	return identifiedAuth{func(r *http.Request) error {
+		r.Header.Set(header, value)
+		return nil
+	}, "header " + http.CanonicalHeaderKey(header) + ": " + value}
+}
+
+// QueryAuth sends key as the named query parameter.
+func QueryAuth(param, key string) Authenticator {
+	return identifiedAuth{func(r *http.Request) error {
+		q := r.URL.Query()
+		q.Set(param, key)
+		r.URL.RawQuery = q.Encode()
+		return nil
+	}, "query " + param + "=" + key}
+}
+
+// WithAuthenticator authenticates every request with a instead of the API
+// key. The API key given to NewClient may then be empty.
+func WithAuthenticator(a Authenticator) Option {
+	return func(c *Client) {
+		c.Auth = a
+	}
+}
+
+// WithKeyAuth changes how the API keys are sent, e.g. WithKeyAuth(BearerAuth).
+func WithKeyAuth(scheme func(key string) Authenticator) Option {
+	return func(c *Client) {
+		c.KeyAuth = scheme
+	}
+}
+
+// authorize applies the client's credentials to r, using the API key in
+// slot unless a fixed Authenticator is configured.
+func (c *Client) authorize(r *http.Request, slot KeySlot) error {
+	if c.Auth != nil {
+		return c.Auth.Apply(r)
+	}
+
+	key := c.apiKeyFor(slot)
+	if key == "" {
+		return nil
+	}
+	scheme := c.KeyAuth
+	if scheme == nil {
+		scheme = TokenAuth
+	}
+	return scheme(key).Apply(r)
+}
+
+type credentialsContextKey struct{}
+
+// credentials records what authorize and the URLSigner added to a request
+// attempt, so Shadow can take them out of the copies it sends elsewhere.
+type credentials struct {
+	headers []string
+	query   []string
+
+	// unsigned is the URL before URLSigner signed it, if it did.
+	unsigned *url.URL
+}
+
+// withCredentials returns out, the authorized copy of r, recording the
+// headers and query parameters authorize set on it.
+func withCredentials(r, out *http.Request) *http.Request {
+	cred := &credentials{}
+	for name, values := range out.Header {
+		if !equalStrings(values, r.Header[name]) {
+			cred.headers = append(cred.headers, name)
+		}
+	}
+	if out.URL.RawQuery != r.URL.RawQuery {
+		was := r.URL.Query()
+		for name, values := range out.URL.Query() {
+			if !equalStrings(values, was[name]) {
+				cred.query = append(cred.query, name)
+			}
+		}
+	}
+	return out.WithContext(context.WithValue(out.Context(), credentialsContextKey{}, cred))
+}
+
+func requestCredentials(r *http.Request) *credentials {
+	cred, _ := r.Context().Value(credentialsContextKey{}).(*credentials)
+	return cred
+}
+
+// stripCredentials removes from r the credentials of the client that sent
+// it: those authorize and the URLSigner added, and any Authorization,
+// Proxy-Authorization or Cookie header.
+func stripCredentials(r *http.Request) {
+	if cred := requestCredentials(r); cred != nil {
+		if cred.unsigned != nil {
+			r.URL.Path, r.URL.RawPath, r.URL.RawQuery = cred.unsigned.Path, cred.unsigned.RawPath, cred.unsigned.RawQuery
+		}
+		for _, name := range cred.headers {
+			r.Header.Del(name)
+		}
+		if len(cred.query) > 0 {
+			q := r.URL.Query()
+			for _, name := range cred.query {
+				q.Del(name)
+			}
+			r.URL.RawQuery = q.Encode()
+		}
+	}
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie"} {
+		r.Header.Del(name)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClient_Authenticators(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		opt   Option
+		check func(r *http.Request) bool
+	}{
+		{"default", nil, func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Token token=\"apiKey\""
+		}},
+		{"key bearer", WithKeyAuth(BearerAuth), func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer apiKey"
+		}},
+		{"bearer", WithAuthenticator(BearerAuth("jwt")), func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer jwt"
+		}},
+		{"basic", WithAuthenticator(BasicAuth("user", "pass")), func(r *http.Request) bool {
+			u, p, ok := r.BasicAuth()
+			return ok && u == "user" && p == "pass"
+		}},
+		{"header", WithAuthenticator(HeaderAuth("X-API-Key", "k")), func(r *http.Request) bool {
+			return r.Header.Get("X-API-Key") == "k" && r.Header.Get("Authorization") == ""
+		}},
+		{"query", WithAuthenticator(QueryAuth("api_key", "k")), func(r *http.Request) bool {
+			return r.URL.Query().Get("api_key") == "k" && r.URL.Query().Get("page") == "2"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.opt != nil {
+				opts = append(opts, tt.opt)
+			}
+			c, err := NewClient(server.URL, apiKey, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := mustRequest(t, c, http.MethodGet, "/api/foo?page=2")
+			if _, err := c.GetResponse(req); err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.check(got) {
+				t.Errorf("Unexpected credentials in %v %v", got.URL, got.Header)
+			}
+			if req.Header.Get("Authorization") != "" || req.URL.Query().Get("api_key") != "" {
+				t.Errorf("Expected the caller's request to stay free of credentials")
+			}
+		})
+	}
+}
+
+func TestClient_NewClientWithAuthenticatorAndNoKey(t *testing.T) {
+	if _, err := NewClient(goodURL, "", WithAuthenticator(BearerAuth("jwt"))); err != nil {
+		t.Errorf("Expected an authenticator to stand in for the API key, got %v", err)
+	}
+}
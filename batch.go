@@ -0,0 +1,233 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DefaultBatchItems is used when BatchOptions.MaxItems and MaxBytes are
+// both zero.
+const DefaultBatchItems = 100
+
+// Batch is a group of encoded items sent in one request.
+type Batch struct {
+	// Index is the position of the batch, starting at 0.
+	Index int
+
+	// Offset is the position of the first item of the batch in the input.
+	Offset int
+
+	Items []json.RawMessage
+}
+
+// BatchOptions configures PostBatches.
+type BatchOptions struct {
+	// MaxItems and MaxBytes bound each batch by item count and by the
+	// size of its encoded JSON array. Zero means no bound; if both are
+	// zero, batches hold DefaultBatchItems items.
+	MaxItems int
+	MaxBytes int
+
+	// Field, when set, sends each batch as {"<Field>": [...]} instead of
+	// a bare array.
+	Field string
+
+	// Concurrency is the number of batches in flight at once. Defaults to
+	// 1, sending batches in order.
+	Concurrency int
+
+	// StopOnError skips the batches not yet sent once one fails.
+	StopOnError bool
+
+	// Retry, when set, replaces the client's RetryPolicy for each batch.
+	// Batches are POSTed, so it needs RetryNonIdempotent or an
+	// IdempotencyKey to take effect.
+	Retry *RetryPolicy
+
+	// IdempotencyKey, when set, returns the Idempotency-Key of a batch.
+	IdempotencyKey func(b *Batch) string
+
+	// Options are applied to every batch request.
+	Options []RequestOption
+}
+
+// BatchOutcome is the result of sending one batch.
+type BatchOutcome struct {
+	Batch    Batch
+	Response json.RawMessage
+	Err      error
+
+	// Skipped reports that the batch was not sent because an earlier one
+	// failed and StopOnError is set.
+	Skipped bool
+}
+
+// BatchResult combines the outcomes of all batches, in batch order.
+type BatchResult struct {
+	Outcomes []BatchOutcome
+}
+
+// Failed returns the outcomes of the batches that failed or were skipped.
+func (r *BatchResult) Failed() []BatchOutcome {
+	var failed []BatchOutcome
+	for _, o := range r.Outcomes {
+		if o.Err != nil {
+			failed = append(failed, o)
+		}
+	}
+	return failed
+}
+
+// BatchError reports a failed batch.
+type BatchError struct {
+	Index  int
+	Offset int
+	Count  int
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d (items %d-%d): %s", e.Index, e.Offset, e.Offset+e.Count-1, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+var errBatchSkipped = errors.New("skipped after an earlier batch failed")
+
+// SplitBatches encodes items as JSON and groups them into batches bounded
+// by maxItems and maxBytes, the size of a batch's JSON array. A bound of
+// zero is not applied. A single item over maxBytes is an error.
+func SplitBatches[T any](items []T, maxItems, maxBytes int) ([]Batch, error) {
+	var batches []Batch
+	var cur Batch
+	size := 2 // the brackets of the array
+	for i, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("batch item %d: %s", i, err)
+		}
+		if maxBytes > 0 && len(b)+2 > maxBytes {
+			return nil, fmt.Errorf("batch item %d is %d bytes, over the %d byte limit", i, len(b), maxBytes)
+		}
+
+		grown := size + len(b)
+		if len(cur.Items) > 0 {
+			grown++ // comma
+		}
+		if len(cur.Items) > 0 && ((maxItems > 0 && len(cur.Items) >= maxItems) || (maxBytes > 0 && grown > maxBytes)) {
+			batches = append(batches, cur)
+			cur = Batch{Index: len(batches), Offset: i}
+			size, grown = 2, 2+len(b)
+		}
+		cur.Items = append(cur.Items, b)
+		size = grown
+	}
+	if len(cur.Items) > 0 {
+		batches = append(batches, cur)
+	}
+	return batches, nil
+}
+
+// PostBatches splits items into batches as configured by opts and POSTs
+// each to uri, decoding every batch response as raw JSON. It returns the
+// outcome of every batch and, if any failed, an error joining their
+// BatchErrors. opts may be nil.
+func PostBatches[T any](ctx context.Context, c *Client, uri string, items []T, opts *BatchOptions) (*BatchResult, error) {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+	maxItems := opts.MaxItems
+	if maxItems == 0 && opts.MaxBytes == 0 {
+		maxItems = DefaultBatchItems
+	}
+	batches, err := SplitBatches(items, maxItems, opts.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return c.postBatches(ctx, uri, batches, opts)
+}
+
+func (c *Client) postBatches(ctx context.Context, uri string, batches []Batch, opts *BatchOptions) (*BatchResult, error) {
+	if opts.Retry != nil {
+		ctx = context.WithValue(ctx, retryPolicyContextKey{}, opts.Retry)
+	}
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	result := &BatchResult{Outcomes: make([]BatchOutcome, len(batches))}
+	var mu sync.Mutex
+	failed := false
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed && opts.StopOnError
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				out := &result.Outcomes[i]
+				out.Batch = batches[i]
+				if stopped() {
+					out.Skipped = true
+					out.Err = errBatchSkipped
+					continue
+				}
+				out.Response, out.Err = c.postBatch(ctx, uri, &batches[i], opts)
+				if out.Err != nil {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range batches {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for _, out := range result.Outcomes {
+		if out.Err != nil {
+			errs = append(errs, &BatchError{Index: out.Batch.Index, Offset: out.Batch.Offset, Count: len(out.Batch.Items), Err: out.Err})
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+func (c *Client) postBatch(ctx context.Context, uri string, b *Batch, opts *BatchOptions) (json.RawMessage, error) {
+	var body interface{} = b.Items
+	if opts.Field != "" {
+		body = map[string]interface{}{opts.Field: b.Items}
+	}
+
+	ropts := opts.Options
+	if opts.IdempotencyKey != nil {
+		ropts = append(ropts[:len(ropts):len(ropts)], WithIdempotencyKey(opts.IdempotencyKey(b)))
+	}
+
+	var res json.RawMessage
+	if err := c.DoContext(ctx, http.MethodPost, uri, body, &res, ropts...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSplitBatches(t *testing.T) {
+	items := []string{"aa", "bb", "cc", "dd", "ee"}
+
+	batches, err := SplitBatches(items, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || len(batches[2].Items) != 1 || batches[2].Offset != 4 || batches[2].Index != 2 {
+		t.Errorf("Unexpected count batches %+v", batches)
+	}
+
+	// Each item is 4 bytes; ["aa","bb"] is 11.
+	batches, err = SplitBatches(items, 0, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || len(batches[0].Items) != 2 {
+		t.Errorf("Unexpected size batches %+v", batches)
+	}
+	for _, b := range batches {
+		if body, _ := json.Marshal(b.Items); len(body) > 11 {
+			t.Errorf("Batch %d is %d bytes", b.Index, len(body))
+		}
+	}
+
+	if _, err := SplitBatches(items, 0, 5); err == nil {
+		t.Errorf("Expected an item over the byte limit to fail")
+	}
+}
+
+func TestPostBatches(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.Write([]byte(`{"accepted": true}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	result, err := PostBatches(context.Background(), c, "/bulk", []int{1, 2, 3}, &BatchOptions{MaxItems: 2, Field: "items"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Outcomes) != 2 || string(result.Outcomes[1].Response) != `{"accepted": true}` {
+		t.Errorf("Unexpected outcomes %+v", result.Outcomes)
+	}
+	if len(bodies) != 2 || bodies[0] != `{"items":[1,2]}` || bodies[1] != `{"items":[3]}` {
+		t.Errorf("Unexpected bodies %q", bodies)
+	}
+}
+
+func TestPostBatchesParallelWithRetry(t *testing.T) {
+	var calls int32
+	var inflight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	opts := &BatchOptions{
+		MaxItems:       1,
+		Concurrency:    3,
+		Retry:          &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Millisecond)},
+		IdempotencyKey: func(b *Batch) string { return fmt.Sprintf("import-%d", b.Index) },
+	}
+	result, err := PostBatches(context.Background(), c, "/bulk", []int{1, 2, 3, 4, 5, 6}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failed()) != 0 || calls != 7 {
+		t.Errorf("Expected the failed batch to be retried, got %d calls and %+v", calls, result.Failed())
+	}
+	if peak < 2 || peak > 3 {
+		t.Errorf("Expected up to 3 batches in flight, peaked at %d", peak)
+	}
+}
+
+func TestPostBatchesStopOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid", http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	result, err := PostBatches(context.Background(), c, "/bulk", []int{1, 2, 3}, &BatchOptions{MaxItems: 1, StopOnError: true})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 0 || StatusCode(err) != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected the first batch to fail with 422, got %v", err)
+	}
+	if !result.Outcomes[1].Skipped || !result.Outcomes[2].Skipped || len(result.Failed()) != 3 {
+		t.Errorf("Expected the remaining batches to be skipped, got %+v", result.Outcomes)
+	}
+}
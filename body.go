@@ -0,0 +1,140 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// ResponseTooLargeError is returned when a response body is longer than
+// Client.MaxResponseBytes.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
+
+// limitBody caps r at MaxResponseBytes.
+func (c *Client) limitBody(r io.Reader) io.Reader {
+	if c.MaxResponseBytes <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, limit: c.MaxResponseBytes, left: c.MaxResponseBytes}
+}
+
+// limitedReader reads up to limit bytes from r and fails with a
+// ResponseTooLargeError if there are more.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	left  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, &ResponseTooLargeError{Limit: l.limit}
+	}
+	// Read one byte past the limit to tell a body of exactly limit bytes
+	// from a longer one.
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.left {
+		n, l.left = int(l.left), -1
+		return n, &ResponseTooLargeError{Limit: l.limit}
+	}
+	l.left -= int64(n)
+	return n, err
+}
+
+// streams reports whether the successful response of a call may be decoded
+// as it is read instead of being read into memory first. That is not
+// possible when something else needs the raw body: the caller through
+// WithResult or LastBody, the cache, replay, error extraction, schema
+// drift detection or a target copying the body.
+func (c *Client) streams(response interface{}, o *callOptions, replay string) bool {
+	switch response.(type) {
+	case teeTarget, writerTarget, fileTarget, *json.RawMessage, *[]byte:
+		return false
+	}
+	return !c.KeepLastBody && o.result == nil && replay == "" && c.Cache == nil &&
+		c.SchemaDrift == nil && c.errorExtractorFor(o) == nil
+}
+
+// decodeStream decodes r into response with the call's decoder. It
+// returns the number of bytes read and whether r was empty.
+func (c *Client) decodeStream(r io.Reader, response interface{}, o *callOptions) (int64, bool, error) {
+	cr := &countingReader{r: r}
+	br := bufio.NewReader(cr)
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return cr.n, true, nil
+		}
+		if err != nil {
+			return cr.n, false, err
+		}
+		if !isSpace(b) {
+			br.UnreadByte()
+			break
+		}
+	}
+
+	if response != nil {
+		max := c.errorPreviewBytes()
+		// Keep enough of the body to sniff its type, whatever the preview
+		// size.
+		keep := max
+		if keep < sniffLen {
+			keep = sniffLen
+		}
+		preview := &prefixWriter{max: keep + utf8.UTFMax}
+		tee := io.TeeReader(br, preview)
+		if err := c.decoderFor(o).Decode(tee, response); err != nil {
+			var tooLarge *ResponseTooLargeError
+			if errors.As(err, &tooLarge) {
+				return cr.n, false, tooLarge
+			}
+			// Read the rest to tell how much the preview leaves out.
+			io.Copy(ioutil.Discard, tee)
+			return cr.n, false, &DecodeError{Err: sniffDecodeError(preview.Bytes(), err), Preview: previewOf(preview.Bytes(), preview.n, max)}
+		}
+	}
+	// Drain the rest so the connection can be reused.
+	_, err := io.Copy(ioutil.Discard, br)
+	return cr.n, false, err
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// prefixWriter keeps the first max bytes written to it and counts all.
+type prefixWriter struct {
+	bytes.Buffer
+	max int
+	n   int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	if room := w.max - w.Len(); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		w.Buffer.Write(p[:room])
+	}
+	return len(p), nil
+}
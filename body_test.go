@@ -0,0 +1,84 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_MaxResponseBytes(t *testing.T) {
+	body := `{"Foo": "` + strings.Repeat("x", 100) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/chunked" {
+			// Flushing first leaves the length unknown.
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.MaxResponseBytes = int64(len(body)) - 1
+
+	for _, uri := range []string{"/api/foo", "/api/chunked"} {
+		var response Response
+		err := c.ReadJson(uri, &response)
+		var tooLarge *ResponseTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != c.MaxResponseBytes {
+			t.Errorf("Expected %s to be too large, got %v", uri, err)
+		}
+
+		var result Result
+		if err := c.ReadJson(uri, &response, WithResult(&result)); !errors.As(err, &tooLarge) {
+			t.Errorf("Expected the buffered %s to be too large, got %v", uri, err)
+		}
+	}
+
+	c.MaxResponseBytes = int64(len(body))
+	var response Response
+	if err := c.ReadJson("/api/chunked", &response); err != nil {
+		t.Errorf("Expected a body of exactly the limit to pass, got %s", err)
+	}
+}
+
+func TestClient_StreamedDecode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/empty":
+			w.Write([]byte("  \n"))
+		default:
+			w.Write([]byte(`{"Foo": "bar"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var response Response
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "bar" {
+		t.Errorf("Expected the streamed body to be decoded, got %q", response.Foo)
+	}
+	if c.LastBody != nil || c.LastResponse == nil {
+		t.Errorf("Expected LastBody to be opt-in, got %q", c.LastBody)
+	}
+	if err := c.ReadJson("/api/empty", &response); err != nil || !c.LastEmpty {
+		t.Errorf("Expected an empty body to be reported, got %v", err)
+	}
+
+	c.KeepLastBody = true
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Fatal(err)
+	}
+	if string(c.LastBody) != `{"Foo": "bar"}` {
+		t.Errorf("Expected LastBody to be kept, got %q", c.LastBody)
+	}
+}
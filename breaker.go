@@ -0,0 +1,239 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the server while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const (
+	// DefaultBreakerThreshold is used when CircuitBreakerConfig.Threshold
+	// is zero.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerOpenTimeout is used when
+	// CircuitBreakerConfig.OpenTimeout is zero.
+	DefaultBreakerOpenTimeout = 30 * time.Second
+)
+
+// CircuitBreakerConfig makes the client fail fast while the server is down.
+// After Threshold consecutive failed attempts the circuit opens and requests
+// return ErrCircuitOpen at once. After OpenTimeout it is half-open: up to
+// HalfOpenRequests probes are let through, and the circuit closes when one
+// succeeds or opens again when one fails.
+//
+// The breaker counts attempts, so retries of a failing request move it
+// towards opening, and ErrCircuitOpen is never retried.
+type CircuitBreakerConfig struct {
+	// Threshold is the number of consecutive failures that opens the
+	// circuit. Defaults to DefaultBreakerThreshold.
+	Threshold int
+
+	// OpenTimeout is how long the circuit stays open before probing.
+	// Defaults to DefaultBreakerOpenTimeout.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of probes allowed at once while
+	// half-open. Defaults to 1.
+	HalfOpenRequests int
+
+	// PerHost keeps a separate circuit per host instead of one for the
+	// client. Circuits are always kept per host when the client has
+	// Failover fallbacks, so a fallback is not refused for the failures
+	// of the endpoint it stands in for.
+	PerHost bool
+
+	// IsFailure, when set, decides which attempts count as failures.
+	// Defaults to network errors, 5xx and 429 responses. Attempts ended by
+	// their own context, or refused by the client's own throttle, never
+	// count.
+	IsFailure func(res *http.Response, err error) bool
+}
+
+// WithCircuitBreaker enables the circuit breaker configured by cfg.
+func WithCircuitBreaker(cfg *CircuitBreakerConfig) Option {
+	return func(c *Client) {
+		c.CircuitBreaker = cfg
+	}
+}
+
+// BreakerState is the state of a circuit.
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+type circuit struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+type breakers struct {
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// breakerPerHost reports whether c keeps a circuit per host.
+func (c *Client) breakerPerHost() bool {
+	return c.CircuitBreaker.PerHost || c.Failover != nil && len(c.Failover.Fallbacks) > 0
+}
+
+// circuitKey returns the circuit r belongs to.
+func (c *Client) circuitKey(r *http.Request) string {
+	if c.breakerPerHost() {
+		return r.URL.Host
+	}
+	return ""
+}
+
+// current returns the circuit for key, moving it from open to half-open
+// once its timeout has passed. b.mu must be held.
+func (b *breakers) current(cfg *CircuitBreakerConfig, key string, now time.Time) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	cb, ok := b.circuits[key]
+	if !ok {
+		cb = &circuit{}
+		b.circuits[key] = cb
+	}
+
+	timeout := cfg.OpenTimeout
+	if timeout <= 0 {
+		timeout = DefaultBreakerOpenTimeout
+	}
+	if cb.state == BreakerOpen && now.Sub(cb.openedAt) >= timeout {
+		cb.state = BreakerHalfOpen
+		cb.probes = 0
+	}
+	return cb
+}
+
+// allow reports whether an attempt may be sent on the circuit for key and
+// whether it is a half-open probe.
+func (b *breakers) allow(cfg *CircuitBreakerConfig, key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cb := b.current(cfg, key, time.Now())
+	switch cb.state {
+	case BreakerOpen:
+		return false, ErrCircuitOpen
+	case BreakerHalfOpen:
+		max := cfg.HalfOpenRequests
+		if max <= 0 {
+			max = 1
+		}
+		if cb.probes >= max {
+			return false, ErrCircuitOpen
+		}
+		cb.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// record updates the circuit for key with the outcome of an attempt.
+// Outcomes that are neither successes nor failures only return a probe.
+func (b *breakers) record(cfg *CircuitBreakerConfig, key string, probe, counted, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	cb := b.current(cfg, key, now)
+	if probe && cb.state == BreakerHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+	if !counted {
+		return
+	}
+
+	if !failed {
+		cb.state = BreakerClosed
+		cb.failures = 0
+		return
+	}
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	switch cb.state {
+	case BreakerHalfOpen:
+		cb.state = BreakerOpen
+		cb.openedAt = now
+	case BreakerClosed:
+		cb.failures++
+		if cb.failures >= threshold {
+			cb.state = BreakerOpen
+			cb.openedAt = now
+		}
+	}
+}
+
+// sendGuarded sends r through the circuit breaker.
+func (c *Client) sendGuarded(r *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	cfg := c.CircuitBreaker
+	key := c.circuitKey(r)
+	probe, err := c.breakers.allow(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := send(r)
+	counted := r.Context().Err() == nil && !isLocalRefusal(err)
+	failed := false
+	if counted {
+		if cfg.IsFailure != nil {
+			failed = cfg.IsFailure(res, err)
+		} else {
+			failed = isFailure(res, err)
+		}
+	}
+	c.breakers.record(cfg, key, probe, counted, failed)
+	return res, err
+}
+
+// BreakerState returns the state of the circuit for host, or of the
+// client's single circuit when CircuitBreakerConfig.PerHost is not set and
+// there are no Failover fallbacks, in which case host is ignored.
+func (c *Client) BreakerState(host string) BreakerState {
+	cfg := c.CircuitBreaker
+	if cfg == nil {
+		return BreakerClosed
+	}
+	if !c.breakerPerHost() {
+		host = ""
+	}
+
+	c.breakers.mu.Lock()
+	defer c.breakers.mu.Unlock()
+
+	if _, ok := c.breakers.circuits[host]; !ok {
+		return BreakerClosed
+	}
+	return c.breakers.current(cfg, host, time.Now()).state
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSwitchServer fails with 503 while *down is non-zero.
+func newSwitchServer(down, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if atomic.LoadInt32(down) != 0 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+}
+
+func TestClient_CircuitBreakerOpens(t *testing.T) {
+	down, calls := int32(1), int32(0)
+	server := newSwitchServer(&down, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.CircuitBreaker = &CircuitBreakerConfig{Threshold: 3, OpenTimeout: time.Hour}
+
+	var response Response
+	for i := 0; i < 3; i++ {
+		if err := c.ReadJson("/api/foo", &response); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected request %d to reach the server, got %v", i, err)
+		}
+	}
+	if s := c.BreakerState(""); s != BreakerOpen {
+		t.Errorf("Expected open circuit, got %s", s)
+	}
+
+	err := c.ReadJson("/api/foo", &response)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected 3 calls, got %d", n)
+	}
+}
+
+func TestClient_CircuitBreakerHalfOpen(t *testing.T) {
+	down, calls := int32(1), int32(0)
+	server := newSwitchServer(&down, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.CircuitBreaker = &CircuitBreakerConfig{Threshold: 1, OpenTimeout: 20 * time.Millisecond}
+
+	var response Response
+	c.ReadJson("/api/foo", &response)
+	time.Sleep(30 * time.Millisecond)
+	if s := c.BreakerState(""); s != BreakerHalfOpen {
+		t.Errorf("Expected half-open circuit, got %s", s)
+	}
+
+	// A failed probe opens the circuit again.
+	if err := c.ReadJson("/api/foo", &response); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("Expected probe to reach the server")
+	}
+	if err := c.ReadJson("/api/foo", &response); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen after failed probe, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	atomic.StoreInt32(&down, 0)
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.BreakerState(""); s != BreakerClosed {
+		t.Errorf("Expected closed circuit after successful probe, got %s", s)
+	}
+}
+
+func TestClient_CircuitBreakerPerHost(t *testing.T) {
+	down, calls := int32(1), int32(0)
+	bad := newSwitchServer(&down, &calls)
+	defer bad.Close()
+	up, upCalls := int32(0), int32(0)
+	good := newSwitchServer(&up, &upCalls)
+	defer good.Close()
+
+	c := newClientOrFatal(t, bad.URL, apiKey)
+	c.CircuitBreaker = &CircuitBreakerConfig{Threshold: 1, OpenTimeout: time.Hour, PerHost: true}
+
+	var response Response
+	c.ReadJson("/api/foo", &response)
+	if err := c.ReadJson("/api/foo", &response); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	// A clone shares the circuits of c.
+	d := c.Clone()
+	d.url, _ = url.Parse(good.URL)
+	if err := d.ReadJson("/api/foo", &response); err != nil {
+		t.Errorf("Expected other host to be unaffected, got %v", err)
+	}
+}
+
+func TestClient_CircuitBreakerWithRetry(t *testing.T) {
+	down, calls := int32(1), int32(0)
+	server := newSwitchServer(&down, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.CircuitBreaker = &CircuitBreakerConfig{Threshold: 2, OpenTimeout: time.Hour}
+	c.Retry = &RetryPolicy{MaxAttempts: 5, Backoff: ConstantBackoff(time.Millisecond)}
+
+	var response Response
+	err := c.ReadJson("/api/foo", &response)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected retries to stop at ErrCircuitOpen, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 calls, got %d", n)
+	}
+}
+
+func TestClient_CircuitBreakerIgnoresThrottle(t *testing.T) {
+	var calls int32
+	server := newTooManyServer(1, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.CircuitBreaker = &CircuitBreakerConfig{Threshold: 1, OpenTimeout: time.Hour}
+	c.Throttle = &ThrottleConfig{DefaultDelay: time.Hour, MaxWait: time.Second}
+
+	for i := 0; i < 3; i++ {
+		if err := c.ReadJson("/api/foo", nil); !errors.Is(err, ErrThrottleWaitExceeded) {
+			t.Fatalf("Expected request %d to be refused by the throttle, got %v", i, err)
+		}
+	}
+	if s := c.BreakerState(""); s != BreakerClosed {
+		t.Errorf("Expected throttle refusals not to open the circuit, got %s", s)
+	}
+}
+
+func TestClient_CircuitBreakerFailover(t *testing.T) {
+	var primaryCalls, fallbackCalls int32
+	primary := newRegionServer("primary", http.StatusServiceUnavailable, &primaryCalls)
+	defer primary.Close()
+	fallback := newRegionServer("fallback", http.StatusOK, &fallbackCalls)
+	defer fallback.Close()
+
+	c, err := NewClient(primary.URL, apiKey, WithFallbackURLs(fallback.URL), WithCircuitBreaker(&CircuitBreakerConfig{Threshold: 1, OpenTimeout: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response Response
+	for i := 0; i < 2; i++ {
+		if err := c.ReadJson("/api/foo", &response); err != nil {
+			t.Fatalf("Expected request %d to be served by the fallback, got %v", i, err)
+		}
+	}
+	primaryHost, _ := url.Parse(primary.URL)
+	if s := c.BreakerState(primaryHost.Host); s != BreakerOpen {
+		t.Errorf("Expected the circuit of the primary to open, got %s", s)
+	}
+	if n := atomic.LoadInt32(&primaryCalls); n != 1 {
+		t.Errorf("Expected the open circuit to skip the primary, got %d calls", n)
+	}
+}
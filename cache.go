@@ -0,0 +1,183 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response stored by a Store.
+type CachedResponse struct {
+	Header     http.Header
+	Body       []byte
+	Validators Validators
+	Stored     time.Time
+}
+
+// Store holds cached responses by absolute URL. Implementations must be
+// safe for concurrent use.
+type Store interface {
+	Get(url string) (*CachedResponse, bool)
+	Set(url string, res *CachedResponse)
+	Delete(url string)
+}
+
+// MemoryStore is an in-memory Store evicting its oldest entry once full.
+type MemoryStore struct {
+	max int
+
+	mu      sync.Mutex
+	entries map[string]*CachedResponse
+	order   []string
+}
+
+// NewMemoryStore returns a MemoryStore holding at most maxEntries responses,
+// or any number if maxEntries is zero.
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{max: maxEntries, entries: make(map[string]*CachedResponse)}
+}
+
+func (s *MemoryStore) Get(url string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.entries[url]
+	return res, ok
+}
+
+func (s *MemoryStore) Set(url string, res *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[url]; !ok {
+		s.order = append(s.order, url)
+	}
+	s.entries[url] = res
+
+	for s.max > 0 && len(s.order) > s.max {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *MemoryStore) Delete(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[url]; !ok {
+		return
+	}
+	delete(s.entries, url)
+	for i, u := range s.order {
+		if u == url {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// cacheLookup makes the GET req conditional on the response cached for it,
+// unless the caller set its own conditionals, and returns that response.
+// It reports whether the response is fresh under CacheHints, and may be
+// served without sending req.
+func (c *Client) cacheLookup(req *http.Request) (*CachedResponse, bool) {
+	if c.Cache == nil || req.Method != http.MethodGet {
+		return nil, false
+	}
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return nil, false
+	}
+	hint := c.cacheHint(req.URL.Path)
+	if hint != nil && hint.NoStore {
+		return nil, false
+	}
+
+	c.applyDefaultQuery(req)
+	key, ok := c.cacheKey(req.URL, req, hint)
+	if !ok {
+		return nil, false
+	}
+	cached, ok := c.Cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if c.fresh(cached, hint) {
+		return cached, true
+	}
+	if cached.Validators.ETag != "" {
+		req.Header.Set("If-None-Match", cached.Validators.ETag)
+	}
+	if cached.Validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.Validators.LastModified)
+	}
+	return cached, false
+}
+
+// cacheStore stores a 200 response to a GET that carries validators and
+// may be stored. With CacheWrites it also stores the responses to writes
+// under the URL written to, and drops the entry of a deleted URL.
+func (c *Client) cacheStore(req *http.Request, res *http.Response, body []byte) {
+	if c.Cache == nil || req.Method != http.MethodGet && !c.CacheWrites {
+		return
+	}
+	target := c.validatorTarget(req, res)
+	if target == "" {
+		return
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return
+	}
+	hint := c.cacheHint(u.Path)
+	if hint != nil && hint.NoStore {
+		return
+	}
+	key, ok := c.cacheKey(u, req, hint)
+	if !ok {
+		return
+	}
+	switch {
+	case req.Method == http.MethodDelete:
+		c.Cache.Delete(key)
+		return
+	case req.Method != http.MethodGet && isEmptyBody(body):
+		return
+	}
+
+	v := validatorsFrom(res.Header)
+	if v.IsZero() && (hint == nil || hint.TTL <= 0) || !cacheable(res.Header, hint) {
+		return
+	}
+	c.Cache.Set(key, &CachedResponse{
+		Header:     res.Header.Clone(),
+		Body:       body,
+		Validators: v,
+		Stored:     c.now(),
+	})
+}
+
+// cacheable reports whether a response with header h may be stored. The
+// cache is keyed by URL and the Vary headers of hint only, so responses
+// varying on anything else but Accept-Encoding are not stored.
+func cacheable(h http.Header, hint *CacheHint) bool {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), "no-store") {
+				return false
+			}
+		}
+	}
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" && !strings.EqualFold(f, "Accept-Encoding") && !hint.varies(f) {
+				return false
+			}
+		}
+	}
+	return true
+}
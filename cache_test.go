@@ -0,0 +1,74 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Cache(t *testing.T) {
+	var full, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte(`{"Foo":"bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Cache = NewMemoryStore(0)
+
+	for i := 0; i < 3; i++ {
+		var data Response
+		if err := c.ReadJson("/api/foo", &data); err != nil {
+			t.Fatal(err)
+		}
+		if data.Foo != "bar" {
+			t.Errorf("Expected data.Foo to be \"bar\", got %q", data.Foo)
+		}
+		if c.LastCached != (i > 0) {
+			t.Errorf("Request %d: unexpected LastCached %v", i, c.LastCached)
+		}
+	}
+	if full != 1 || notModified != 2 {
+		t.Errorf("Expected 1 full and 2 conditional responses, got %d and %d", full, notModified)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.ReadJson("/private", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if full != 3 {
+		t.Errorf("Expected no-store responses not to be cached, got %d full responses", full)
+	}
+}
+
+func TestMemoryStore_Evicts(t *testing.T) {
+	s := NewMemoryStore(2)
+	for _, u := range []string{"a", "b", "c"} {
+		s.Set(u, &CachedResponse{})
+	}
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("Expected the oldest entry to be evicted")
+	}
+	s.Delete("b")
+	if _, ok := s.Get("b"); ok {
+		t.Errorf("Expected b to be deleted")
+	}
+	if _, ok := s.Get("c"); !ok {
+		t.Errorf("Expected c to be kept")
+	}
+}
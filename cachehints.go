@@ -0,0 +1,164 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheHint tunes how Cache handles the responses of a route, for APIs
+// whose endpoints each cache differently.
+type CacheHint struct {
+	// TTL, when positive, serves stored responses for this long after
+	// they were stored without contacting the server, and stores
+	// responses even when they carry no validators. After the TTL the
+	// stored response is revalidated as usual.
+	TTL time.Duration
+
+	// Query, when set, lists the query parameters the response depends
+	// on. Only they are part of the cache key, so tracking or cache-busting
+	// parameters do not split the cache.
+	Query []string
+
+	// Vary lists the request headers the response depends on. Their values
+	// are part of the cache key, and responses that Vary on them are
+	// stored.
+	Vary []string
+
+	// NoStore never caches the route.
+	NoStore bool
+}
+
+// WithCacheHints sets Client.CacheHints, e.g.
+//
+//	relax.WithCacheHints(map[string]*relax.CacheHint{
+//		"/v1/countries": {TTL: 24 * time.Hour},
+//		"/v1/search":    {TTL: time.Minute, Query: []string{"q", "page"}, Vary: []string{"Accept-Language"}},
+//		"/v1/me":        {NoStore: true},
+//	})
+func WithCacheHints(hints map[string]*CacheHint) Option {
+	return func(c *Client) {
+		c.CacheHints = hints
+	}
+}
+
+// cacheHint returns the hint of the longest prefix of path in CacheHints,
+// or nil.
+func (c *Client) cacheHint(path string) *CacheHint {
+	var best string
+	var hint *CacheHint
+	for prefix, h := range c.CacheHints {
+		if strings.HasPrefix(path, prefix) && (hint == nil || len(prefix) > len(best)) {
+			best, hint = prefix, h
+		}
+	}
+	return hint
+}
+
+// cacheKey returns the key of the response to u in Cache, for a request
+// with header h.
+func cacheKey(u *url.URL, h http.Header, hint *CacheHint) string {
+	if hint == nil || hint.Query == nil && len(hint.Vary) == 0 {
+		return u.String()
+	}
+	k := *u
+	if hint.Query != nil {
+		q := u.Query()
+		kept := make(url.Values, len(hint.Query))
+		for _, name := range hint.Query {
+			if v, ok := q[name]; ok {
+				kept[name] = v
+			}
+		}
+		k.RawQuery = kept.Encode()
+	}
+	key := k.String()
+
+	vary := append([]string(nil), hint.Vary...)
+	sort.Strings(vary)
+	for _, name := range vary {
+		key += "\n" + http.CanonicalHeaderKey(name) + ": " + strings.Join(h.Values(name), ", ")
+	}
+	return key
+}
+
+// cacheKey returns the key in Cache of the response to u for req, scoped
+// to the credentials and tenant it is sent with: Cache is shared by Clone
+// and Sub, and clients derived with other credentials must not be served
+// each other's responses. ok is false when the credentials cannot be told
+// apart, and the response must not be cached.
+func (c *Client) cacheKey(u *url.URL, req *http.Request, hint *CacheHint) (key string, ok bool) {
+	scope, ok := c.cacheScope(req)
+	if !ok {
+		return "", false
+	}
+	return cacheKey(u, req.Header, hint) + "\n" + scope, true
+}
+
+// cacheScope identifies whom the response to req is for: the credentials
+// set on req itself, or else CacheScope, the Identity of Auth, or the API
+// key of c, and the tenant of c. Credentials are only kept as a hash.
+func (c *Client) cacheScope(req *http.Request) (string, bool) {
+	var id string
+	switch {
+	case req.Header.Get("Authorization") != "":
+		id = "header " + req.Header.Get("Authorization")
+	case c.CacheScope != "":
+		id = "scope " + c.CacheScope
+	case c.Auth != nil:
+		var ok bool
+		if id, ok = authIdentity(c.Auth); !ok {
+			return "", false
+		}
+	default:
+		id = "key " + c.apiKey
+	}
+	sum := sha256.Sum256([]byte(id))
+	scope := "Scope: " + hex.EncodeToString(sum[:])
+	if c.Tenant != "" {
+		scope += " " + c.Tenant
+	}
+	return scope, true
+}
+
+// authIdentity identifies the credentials of a by its Identity or, for
+// pointers like a TokenAuthenticator, by address. Other authenticators
+// cannot be told apart without applying them, which may refresh tokens or
+// use up nonces, so ok is false.
+func authIdentity(a Authenticator) (id string, ok bool) {
+	if i, ok := a.(Identifier); ok {
+		return fmt.Sprintf("auth %T %s", a, i.Identity()), true
+	}
+	if v := reflect.ValueOf(a); v.Kind() == reflect.Ptr {
+		return fmt.Sprintf("auth %T %x", a, v.Pointer()), true
+	}
+	return "", false
+}
+
+// varies reports whether name is one of the headers of hint.Vary.
+func (hint *CacheHint) varies(name string) bool {
+	if hint == nil {
+		return false
+	}
+	for _, v := range hint.Vary {
+		if strings.EqualFold(v, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// fresh reports whether cached may be served without asking the server.
+func (c *Client) fresh(cached *CachedResponse, hint *CacheHint) bool {
+	return hint != nil && hint.TTL > 0 && c.now().Sub(cached.Stored) < hint.TTL
+}
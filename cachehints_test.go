@@ -0,0 +1,190 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_CacheHints(t *testing.T) {
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		if r.URL.Path == "/v1/search" {
+			w.Header().Set("Vary", "Accept-Language")
+			w.Write([]byte(`{"Foo": "` + r.URL.Query().Get("q") + ` ` + r.Header.Get("Accept-Language") + `"}`))
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	now := time.Unix(1700000000, 0)
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Cache = NewMemoryStore(0)
+	c.Clock = ClockFunc(func() time.Time { return now })
+	c.CacheHints = map[string]*CacheHint{
+		"/v1/":       {TTL: time.Hour},
+		"/v1/search": {TTL: time.Minute, Query: []string{"q"}, Vary: []string{"Accept-Language"}},
+		"/v1/me":     {TTL: time.Hour, NoStore: true},
+	}
+
+	read := func(uri string, opts ...RequestOption) (string, *Result) {
+		t.Helper()
+		var res Result
+		var data Response
+		if err := c.ReadJson(uri, &data, append(opts, WithResult(&res))...); err != nil {
+			t.Fatal(err)
+		}
+		return data.Foo, &res
+	}
+
+	read("/v1/countries")
+	foo, res := read("/v1/countries")
+	if foo != "bar" || !res.Cached || calls["/v1/countries"] != 1 {
+		t.Errorf("Expected a fresh response without validators to be served from the cache, got %q after %d calls", foo, calls["/v1/countries"])
+	}
+	now = now.Add(2 * time.Hour)
+	if read("/v1/countries"); calls["/v1/countries"] != 2 {
+		t.Errorf("Expected a stale response to be fetched again, got %d calls", calls["/v1/countries"])
+	}
+
+	read("/v1/search?q=go&utm_source=x")
+	foo, _ = read("/v1/search?q=go&utm_source=y")
+	if foo != "go " || calls["/v1/search"] != 1 {
+		t.Errorf("Expected parameters outside the key to share the entry, got %q after %d calls", foo, calls["/v1/search"])
+	}
+	foo, _ = read("/v1/search?q=go", WithHeader("Accept-Language", "fr"))
+	if foo != "go fr" || calls["/v1/search"] != 2 {
+		t.Errorf("Expected the Vary header to split the entry, got %q after %d calls", foo, calls["/v1/search"])
+	}
+	now = now.Add(2 * time.Minute)
+	if read("/v1/search?q=go"); calls["/v1/search"] != 3 {
+		t.Errorf("Expected the route's own TTL, got %d calls", calls["/v1/search"])
+	}
+
+	read("/v1/me")
+	if read("/v1/me"); calls["/v1/me"] != 2 {
+		t.Errorf("Expected NoStore routes not to be cached, got %d calls", calls["/v1/me"])
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	u, _ := url.Parse("https://api.example.com/v1/search?page=2&q=go&utm=x")
+	h := http.Header{"Accept-Language": {"fr"}}
+
+	if got := cacheKey(u, h, nil); got != u.String() {
+		t.Errorf("Expected the URL without a hint, got %q", got)
+	}
+	hint := &CacheHint{Query: []string{"q", "page"}, Vary: []string{"accept-language"}}
+	if got, want := cacheKey(u, h, hint), "https://api.example.com/v1/search?page=2&q=go\nAccept-Language: fr"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := cacheKey(u, h, &CacheHint{Query: []string{}}); got != "https://api.example.com/v1/search" {
+		t.Errorf("Expected an empty Query to drop the query, got %q", got)
+	}
+}
+
+func TestClient_CacheScopedToCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Response{Foo: r.Header.Get("Authorization") + r.Header.Get("X-Tenant")})
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Cache = NewMemoryStore(0)
+	c.CacheHints = map[string]*CacheHint{"/": {TTL: time.Hour}}
+
+	read := func(d *Client) (string, bool) {
+		t.Helper()
+		var res Result
+		var data Response
+		if err := d.ReadJson("/v1/me", &data, WithResult(&res)); err != nil {
+			t.Fatal(err)
+		}
+		return data.Foo, res.Cached
+	}
+
+	read(c)
+	if _, cached := read(c.Clone()); !cached {
+		t.Errorf("Expected a clone with the same credentials to share the entry")
+	}
+
+	derived := []*Client{
+		c.Clone(func(d *Client) { d.apiKey = "other-key" }),
+		c.Clone(WithAuthenticator(BearerAuth("alice"))),
+		c.Clone(WithAuthenticator(BearerAuth("bob"))),
+		c.Clone(func(d *Client) { d.Tenant = "acme"; d.DefaultHeader = http.Header{"X-Tenant": {"acme"}} }),
+	}
+	seen := map[string]bool{}
+	for i, d := range derived {
+		foo, cached := read(d)
+		if cached || seen[foo] {
+			t.Errorf("Expected client %d to get its own response, got %q (cached %v)", i, foo, cached)
+		}
+		seen[foo] = true
+		if _, cached := read(d); !cached {
+			t.Errorf("Expected client %d to be served its own entry", i)
+		}
+	}
+
+	var res Result
+	if err := c.ReadJson("/v1/me", nil, WithHeader("Authorization", "Bearer carol"), WithResult(&res)); err != nil {
+		t.Fatal(err)
+	}
+	if res.Cached {
+		t.Errorf("Expected the request's own credentials to split the entry")
+	}
+}
+
+func TestClient_CacheNeverAppliesAuth(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	var applied int
+	signing := AuthenticatorFunc(func(r *http.Request) error {
+		applied++
+		r.Header.Set("X-Signature", strconv.Itoa(applied))
+		return nil
+	})
+	c, err := NewClient(server.URL, "", WithAuthenticator(signing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Cache = NewMemoryStore(0)
+	c.CacheHints = map[string]*CacheHint{"/": {TTL: time.Hour}}
+
+	for i := 0; i < 2; i++ {
+		if err := c.ReadJson("/v1/me", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); applied != 2 || n != 2 {
+		t.Errorf("Expected an unidentified authenticator to be applied once per request and not cached, got %d applications and %d requests", applied, n)
+	}
+
+	applied = 0
+	atomic.StoreInt32(&calls, 0)
+	c.CacheScope = "alice"
+	for i := 0; i < 2; i++ {
+		if err := c.ReadJson("/v1/me", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); applied != 1 || n != 1 {
+		t.Errorf("Expected CacheScope to let the response be cached, got %d applications and %d requests", applied, n)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var (
+	// ErrCanceled is matched by every CancelError, for telling requests
+	// the client gave up on apart from requests the server failed.
+	ErrCanceled = errors.New("request canceled")
+
+	// ErrClientClosed is the Err of the CancelError of requests made on or
+	// interrupted by Close.
+	ErrClientClosed = errors.New("client is closed")
+)
+
+// CancelReason identifies why a request was given up on.
+type CancelReason string
+
+const (
+	// CancelContext is a request whose context was canceled.
+	CancelContext CancelReason = "context"
+
+	// CancelDeadline is a request whose context deadline passed.
+	CancelDeadline CancelReason = "deadline"
+
+	// CancelShutdown is a request made on, or interrupted by, Close.
+	CancelShutdown CancelReason = "shutdown"
+
+	// CancelCircuitBreaker is a request refused by the open circuit
+	// breaker.
+	CancelCircuitBreaker CancelReason = "circuit breaker"
+
+	// CancelRateLimit is a request refused by the throttle.
+	CancelRateLimit CancelReason = "rate limit"
+)
+
+// CancelError reports a request the client gave up on instead of one the
+// server failed, with the subsystem that did so. It matches ErrCanceled
+// and unwraps to the error of that subsystem, e.g. ErrCircuitOpen or
+// context.DeadlineExceeded:
+//
+//	var ce *relax.CancelError
+//	if errors.As(err, &ce) {
+//		metrics.Inc("gave_up", string(ce.Reason))
+//	}
+type CancelError struct {
+	Reason CancelReason
+	Err    error
+}
+
+func (e *CancelError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", ErrCanceled, e.Reason, e.Err)
+}
+
+// Unwrap returns the error of the subsystem that gave up.
+func (e *CancelError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrCanceled.
+func (e *CancelError) Is(target error) bool {
+	return target == ErrCanceled
+}
+
+// CancelReasonOf returns the reason of the CancelError in err's chain, or
+// "" if the request was not given up on.
+func CancelReasonOf(err error) CancelReason {
+	var ce *CancelError
+	if errors.As(err, &ce) {
+		return ce.Reason
+	}
+	return ""
+}
+
+// shutdown is canceled by Close. A client is also closed by the Close of
+// the client it was derived from, which is checked as each request is made
+// rather than by linking their contexts, so a derived client that is never
+// closed is not retained by its parent.
+type shutdown struct {
+	once   sync.Once
+	parent *shutdown
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+func (s *shutdown) context() context.Context {
+	s.once.Do(func() {
+		s.ctx, s.cancel = context.WithCancelCause(context.Background())
+	})
+	return s.ctx
+}
+
+// closed reports whether s, or one it was derived from, was closed.
+func (s *shutdown) closed() bool {
+	for ; s != nil; s = s.parent {
+		if s.context().Err() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Close shuts the client down: requests in flight are canceled, and later
+// requests fail at once, both with a CancelError for CancelShutdown. The
+// clients derived from c with Clone or Sub are closed with it, but closing
+// a derived client leaves c and its other clones open, so a client made
+// per request can be closed when done. Close is safe to call more than
+// once.
+func (c *Client) Close() error {
+	c.shutdown.context()
+	c.shutdown.cancel(ErrClientClosed)
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// bindShutdown returns r with a context canceled by Close, and the func
+// releasing it once r is done.
+func (c *Client) bindShutdown(r *http.Request) (*http.Request, func(), error) {
+	if c.shutdown.closed() {
+		return nil, nil, &CancelError{Reason: CancelShutdown, Err: ErrClientClosed}
+	}
+	ctx, cancel := context.WithCancelCause(r.Context())
+	var stops []func() bool
+	for s := c.shutdown; s != nil; s = s.parent {
+		stops = append(stops, context.AfterFunc(s.context(), func() { cancel(ErrClientClosed) }))
+	}
+	return r.WithContext(ctx), func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel(context.Canceled)
+	}, nil
+}
+
+// cancelError returns err as a CancelError if r was given up on, with
+// caller the context r was made with.
+func cancelError(caller context.Context, r *http.Request, err error) error {
+	var ce *CancelError
+	if errors.As(err, &ce) {
+		return err
+	}
+	switch {
+	case caller.Err() == context.DeadlineExceeded:
+		return &CancelError{Reason: CancelDeadline, Err: err}
+	case caller.Err() != nil:
+		return &CancelError{Reason: CancelContext, Err: err}
+	case context.Cause(r.Context()) == ErrClientClosed:
+		return &CancelError{Reason: CancelShutdown, Err: ErrClientClosed}
+	case errors.Is(err, ErrCircuitOpen):
+		return &CancelError{Reason: CancelCircuitBreaker, Err: err}
+	case errors.Is(err, ErrThrottleQueueFull), errors.Is(err, ErrThrottleWaitExceeded):
+		return &CancelError{Reason: CancelRateLimit, Err: err}
+	}
+	return err
+}
+
+// releasingBody releases the request of a response once it is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
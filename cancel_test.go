@@ -0,0 +1,152 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_CancelReasons(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/slow":
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		case "/api/down":
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.ReadJsonContext(ctx, "/api/slow", nil)
+	if CancelReasonOf(err) != CancelDeadline || !errors.Is(err, ErrCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline CancelError, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := c.ReadJsonContext(ctx, "/api/slow", nil); CancelReasonOf(err) != CancelContext {
+		t.Errorf("Expected a context CancelError, got %v", err)
+	}
+
+	c.CircuitBreaker = &CircuitBreakerConfig{Threshold: 1, OpenTimeout: time.Hour}
+	err = c.ReadJson("/api/down", nil)
+	if errors.Is(err, ErrCanceled) || CancelReasonOf(err) != "" {
+		t.Errorf("Expected a server failure not to be a CancelError, got %v", err)
+	}
+	err = c.ReadJson("/api/down", nil)
+	if CancelReasonOf(err) != CancelCircuitBreaker || !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected a circuit breaker CancelError, got %v", err)
+	}
+}
+
+func TestClient_CancelThrottle(t *testing.T) {
+	var calls int32
+	server := newTooManyServer(1, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Throttle = &ThrottleConfig{DefaultDelay: time.Hour, MaxWait: time.Second}
+
+	err := c.ReadJson("/api/foo", nil)
+	if CancelReasonOf(err) != CancelRateLimit || !errors.Is(err, ErrThrottleWaitExceeded) {
+		t.Errorf("Expected a rate limit CancelError, got %v", err)
+	}
+}
+
+func TestClient_Close(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/slow" {
+			close(started)
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	sub := c.Clone()
+	grandchild := sub.Sub("v1")
+
+	var response Response
+	if err := sub.ReadJson("/api/foo", &response); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- grandchild.ReadJson("/api/slow", nil)
+	}()
+	<-started
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if CancelReasonOf(err) != CancelShutdown || !errors.Is(err, ErrClientClosed) {
+			t.Errorf("Expected a shutdown CancelError, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to cancel the request in flight on a derived client")
+	}
+
+	if sub.shutdown.context().Err() != nil {
+		t.Errorf("Expected the clone not to be linked to the context of its parent")
+	}
+	for _, d := range []*Client{c, sub, c.Clone()} {
+		err := d.ReadJson("/api/foo", &response)
+		if CancelReasonOf(err) != CancelShutdown {
+			t.Errorf("Expected requests after Close to fail, got %v", err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Expected a second Close to succeed, got %v", err)
+	}
+}
+
+func TestClient_CloseDerived(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	sub := c.Sub("x")
+	sibling := c.Clone()
+	grandchild := sub.Clone()
+
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	for name, d := range map[string]*Client{"parent": c, "sibling": sibling} {
+		if err := d.ReadJson("/api/foo", &response); err != nil {
+			t.Errorf("Expected the %s to stay open, got %v", name, err)
+		}
+	}
+	for name, d := range map[string]*Client{"closed clone": sub, "its clone": grandchild} {
+		if err := d.ReadJson("/api/foo", &response); CancelReasonOf(err) != CancelShutdown {
+			t.Errorf("Expected the %s to be closed, got %v", name, err)
+		}
+	}
+}
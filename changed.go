@@ -0,0 +1,71 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import "context"
+
+// ConditionalGet is one resource fetched by GetChanged.
+type ConditionalGet struct {
+	URI string
+
+	// ETag, when set, is sent as If-None-Match. Otherwise the validators
+	// stored in the client's Cache are used, if any.
+	ETag string
+
+	// Response receives the decoded body when the resource changed.
+	Response interface{}
+
+	Options []RequestOption
+}
+
+// ChangedOutcome is the outcome of one resource of GetChanged.
+type ChangedOutcome struct {
+	URI string
+
+	// Changed reports that the resource was fetched and decoded; it is
+	// false when the server answered 304 Not Modified or the call failed.
+	Changed bool
+
+	// ETag is the current entity tag of the resource, to send on the next
+	// sync, or the one sent when the server reports none.
+	ETag string
+
+	Result Result
+	Err    error
+}
+
+// GetChanged fetches many resources concurrently with conditional GETs,
+// for periodic syncs where most resources have not changed. A resource the
+// server answers with 304 Not Modified is neither transferred nor decoded,
+// even when its body is in Cache; the outcomes tell which ones changed.
+// If any failed, the error joins their CallErrors. opts may be nil.
+func (c *Client) GetChanged(ctx context.Context, gets []ConditionalGet, opts *ParallelOptions) ([]ChangedOutcome, error) {
+	calls := make([]Call, len(gets))
+	for i, g := range gets {
+		options := append(g.Options[:len(g.Options):len(g.Options)], ifChanged)
+		if g.ETag != "" {
+			options = append(options, WithHeader("If-None-Match", g.ETag))
+		}
+		calls[i] = Call{URI: g.URI, Response: g.Response, Options: options}
+	}
+
+	outcomes, err := c.DoAll(ctx, calls, opts)
+	changed := make([]ChangedOutcome, len(gets))
+	for i, out := range outcomes {
+		ch := &changed[i]
+		ch.URI, ch.Result, ch.Err = gets[i].URI, out.Result, out.Err
+		ch.Changed = out.Err == nil && !out.Result.NotModified
+		ch.ETag = gets[i].ETag
+		if etag := out.Result.Header.Get("ETag"); etag != "" {
+			ch.ETag = etag
+		}
+	}
+	return changed, err
+}
+
+// ifChanged skips decoding the body of 304 responses.
+func ifChanged(o *callOptions) {
+	o.ifChanged = true
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_GetChanged(t *testing.T) {
+	etags := map[string]string{"/items/1": `"v1"`, "/items/2": `"v2"`, "/items/3": `"v3"`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag, ok := etags[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"Foo": "` + strings.TrimPrefix(r.URL.Path, "/items/") + `"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	responses := make([]Response, 4)
+	gets := []ConditionalGet{
+		{URI: "/items/1", ETag: `"v1"`, Response: &responses[0]},
+		{URI: "/items/2", ETag: `"old"`, Response: &responses[1]},
+		{URI: "/items/3", Response: &responses[2]},
+		{URI: "/items/4", Response: &responses[3]},
+	}
+	out, err := c.GetChanged(context.Background(), gets, nil)
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Index != 3 {
+		t.Errorf("Expected the missing item to fail, got %v", err)
+	}
+	if out[0].Changed || !out[0].Result.NotModified || responses[0].Foo != "" {
+		t.Errorf("Expected item 1 to be unchanged and not decoded, got %+v", out[0])
+	}
+	if !out[1].Changed || out[1].ETag != `"v2"` || responses[1].Foo != "2" {
+		t.Errorf("Expected item 2 to change to v2, got %+v, %+v", out[1], responses[1])
+	}
+	if !out[2].Changed || responses[2].Foo != "3" {
+		t.Errorf("Expected item 3 without an ETag to be fetched, got %+v", out[2])
+	}
+	if out[3].Changed || out[3].Err == nil {
+		t.Errorf("Expected item 4 to fail, got %+v", out[3])
+	}
+}
+
+func TestClient_GetChangedFromCache(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"1"`)
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Cache = NewMemoryStore(0)
+
+	var first, second Response
+	out, err := c.GetChanged(context.Background(), []ConditionalGet{{URI: "/a", Response: &first}}, nil)
+	if err != nil || !out[0].Changed || first.Foo != "bar" {
+		t.Fatalf("Expected the first sync to fetch the item, got %+v, %v", out[0], err)
+	}
+	out, err = c.GetChanged(context.Background(), []ConditionalGet{{URI: "/a", Response: &second}}, nil)
+	if err != nil || out[0].Changed || second.Foo != "" || out[0].ETag != `"1"` {
+		t.Errorf("Expected the cached validators to short-circuit the second sync, got %+v, %v", out[0], err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 requests, got %d", calls)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check is a declarative probe of an endpoint, for smoke tests run against
+// dependencies after a deployment:
+//
+//	res := c.Check("/health").ExpectStatus(200).ExpectJSONPath("$.status", "ok").Run(ctx)
+//	if !res.OK() {
+//		log.Print(res)
+//	}
+//
+// The request goes through the client like any other, with its
+// authentication, retries and policies. Build a Check with Client.Check.
+type Check struct {
+	c      *Client
+	name   string
+	method string
+	uri    string
+	opts   []RequestOption
+	expect []expectation
+}
+
+type expectation struct {
+	name string
+	fn   func(res *http.Response, body []byte, elapsed time.Duration) error
+}
+
+// Check returns a Check sending GET requests to uri with opts.
+func (c *Client) Check(uri string, opts ...RequestOption) *Check {
+	return &Check{c: c, name: uri, method: http.MethodGet, uri: uri, opts: opts}
+}
+
+// Named names the check in its result. Defaults to the URI.
+func (ch *Check) Named(name string) *Check {
+	ch.name = name
+	return ch
+}
+
+// Method sets the request method. Defaults to GET.
+func (ch *Check) Method(method string) *Check {
+	ch.method = method
+	return ch
+}
+
+// Expect adds an expectation called name, met when fn returns nil.
+func (ch *Check) Expect(name string, fn func(res *http.Response, body []byte) error) *Check {
+	ch.expect = append(ch.expect, expectation{name: name, fn: func(res *http.Response, body []byte, _ time.Duration) error {
+		return fn(res, body)
+	}})
+	return ch
+}
+
+// ExpectStatus expects the response status to be one of codes.
+func (ch *Check) ExpectStatus(codes ...int) *Check {
+	name := "status"
+	for i, code := range codes {
+		sep := " "
+		if i > 0 {
+			sep = " or "
+		}
+		name += sep + strconv.Itoa(code)
+	}
+	return ch.Expect(name, func(res *http.Response, _ []byte) error {
+		for _, code := range codes {
+			if res.StatusCode == code {
+				return nil
+			}
+		}
+		return fmt.Errorf("got status %d", res.StatusCode)
+	})
+}
+
+// ExpectHeader expects the response to carry the header value.
+func (ch *Check) ExpectHeader(key, value string) *Check {
+	return ch.Expect(fmt.Sprintf("header %s: %s", key, value), func(res *http.Response, _ []byte) error {
+		for _, v := range res.Header.Values(key) {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("got %q", res.Header.Values(key))
+	})
+}
+
+// ExpectBodyContains expects the response body to contain s.
+func (ch *Check) ExpectBodyContains(s string) *Check {
+	return ch.Expect(fmt.Sprintf("body contains %q", s), func(_ *http.Response, body []byte) error {
+		if bytes.Contains(body, []byte(s)) {
+			return nil
+		}
+		return fmt.Errorf("not found in %s", bodyPreview(body, DefaultErrorPreviewBytes))
+	})
+}
+
+// ExpectJSONPath expects the value at path in the JSON response body to
+// equal want once both are encoded as JSON. Paths use a subset of
+// JSONPath: the root $ followed by .name, ['name'] and [index] steps, like
+// $.checks[0].status.
+func (ch *Check) ExpectJSONPath(path string, want interface{}) *Check {
+	return ch.Expect(fmt.Sprintf("%s == %s", path, jsonString(want)), func(_ *http.Response, body []byte) error {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fmt.Errorf("body is not JSON: %s", err)
+		}
+		got, err := lookupJSONPath(doc, path)
+		if err != nil {
+			return err
+		}
+		var expected interface{}
+		b, err := json.Marshal(want)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(b, &expected)
+		if !reflect.DeepEqual(got, expected) {
+			return fmt.Errorf("got %s", jsonString(got))
+		}
+		return nil
+	})
+}
+
+// ExpectLatency expects the response within max of sending the request.
+func (ch *Check) ExpectLatency(max time.Duration) *Check {
+	ch.expect = append(ch.expect, expectation{name: "latency under " + max.String(), fn: func(_ *http.Response, _ []byte, elapsed time.Duration) error {
+		if elapsed < max {
+			return nil
+		}
+		return fmt.Errorf("took %s", elapsed)
+	}})
+	return ch
+}
+
+// CheckResult is the outcome of running a Check.
+type CheckResult struct {
+	Name   string
+	Method string
+	URL    string
+
+	// StatusCode is zero when no response was received.
+	StatusCode int
+	Duration   time.Duration
+
+	// Err is the error that kept the request from completing, in which
+	// case no expectation was evaluated.
+	Err error
+
+	Expectations []ExpectationResult
+}
+
+// ExpectationResult is the outcome of one expectation of a Check.
+type ExpectationResult struct {
+	Name string
+
+	// Err tells how the expectation was not met; nil when it was.
+	Err error
+}
+
+// OK reports whether the request completed and met every expectation.
+func (r *CheckResult) OK() bool {
+	return r.Error() == nil
+}
+
+// Error returns nil if the check passed, or an error listing what failed.
+func (r *CheckResult) Error() error {
+	if r.Err != nil {
+		return fmt.Errorf("check %s: %w", r.Name, r.Err)
+	}
+	var failed []string
+	for _, e := range r.Expectations {
+		if e.Err != nil {
+			failed = append(failed, e.Name+": "+e.Err.Error())
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("check %s: %s", r.Name, strings.Join(failed, "; "))
+}
+
+// String summarizes r with one line per expectation.
+func (r *CheckResult) String() string {
+	var b strings.Builder
+	status := "PASS"
+	if !r.OK() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&b, "%s %s: %s %s", status, r.Name, r.Method, r.URL)
+	if r.StatusCode != 0 {
+		fmt.Fprintf(&b, " %d", r.StatusCode)
+	}
+	fmt.Fprintf(&b, " in %s\n", r.Duration.Round(time.Millisecond))
+	if r.Err != nil {
+		fmt.Fprintf(&b, "  error: %s\n", r.Err)
+	}
+	for _, e := range r.Expectations {
+		if e.Err != nil {
+			fmt.Fprintf(&b, "  fail: %s: %s\n", e.Name, e.Err)
+		} else {
+			fmt.Fprintf(&b, "  ok:   %s\n", e.Name)
+		}
+	}
+	return b.String()
+}
+
+// Run sends the request and evaluates the expectations against the
+// response. Failed requests and unmet expectations are reported in the
+// result, never by panicking or failing a test.
+func (ch *Check) Run(ctx context.Context) *CheckResult {
+	result := &CheckResult{Name: ch.name, Method: ch.method}
+	req, err := ch.c.MakeRequestContext(ctx, ch.method, ch.uri)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if err := newCallOptions(ch.opts).apply(req, ch.c); err != nil {
+		result.Err = err
+		return result
+	}
+	result.URL = redactURL(req.URL, nil)
+
+	start := time.Now()
+	res, err := ch.c.GetResponse(req)
+	if err != nil {
+		result.Duration = time.Since(start)
+		result.Err = err
+		return result
+	}
+	body, err := ioutil.ReadAll(ch.c.limitBody(res.Body))
+	res.Body.Close()
+	result.Duration = time.Since(start)
+	result.StatusCode = res.StatusCode
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for _, e := range ch.expect {
+		result.Expectations = append(result.Expectations, ExpectationResult{Name: e.name, Err: e.fn(res, body, result.Duration)})
+	}
+	return result
+}
+
+// RunChecks runs checks concurrently and returns their results in the
+// same order, with an error joining the failures, if any.
+func RunChecks(ctx context.Context, checks ...*Check) ([]*CheckResult, error) {
+	results := make([]*CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func(i int, ch *Check) {
+			defer wg.Done()
+			results[i] = ch.Run(ctx)
+		}(i, ch)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if err := r.Error(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// lookupJSONPath returns the value at path in doc.
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path %q does not start at $", path)
+	}
+	v := doc
+	rest := path[1:]
+	for rest != "" {
+		var key string
+		index := -1
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key, rest = rest[1:end+1], rest[end+1:]
+			if key == "" {
+				return nil, fmt.Errorf("path %q has an empty name", path)
+			}
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed [", path)
+			}
+			step := rest[1:end]
+			rest = rest[end+1:]
+			if len(step) >= 2 && (step[0] == '\'' || step[0] == '"') && step[len(step)-1] == step[0] {
+				key = step[1 : len(step)-1]
+				break
+			}
+			n, err := strconv.Atoi(step)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("path %q has an invalid index [%s]", path, step)
+			}
+			index = n
+		default:
+			return nil, fmt.Errorf("path %q is invalid at %q", path, rest)
+		}
+
+		if index >= 0 {
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: parent is not an array", strings.TrimSuffix(path, rest))
+			}
+			if index >= len(list) {
+				return nil, fmt.Errorf("%s is out of range", strings.TrimSuffix(path, rest))
+			}
+			v = list[index]
+			continue
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: parent is not an object", strings.TrimSuffix(path, rest))
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, fmt.Errorf("%s is missing", strings.TrimSuffix(path, rest))
+		}
+	}
+	return v, nil
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Check(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status": "degraded", "checks": [{"name": "db", "ok": false}]}`))
+			return
+		}
+		w.Write([]byte(`{"status": "ok", "version": 3, "checks": [{"name": "db", "ok": true}]}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	res := c.Check("/health").
+		ExpectStatus(200).
+		ExpectHeader("Content-Type", "application/json").
+		ExpectJSONPath("$.status", "ok").
+		ExpectJSONPath("$.version", 3).
+		ExpectJSONPath("$.checks[0]['ok']", true).
+		ExpectBodyContains(`"db"`).
+		Run(context.Background())
+	if !res.OK() {
+		t.Errorf("Expected the check to pass, got %s", res)
+	}
+	if res.StatusCode != 200 || len(res.Expectations) != 6 {
+		t.Errorf("Expected 6 expectations on a 200, got %d on %d", len(res.Expectations), res.StatusCode)
+	}
+
+	res = c.Check("/down").Named("api").
+		ExpectStatus(200, 204).
+		ExpectJSONPath("$.status", "ok").
+		ExpectJSONPath("$.checks[3].ok", true).
+		ExpectJSONPath("$.missing", 1).
+		Run(context.Background())
+	if res.OK() {
+		t.Fatal("Expected the check to fail")
+	}
+	for i, want := range []string{"got status 503", `got "degraded"`, "$.checks[3] is out of range", "$.missing is missing"} {
+		if err := res.Expectations[i].Err; err == nil || err.Error() != want {
+			t.Errorf("Expected expectation %d to fail with %q, got %v", i, want, err)
+		}
+	}
+	if s := res.String(); !strings.HasPrefix(s, "FAIL api: GET ") || !strings.Contains(s, "fail: status 200 or 204: got status 503") {
+		t.Errorf("Unexpected summary %q", s)
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	results, err := RunChecks(context.Background(),
+		c.Check("/a").ExpectStatus(200),
+		c.Check("/b").ExpectStatus(201),
+	)
+	if len(results) != 2 || !results[0].OK() || results[1].OK() {
+		t.Fatalf("Expected only the second check to fail, got %v", results)
+	}
+	if err == nil || !strings.Contains(err.Error(), "check /b: status 201: got status 200") {
+		t.Errorf("Expected an error naming the failed check, got %v", err)
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	doc := map[string]interface{}{"a": []interface{}{map[string]interface{}{"b.c": "x"}}}
+	if v, err := lookupJSONPath(doc, "$.a[0]['b.c']"); err != nil || v != "x" {
+		t.Errorf("Expected x, got %v, %v", v, err)
+	}
+	for _, path := range []string{"a", "$.", "$.a[x]", "$.a[0", "$.a.b"} {
+		if _, err := lookupJSONPath(doc, path); err == nil {
+			t.Errorf("Expected %q to fail", path)
+		}
+	}
+}
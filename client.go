@@ -6,32 +6,341 @@ package relax
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
-	"reflect"
+	"sync"
+	"time"
 )
 
+// Client is a client for a JSON API. It is safe for concurrent use once
+// configured; per-call details are reported through WithResult.
 type Client struct {
-	url          *url.URL
-	apiKey       string
-	client       *http.Client
+	url    *url.URL
+	apiKey string
+	client *http.Client
+
+	// LastResponse and LastBody are the final response and body of the
+	// last call. LastBody is only kept when KeepLastBody is set.
+	//
+	// Deprecated: The Last fields are shared by every call on the Client,
+	// so they are only meaningful when calls are not concurrent. Use
+	// WithResult instead.
 	LastResponse *http.Response
 	LastBody     []byte
+
+	// LastKey is the API key used by the last request.
+	//
+	// Deprecated: Use Result.Key.
+	LastKey KeySlot
+
+	// Auth, when set, authenticates every request instead of the API key.
+	Auth Authenticator
+
+	// KeyAuth sends the API keys. Defaults to TokenAuth.
+	KeyAuth func(key string) Authenticator
+
+	// Environment names the Profile the client was created from, if any.
+	Environment string
+
+	// SecondaryAPIKey, when set, is tried whenever the other key is
+	// rejected with 401 or 403, for zero-downtime key rotation. Once it
+	// succeeds it is used first until it is rejected in turn.
+	SecondaryAPIKey string
+
+	// LastLocation is the Location of the last 201, 302 or 303 response
+	// when Location is not LocationIgnore.
+	//
+	// Deprecated: Use Result.Location.
+	LastLocation *url.URL
+
+	// LastEmpty reports whether the last response was a 204 or had an
+	// empty body, in which case nothing was decoded.
+	//
+	// Deprecated: Use Result.Empty.
+	LastEmpty bool
+
+	// LastCached reports whether the last response was served from Cache
+	// after the server answered 304 Not Modified.
+	//
+	// Deprecated: Use Result.Cached.
+	LastCached bool
+
+	// TraceTiming collects a timing breakdown of every call into its
+	// Result. Use WithTiming to time a single call.
+	TraceTiming bool
+
+	// LastTiming is the timing breakdown of the last call, when collected.
+	//
+	// Deprecated: Use Result.Timing.
+	LastTiming *Timing
+
+	lastMu sync.Mutex // guards the Last fields
+
+	// KeepLastBody keeps the body of the last call in LastBody. Without
+	// it, and when nothing else needs the raw body, successful responses
+	// are decoded as they are read rather than held in memory.
+	KeepLastBody bool
+
+	// MaxResponseBytes, when positive, fails calls whose response body is
+	// longer with a ResponseTooLargeError.
+	MaxResponseBytes int64
+
+	// Cache, when set, stores GET responses carrying an ETag or
+	// Last-Modified and revalidates them with conditional requests,
+	// returning the stored body on 304 Not Modified. Entries are scoped
+	// to the credentials and Tenant of the client, so the clients derived
+	// from it share Cache safely. Responses are not cached for an Auth
+	// that is neither an Identifier nor a pointer unless CacheScope is
+	// set.
+	Cache Store
+
+	// CacheScope, when set, names whom the client's requests are for in
+	// place of its credentials, keeping its Cache entries apart from those
+	// of clients with another scope.
+	CacheScope string
+
+	// RequestID, when set, attaches a correlation ID to every request.
+	RequestID *RequestIDConfig
+
+	// IDs, when set, generates idempotency keys and, unless
+	// RequestID.Generate is set, request IDs. Defaults to random UUIDs
+	// for keys and random hex for request IDs.
+	IDs IDGenerator
+
+	// Clock, when set, tells the time recorded in cached and archived
+	// responses. Durations are always measured with the system clock.
+	Clock Clock
+
+	// PathJoin selects how request URIs are joined to the base URL.
+	PathJoin JoinMode
+
+	// Normalize, when set, rewrites request URLs before they are sent.
+	Normalize *URLNormalization
+
+	// Location selects how responses carrying a Location header are handled.
+	Location LocationMode
+
+	// Hosts overrides DNS resolution per host name, like /etc/hosts. Values
+	// are an IP or host, optionally with a port. Set it before issuing
+	// requests.
+	Hosts map[string]string
+
+	// Compression, when set, compresses request bodies per route.
+	Compression *CompressionConfig
+
+	// HealthTracking, when set, keeps a smoothed health score per route.
+	HealthTracking *HealthConfig
+
+	// Decoder decodes response bodies. Defaults to Codec.
+	Decoder Decoder
+
+	// Codec encodes request bodies and, unless Decoder is set, decodes
+	// response bodies. Defaults to JSONCodec.
+	Codec Codec
+
+	// ErrorCodes maps the error codes of failed responses to errors that
+	// the returned APIError or EnvelopeError unwraps to, so callers can
+	// match errors by code rather than by a possibly localized message.
+	ErrorCodes map[string]error
+
+	// ErrorCodeOf, when set, extracts the code of a failed response
+	// instead of ErrorCode.
+	ErrorCodeOf func(res *http.Response, body []byte) string
+
+	// ErrorExtractor, when set, turns successful responses whose body
+	// reports a failure into errors. See EnvelopeErrors.
+	ErrorExtractor ErrorExtractor
+
+	// Validators, when set, records the ETag and Last-Modified of
+	// successful GETs and writes for use by Validate and conditional
+	// updates.
+	Validators ValidatorStore
+
+	// CacheHints tunes Cache per route: it maps a URL path prefix to the
+	// hint of the routes under it. The longest matching prefix wins; ""
+	// matches every path.
+	CacheHints map[string]*CacheHint
+
+	// CacheWrites also stores in Cache the responses to successful PUT,
+	// PATCH and creating POST requests, under the URL they were written
+	// to, for APIs that answer writes with the stored representation.
+	CacheWrites bool
+
+	// VersionHeader, when set, names a response header holding a resource
+	// version, recorded as Validators.Version.
+	VersionHeader string
+
+	// ErrorPreviewBytes caps the body snippet included in errors. Defaults
+	// to DefaultErrorPreviewBytes; negative disables the snippet.
+	ErrorPreviewBytes int
+
+	// IdempotencyHeader names the header carrying idempotency keys.
+	// Defaults to DefaultIdempotencyHeader.
+	IdempotencyHeader string
+
+	// ReplayTTL, when positive, remembers the response to a request sent
+	// with an idempotency key for this long. Repeating the request returns
+	// the remembered result without contacting the server.
+	ReplayTTL time.Duration
+
+	// Throttle, when set, parks requests after a 429 instead of failing.
+	Throttle *ThrottleConfig
+
+	// SchemaDrift, when set, compares every JSON response against the
+	// struct it is decoded into. See DriftDetector.
+	SchemaDrift *DriftDetector
+
+	// DefaultQuery is merged into the query of every request. Parameters
+	// set on the request itself take precedence. Values may contain {name}
+	// placeholders resolved through QueryVars.
+	DefaultQuery url.Values
+
+	// Experimental names the experimental subsystems enabled. See
+	// EnableExperimental.
+	Experimental []string
+
+	// Negotiation, when set, sends a call again once asking for another
+	// media type when its response cannot be decoded or is refused with
+	// a 406.
+	Negotiation *NegotiationConfig
+
+	// QueryEncoding, when set, encodes the parameters of WithParams and
+	// ReadJsonWithParams instead of the defaults of EncodeQuery.
+	QueryEncoding *QueryEncoding
+
+	// QueryVars resolves the placeholders in DefaultQuery values against
+	// the request context.
+	QueryVars map[string]HeaderExtractor
+
+	// DefaultHeader is sent with every request.
+	DefaultHeader http.Header
+
+	// Retry, when set, retries failed requests. See RetryPolicy.
+	Retry *RetryPolicy
+
+	// CollectStats enables the per-route statistics returned by Stats.
+	// Collecting takes no locks and costs well under a microsecond per
+	// request, so it can stay on in production as long as calls on paths
+	// holding IDs are given a route WithRoute or WithPathParams.
+	CollectStats bool
+
+	// Payloads, when set, reports request and response bodies exceeding
+	// size thresholds.
+	Payloads *PayloadConfig
+
+	// ContextHeaders maps header names to extractors run against the
+	// context of every outgoing request, so values set by upstream
+	// middleware (user ID, locale, trace IDs) are forwarded automatically.
+	ContextHeaders map[string]HeaderExtractor
+
+	// RateLimit, when set, spaces requests out to stay under a quota.
+	RateLimit *RateLimitConfig
+
+	// Tenant names the tenant requests are sent for, which the rate
+	// limiter shares its rate among when RateLimit.PerTenant is set.
+	Tenant string
+
+	// Policies vet every request before it is sent, after those registered
+	// with RegisterPolicy.
+	Policies []Policy
+
+	// Failover, when set, sends requests that fail to fallback base URLs.
+	Failover *FailoverConfig
+
+	// Maintenance, when set, holds requests back during maintenance
+	// windows.
+	Maintenance *MaintenanceConfig
+
+	// CircuitBreaker, when set, fails requests fast while the server keeps
+	// failing.
+	CircuitBreaker *CircuitBreakerConfig
+
+	// Journal, when set, records recent request attempts for diagnosis.
+	Journal *Journal
+
+	// Informational, when set, is called with the 1xx interim responses
+	// of every request, such as 103 Early Hints.
+	Informational InformationalFunc
+
+	// Archive, when set, copies response bodies to a sink as they are
+	// read.
+	Archive *ArchiveConfig
+
+	// Fixtures, when set, saves responses as fixtures for writing tests.
+	Fixtures *FixtureConfig
+
+	// Logging, when set, reports every request attempt to a Hook.
+	Logging *LogConfig
+
+	// Telemetry, when set, traces and measures every request attempt.
+	Telemetry *TelemetryConfig
+
+	// Presets holds the named call settings selectable with Preset.
+	Presets map[string]*RequestPreset
+
+	// URLSigner, when set, signs the URL of every request attempt.
+	URLSigner URLSigner
+
+	// BufferBodies, when positive, reads request bodies that cannot be
+	// replayed, such as plain io.Readers, into memory when they hold at
+	// most this many bytes, so middleware and retries can read them again.
+	// Larger bodies and JSONArray streams are sent as they are.
+	BufferBodies int64
+
+	// HeaderPolicy controls how DefaultHeader, ContextHeaders and the
+	// request's own headers merge. See HeaderMergeMode.
+	HeaderPolicy *HeaderPolicy
+
+	*clientState
+
+	// shutdown is the client's own, below that of the client it was
+	// derived from, so Close does not reach its parent or siblings.
+	shutdown *shutdown
+
+	middleware []Middleware
+
+	// owned records which shared maps a derived client has copied.
+	owned ownedConfig
+
+	// invalid is the error of a Clone given an invalid option, returned
+	// by every request of the clone.
+	invalid error
+
+	// Set by options and consumed by NewClient.
+	httpClient *http.Client
+	timeout    time.Duration
+	timeouts   Timeouts
+	tlsConfig  *tls.Config
+	jar        http.CookieJar
+
+	userCheckRedirect func(*http.Request, []*http.Request) error
 }
 
-func NewClient(surl, apiKey string) (*Client, error) {
-	if apiKey == "" {
-		return nil, errors.New("api key is empty")
-	}
+// clientState is the runtime state of a client, shared with the clients
+// derived from it by Clone and Sub.
+type clientState struct {
+	inflight     inflight
+	health       healthTracker
+	payloads     payloadTracker
+	replay       replayCache
+	throttle     throttle
+	limiter      rateLimiter
+	breakers     breakers
+	failoverNext uint32
+	maintenance  maintenanceStatus
+	keySlot      int32
+	stats        statsCollector
+	capabilities capabilityCache
+}
 
+// NewClient returns a client for the API at surl, authenticating with
+// apiKey unless WithAuthenticator is given. Options are applied in order.
+func NewClient(surl, apiKey string, opts ...Option) (*Client, error) {
 	nurl, err := url.Parse(surl)
 	if err != nil {
 		return nil, err
@@ -41,7 +350,43 @@ func NewClient(surl, apiKey string) (*Client, error) {
 		return nil, errors.New("URL is not absolute")
 	}
 
-	return &Client{url: nurl, client: &http.Client{}, apiKey: apiKey}, nil
+	c := &Client{url: nurl, apiKey: apiKey, clientState: &clientState{}, shutdown: &shutdown{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if apiKey == "" && c.Auth == nil {
+		return nil, errors.New("api key is empty")
+	}
+	if err := c.enableExperiments(); err != nil {
+		return nil, err
+	}
+	c.client = c.buildHTTPClient()
+
+	return c, nil
+}
+
+// buildHTTPClient returns the http.Client used for all requests: a copy of
+// the one given WithHTTPClient, or a new one using the client's transport.
+func (c *Client) buildHTTPClient() *http.Client {
+	hc := &http.Client{}
+	if c.httpClient != nil {
+		*hc = *c.httpClient
+	}
+	if hc.Transport == nil {
+		hc.Transport = c.newTransport()
+	}
+	c.applyTLS(hc)
+	c.applyTimeouts(hc)
+	if c.jar != nil {
+		hc.Jar = c.jar
+	}
+	if c.timeout > 0 {
+		hc.Timeout = c.timeout
+	}
+	c.userCheckRedirect = hc.CheckRedirect
+	hc.CheckRedirect = c.checkRedirect
+	return hc
 }
 
 func (c *Client) GetQuery(uri string) (string, error) {
@@ -54,16 +399,31 @@ func (c *Client) GetQuery(uri string) (string, error) {
 		return "", errors.New("URI is not absolute")
 	}
 
-	return c.url.ResolveReference(nurl).String(), nil
+	var u *url.URL
+	if c.PathJoin == JoinAppend {
+		u = appendURL(c.url, nurl)
+	} else {
+		u = c.url.ResolveReference(nurl)
+	}
+	if c.Normalize != nil {
+		c.Normalize.apply(u)
+	}
+	return u.String(), nil
 }
 
 func (c *Client) MakeRequest(method, uri string) (*http.Request, error) {
+	return c.MakeRequestContext(context.Background(), method, uri)
+}
+
+// MakeRequestContext is like MakeRequest but the request carries ctx, which
+// cancels it and bounds its lifetime.
+func (c *Client) MakeRequestContext(ctx context.Context, method, uri string) (*http.Request, error) {
 	query, err := c.GetQuery(uri)
 	if err != nil {
 		return nil, err
 	}
 
-	request, err := http.NewRequest(method, query, nil)
+	request, err := http.NewRequestWithContext(ctx, method, query, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -72,152 +432,580 @@ func (c *Client) MakeRequest(method, uri string) (*http.Request, error) {
 }
 
 func (c *Client) MakeMultipartRequest(method, uri string, mpf MultipartForm) (req *http.Request, err error) {
+	return c.MakeMultipartRequestContext(context.Background(), method, uri, mpf)
+}
+
+// MakeMultipartRequestContext is like MakeMultipartRequest but the request
+// carries ctx. The body is streamed from the files and readers of mpf as
+// the request is sent.
+func (c *Client) MakeMultipartRequestContext(ctx context.Context, method, uri string, mpf MultipartForm) (req *http.Request, err error) {
 	query, err := c.GetQuery(uri)
 	if err != nil {
 		return nil, err
 	}
 
-	b := &bytes.Buffer{}
-
-	w := multipart.NewWriter(b)
-
-	// Add files
-	for field, file := range mpf.Files {
-		f, err := os.Open(file)
-		if err != nil {
-			return nil, errors.New(fmt.Sprintf("Error with file %s, %s", file, err.Error()))
-		}
-		defer f.Close()
-
-		fw, err := w.CreateFormFile(field, filepath.Base(file))
-		if err != nil {
-			return nil, err
-		}
-		_, err = io.Copy(fw, f)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	for field, value := range mpf.Fields {
-		err := w.WriteField(field, value)
-		if err != nil {
-			return nil, err
-		}
+	body, err := newMultipartBody(&mpf)
+	if err != nil {
+		return nil, err
 	}
 
-	err = w.Close()
+	r, err := body.open()
 	if err != nil {
 		return nil, err
 	}
-
-	req, err = http.NewRequest(method, query, b)
+	req, err = http.NewRequestWithContext(ctx, method, query, r)
 	if err != nil {
+		r.Close()
 		return req, err
 	}
-
-	req.Header.Add("Content-Type", w.FormDataContentType())
+	req.ContentLength = body.size
+	if body.size < 0 {
+		req.ContentLength = 0
+		req.TransferEncoding = []string{"chunked"}
+	}
+	if body.replayable() {
+		req.GetBody = body.open
+	}
+	req.Header.Add("Content-Type", body.contentType())
 
 	return
 }
 
-func (c *Client) PostMultipartJson(uri string, mpf MultipartForm, data interface{}) (err error) {
-	req, err := c.MakeMultipartRequest(http.MethodPost, uri, mpf)
+// PostMultipartJsonContext is like PostMultipartJson but ctx bounds the
+// request.
+func (c *Client) PostMultipartJsonContext(ctx context.Context, uri string, mpf MultipartForm, data interface{}, opts ...RequestOption) (err error) {
+	req, err := c.MakeMultipartRequestContext(ctx, http.MethodPost, uri, mpf)
 	if err != nil {
 		return err
 	}
 
-	return c.jsonResponse(req, &data)
+	return c.jsonResponse(req, data, newCallOptions(opts))
 }
 
 func (c *Client) GetResponse(r *http.Request) (res *http.Response, err error) {
-	if c.apiKey != "" {
-		r.Header.Set("Autorization", fmt.Sprintf("Token token=\"%s\"", c.apiKey))
+	res, _, err = c.do(r)
+	return res, err
+}
+
+// do prepares r and sends it, retrying as configured. It also returns the
+// number of attempts made.
+func (c *Client) do(r *http.Request) (*http.Response, int, error) {
+	if c.invalid != nil {
+		return nil, 0, c.invalid
+	}
+	result := callResult(r)
+	if result == nil {
+		// A bare GetResponse call; only LastResponse and LastKey are kept.
+		result = &Result{}
+		r = withResult(r, result)
+		defer func() {
+			c.lastMu.Lock()
+			if result.Response != nil {
+				c.LastResponse = result.Response
+			}
+			c.LastKey = result.Key
+			c.lastMu.Unlock()
+		}()
+	}
+	if c.Normalize != nil {
+		c.Normalize.apply(r.URL)
+	}
+	c.applyDefaultQuery(r)
+	c.mergeHeaders(r)
+	if c.RequestID != nil {
+		c.RequestID.apply(r, c.IDs)
+	}
+	if c.Compression != nil {
+		if err := c.Compression.apply(r); err != nil {
+			return nil, 0, err
+		}
+	}
+	if c.BufferBodies > 0 {
+		if err := bufferBody(r, c.BufferBodies); err != nil {
+			return nil, 0, err
+		}
 	}
 
-	res, err = c.client.Do(r)
+	caller := r.Context()
+	r, release, err := c.bindShutdown(r)
 	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	res, attempts, err := c.sendWithRetry(r)
+	result.Attempts = attempts
+	if c.CollectStats {
+		c.stats.request(routeKey(r), err != nil || res.StatusCode >= 400, time.Since(start))
+	}
+	if err != nil {
+		release()
+		return nil, attempts, cancelError(caller, r, err)
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	result.Response = res
+
+	return res, attempts, nil
+}
+
+// sendRequest sends r, through the circuit breaker, rate limiter and
+// throttle if configured.
+func (c *Client) sendRequest(r *http.Request) (*http.Response, error) {
+	if c.CircuitBreaker != nil {
+		return c.sendGuarded(r, c.sendPaced)
+	}
+	return c.sendPaced(r)
+}
+
+// sendPaced sends r through the rate limiter and throttle.
+func (c *Client) sendPaced(r *http.Request) (*http.Response, error) {
+	send := c.send
+	if c.RateLimit != nil {
+		send = func(r *http.Request) (*http.Response, error) {
+			return c.sendLimited(r, c.send)
+		}
+	}
+	if c.Throttle != nil {
+		return c.sendThrottled(r, send)
+	}
+	return send(r)
+}
+
+// send performs a single round trip of r.
+func (c *Client) send(r *http.Request) (*http.Response, error) {
+	c.inflight.add()
+	start := time.Now()
+	if c.Payloads != nil && hasBody(r) {
+		c.checkPayload(r, PayloadRequest, r.ContentLength)
+	}
+	var span Span
+	if c.Telemetry != nil {
+		r, span = c.Telemetry.start(r)
+	}
+	r = c.traceInformational(r)
+	var logged *LogEvent
+	if c.Logging != nil {
+		logged = c.Logging.request(r)
+	}
+	r, redirects := c.traceRedirects(r, start)
+	res, err := timeAttempt(r, c.roundTrip)
+	if redirects != nil {
+		hops := redirects.chain()
+		if result := callResult(r); result != nil {
+			result.Redirects = hops
+		}
+		if logged != nil {
+			logged.Redirects = hops
+		}
+	}
+	if err != nil && !c.timeouts.IsZero() {
+		err = c.classifyTimeout(err)
+	}
+	if err == nil && c.timeouts.BodyIdle > 0 {
+		res.Body = &idleBody{ReadCloser: res.Body, timeout: c.timeouts.BodyIdle}
+	}
+	if err == nil && c.Compression != nil && c.Compression.DecompressResponses {
+		res = c.Compression.decompress(res)
+	}
+	if err == nil && c.tracksPayloads() {
+		res = c.trackPayload(r, res)
+	}
+	if err == nil && c.Archive != nil {
+		res = c.Archive.wrap(r, res, c.now())
+	}
+	if err == nil && c.Fixtures != nil {
+		res = c.Fixtures.archive().wrap(r, res, c.now())
+	}
+	if c.Journal != nil {
+		res = c.Journal.record(r, res, err, start)
+	}
+	if c.Logging != nil {
+		res = c.Logging.response(logged, res, err, start)
+	}
+	if c.HealthTracking != nil {
+		c.health.record(c.HealthTracking, routeKey(r), !isFailure(res, err), time.Since(start))
+	}
+	if c.Telemetry != nil {
+		c.Telemetry.finish(r, span, res, err, start)
+	}
+	if err != nil {
+		c.inflight.done()
 		return nil, err
 	}
-	c.LastResponse = res
+	res.Body = &trackedBody{ReadCloser: res.Body, f: &c.inflight}
 
 	return res, nil
 }
 
-func (c *Client) ReadJson(uri string, response interface{}) (err error) {
-	req, err := c.MakeRequest(http.MethodGet, uri)
+// ReadJsonContext is like ReadJson but ctx bounds the request.
+func (c *Client) ReadJsonContext(ctx context.Context, uri string, response interface{}, opts ...RequestOption) (err error) {
+	req, err := c.MakeRequestContext(ctx, http.MethodGet, uri)
 	if err != nil {
 		return err
 	}
 
-	return c.jsonResponse(req, &response)
+	return c.jsonResponse(req, response, newCallOptions(opts))
 }
 
-func (c *Client) DeleteJson(uri string, response interface{}) (err error) {
-	req, err := c.MakeRequest(http.MethodDelete, uri)
+// DeleteJsonContext is like DeleteJson but ctx bounds the request.
+func (c *Client) DeleteJsonContext(ctx context.Context, uri string, response interface{}, opts ...RequestOption) (err error) {
+	req, err := c.MakeRequestContext(ctx, http.MethodDelete, uri)
 	if err != nil {
 		return err
 	}
 
-	return c.jsonResponse(req, &response)
+	return c.jsonResponse(req, response, newCallOptions(opts))
 }
 
-func (c *Client) CreateJson(uri string, data interface{}, response interface{}) (err error) {
-	req, err := c.MakeRequest(http.MethodPost, uri)
+// CreateJsonContext is like CreateJson but ctx bounds the request.
+func (c *Client) CreateJsonContext(ctx context.Context, uri string, data interface{}, response interface{}, opts ...RequestOption) (err error) {
+	req, err := c.MakeRequestContext(ctx, http.MethodPost, uri)
 	if err != nil {
 		return err
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
+	o := newCallOptions(opts)
+	if err := c.setEncodedBody(req, data, o); err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application.json")
-	req.Body = ioutil.NopCloser(bytes.NewReader(jsonData))
-
-	return c.jsonResponse(req, &response)
+	return c.jsonResponse(req, response, o)
 }
 
-func (c *Client) UpdateJson(uri string, data interface{}, response interface{}) (err error) {
-	req, err := c.MakeRequest(http.MethodPut, uri)
+// UpdateJsonContext is like UpdateJson but ctx bounds the request.
+func (c *Client) UpdateJsonContext(ctx context.Context, uri string, data interface{}, response interface{}, opts ...RequestOption) (err error) {
+	req, err := c.MakeRequestContext(ctx, http.MethodPut, uri)
 	if err != nil {
 		return err
 	}
 
-	jsonData, err := json.Marshal(data)
+	o := newCallOptions(opts)
+	if err := c.setEncodedBody(req, data, o); err != nil {
+		return err
+	}
+
+	return c.jsonResponse(req, response, o)
+}
+
+// PatchJsonContext PATCHes data as JSON to uri and decodes the response
+// into response.
+func (c *Client) PatchJsonContext(ctx context.Context, uri string, data interface{}, response interface{}, opts ...RequestOption) (err error) {
+	return c.DoContext(ctx, http.MethodPatch, uri, data, response, opts...)
+}
+
+// DoContext sends a request with any method to uri and decodes the response
+// into response. A non-nil body is encoded with the call's Codec; a nil
+// body sends none.
+// Pass a nil response to skip decoding, as for HEAD or OPTIONS; the request
+// is still sent and checked for errors. See FireAndForget to not wait for
+// it.
+func (c *Client) DoContext(ctx context.Context, method, uri string, body interface{}, response interface{}, opts ...RequestOption) (err error) {
+	req, err := c.MakeRequestContext(ctx, method, uri)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Body = ioutil.NopCloser(bytes.NewReader(jsonData))
+	o := newCallOptions(opts)
+	if body != nil {
+		if err := c.setEncodedBody(req, body, o); err != nil {
+			return err
+		}
+	}
 
-	return c.jsonResponse(req, &response)
+	return c.jsonResponse(req, response, o)
 }
 
-func (c *Client) jsonResponse(req *http.Request, response interface{}) (err error) {
+func (c *Client) jsonResponse(req *http.Request, response interface{}, o *callOptions) error {
+	if c.Negotiation != nil && !o.fireAndForget {
+		return c.negotiate(req, response, o)
+	}
+	return c.exchange(req, response, o)
+}
 
-	res, err := c.GetResponse(req)
-	if err != nil {
+// exchange sends req and decodes its response into response.
+func (c *Client) exchange(req *http.Request, response interface{}, o *callOptions) (err error) {
+	if o.fireAndForget {
+		c.sendDetached(req, o)
+		return nil
+	}
+
+	start := time.Now()
+	result := o.result
+	if result == nil {
+		result = &Result{}
+	}
+	*result = Result{Attempts: 1}
+	defer c.publish(result)
+	req = withResult(req, result)
+
+	var streamed int64
+	fail := func(err error) error {
+		return &RequestError{
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Attempt:   result.Attempts,
+			Elapsed:   time.Since(start),
+			BytesRead: int64(len(result.Body)) + streamed,
+			Err:       err,
+		}
+	}
+	if c.invalid != nil {
+		return fail(c.invalid)
+	}
+	if err := o.apply(req, c); err != nil {
+		return fail(err)
+	}
+	if o.route != "" {
+		req = withRoute(req, o.route)
+	}
+	if o.informational != nil {
+		req = withInformational(req, o.informational)
+	}
+	c.acceptCodec(req, o)
+
+	req, timing := c.timingFor(req, o)
+	if timing != nil {
+		result.Timing = timing.t
+		defer timing.finish(start)
+	}
+	decode := func(body []byte) error {
+		decodeStart := time.Now()
+		err := c.decode(body, response, o)
+		if timing != nil {
+			timing.decoded(time.Since(decodeStart))
+		}
 		return err
 	}
 
-	c.LastBody, err = ioutil.ReadAll(res.Body)
+	req, cancel, err := c.applyPresets(req, o)
 	if err != nil {
-		return err
+		return fail(err)
+	}
+	defer cancel()
+	if o.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), o.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	var replay string
+	if c.ReplayTTL > 0 {
+		replay = replayKey(req, c.idempotencyHeader())
+	}
+	if replay != "" {
+		if body, ok := c.replay.get(replay); ok {
+			if c.CollectStats {
+				c.stats.cacheHit(routeKey(req))
+			}
+			result.Body = body
+			result.Empty = isEmptyBody(body)
+			if err := decode(body); err != nil {
+				return fail(err)
+			}
+			return nil
+		}
 	}
 
-	if reflect.ValueOf(response).IsNil() {
-		fmt.Println("Response is nil")
+	cached, fresh := c.cacheLookup(req)
+	if fresh {
+		if c.CollectStats {
+			c.stats.cacheHit(routeKey(req))
+		}
+		result.Cached = true
+		result.Body = cached.Body
+		result.Empty = isEmptyBody(cached.Body)
+		if err := decode(cached.Body); err != nil {
+			return fail(err)
+		}
 		return nil
 	}
 
-	err = json.Unmarshal(c.LastBody, &response)
+	res, _, err := c.do(req)
+	if err != nil {
+		return fail(err)
+	}
+
+	res, redirected, err := c.handleLocation(req, res)
+	if err != nil {
+		return fail(err)
+	}
+	defer res.Body.Close()
+	result.Response = res
+	result.StatusCode = res.StatusCode
+	result.Header = res.Header
+
+	if c.MaxResponseBytes > 0 && res.ContentLength > c.MaxResponseBytes {
+		return fail(&ResponseTooLargeError{Limit: c.MaxResponseBytes})
+	}
+	body := c.limitBody(res.Body)
+	if !redirected && isSuccess(res.StatusCode) && c.streams(response, o, replay) {
+		c.recordValidators(req, res)
+		decodeStart := time.Now()
+		n, empty, err := c.decodeStream(body, response, o)
+		streamed = n
+		if timing != nil {
+			timing.decoded(time.Since(decodeStart))
+		}
+		result.Empty = res.StatusCode == http.StatusNoContent || empty
+		if err != nil {
+			return fail(err)
+		}
+		return nil
+	}
 
+	result.Body, err = ioutil.ReadAll(body)
 	if err != nil {
-		return fmt.Errorf("Invalid JSON: %s", c.LastBody)
+		return fail(err)
 	}
 
+	if redirected {
+		return nil
+	}
+	if res.StatusCode == http.StatusNotModified && o.ifChanged {
+		// Unchanged resources are not decoded; see GetChanged.
+		result.NotModified = true
+		return nil
+	}
+	if cached != nil && res.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		if c.CollectStats {
+			c.stats.cacheHit(routeKey(req))
+		}
+		result.Cached = true
+		result.Body = cached.Body
+		result.Empty = isEmptyBody(cached.Body)
+		if err := decode(cached.Body); err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+	if !isSuccess(res.StatusCode) {
+		return fail(c.apiError(res, result.Body))
+	}
+	if err := c.extractError(res, result.Body, o); err != nil {
+		return fail(err)
+	}
+	c.recordValidators(req, res)
+	c.cacheStore(req, res, result.Body)
+
+	result.Empty = res.StatusCode == http.StatusNoContent || isEmptyBody(result.Body)
+	if err := decode(result.Body); err != nil {
+		return fail(err)
+	}
+	if replay != "" {
+		c.replay.put(replay, result.Body, c.ReplayTTL)
+	}
+	if result.Empty {
+		return nil
+	}
+	if c.SchemaDrift != nil && c.decoderFor(o) == JSONDecoder {
+		c.SchemaDrift.observe(routeKey(req), primaryTarget(response), result.Body)
+	}
+
+	return nil
+}
+
+// sendDetached runs the call of req in the background, discarding its
+// response.
+func (c *Client) sendDetached(req *http.Request, o *callOptions) {
+	ctx := context.WithoutCancel(req.Context())
+	cancel := context.CancelFunc(func() {})
+	if deadline, ok := req.Context().Deadline(); ok {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	}
+
+	d := *o
+	d.fireAndForget = false
+	d.result = nil
+	d.timing = nil
+	c.inflight.add()
+	go func() {
+		defer c.inflight.done()
+		defer cancel()
+		if err := c.jsonResponse(req.WithContext(ctx), nil, &d); err != nil && d.onError != nil {
+			d.onError(err)
+		}
+	}()
+}
+
+// decoderFor returns the decoder for a call: the call's decoder or codec,
+// the client's decoder or codec, or JSONDecoder.
+func (c *Client) decoderFor(o *callOptions) Decoder {
+	if o.decoder != nil {
+		return o.decoder
+	}
+	if o.codec != nil {
+		return o.codec
+	}
+	if c.Decoder != nil {
+		return c.Decoder
+	}
+	if c.Codec != nil {
+		return c.Codec
+	}
+	return JSONDecoder
+}
+
+// isEmptyBody reports whether body holds nothing but whitespace.
+func isEmptyBody(body []byte) bool {
+	return len(bytes.TrimSpace(body)) == 0
+}
+
+// decode decodes body into response with the call's decoder, falling back
+// to the client's. A nil response or an empty body skips decoding; writer
+// and file targets get the body as is.
+func (c *Client) decode(body []byte, response interface{}, o *callOptions) error {
+	if response == nil {
+		return nil
+	}
+
+	if tee, ok := response.(teeTarget); ok {
+		for _, target := range tee {
+			if err := c.decode(body, target, o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if ok, err := sinkTarget(response, body); ok {
+		return err
+	}
+	if isEmptyBody(body) {
+		return nil
+	}
+	if rawTarget(response, body) {
+		return nil
+	}
+
+	if err := c.decoderFor(o).Decode(bytes.NewReader(body), response); err != nil {
+		return &DecodeError{Err: sniffDecodeError(body, err), Preview: bodyPreview(body, c.errorPreviewBytes())}
+	}
+	return nil
+}
+
+// setBody replaces the body of r with a replayable copy of body.
+func setBody(r *http.Request, body []byte) {
+	r.ContentLength = int64(len(body))
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+var errBodyNotReplayable = errors.New("request body cannot be replayed")
+
+// rewindBody resets the body of r so it can be sent again.
+func rewindBody(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.GetBody == nil {
+		return errBodyNotReplayable
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	r.Body = body
 	return nil
 }
@@ -5,12 +5,15 @@
 package relax
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 var goodURL = "https://ms.example.com"
@@ -298,9 +301,88 @@ func TestClient_jsonResponse(t *testing.T) {
 				LastResponse: tt.fields.LastResponse,
 				LastBody:     tt.fields.LastBody,
 			}
-			if err := c.jsonResponse(tt.args.req, tt.args.data); (err != nil) != tt.wantErr {
+			if err := c.jsonResponse(tt.args.req, tt.args.data, &callOptions{}); (err != nil) != tt.wantErr {
 				t.Errorf("Client.jsonResponse() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestClient_ReadJsonContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var data Response
+	err := c.ReadJsonContext(ctx, "/api/foo", &data)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestClient_MakeRequestContext(t *testing.T) {
+	type key string
+
+	c := newClientOrFatal(t, goodURL, apiKey)
+	ctx := context.WithValue(context.Background(), key("k"), "v")
+
+	req, err := c.MakeRequestContext(ctx, http.MethodGet, "/api/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Context().Value(key("k")) != "v" {
+		t.Errorf("Expected request to carry the context")
+	}
+}
+
+func TestClient_NewClientOptions(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	var redirects int
+	hc := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		redirects++
+		return nil
+	}}
+
+	c, err := NewClient(server.URL, apiKey,
+		WithTimeout(time.Second),
+		WithHTTPClient(hc),
+		WithUserAgent("relax-test/1.0"),
+		WithDefaultHeader("X-Env", "test"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.client == hc {
+		t.Errorf("Expected the given http.Client to be copied")
+	}
+	if c.client.Timeout != time.Second {
+		t.Errorf("Expected timeout to apply regardless of option order, got %s", c.client.Timeout)
+	}
+	if c.client.CheckRedirect(&http.Request{}, nil); redirects != 1 {
+		t.Errorf("Expected the given redirect policy to be kept")
+	}
+
+	if err := c.ReadJson("/", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ua := got.Header.Get("User-Agent"); ua != "relax-test/1.0" {
+		t.Errorf("Expected User-Agent \"relax-test/1.0\", got %q", ua)
+	}
+	if v := got.Header.Get("X-Env"); v != "test" {
+		t.Errorf("Expected X-Env \"test\", got %q", v)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import "time"
+
+// IDGenerator generates the unique IDs the client puts in requests, such
+// as idempotency keys and request IDs.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to an IDGenerator.
+type IDGeneratorFunc func() string
+
+// NewID calls f.
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// Clock tells the time recorded by the client, such as when a response
+// was cached or archived.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock.
+type ClockFunc func() time.Time
+
+// Now calls f.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// WithIDGenerator generates the IDs put in requests with ids instead of
+// randomly. With a Clock, a predictable generator makes a recorded
+// session replay byte for byte in tests.
+func WithIDGenerator(ids IDGenerator) Option {
+	return func(c *Client) {
+		c.IDs = ids
+	}
+}
+
+// WithClock takes the time recorded by the client from clock.
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.Clock = clock
+	}
+}
+
+// newID returns a new ID, a random UUID unless IDs is set.
+func (c *Client) newID() string {
+	if c.IDs != nil {
+		return c.IDs.NewID()
+	}
+	return NewUUID()
+}
+
+// now returns the current time of Clock, or the system time.
+func (c *Client) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now()
+	}
+	return time.Now()
+}
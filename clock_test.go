@@ -0,0 +1,87 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_IDGenerator(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, r.Header.Get("X-Request-ID")+" "+r.Header.Get("Idempotency-Key"))
+		if calls++; calls%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	run := func() []string {
+		n := 0
+		c := newClientOrFatal(t, server.URL, apiKey)
+		c.IDs = IDGeneratorFunc(func() string {
+			n++
+			return fmt.Sprintf("id-%d", n)
+		})
+		c.RequestID = &RequestIDConfig{}
+		c.Retry = &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Millisecond), IdempotencyKeys: true}
+
+		seen = nil
+		var response Response
+		if err := c.CreateJson("/api/foo", "bar", &response); err != nil {
+			t.Fatal(err)
+		}
+		return seen
+	}
+
+	first := run()
+	want := []string{"id-1 id-2", "id-1 id-2"}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("Expected generated IDs %q, got %q", want, first)
+	}
+	if second := run(); !reflect.DeepEqual(second, first) {
+		t.Errorf("Expected a second run to send the same IDs %q, got %q", first, second)
+	}
+}
+
+func TestClient_Clock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"1"`)
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	store := NewMemoryStore(0)
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Cache = store
+	c.Clock = ClockFunc(func() time.Time { return at })
+
+	var response Response
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/foo", nil)
+	key, _ := c.cacheKey(req.URL, req, nil)
+	cached, ok := store.Get(key)
+	if !ok {
+		t.Fatal("Expected the response to be cached")
+	}
+	if !cached.Stored.Equal(at) {
+		t.Errorf("Expected the clock's time %s, got %s", at, cached.Stored)
+	}
+}
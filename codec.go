@@ -0,0 +1,133 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Codec encodes request bodies and decodes response bodies in one format.
+// Set Client.Codec, or pass WithCodec to a call, to talk to services that
+// do not speak JSON.
+type Codec interface {
+	Decoder
+	Encode(w io.Writer, v interface{}) error
+
+	// ContentType is sent as the Content-Type of encoded bodies and as
+	// the Accept header when the request does not set one.
+	ContentType() string
+}
+
+var (
+	// JSONCodec encodes and decodes JSON. It is the default.
+	JSONCodec Codec = jsonDecoder{}
+
+	// XMLCodec encodes and decodes XML.
+	XMLCodec Codec = xmlDecoder{}
+
+	// MsgpackCodec encodes and decodes MessagePack. See MarshalMsgpack for
+	// how Go values are mapped.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+func (jsonDecoder) Encode(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (jsonDecoder) ContentType() string {
+	return "application/json"
+}
+
+func (xmlDecoder) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlDecoder) ContentType() string {
+	return "application/xml"
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	b, err := MarshalMsgpack(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return UnmarshalMsgpack(b, v)
+}
+
+func (msgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+// WithCodec encodes the body and decodes the response of this call with
+// codec instead of the client's Codec.
+func WithCodec(codec Codec) RequestOption {
+	return func(o *callOptions) {
+		o.codec = codec
+	}
+}
+
+// codecFor returns the codec for a call: the call's, the client's, or
+// JSONCodec.
+func (c *Client) codecFor(o *callOptions) Codec {
+	if o.codec != nil {
+		return o.codec
+	}
+	if c.Codec != nil {
+		return c.Codec
+	}
+	return JSONCodec
+}
+
+// setEncodedBody encodes data with the call's codec, or as a form if it
+// was wrapped by Form, as the body of r.
+func (c *Client) setEncodedBody(r *http.Request, data interface{}, o *callOptions) error {
+	switch b := data.(type) {
+	case formBody:
+		return setFormBody(r, b)
+	case streamBody:
+		setStreamBody(r, b)
+		return nil
+	}
+	codec := c.codecFor(o)
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, data); err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", codec.ContentType())
+	setBody(r, buf.Bytes())
+	return nil
+}
+
+// acceptCodec asks for the call's codec format when a codec was chosen
+// explicitly and the request does not say what it accepts.
+func (c *Client) acceptCodec(r *http.Request, o *callOptions) {
+	if o.codec == nil && c.Codec == nil {
+		return
+	}
+	if r.Header.Get("Accept") == "" {
+		r.Header.Set("Accept", c.codecFor(o).ContentType())
+	}
+}
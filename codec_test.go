@@ -0,0 +1,91 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_XMLCodec(t *testing.T) {
+	type item struct {
+		XMLName xml.Name `xml:"item"`
+		Name    string   `xml:"name"`
+	}
+
+	var gotBody, gotType, gotAccept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody, gotType, gotAccept = string(body), r.Header.Get("Content-Type"), r.Header.Get("Accept")
+		w.Write([]byte(`<item><name>created</name></item>`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Codec = XMLCodec
+
+	var out item
+	if err := c.CreateJson("/items", item{Name: "new"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if gotBody != "<item><name>new</name></item>" {
+		t.Errorf("Expected an XML body, got %q", gotBody)
+	}
+	if gotType != "application/xml" || gotAccept != "application/xml" {
+		t.Errorf("Expected XML Content-Type and Accept, got %q and %q", gotType, gotAccept)
+	}
+	if out.Name != "created" {
+		t.Errorf("Expected out.Name to be \"created\", got %q", out.Name)
+	}
+}
+
+func TestClient_WithCodec(t *testing.T) {
+	var gotType, gotAccept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in Response
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := UnmarshalMsgpack(body, &in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gotType, gotAccept = r.Header.Get("Content-Type"), r.Header.Get("Accept")
+		out, _ := MarshalMsgpack(Response{Foo: in.Foo + "!"})
+		w.Write(out)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var out Response
+	if err := c.UpdateJson("/items/1", Response{Foo: "bar"}, &out, WithCodec(MsgpackCodec)); err != nil {
+		t.Fatal(err)
+	}
+	if out.Foo != "bar!" {
+		t.Errorf("Expected out.Foo to be \"bar!\", got %q", out.Foo)
+	}
+	if gotType != "application/msgpack" || gotAccept != "application/msgpack" {
+		t.Errorf("Expected msgpack Content-Type and Accept, got %q and %q", gotType, gotAccept)
+	}
+}
+
+func TestClient_CreateJsonContentType(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if err := c.CreateJson("/", Response{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ct := got.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type \"application/json\", got %q", ct)
+	}
+	if accept := got.Header.Get("Accept"); accept != "" {
+		t.Errorf("Expected no Accept header by default, got %q", accept)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChangeOp is the kind of a JSONChange.
+type ChangeOp string
+
+const (
+	ChangeAdded    ChangeOp = "added"
+	ChangeRemoved  ChangeOp = "removed"
+	ChangeModified ChangeOp = "modified"
+)
+
+// JSONChange is one difference between two JSON documents.
+type JSONChange struct {
+	// Path locates the value in the syntax of Check.ExpectJSONPath, like
+	// $.items[2].name.
+	Path string   `json:"path"`
+	Op   ChangeOp `json:"op"`
+
+	// Old is unset for added values, New for removed ones.
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+func (c JSONChange) String() string {
+	switch c.Op {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, jsonString(c.New))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, jsonString(c.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, jsonString(c.Old), jsonString(c.New))
+}
+
+// DiffJSON returns the differences between the JSON documents a and b,
+// sorted by path. Objects are compared key by key and arrays index by
+// index, so an item inserted in an array changes every item after it.
+func DiffJSON(a, b []byte) ([]JSONChange, error) {
+	var from, to interface{}
+	if err := json.Unmarshal(a, &from); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &to); err != nil {
+		return nil, err
+	}
+	var changes []JSONChange
+	diffJSON("$", from, to, &changes)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func diffJSON(path string, from, to interface{}, changes *[]JSONChange) {
+	switch f := from.(type) {
+	case map[string]interface{}:
+		t, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for k, v := range f {
+			if nv, ok := t[k]; ok {
+				diffJSON(jsonPathKey(path, k), v, nv, changes)
+			} else {
+				*changes = append(*changes, JSONChange{Path: jsonPathKey(path, k), Op: ChangeRemoved, Old: v})
+			}
+		}
+		for k, v := range t {
+			if _, ok := f[k]; !ok {
+				*changes = append(*changes, JSONChange{Path: jsonPathKey(path, k), Op: ChangeAdded, New: v})
+			}
+		}
+		return
+	case []interface{}:
+		t, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(f) || i < len(t); i++ {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(t):
+				*changes = append(*changes, JSONChange{Path: p, Op: ChangeRemoved, Old: f[i]})
+			case i >= len(f):
+				*changes = append(*changes, JSONChange{Path: p, Op: ChangeAdded, New: t[i]})
+			default:
+				diffJSON(p, f[i], t[i], changes)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, JSONChange{Path: path, Op: ChangeModified, Old: from, New: to})
+	}
+}
+
+// CompareOptions configures CompareResponses.
+type CompareOptions struct {
+	// Body, when set, is encoded with each client's codec and sent.
+	Body interface{}
+
+	// Ignore lists paths whose differences are expected, like
+	// $.updated_at. A path also covers the values below it, and [*]
+	// matches any array index, as in $.items[*].id.
+	Ignore []string
+
+	// Request options applied to both requests.
+	Options []RequestOption
+}
+
+// ComparedResponse is one of the responses compared by CompareResponses.
+type ComparedResponse struct {
+	StatusCode int           `json:"status_code"`
+	Header     http.Header   `json:"header,omitempty"`
+	Body       []byte        `json:"-"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Comparison is the result of CompareResponses. It encodes to JSON as a
+// structured diff.
+type Comparison struct {
+	Method string `json:"method"`
+	URI    string `json:"uri"`
+
+	A ComparedResponse `json:"a"`
+	B ComparedResponse `json:"b"`
+
+	// Changes are the differences between the bodies. A body that is not
+	// JSON is compared as a whole, reported as a change at $.
+	Changes []JSONChange `json:"changes"`
+}
+
+// Equal reports whether both responses had the same status and
+// equivalent bodies.
+func (c *Comparison) Equal() bool {
+	return c.A.StatusCode == c.B.StatusCode && len(c.Changes) == 0
+}
+
+// String summarizes c with one line per change.
+func (c *Comparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: ", c.Method, c.URI)
+	if c.Equal() {
+		b.WriteString("equal\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "status %d vs %d, %d changes\n", c.A.StatusCode, c.B.StatusCode, len(c.Changes))
+	for _, ch := range c.Changes {
+		fmt.Fprintf(&b, "  %s\n", ch)
+	}
+	return b.String()
+}
+
+// CompareResponses sends the same request to a and b concurrently, e.g.
+// clients for the old and new versions of an API, and reports how their
+// responses differ. Requests are sent even when they are writes, so
+// compare reads or endpoints that tolerate both. Error statuses are
+// compared like any other; only failures to get a response are returned
+// as errors. opts may be nil.
+func CompareResponses(ctx context.Context, a, b *Client, method, uri string, opts *CompareOptions) (*Comparison, error) {
+	if opts == nil {
+		opts = &CompareOptions{}
+	}
+	var ignore []*regexp.Regexp
+	for _, p := range opts.Ignore {
+		ignore = append(ignore, ignorePattern(p))
+	}
+
+	clients := []*Client{a, b}
+	responses := make([]ComparedResponse, 2)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func(i int, c *Client) {
+			defer wg.Done()
+			responses[i], errs[i] = c.compareRequest(ctx, method, uri, opts)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cmp := &Comparison{Method: method, URI: uri, A: responses[0], B: responses[1]}
+	changes, err := DiffJSON(cmp.A.Body, cmp.B.Body)
+	if err != nil {
+		changes = nil
+		if !bytes.Equal(cmp.A.Body, cmp.B.Body) {
+			changes = []JSONChange{{Path: "$", Op: ChangeModified, Old: string(cmp.A.Body), New: string(cmp.B.Body)}}
+		}
+	}
+	for _, ch := range changes {
+		if !ignored(ch.Path, ignore) {
+			cmp.Changes = append(cmp.Changes, ch)
+		}
+	}
+	return cmp, nil
+}
+
+// compareRequest sends the request of CompareResponses through c.
+func (c *Client) compareRequest(ctx context.Context, method, uri string, opts *CompareOptions) (ComparedResponse, error) {
+	req, err := c.MakeRequestContext(ctx, method, uri)
+	if err != nil {
+		return ComparedResponse{}, err
+	}
+	o := newCallOptions(opts.Options)
+	if opts.Body != nil {
+		if err := c.setEncodedBody(req, opts.Body, o); err != nil {
+			return ComparedResponse{}, err
+		}
+	}
+	if err := o.apply(req, c); err != nil {
+		return ComparedResponse{}, err
+	}
+
+	start := time.Now()
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return ComparedResponse{}, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(c.limitBody(res.Body))
+	if err != nil {
+		return ComparedResponse{}, err
+	}
+	return ComparedResponse{StatusCode: res.StatusCode, Header: res.Header, Body: body, Duration: time.Since(start)}, nil
+}
+
+// ignorePattern compiles an Ignore path matching itself, with [*] for any
+// index, and the values below it.
+func ignorePattern(path string) *regexp.Regexp {
+	quoted := strings.ReplaceAll(regexp.QuoteMeta(path), `\[\*\]`, `\[\d+\]`)
+	return regexp.MustCompile(`^` + quoted + `($|[.\[])`)
+}
+
+func ignored(path string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
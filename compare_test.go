@@ -0,0 +1,82 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	changes, err := DiffJSON(
+		[]byte(`{"id": 1, "name": "a", "tags": ["x", "y"], "old": true, "meta": {"v": 1}}`),
+		[]byte(`{"id": 1, "name": "b", "tags": ["x"], "new": null, "meta": {"v": 2, "w": 3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ch := range changes {
+		got = append(got, ch.String())
+	}
+	want := []string{
+		"~ $.meta.v: 1 -> 2",
+		"+ $.meta.w: 3",
+		"~ $.name: \"a\" -> \"b\"",
+		"+ $.new: null",
+		"- $.old: true",
+		"- $.tags[1]: \"y\"",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestClient_CompareResponses(t *testing.T) {
+	newServer := func(version string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte(`{"version": "` + version + `", "items": [{"id": "` + version + `", "name": "a"}], "echo": ` + string(body) + `}`))
+		}))
+	}
+	v1, v2 := newServer("1"), newServer("2")
+	defer v1.Close()
+	defer v2.Close()
+
+	a, b := newClientOrFatal(t, v1.URL, apiKey), newClientOrFatal(t, v2.URL, apiKey)
+	cmp, err := CompareResponses(context.Background(), a, b, http.MethodPost, "/search", &CompareOptions{
+		Body:   Response{Foo: "bar"},
+		Ignore: []string{"$.items[*].id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.Equal() || len(cmp.Changes) != 1 || cmp.Changes[0].Path != "$.version" {
+		t.Errorf("Expected only the version to differ, got %s", cmp)
+	}
+
+	data, err := json.Marshal(cmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		A struct {
+			StatusCode int `json:"status_code"`
+		}
+		Changes []JSONChange
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.A.StatusCode != 200 || decoded.Changes[0].Op != ChangeModified {
+		t.Errorf("Expected a structured JSON diff, got %s", data)
+	}
+
+	cmp, err = CompareResponses(context.Background(), a, a, http.MethodPost, "/search", &CompareOptions{Body: Response{}})
+	if err != nil || !cmp.Equal() {
+		t.Errorf("Expected equal responses, got %s, %v", cmp, err)
+	}
+}
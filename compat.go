@@ -0,0 +1,114 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import "context"
+
+// This file keeps the original, context-free method set working on top of
+// the context-first core, so callers can migrate one call site at a time.
+
+// JSONClient is the original JSON method set. Both *Client and the
+// *BoundClient returned by Client.Bind implement it, so code written
+// against it can be handed a context-bound client without being rewritten.
+type JSONClient interface {
+	ReadJson(uri string, response interface{}, opts ...RequestOption) error
+	CreateJson(uri string, data interface{}, response interface{}, opts ...RequestOption) error
+	UpdateJson(uri string, data interface{}, response interface{}, opts ...RequestOption) error
+	DeleteJson(uri string, response interface{}, opts ...RequestOption) error
+	PostMultipartJson(uri string, mpf MultipartForm, data interface{}, opts ...RequestOption) error
+}
+
+// ReadJson GETs uri and decodes the response into response. A nil
+// response still sends the request but decodes nothing; pass ToWriter or
+// ToFile to keep the raw body instead. It is ReadJsonContext without a
+// context; new code should prefer that.
+func (c *Client) ReadJson(uri string, response interface{}, opts ...RequestOption) (err error) {
+	return c.ReadJsonContext(context.Background(), uri, response, opts...)
+}
+
+// CreateJson POSTs data as JSON to uri and decodes the response into
+// response. It is CreateJsonContext without a context; new code should
+// prefer that.
+func (c *Client) CreateJson(uri string, data interface{}, response interface{}, opts ...RequestOption) (err error) {
+	return c.CreateJsonContext(context.Background(), uri, data, response, opts...)
+}
+
+// UpdateJson PUTs data as JSON to uri and decodes the response into
+// response. It is UpdateJsonContext without a context; new code should
+// prefer that.
+func (c *Client) UpdateJson(uri string, data interface{}, response interface{}, opts ...RequestOption) (err error) {
+	return c.UpdateJsonContext(context.Background(), uri, data, response, opts...)
+}
+
+// DeleteJson DELETEs uri and decodes the response into response. It is
+// DeleteJsonContext without a context; new code should prefer that.
+func (c *Client) DeleteJson(uri string, response interface{}, opts ...RequestOption) (err error) {
+	return c.DeleteJsonContext(context.Background(), uri, response, opts...)
+}
+
+// PostMultipartJson POSTs mpf to uri and decodes the response into data.
+// It is PostMultipartJsonContext without a context; new code should prefer
+// that.
+func (c *Client) PostMultipartJson(uri string, mpf MultipartForm, data interface{}, opts ...RequestOption) (err error) {
+	return c.PostMultipartJsonContext(context.Background(), uri, mpf, data, opts...)
+}
+
+// PatchJson PATCHes data as JSON to uri and decodes the response into
+// response. It is PatchJsonContext without a context.
+func (c *Client) PatchJson(uri string, data interface{}, response interface{}, opts ...RequestOption) (err error) {
+	return c.PatchJsonContext(context.Background(), uri, data, response, opts...)
+}
+
+// Do sends a request with any method to uri. It is DoContext without a
+// context.
+func (c *Client) Do(method, uri string, body interface{}, response interface{}, opts ...RequestOption) (err error) {
+	return c.DoContext(context.Background(), method, uri, body, response, opts...)
+}
+
+// BoundClient adapts the original method signatures onto a fixed context.
+type BoundClient struct {
+	c   *Client
+	ctx context.Context
+}
+
+// Bind returns a JSONClient whose calls all use ctx. It lets code that still
+// calls ReadJson and friends honor cancellation and deadlines before it is
+// migrated to the Context methods.
+func (c *Client) Bind(ctx context.Context) *BoundClient {
+	return &BoundClient{c: c, ctx: ctx}
+}
+
+func (b *BoundClient) ReadJson(uri string, response interface{}, opts ...RequestOption) error {
+	return b.c.ReadJsonContext(b.ctx, uri, response, opts...)
+}
+
+func (b *BoundClient) CreateJson(uri string, data interface{}, response interface{}, opts ...RequestOption) error {
+	return b.c.CreateJsonContext(b.ctx, uri, data, response, opts...)
+}
+
+func (b *BoundClient) UpdateJson(uri string, data interface{}, response interface{}, opts ...RequestOption) error {
+	return b.c.UpdateJsonContext(b.ctx, uri, data, response, opts...)
+}
+
+func (b *BoundClient) DeleteJson(uri string, response interface{}, opts ...RequestOption) error {
+	return b.c.DeleteJsonContext(b.ctx, uri, response, opts...)
+}
+
+func (b *BoundClient) PostMultipartJson(uri string, mpf MultipartForm, data interface{}, opts ...RequestOption) error {
+	return b.c.PostMultipartJsonContext(b.ctx, uri, mpf, data, opts...)
+}
+
+func (b *BoundClient) PatchJson(uri string, data interface{}, response interface{}, opts ...RequestOption) error {
+	return b.c.PatchJsonContext(b.ctx, uri, data, response, opts...)
+}
+
+func (b *BoundClient) Do(method, uri string, body interface{}, response interface{}, opts ...RequestOption) error {
+	return b.c.DoContext(b.ctx, method, uri, body, response, opts...)
+}
+
+var (
+	_ JSONClient = (*Client)(nil)
+	_ JSONClient = (*BoundClient)(nil)
+)
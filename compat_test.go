@@ -0,0 +1,42 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// legacyFetch stands in for application code written against the original
+// method set.
+func legacyFetch(c JSONClient, data *Response) error {
+	return c.ReadJson("/api/foo", data)
+}
+
+func TestClient_Bind(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: "{\"Foo\": \"bar\"}", Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var data Response
+	if err := legacyFetch(c.Bind(context.Background()), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "bar" {
+		t.Errorf("Expected data.Foo to be \"bar\", got \"%s\"", data.Foo)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := legacyFetch(c.Bind(ctx), &data); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the bound context to cancel the call, got %v", err)
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Compressor encodes request bodies for a single Content-Encoding.
+type Compressor interface {
+	// Encoding returns the Content-Encoding token, e.g. "gzip" or "zstd".
+	Encoding() string
+
+	// NewWriter returns a writer compressing into w. Closing it must flush
+	// all data but not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// Decompressor is implemented by registered Compressors that can also
+// decode responses, e.g. a br or zstd one. Their encoding is then asked
+// for and decoded when CompressionConfig.DecompressResponses is set.
+type Decompressor interface {
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// GzipCompressor compresses with gzip at Level, which defaults to
+// gzip.DefaultCompression when zero.
+type GzipCompressor struct {
+	Level int
+}
+
+func (GzipCompressor) Encoding() string { return "gzip" }
+
+func (g GzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// DeflateCompressor compresses with deflate at Level, which defaults to
+// flate.DefaultCompression when zero.
+type DeflateCompressor struct {
+	Level int
+}
+
+func (DeflateCompressor) Encoding() string { return "deflate" }
+
+func (d DeflateCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := d.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	return flate.NewWriter(w, level)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{
+		"gzip":    GzipCompressor{},
+		"deflate": DeflateCompressor{},
+	}
+)
+
+// RegisterCompressor makes c available to every client under c.Encoding(),
+// replacing any compressor registered for the same encoding.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Encoding()] = c
+}
+
+func lookupCompressor(encoding string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[encoding]
+	return c, ok
+}
+
+// CompressionConfig selects the request body encoding per route.
+type CompressionConfig struct {
+	// Routes maps a URL path prefix to a registered encoding. The longest
+	// matching prefix wins; "" matches every path.
+	Routes map[string]string
+
+	// MinSize is the smallest body that is compressed. Smaller bodies are
+	// sent as is. Bodies are compressed as they are sent, so they are never
+	// held in memory whole; up to MinSize bytes of a body of unknown size
+	// are read first to tell whether it is small.
+	MinSize int
+
+	// DecompressResponses asks for gzip, deflate or Decompressor encoded
+	// responses and decodes them before they are read. Without it only
+	// gzip is handled, by net/http, and only when the request sets no
+	// Accept-Encoding; the limits below then do not apply.
+	DecompressResponses bool
+
+	// MaxDecompressedBytes, when positive, caps the decoded size of a
+	// response body.
+	MaxDecompressedBytes int64
+
+	// MaxRatio caps how many times larger than its encoded form a decoded
+	// body may grow, once it passes 1 MiB. Defaults to
+	// DefaultMaxDecompressionRatio; negative disables the check.
+	MaxRatio float64
+}
+
+// DefaultMaxDecompressionRatio is used when CompressionConfig.MaxRatio is
+// zero. Real payloads rarely compress beyond 50:1, while decompression
+// bombs reach 1000:1.
+const DefaultMaxDecompressionRatio = 200
+
+// ratioSlack is the decoded size below which the ratio is not checked, as
+// small repetitive bodies compress very well.
+const ratioSlack = 1 << 20
+
+// DecompressionError is returned when reading a response body that
+// decodes to more than a CompressionConfig allows.
+type DecompressionError struct {
+	Encoding string
+
+	// Compressed and Decompressed are the bytes read and produced when
+	// the limit was hit.
+	Compressed   int64
+	Decompressed int64
+
+	// MaxBytes is set when MaxDecompressedBytes was exceeded, MaxRatio
+	// when the ratio was.
+	MaxBytes int64
+	MaxRatio float64
+}
+
+func (e *DecompressionError) Error() string {
+	if e.MaxBytes > 0 {
+		return fmt.Sprintf("%s response decompresses to more than %d bytes", e.Encoding, e.MaxBytes)
+	}
+	return fmt.Sprintf("%s response exceeds the decompression ratio of %g (%d bytes from %d)", e.Encoding, e.MaxRatio, e.Decompressed, e.Compressed)
+}
+
+// WithGzip gzips request bodies of at least minSize bytes and decompresses
+// gzip and deflate responses.
+func WithGzip(minSize int) Option {
+	return func(c *Client) {
+		c.Compression = &CompressionConfig{
+			Routes:              map[string]string{"": "gzip"},
+			MinSize:             minSize,
+			DecompressResponses: true,
+		}
+	}
+}
+
+func (cc *CompressionConfig) encodingFor(path string) string {
+	var best, encoding string
+	matched := false
+	for prefix, enc := range cc.Routes {
+		if strings.HasPrefix(path, prefix) && (!matched || len(prefix) > len(best)) {
+			best, encoding, matched = prefix, enc, true
+		}
+	}
+	return encoding
+}
+
+// apply compresses the body of r with the encoding configured for its path.
+// Requests that already carry a Content-Encoding are left alone.
+func (cc *CompressionConfig) apply(r *http.Request) error {
+	if cc.DecompressResponses && r.Header.Get("Accept-Encoding") == "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+	if r.Body == nil || r.Body == http.NoBody || r.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	encoding := cc.encodingFor(r.URL.Path)
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	comp, ok := lookupCompressor(encoding)
+	if !ok {
+		return fmt.Errorf("no compressor registered for encoding %q", encoding)
+	}
+
+	if r.ContentLength > 0 && r.ContentLength < int64(cc.MinSize) {
+		return nil
+	}
+	var head []byte
+	if r.ContentLength <= 0 && cc.MinSize > 0 {
+		// The size is unknown: read up to MinSize bytes to tell.
+		var err error
+		head, err = ioutil.ReadAll(io.LimitReader(r.Body, int64(cc.MinSize)))
+		if err != nil {
+			r.Body.Close()
+			return err
+		}
+		if len(head) < cc.MinSize {
+			r.Body.Close()
+			setBody(r, head)
+			return nil
+		}
+	}
+
+	r.Body = &compressedBody{head: head, src: r.Body, comp: comp}
+	r.ContentLength = -1
+	if getBody := r.GetBody; getBody != nil {
+		r.GetBody = func() (io.ReadCloser, error) {
+			src, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &compressedBody{src: src, comp: comp}, nil
+		}
+	}
+	r.Header.Set("Content-Encoding", encoding)
+	return nil
+}
+
+// compressedBody compresses its source into a pipe once first read, so
+// bodies of any size are sent without being held in memory, and no
+// goroutine is left behind for a request that is never sent.
+type compressedBody struct {
+	head []byte // read from src to check MinSize
+	src  io.ReadCloser
+	comp Compressor
+
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (b *compressedBody) start() {
+	pr, pw := io.Pipe()
+	b.pr = pr
+	go func() {
+		pw.CloseWithError(b.compress(pw))
+	}()
+}
+
+func (b *compressedBody) compress(dst io.Writer) error {
+	defer b.src.Close()
+	w, err := b.comp.NewWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, io.MultiReader(bytes.NewReader(b.head), b.src)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (b *compressedBody) Read(p []byte) (int, error) {
+	b.once.Do(b.start)
+	return b.pr.Read(p)
+}
+
+// Close stops the compression, which fails its next write, or closes the
+// source if it never started.
+func (b *compressedBody) Close() error {
+	b.once.Do(func() {})
+	if b.pr != nil {
+		return b.pr.Close()
+	}
+	return b.src.Close()
+}
+
+// acceptEncoding lists gzip, deflate and the encodings of the registered
+// Decompressors.
+func acceptEncoding() string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	var extra []string
+	for encoding, c := range compressors {
+		if _, ok := c.(Decompressor); ok && encoding != "gzip" && encoding != "deflate" {
+			extra = append(extra, encoding)
+		}
+	}
+	sort.Strings(extra)
+	return strings.Join(append([]string{"gzip", "deflate"}, extra...), ", ")
+}
+
+// decompress replaces an encoded body of res with its decoded content,
+// bounded as cc configures.
+func (cc *CompressionConfig) decompress(res *http.Response) *http.Response {
+	var open func(io.Reader) (io.ReadCloser, error)
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "":
+		return res
+	case "gzip", "x-gzip":
+		open = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+	case "deflate":
+		open = openDeflate
+	default:
+		c, ok := lookupCompressor(encoding)
+		d, isDecompressor := c.(Decompressor)
+		if !ok || !isDecompressor {
+			return res
+		}
+		open = d.NewReader
+	}
+
+	ratio := cc.MaxRatio
+	if ratio == 0 {
+		ratio = DefaultMaxDecompressionRatio
+	}
+	res.Body = &decompressedBody{
+		raw:      &countingReadCloser{ReadCloser: res.Body},
+		open:     open,
+		encoding: encoding,
+		maxBytes: cc.MaxDecompressedBytes,
+		maxRatio: ratio,
+	}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return res
+}
+
+// openDeflate reads HTTP deflate, which should be zlib wrapped but is
+// sent raw by some servers.
+func openDeflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decompressedBody opens its decoder on the first read, so an empty 204
+// or HEAD body needs no valid header.
+type decompressedBody struct {
+	raw  *countingReadCloser
+	open func(io.Reader) (io.ReadCloser, error)
+	dec  io.ReadCloser
+	err  error
+
+	encoding string
+	maxBytes int64
+	maxRatio float64
+	n        int64 // decoded bytes read
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.dec == nil && b.err == nil {
+		b.dec, b.err = b.open(b.raw)
+		if b.err == io.EOF {
+			b.err = nil
+			b.dec = ioutil.NopCloser(strings.NewReader(""))
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.dec.Read(p)
+	b.n += int64(n)
+	if b.maxBytes > 0 && b.n > b.maxBytes {
+		b.err = &DecompressionError{Encoding: b.encoding, Compressed: b.raw.n, Decompressed: b.n, MaxBytes: b.maxBytes}
+	} else if b.maxRatio > 0 && b.n > ratioSlack && float64(b.n) > b.maxRatio*float64(b.raw.n) {
+		b.err = &DecompressionError{Encoding: b.encoding, Compressed: b.raw.n, Decompressed: b.n, MaxRatio: b.maxRatio}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return n, err
+}
+
+// countingReadCloser counts the bytes read through it.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (b *decompressedBody) Close() error {
+	if b.dec != nil {
+		b.dec.Close()
+	}
+	return b.raw.Close()
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// reverseCompressor is a toy encoding used to test registration.
+type reverseCompressor struct{}
+
+func (reverseCompressor) Encoding() string { return "x-reverse" }
+
+func (reverseCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return &reverseWriter{w: w}, nil
+}
+
+type reverseWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (r *reverseWriter) Write(p []byte) (int, error) { return r.buf.Write(p) }
+
+func (r *reverseWriter) Close() error {
+	b := r.buf.Bytes()
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	_, err := r.w.Write(b)
+	return err
+}
+
+func newEncodingServer(t *testing.T, encoding *string, body *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*encoding = r.Header.Get("Content-Encoding")
+		var rd io.Reader = r.Body
+		if *encoding == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rd = gz
+		}
+		b, err := ioutil.ReadAll(rd)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*body = string(b)
+		w.Write([]byte("{}"))
+	}))
+}
+
+func TestClient_CompressionPerRoute(t *testing.T) {
+	RegisterCompressor(reverseCompressor{})
+
+	var encoding, body string
+	server := newEncodingServer(t, &encoding, &body)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Compression = &CompressionConfig{Routes: map[string]string{
+		"":            "gzip",
+		"/api/small":  "identity",
+		"/api/custom": "x-reverse",
+	}}
+
+	data := map[string]string{"Name": "new_name"}
+
+	if err := c.CreateJson("/api/foo", data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "gzip" || body != "{\"Name\":\"new_name\"}" {
+		t.Errorf("Expected gzip body, got %q %q", encoding, body)
+	}
+
+	if err := c.CreateJson("/api/small", data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "" {
+		t.Errorf("Expected no encoding, got %q", encoding)
+	}
+
+	if err := c.CreateJson("/api/custom/1", data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "x-reverse" || body != "}\"eman_wen\":\"emaN\"{" {
+		t.Errorf("Expected reversed body, got %q %q", encoding, body)
+	}
+}
+
+func TestClient_CompressionMinSize(t *testing.T) {
+	var encoding, body string
+	server := newEncodingServer(t, &encoding, &body)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Compression = &CompressionConfig{Routes: map[string]string{"": "gzip"}, MinSize: 1024}
+
+	if err := c.CreateJson("/api/foo", map[string]string{"Name": "new_name"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "" {
+		t.Errorf("Expected small body to be sent uncompressed, got %q", encoding)
+	}
+}
+
+func TestClient_CompressionUnknownEncoding(t *testing.T) {
+	var encoding, body string
+	server := newEncodingServer(t, &encoding, &body)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Compression = &CompressionConfig{Routes: map[string]string{"": "zstd-unregistered"}}
+
+	if err := c.CreateJson("/api/foo", map[string]string{"Name": "new_name"}, nil); err == nil {
+		t.Errorf("Expected unknown encoding to fail")
+	}
+}
+
+func TestClient_WithGzip(t *testing.T) {
+	var gotEncoding, gotAccept, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding, gotAccept = r.Header.Get("Content-Encoding"), r.Header.Get("Accept-Encoding")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(zr)
+		gotBody = string(body)
+
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"Foo": "zipped"}`))
+		zw.Close()
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithGzip(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data Response
+	if err := c.CreateJson("/api/foo", Response{Foo: "bar"}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if gotEncoding != "gzip" || gotBody != `{"Foo":"bar"}` {
+		t.Errorf("Expected a gzipped request body, got %q encoded %q", gotBody, gotEncoding)
+	}
+	if gotAccept != "gzip, deflate" {
+		t.Errorf("Expected Accept-Encoding \"gzip, deflate\", got %q", gotAccept)
+	}
+	if data.Foo != "zipped" {
+		t.Errorf("Expected the response to be decompressed, got %q", data.Foo)
+	}
+}
+
+func TestClient_DecompressDeflate(t *testing.T) {
+	for _, zlibWrapped := range []bool{true, false} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "deflate")
+			var zw io.WriteCloser
+			if zlibWrapped {
+				zw = zlib.NewWriter(w)
+			} else {
+				zw, _ = flate.NewWriter(w, flate.DefaultCompression)
+			}
+			zw.Write([]byte(`{"Foo": "deflated"}`))
+			zw.Close()
+		}))
+
+		c := newClientOrFatal(t, server.URL, apiKey)
+		c.Compression = &CompressionConfig{DecompressResponses: true}
+
+		var data Response
+		if err := c.ReadJson("/api/foo", &data); err != nil {
+			t.Errorf("zlib %v: %v", zlibWrapped, err)
+		} else if data.Foo != "deflated" {
+			t.Errorf("zlib %v: expected the response to be decompressed, got %q", zlibWrapped, data.Foo)
+		}
+		server.Close()
+	}
+}
+
+func newGzipServer(body []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write(body)
+		zw.Close()
+	}))
+}
+
+func TestClient_DecompressionBomb(t *testing.T) {
+	bomb := append([]byte(`{"Foo": "`), bytes.Repeat([]byte("0"), 8<<20)...)
+	bomb = append(bomb, `"}`...)
+	server := newGzipServer(bomb)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Compression = &CompressionConfig{DecompressResponses: true}
+
+	var data Response
+	err := c.ReadJson("/api/foo", &data)
+	var derr *DecompressionError
+	if !errors.As(err, &derr) || derr.MaxRatio != DefaultMaxDecompressionRatio || derr.Encoding != "gzip" {
+		t.Fatalf("Expected the ratio to be exceeded, got %v", err)
+	}
+	if derr.Decompressed > 2<<20 {
+		t.Errorf("Expected decoding to stop early, got %d bytes", derr.Decompressed)
+	}
+
+	c.Compression.MaxRatio = -1
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Errorf("Expected a disabled ratio to pass, got %v", err)
+	}
+
+	c.Compression.MaxDecompressedBytes = 1 << 20
+	err = c.ReadJson("/api/foo", &data)
+	if !errors.As(err, &derr) || derr.MaxBytes != 1<<20 {
+		t.Errorf("Expected the size limit to be exceeded, got %v", err)
+	}
+}
+
+// reverseCodec is reverseCompressor with a decoder, under its own name so
+// it can be unregistered.
+type reverseCodec struct {
+	reverseCompressor
+}
+
+func (reverseCodec) Encoding() string { return "x-rev" }
+
+func (reverseCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	b, err := ioutil.ReadAll(r)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), err
+}
+
+func TestClient_RegisteredDecompressor(t *testing.T) {
+	RegisterCompressor(reverseCodec{})
+	defer func() {
+		compressorsMu.Lock()
+		delete(compressors, "x-rev")
+		compressorsMu.Unlock()
+	}()
+
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Encoding", "x-rev")
+		w.Write([]byte(`}"desrever" :"ooF"{`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Compression = &CompressionConfig{DecompressResponses: true}
+
+	var data Response
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "reversed" || accept != "gzip, deflate, x-rev" {
+		t.Errorf("Expected the registered decoder to be used, got %q with Accept-Encoding %q", data.Foo, accept)
+	}
+}
+
+func TestCompressionConfig_Streams(t *testing.T) {
+	content := bytes.Repeat([]byte("relax "), 200000)
+	src := &countingReader{r: bytes.NewReader(content)}
+	r, err := http.NewRequest(http.MethodPost, "http://example.com/upload", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &CompressionConfig{Routes: map[string]string{"": "gzip"}, MinSize: 16}
+	if err := cc.apply(r); err != nil {
+		t.Fatal(err)
+	}
+	if src.n > 16 || r.ContentLength != -1 || r.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected the body to be compressed as it is sent, read %d bytes first", src.n)
+	}
+
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if !bytes.Equal(got, content) {
+		t.Errorf("Expected the whole body to be compressed, got %d of %d bytes", len(got), len(content))
+	}
+
+	replayable, _ := http.NewRequest(http.MethodPost, "http://example.com/upload", bytes.NewReader(content))
+	if err := cc.apply(replayable); err != nil {
+		t.Fatal(err)
+	}
+	replayable.Body.Close()
+	body, err := replayable.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if gz, err = gzip.NewReader(body); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = ioutil.ReadAll(gz); !bytes.Equal(got, content) {
+		t.Errorf("Expected GetBody to compress the body again, got %d bytes", len(got))
+	}
+}
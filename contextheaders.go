@@ -0,0 +1,46 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// HeaderExtractor derives a header value from a request context. It returns
+// false when ctx carries nothing to send.
+type HeaderExtractor func(ctx context.Context) (string, bool)
+
+// FromContextKey returns a HeaderExtractor for the value stored under key,
+// which must be a string or a fmt.Stringer.
+func FromContextKey(key interface{}) HeaderExtractor {
+	return func(ctx context.Context) (string, bool) {
+		switch v := ctx.Value(key).(type) {
+		case string:
+			return v, v != ""
+		case fmt.Stringer:
+			s := v.String()
+			return s, s != ""
+		}
+		return "", false
+	}
+}
+
+// contextHeaders returns the headers extracted from the context of r.
+func (c *Client) contextHeaders(r *http.Request) http.Header {
+	if len(c.ContextHeaders) == 0 {
+		return nil
+	}
+
+	ctx := r.Context()
+	h := make(http.Header)
+	for header, extract := range c.ContextHeaders {
+		if v, ok := extract(ctx); ok {
+			h.Set(header, v)
+		}
+	}
+	return h
+}
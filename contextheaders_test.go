@@ -0,0 +1,50 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestClient_ContextHeaders(t *testing.T) {
+	type key string
+
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ContextHeaders = map[string]HeaderExtractor{
+		"X-User-ID":       FromContextKey(key("user")),
+		"Accept-Language": FromContextKey(key("locale")),
+		"X-Static": func(ctx context.Context) (string, bool) {
+			return "static", true
+		},
+	}
+
+	ctx := context.WithValue(context.Background(), key("user"), "u-42")
+	req, err := c.MakeRequest(http.MethodGet, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Static", "explicit")
+
+	if _, err := c.GetResponse(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := got.Header.Get("X-User-ID"); v != "u-42" {
+		t.Errorf("Expected X-User-ID \"u-42\", got %q", v)
+	}
+	if _, ok := got.Header["Accept-Language"]; ok {
+		t.Errorf("Expected no Accept-Language header when context has no locale")
+	}
+	if v := got.Header.Get("X-Static"); v != "explicit" {
+		t.Errorf("Expected explicit header to win, got %q", v)
+	}
+}
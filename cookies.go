@@ -0,0 +1,172 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithCookieJar keeps the cookies servers set in jar and sends them back,
+// so session cookies survive across calls. A nil jar is a new in-memory
+// one; see NewCookieJar for one persisted to a CookieStore. Clients derived
+// with Clone and Sub share the jar.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Client) {
+		if jar == nil {
+			jar, _ = cookiejar.New(nil)
+		}
+		c.jar = jar
+	}
+}
+
+// StoredCookie is a cookie as persisted by a CookieStore, with the URL
+// that set it.
+type StoredCookie struct {
+	URL    string
+	Cookie *http.Cookie
+}
+
+// CookieStore persists the cookies of a CookieJar. Implementations must be
+// safe for concurrent use.
+type CookieStore interface {
+	Load() ([]StoredCookie, error)
+	Save(cookies []StoredCookie) error
+}
+
+// FileCookieStore is a CookieStore keeping cookies as JSON in the file at
+// Path. A missing file holds no cookies.
+type FileCookieStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileCookieStore) Load() ([]StoredCookie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cookies []StoredCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+func (s *FileCookieStore) Save(cookies []StoredCookie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, data)
+}
+
+// CookieJar is an http.CookieJar saving every change to a CookieStore, so
+// a session outlives the process.
+type CookieJar struct {
+	jar   *cookiejar.Jar
+	store CookieStore
+
+	mu      sync.Mutex
+	cookies map[cookieKey]StoredCookie
+	err     error
+}
+
+type cookieKey struct {
+	host, domain, path, name string
+}
+
+// NewCookieJar returns a CookieJar holding the cookies loaded from store.
+func NewCookieJar(store CookieStore) (*CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	j := &CookieJar{jar: jar, store: store, cookies: make(map[cookieKey]StoredCookie)}
+
+	stored, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, sc := range stored {
+		u, err := url.Parse(sc.URL)
+		if err != nil || sc.Cookie == nil || expired(sc.Cookie, now) {
+			continue
+		}
+		jar.SetCookies(u, []*http.Cookie{sc.Cookie})
+		j.cookies[keyOf(u, sc.Cookie)] = sc
+	}
+	return j, nil
+}
+
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// SetCookies stores cookies and saves the jar. A failed save is reported by
+// Err.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range cookies {
+		key := keyOf(u, c)
+		if expired(c, now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if c.MaxAge > 0 {
+			// Persist the deadline rather than restarting it on load.
+			cp := *c
+			cp.Expires, cp.MaxAge = now.Add(time.Duration(c.MaxAge)*time.Second), 0
+			c = &cp
+		}
+		origin := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+		j.cookies[key] = StoredCookie{URL: origin.String(), Cookie: c}
+	}
+
+	all := make([]StoredCookie, 0, len(j.cookies))
+	for _, sc := range j.cookies {
+		if !expired(sc.Cookie, now) {
+			all = append(all, sc)
+		}
+	}
+	j.err = j.store.Save(all)
+}
+
+// Err returns the error of the last save, if it failed.
+func (j *CookieJar) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+func keyOf(u *url.URL, c *http.Cookie) cookieKey {
+	return cookieKey{host: u.Hostname(), domain: c.Domain, path: c.Path, name: c.Name}
+}
+
+func expired(c *http.Cookie, now time.Time) bool {
+	return c.MaxAge < 0 || !c.Expires.IsZero() && c.Expires.Before(now)
+}
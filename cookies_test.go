@@ -0,0 +1,105 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newSessionServer issues a session cookie on POST /login and answers
+// other requests with the session it is sent, or 401 without one.
+func newSessionServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/", MaxAge: 3600})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			http.Error(w, "no session", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"Foo": "` + cookie.Value + `"}`))
+	}))
+}
+
+func TestClient_CookieJar(t *testing.T) {
+	server := newSessionServer()
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithCookieJar(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateJson("/login", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var response Response
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "s1" {
+		t.Errorf("Expected the session cookie to be sent, got %q", response.Foo)
+	}
+
+	if err := c.Sub("api").ReadJson("foo", &response); err != nil {
+		t.Errorf("Expected derived clients to share the jar, got %s", err)
+	}
+}
+
+func TestCookieJar_Persisted(t *testing.T) {
+	server := newSessionServer()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "relax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &FileCookieStore{Path: filepath.Join(dir, "cookies.json")}
+
+	jar, err := NewCookieJar(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(server.URL, apiKey, WithCookieJar(jar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateJson("/login", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if jar.Err() != nil {
+		t.Fatal(jar.Err())
+	}
+
+	// A new process loads the session from the store.
+	jar, err = NewCookieJar(&FileCookieStore{Path: store.Path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = NewClient(server.URL, apiKey, WithCookieJar(jar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "s1" {
+		t.Errorf("Expected the persisted session, got %q", response.Foo)
+	}
+	stored, _ := store.Load()
+	if len(stored) != 1 || stored[0].Cookie.MaxAge != 0 || stored[0].Cookie.Expires.IsZero() {
+		t.Errorf("Expected the cookie to be stored with its deadline, got %+v", stored)
+	}
+}
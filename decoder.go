@@ -0,0 +1,41 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+)
+
+// Decoder decodes a response body into v.
+type Decoder interface {
+	Decode(r io.Reader, v interface{}) error
+}
+
+// DecoderFunc adapts a function to the Decoder interface.
+type DecoderFunc func(r io.Reader, v interface{}) error
+
+func (f DecoderFunc) Decode(r io.Reader, v interface{}) error {
+	return f(r, v)
+}
+
+// JSONDecoder decodes JSON bodies. It is the default.
+var JSONDecoder Decoder = jsonDecoder{}
+
+// XMLDecoder decodes XML bodies.
+var XMLDecoder Decoder = xmlDecoder{}
+
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlDecoder struct{}
+
+func (xmlDecoder) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_WithDecoder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/json", responseHandler{Method: http.MethodGet, Message: "{\"Foo\": \"json\"}", Path: "/api/json"})
+	mux.Handle("/api/xml", responseHandler{Method: http.MethodGet, Message: "<Response><Foo>xml</Foo></Response>", Path: "/api/xml"})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var data Response
+	if err := c.ReadJson("/api/xml", &data, WithDecoder(XMLDecoder)); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "xml" {
+		t.Errorf("Expected data.Foo to be \"xml\", got \"%s\"", data.Foo)
+	}
+
+	if err := c.ReadJson("/api/json", &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "json" {
+		t.Errorf("Expected data.Foo to be \"json\", got \"%s\"", data.Foo)
+	}
+
+	c.Decoder = XMLDecoder
+	if err := c.ReadJson("/api/json", &data); err == nil {
+		t.Errorf("Expected JSON body to fail with the client's XML decoder")
+	}
+}
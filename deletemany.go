@@ -0,0 +1,196 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrDeleteNotConfirmed is returned by DeleteMany when the Confirm callback
+// declines the deletes.
+var ErrDeleteNotConfirmed = errors.New("deletes not confirmed")
+
+var errDeleteSkipped = errors.New("skipped after an earlier delete failed")
+
+// DeleteOptions configures DeleteMany.
+type DeleteOptions struct {
+	// Concurrency is the number of deletes in flight at once. Defaults to
+	// DefaultParallelism.
+	Concurrency int
+
+	// Retry, when set, replaces the client's RetryPolicy for each delete.
+	Retry *RetryPolicy
+
+	// Confirm, when set, is called with every URI before anything is
+	// deleted. Returning false deletes nothing.
+	Confirm func(uris []string) bool
+
+	// IgnoreNotFound counts a 404 as deleted, so reruns of a cleanup that
+	// was interrupted do not fail on what is already gone.
+	IgnoreNotFound bool
+
+	// StopOnError skips the deletes not yet started once one fails.
+	StopOnError bool
+
+	// Options are applied to every delete request.
+	Options []RequestOption
+}
+
+// DeleteOutcome is the outcome of deleting one URI.
+type DeleteOutcome struct {
+	URI      string
+	Status   int
+	Attempts int
+	Err      error
+
+	// NotFound reports a 404, which is not an error with IgnoreNotFound.
+	NotFound bool
+
+	// Skipped reports that the URI was not deleted because an earlier
+	// delete failed and StopOnError is set.
+	Skipped bool
+}
+
+// DeleteReport combines the outcomes of DeleteMany, in the order of the
+// URIs given.
+type DeleteReport struct {
+	Outcomes []DeleteOutcome
+
+	// Deleted counts the successful deletes, NotFound the 404s ignored
+	// with IgnoreNotFound, Failed the deletes that failed and Skipped
+	// those never sent.
+	Deleted  int
+	NotFound int
+	Failed   int
+	Skipped  int
+}
+
+// FailedURIs returns the URIs that failed or were skipped, e.g. to retry
+// them later.
+func (r *DeleteReport) FailedURIs() []string {
+	var uris []string
+	for _, o := range r.Outcomes {
+		if o.Err != nil {
+			uris = append(uris, o.URI)
+		}
+	}
+	return uris
+}
+
+func (r *DeleteReport) String() string {
+	return fmt.Sprintf("%d deleted, %d not found, %d failed, %d skipped", r.Deleted, r.NotFound, r.Failed, r.Skipped)
+}
+
+// ItemURIs returns the URI of each id below base, with the id escaped as a
+// single path segment:
+//
+//	relax.ItemURIs("/api/users", []int{1, 2}) == []string{"/api/users/1", "/api/users/2"}
+func ItemURIs[T any](base string, ids []T) []string {
+	base = strings.TrimSuffix(base, "/")
+	uris := make([]string, len(ids))
+	for i, id := range ids {
+		uris[i] = base + "/" + url.PathEscape(fmt.Sprint(id))
+	}
+	return uris
+}
+
+// DeleteMany deletes every URI with bounded parallelism and reports the
+// outcome of each. DELETE is idempotent, so the client's RetryPolicy, or
+// opts.Retry, retries each delete on its own. If any delete failed, the
+// error joins their CallErrors. Nothing is deleted if opts.Confirm
+// declines. opts may be nil.
+func (c *Client) DeleteMany(ctx context.Context, uris []string, opts *DeleteOptions) (*DeleteReport, error) {
+	if opts == nil {
+		opts = &DeleteOptions{}
+	}
+	if opts.Confirm != nil && !opts.Confirm(append([]string(nil), uris...)) {
+		return &DeleteReport{}, ErrDeleteNotConfirmed
+	}
+	if opts.Retry != nil {
+		ctx = context.WithValue(ctx, retryPolicyContextKey{}, opts.Retry)
+	}
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = DefaultParallelism
+	}
+	if workers > len(uris) {
+		workers = len(uris)
+	}
+
+	report := &DeleteReport{Outcomes: make([]DeleteOutcome, len(uris))}
+	var mu sync.Mutex
+	failed := false
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed && opts.StopOnError
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				out := &report.Outcomes[i]
+				out.URI = uris[i]
+				if stopped() {
+					out.Skipped = true
+					out.Err = errDeleteSkipped
+					continue
+				}
+				c.deleteOne(ctx, out, opts)
+				if out.Err != nil {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range uris {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for i, out := range report.Outcomes {
+		switch {
+		case out.Skipped:
+			report.Skipped++
+		case out.Err != nil:
+			report.Failed++
+		case out.NotFound:
+			report.NotFound++
+		default:
+			report.Deleted++
+		}
+		if out.Err != nil {
+			errs = append(errs, &CallError{Index: i, Method: http.MethodDelete, URI: out.URI, Err: out.Err})
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+func (c *Client) deleteOne(ctx context.Context, out *DeleteOutcome, opts *DeleteOptions) {
+	var result Result
+	ropts := append(opts.Options[:len(opts.Options):len(opts.Options)], WithResult(&result))
+	out.Err = c.DoContext(ctx, http.MethodDelete, out.URI, nil, nil, ropts...)
+	out.Status, out.Attempts = result.StatusCode, result.Attempts
+	if IsNotFound(out.Err) {
+		out.NotFound = true
+		if opts.IgnoreNotFound {
+			out.Err = nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_DeleteMany(t *testing.T) {
+	var mu sync.Mutex
+	deleted := map[string]int{}
+	var inflight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		deleted[r.URL.Path]++
+		calls := deleted[r.URL.Path]
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/items/gone":
+			http.NotFound(w, r)
+		case "/items/flaky":
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/items/locked":
+			http.Error(w, "locked", http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	uris := ItemURIs("/items/", []string{"1", "2", "3", "gone", "flaky", "locked"})
+
+	var confirmed []string
+	report, err := c.DeleteMany(context.Background(), uris, &DeleteOptions{
+		Concurrency:    2,
+		Retry:          &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Millisecond)},
+		IgnoreNotFound: true,
+		Confirm:        func(u []string) bool { confirmed = u; return true },
+	})
+	if len(confirmed) != 6 {
+		t.Errorf("Expected to confirm 6 deletes, got %v", confirmed)
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 deletes in flight, got %d", peak)
+	}
+	if report.String() != "4 deleted, 1 not found, 1 failed, 0 skipped" {
+		t.Errorf("Unexpected report %s", report)
+	}
+	if failed := report.FailedURIs(); len(failed) != 1 || failed[0] != "/items/locked" {
+		t.Errorf("Expected the locked item to fail, got %v", failed)
+	}
+	if out := report.Outcomes[4]; out.Attempts != 2 || out.Err != nil || out.Status != http.StatusNoContent {
+		t.Errorf("Expected the flaky delete to succeed on retry, got %+v", out)
+	}
+	if out := report.Outcomes[3]; !out.NotFound || out.Err != nil {
+		t.Errorf("Expected the 404 to be ignored, got %+v", out)
+	}
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Index != 5 || StatusCode(err) != http.StatusConflict {
+		t.Errorf("Expected a CallError for the locked item, got %v", err)
+	}
+
+	mu.Lock()
+	before := len(deleted)
+	mu.Unlock()
+	report, err = c.DeleteMany(context.Background(), uris, &DeleteOptions{Confirm: func([]string) bool { return false }})
+	if !errors.Is(err, ErrDeleteNotConfirmed) || len(report.Outcomes) != 0 || len(deleted) != before {
+		t.Errorf("Expected nothing to be deleted without confirmation, got %v", err)
+	}
+}
+
+func TestClient_DeleteManyStopOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/2") {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	report, err := c.DeleteMany(context.Background(), ItemURIs("/items", []int{1, 2, 3, 4}), &DeleteOptions{Concurrency: 1, StopOnError: true})
+	if err == nil || report.String() != "1 deleted, 0 not found, 1 failed, 2 skipped" {
+		t.Errorf("Expected the deletes after the 404 to be skipped, got %s: %v", report, err)
+	}
+	failed := report.FailedURIs()
+	sort.Strings(failed)
+	if fmt.Sprint(failed) != "[/items/2 /items/3 /items/4]" {
+		t.Errorf("Unexpected failed URIs %v", failed)
+	}
+}
+
+func TestItemURIs(t *testing.T) {
+	got := ItemURIs("/api/users", []string{"42", "a/b c"})
+	if fmt.Sprint(got) != "[/api/users/42 /api/users/a%2Fb%20c]" {
+		t.Errorf("Unexpected URIs %v", got)
+	}
+}
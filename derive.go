@@ -0,0 +1,174 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ownedConfig flags the maps a derived client has copied from its parent
+// before changing them.
+type ownedConfig uint8
+
+const (
+	ownsDefaultHeader ownedConfig = 1 << iota
+	ownsPresets
+	ownsErrorCodes
+)
+
+// Clone returns a client with the configuration of c, changed by opts. It
+// is cheap enough to call per request: the clone shares the connection
+// pool, Hosts overrides and runtime state (health, stats, throttling, rate
+// limiting, replay cache) of c, and shares its header, preset and error code
+// maps until an option changes them. Last* fields start empty. Closing c
+// closes the clone, but closing the clone leaves c open. Options Clone
+// cannot apply, such as an unknown experiment, fail every request of the
+// clone.
+//
+// Clone may be called while c is in use. Changing the exported fields of
+// the clone does not affect c, except for the contents of maps and pointers
+// it shares, which must be replaced rather than modified.
+func (c *Client) Clone(opts ...Option) *Client {
+	d := &Client{
+		url:               c.url,
+		apiKey:            c.apiKey,
+		clientState:       c.clientState,
+		shutdown:          &shutdown{parent: c.shutdown},
+		invalid:           c.invalid,
+		Auth:              c.Auth,
+		KeyAuth:           c.KeyAuth,
+		SecondaryAPIKey:   c.SecondaryAPIKey,
+		KeepLastBody:      c.KeepLastBody,
+		MaxResponseBytes:  c.MaxResponseBytes,
+		Cache:             c.Cache,
+		CacheScope:        c.CacheScope,
+		CacheHints:        c.CacheHints,
+		RequestID:         c.RequestID,
+		IDs:               c.IDs,
+		Clock:             c.Clock,
+		PathJoin:          c.PathJoin,
+		Normalize:         c.Normalize,
+		Location:          c.Location,
+		Hosts:             c.Hosts,
+		Compression:       c.Compression,
+		HealthTracking:    c.HealthTracking,
+		Decoder:           c.Decoder,
+		TraceTiming:       c.TraceTiming,
+		Codec:             c.Codec,
+		ErrorExtractor:    c.ErrorExtractor,
+		ErrorCodes:        c.ErrorCodes,
+		ErrorCodeOf:       c.ErrorCodeOf,
+		Validators:        c.Validators,
+		VersionHeader:     c.VersionHeader,
+		CacheWrites:       c.CacheWrites,
+		ErrorPreviewBytes: c.ErrorPreviewBytes,
+		IdempotencyHeader: c.IdempotencyHeader,
+		ReplayTTL:         c.ReplayTTL,
+		Throttle:          c.Throttle,
+		SchemaDrift:       c.SchemaDrift,
+		DefaultQuery:      c.DefaultQuery,
+		QueryVars:         c.QueryVars,
+		QueryEncoding:     c.QueryEncoding,
+		Negotiation:       c.Negotiation,
+		Experimental:      c.Experimental,
+		DefaultHeader:     c.DefaultHeader,
+		Retry:             c.Retry,
+		CollectStats:      c.CollectStats,
+		Payloads:          c.Payloads,
+		ContextHeaders:    c.ContextHeaders,
+		RateLimit:         c.RateLimit,
+		Tenant:            c.Tenant,
+		Failover:          c.Failover,
+		Maintenance:       c.Maintenance,
+		CircuitBreaker:    c.CircuitBreaker,
+		Policies:          c.Policies,
+		Journal:           c.Journal,
+		Informational:     c.Informational,
+		Archive:           c.Archive,
+		Fixtures:          c.Fixtures,
+		Logging:           c.Logging,
+		Telemetry:         c.Telemetry,
+		Presets:           c.Presets,
+		HeaderPolicy:      c.HeaderPolicy,
+		URLSigner:         c.URLSigner,
+		BufferBodies:      c.BufferBodies,
+		Environment:       c.Environment,
+
+		// The full slice expression makes Use reallocate instead of
+		// writing into the parent's backing array.
+		middleware: c.middleware[:len(c.middleware):len(c.middleware)],
+
+		httpClient:        c.httpClient,
+		timeout:           c.timeout,
+		timeouts:          c.timeouts,
+		tlsConfig:         c.tlsConfig,
+		jar:               c.jar,
+		userCheckRedirect: c.userCheckRedirect,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if err := checkExperiments(d.Experimental); err != nil && d.invalid == nil {
+		d.invalid = err
+	}
+
+	if d.httpClient != c.httpClient || d.timeout != c.timeout || d.timeouts != c.timeouts || d.tlsConfig != c.tlsConfig || d.jar != c.jar {
+		d.client = d.buildHTTPClient()
+		return d
+	}
+	hc := *c.client
+	hc.CheckRedirect = d.checkRedirect
+	d.client = &hc
+	return d
+}
+
+// Sub returns a clone of c whose base URL has path appended, e.g. a client
+// for https://host/api/v1 and path "users" gives one for
+// https://host/api/v1/users/. The new base URL ends in "/" so relative
+// URIs resolve below it. path is not escaped; use JoinPath for
+// segments holding user input.
+func (c *Client) Sub(path string, opts ...Option) *Client {
+	d := c.Clone(opts...)
+	ref, err := url.Parse(path)
+	if err != nil || ref.IsAbs() {
+		ref = &url.URL{Path: path}
+	}
+	u := appendURL(c.url, &url.URL{Path: ref.Path, RawPath: ref.RawPath})
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+		if u.RawPath != "" {
+			u.RawPath += "/"
+		}
+	}
+	d.url = u
+	return d
+}
+
+// ownDefaultHeader makes DefaultHeader safe to modify.
+func (c *Client) ownDefaultHeader() http.Header {
+	if c.owned&ownsDefaultHeader == 0 {
+		c.DefaultHeader = c.DefaultHeader.Clone()
+		if c.DefaultHeader == nil {
+			c.DefaultHeader = make(http.Header)
+		}
+		c.owned |= ownsDefaultHeader
+	}
+	return c.DefaultHeader
+}
+
+// ownPresets makes Presets safe to modify.
+func (c *Client) ownPresets() map[string]*RequestPreset {
+	if c.owned&ownsPresets == 0 {
+		presets := make(map[string]*RequestPreset, len(c.Presets)+1)
+		for k, v := range c.Presets {
+			presets[k] = v
+		}
+		c.Presets = presets
+		c.owned |= ownsPresets
+	}
+	return c.Presets
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestClient_Clone(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithDefaultHeader("X-Env", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CollectStats = true
+
+	d := c.Clone(WithDefaultHeader("X-Tenant", "acme"))
+	d.Use(func(next RoundTripFunc) RoundTripFunc { return next })
+
+	if err := d.ReadJson("/", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Get("X-Env") != "test" || got.Header.Get("X-Tenant") != "acme" {
+		t.Errorf("Expected the clone to send both headers, got %v", got.Header)
+	}
+
+	if err := c.ReadJson("/", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Get("X-Tenant") != "" {
+		t.Errorf("Expected the parent to be unaffected by the clone's header")
+	}
+	if len(c.middleware) != 0 {
+		t.Errorf("Expected the parent to be unaffected by the clone's middleware")
+	}
+
+	if d.client == c.client || d.client.Transport != c.client.Transport {
+		t.Errorf("Expected the clone to share the transport but not the http.Client")
+	}
+	if n := c.Stats().Routes[0].Count; n != 2 {
+		t.Errorf("Expected stats to be shared, got count %d", n)
+	}
+}
+
+func TestClient_Sub(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL+"/api/v1", apiKey)
+	users := c.Sub("users").Sub(JoinPath("a b"))
+
+	if err := users.ReadJson("orders", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.EscapedPath() != "/api/v1/users/a%20b/orders" {
+		t.Errorf("Unexpected path %q", got.URL.EscapedPath())
+	}
+}
+
+func TestClient_CloneConcurrent(t *testing.T) {
+	var got *http.Request
+	var mu sync.Mutex
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := c.Clone(WithDefaultHeader("X-N", "1"))
+			mu.Lock()
+			defer mu.Unlock()
+			if err := d.ReadJson("/", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkClient_Clone(b *testing.B) {
+	c, err := NewClient(goodURL, apiKey, WithDefaultHeader("X-Env", "bench"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Clone()
+	}
+}
+
+func BenchmarkClient_CloneWithHeader(b *testing.B) {
+	c, err := NewClient(goodURL, apiKey, WithDefaultHeader("X-Env", "bench"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	opt := WithDefaultHeader("X-Tenant", "acme")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Clone(opt)
+	}
+}
+
+func BenchmarkClient_Sub(b *testing.B) {
+	c := newClientOrFatalB(b, goodURL+"/api/v1")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Sub("users")
+	}
+}
+
+func newClientOrFatalB(b *testing.B, url string) *Client {
+	c, err := NewClient(url, apiKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return c
+}
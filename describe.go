@@ -0,0 +1,222 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Description is the effective configuration of a client, safe to log or
+// attach to a support ticket: credentials are only identified by
+// fingerprint and header values are left out.
+type Description struct {
+	// BaseURL is the URL requests are resolved against, without user info.
+	BaseURL string
+
+	// Environment is Client.Environment.
+	Environment string
+
+	// Auth names how requests are authenticated: "api key" with the
+	// KeyAuth scheme, "oauth2" or the type of a custom Authenticator.
+	Auth string
+
+	// KeyFingerprints identify the API keys in use by the start of their
+	// SHA-256, primary first.
+	KeyFingerprints []string
+
+	// Timeout is the overall timeout of a request, or zero for none.
+	Timeout time.Duration
+
+	// Timeouts are the per-phase timeouts given WithTimeouts.
+	Timeouts Timeouts
+
+	// Retry is nil when retries are disabled.
+	Retry *RetryDescription
+
+	// Middleware names the middleware in the order they run.
+	Middleware []string
+
+	// Policies names the request policies, global ones first.
+	Policies []string
+
+	// Headers are the names of the default headers.
+	Headers []string
+
+	// Features lists the optional behaviors enabled, like "cache" or
+	// "circuit breaker", sorted.
+	Features []string
+
+	// Experiments lists the experiments enabled, sorted.
+	Experiments []string
+}
+
+// RetryDescription describes a RetryPolicy.
+type RetryDescription struct {
+	MaxAttempts        int
+	StatusCodes        []int
+	MaxRetryAfter      time.Duration
+	RetryNonIdempotent bool
+	IdempotencyKeys    bool
+}
+
+// Describe reports the effective configuration of c.
+func (c *Client) Describe() Description {
+	d := Description{
+		BaseURL:     redactURL(c.url, nil),
+		Environment: c.Environment,
+		Auth:        c.authMode(),
+		Timeout:     c.client.Timeout,
+		Timeouts:    c.timeouts,
+	}
+	if c.Auth == nil {
+		d.KeyFingerprints = append(d.KeyFingerprints, keyFingerprint(c.apiKey))
+		if c.SecondaryAPIKey != "" {
+			d.KeyFingerprints = append(d.KeyFingerprints, keyFingerprint(c.SecondaryAPIKey))
+		}
+	}
+
+	if p := c.Retry; p != nil && p.MaxAttempts >= 2 {
+		d.Retry = &RetryDescription{
+			MaxAttempts:        p.MaxAttempts,
+			StatusCodes:        p.StatusCodes,
+			MaxRetryAfter:      p.MaxRetryAfter,
+			RetryNonIdempotent: p.RetryNonIdempotent,
+			IdempotencyKeys:    p.IdempotencyKeys,
+		}
+		if d.Retry.StatusCodes == nil {
+			d.Retry.StatusCodes = DefaultRetryStatusCodes
+		}
+		if d.Retry.MaxRetryAfter == 0 {
+			d.Retry.MaxRetryAfter = DefaultMaxRetryAfter
+		}
+	}
+
+	for _, mw := range c.middleware {
+		d.Middleware = append(d.Middleware, funcName(mw))
+	}
+	for _, p := range c.allPolicies() {
+		if f, ok := p.(PolicyFunc); ok {
+			d.Policies = append(d.Policies, funcName(f))
+		} else {
+			d.Policies = append(d.Policies, fmt.Sprintf("%T", p))
+		}
+	}
+	for name := range c.DefaultHeader {
+		d.Headers = append(d.Headers, name)
+	}
+	sort.Strings(d.Headers)
+
+	features := map[string]bool{
+		"archive":         c.Archive != nil,
+		"buffered bodies": c.BufferBodies > 0,
+		"cache":           c.Cache != nil,
+		"circuit breaker": c.CircuitBreaker != nil,
+		"compression":     c.Compression != nil,
+		"cookies":         c.client.Jar != nil,
+		"failover":        c.Failover != nil,
+		"fixtures":        c.Fixtures != nil,
+		"health tracking": c.HealthTracking != nil,
+		"journal":         c.Journal != nil,
+		"logging":         c.Logging != nil,
+		"maintenance":     c.Maintenance != nil,
+		"payload alerts":  c.Payloads != nil,
+		"query encoding":  c.QueryEncoding != nil,
+		"rate limit":      c.RateLimit != nil,
+		"renegotiation":   c.Negotiation != nil,
+		"replay":          c.ReplayTTL > 0,
+		"request id":      c.RequestID != nil,
+		"schema drift":    c.SchemaDrift != nil,
+		"stats":           c.CollectStats,
+		"telemetry":       c.Telemetry != nil,
+		"throttle":        c.Throttle != nil,
+		"url signing":     c.URLSigner != nil,
+		"validators":      c.Validators != nil,
+	}
+	for name, on := range features {
+		if on {
+			d.Features = append(d.Features, name)
+		}
+	}
+	sort.Strings(d.Features)
+	d.Experiments = c.enabledExperiments()
+	return d
+}
+
+// String formats d as one "name: value" line per setting.
+func (d Description) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "base url: %s\n", d.BaseURL)
+	if d.Environment != "" {
+		fmt.Fprintf(&b, "environment: %s\n", d.Environment)
+	}
+	fmt.Fprintf(&b, "auth: %s\n", d.Auth)
+	if len(d.KeyFingerprints) > 0 {
+		fmt.Fprintf(&b, "keys: %s\n", strings.Join(d.KeyFingerprints, ", "))
+	}
+	fmt.Fprintf(&b, "timeout: %s\n", d.Timeout)
+	if !d.Timeouts.IsZero() {
+		fmt.Fprintf(&b, "timeouts: %s\n", d.Timeouts)
+	}
+	if d.Retry != nil {
+		fmt.Fprintf(&b, "retry: %d attempts on %v\n", d.Retry.MaxAttempts, d.Retry.StatusCodes)
+	} else {
+		b.WriteString("retry: off\n")
+	}
+	for _, l := range []struct {
+		name   string
+		values []string
+	}{
+		{"middleware", d.Middleware},
+		{"policies", d.Policies},
+		{"headers", d.Headers},
+		{"features", d.Features},
+		{"experiments", d.Experiments},
+	} {
+		if len(l.values) > 0 {
+			fmt.Fprintf(&b, "%s: %s\n", l.name, strings.Join(l.values, ", "))
+		}
+	}
+	return b.String()
+}
+
+func (c *Client) authMode() string {
+	switch a := c.Auth.(type) {
+	case nil:
+		if c.KeyAuth == nil {
+			return "api key (relax.TokenAuth)"
+		}
+		return fmt.Sprintf("api key (%s)", funcName(c.KeyAuth))
+	case *TokenAuthenticator:
+		return "oauth2"
+	default:
+		return fmt.Sprintf("%T", a)
+	}
+}
+
+// keyFingerprint identifies key without revealing it.
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// funcName returns the package qualified name of the function f.
+func funcName(f interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// MergePatchContentType is the Content-Type of JSON merge patches
+// (RFC 7396).
+const MergePatchContentType = "application/merge-patch+json"
+
+// UpdateMode selects how UpdateChanged sends the changed fields.
+type UpdateMode int
+
+const (
+	// UpdateMergePatch PATCHes a JSON merge patch holding the changed
+	// fields, down to nested objects. This is the default.
+	UpdateMergePatch UpdateMode = iota
+
+	// UpdatePut PUTs the top-level fields that changed, each with its
+	// whole new value, for APIs that accept partial PUTs but not merge
+	// patches.
+	UpdatePut
+)
+
+// WithUpdateMode sends the changes of an UpdateChanged call as mode
+// selects.
+func WithUpdateMode(mode UpdateMode) RequestOption {
+	return func(o *callOptions) {
+		o.updateMode = mode
+	}
+}
+
+// MergePatch returns the JSON merge patch (RFC 7396) turning original into
+// modified, both compared as they encode to JSON, so field tags apply.
+// Changed values are set, removed fields are null and arrays are replaced
+// whole. It returns nil if nothing changed.
+func MergePatch(original, modified interface{}) (map[string]interface{}, error) {
+	from, err := toJSONObject(original)
+	if err != nil {
+		return nil, err
+	}
+	to, err := toJSONObject(modified)
+	if err != nil {
+		return nil, err
+	}
+	patch := mergePatch(from, to)
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	return patch, nil
+}
+
+func toJSONObject(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func mergePatch(from, to map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+	for k, v := range to {
+		old, ok := from[k]
+		if !ok {
+			patch[k] = v
+			continue
+		}
+		oldObj, isObj := old.(map[string]interface{})
+		newObj, isNewObj := v.(map[string]interface{})
+		if isObj && isNewObj {
+			if sub := mergePatch(oldObj, newObj); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !reflect.DeepEqual(old, v) {
+			patch[k] = v
+		}
+	}
+	for k := range from {
+		if _, ok := to[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
+
+// UpdateChangedContext sends only the fields that differ between original
+// and modified to uri, as a JSON merge patch or, WithUpdateMode(UpdatePut),
+// a minimal PUT, and decodes the response into response. Sending less
+// keeps payloads small and avoids overwriting fields changed concurrently
+// by others. Both are compared as they encode to JSON, and the changes are
+// always sent as JSON. When nothing changed, no request is sent and
+// response is left as it is.
+func (c *Client) UpdateChangedContext(ctx context.Context, uri string, original, modified interface{}, response interface{}, opts ...RequestOption) error {
+	patch, err := MergePatch(original, modified)
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return nil
+	}
+
+	o := newCallOptions(opts)
+	method, contentType := http.MethodPatch, MergePatchContentType
+	if o.updateMode == UpdatePut {
+		method, contentType = http.MethodPut, JSONCodec.ContentType()
+		to, _ := toJSONObject(modified)
+		for k, v := range patch {
+			if v != nil {
+				patch[k] = to[k]
+			}
+		}
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.MakeRequestContext(ctx, method, uri)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	setBody(req, body)
+	return c.jsonResponse(req, response, o)
+}
+
+// UpdateChanged sends the changes between original and modified to uri.
+// It is UpdateChangedContext without a context.
+func (c *Client) UpdateChanged(uri string, original, modified interface{}, response interface{}, opts ...RequestOption) error {
+	return c.UpdateChangedContext(context.Background(), uri, original, modified, response, opts...)
+}
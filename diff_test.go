@@ -0,0 +1,95 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type diffAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type diffUser struct {
+	Name    string      `json:"name"`
+	Email   string      `json:"email"`
+	Nick    string      `json:"nick,omitempty"`
+	Tags    []string    `json:"tags"`
+	Address diffAddress `json:"address"`
+}
+
+func TestMergePatch(t *testing.T) {
+	original := diffUser{Name: "Ann", Email: "ann@example.com", Nick: "annie", Tags: []string{"a"}, Address: diffAddress{City: "Paris", Zip: "75001"}}
+	modified := original
+	modified.Email = "ann@example.org"
+	modified.Nick = ""
+	modified.Tags = []string{"a", "b"}
+	modified.Address.City = "Lyon"
+
+	patch, err := MergePatch(original, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"email":   "ann@example.org",
+		"nick":    nil,
+		"tags":    []interface{}{"a", "b"},
+		"address": map[string]interface{}{"city": "Lyon"},
+	}
+	if !reflect.DeepEqual(patch, want) {
+		t.Errorf("Expected %v, got %v", want, patch)
+	}
+
+	if patch, err := MergePatch(original, original); err != nil || patch != nil {
+		t.Errorf("Expected no patch for equal values, got %v, %v", patch, err)
+	}
+}
+
+func TestClient_UpdateChanged(t *testing.T) {
+	var method, contentType, body string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		method, contentType, body = r.Method, r.Header.Get("Content-Type"), string(b)
+		w.Write([]byte(`{"Foo": "ok"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	original := diffUser{Name: "Ann", Email: "ann@example.com", Address: diffAddress{City: "Paris", Zip: "75001"}}
+	modified := original
+	modified.Address.City = "Lyon"
+
+	var response Response
+	if err := c.UpdateChanged("/users/1", original, modified, &response); err != nil {
+		t.Fatal(err)
+	}
+	if method != "PATCH" || contentType != MergePatchContentType || body != `{"address":{"city":"Lyon"}}` {
+		t.Errorf("Expected a merge patch, got %s %s %s", method, contentType, body)
+	}
+	if response.Foo != "ok" {
+		t.Errorf("Expected the response to be decoded, got %+v", response)
+	}
+
+	if err := c.UpdateChanged("/users/1", original, modified, &response, WithUpdateMode(UpdatePut)); err != nil {
+		t.Fatal(err)
+	}
+	if method != "PUT" || contentType != "application/json" || body != `{"address":{"city":"Lyon","zip":"75001"}}` {
+		t.Errorf("Expected a minimal PUT of the changed field, got %s %s %s", method, contentType, body)
+	}
+
+	if err := c.UpdateChanged("/users/1", original, original, &response); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected no request without changes, got %d calls", calls)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestClient_PatchJson(t *testing.T) {
+	handler := responseHandler{Method: http.MethodPatch, Message: "{\"Foo\": \"bar\"}", Path: "/api/foo", ExpectedBody: "{\"Name\":\"new_name\"}"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var response Response
+	if err := c.PatchJson("/api/foo", struct{ Name string }{"new_name"}, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "bar" {
+		t.Errorf("Expected data.Foo to be \"bar\", got \"%s\"", response.Foo)
+	}
+}
+
+func TestClient_Do(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	err := c.Do(http.MethodHead, "/api/foo?a=1", nil, nil,
+		WithQuery("q", "a b&c"),
+		WithQueryValues(url.Values{"a": {"2"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != http.MethodHead {
+		t.Errorf("Expected HEAD, got %s", got.Method)
+	}
+	if q := got.URL.Query(); q.Get("q") != "a b&c" || len(q["a"]) != 2 {
+		t.Errorf("Unexpected query %q", got.URL.RawQuery)
+	}
+	if len(body) != 0 || got.Header.Get("Content-Type") != "" {
+		t.Errorf("Expected no body for a nil body, got %q", body)
+	}
+
+	if err := c.Do("PURGE", "/cache", map[string]int{"n": 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "PURGE" || string(body) != `{"n":1}` {
+		t.Errorf("Expected PURGE with a JSON body, got %s %q", got.Method, body)
+	}
+}
+
+func TestClient_RequestOptionsOnEveryMethod(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithDefaultHeader("Accept-Language", "en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []RequestOption{
+		WithHeader("Accept-Language", "fr"),
+		WithHeader("If-Match", `"v1"`),
+		WithQuery("expand", "owner"),
+		WithIdempotencyKey("k1"),
+		WithCallTimeout(time.Second),
+	}
+
+	calls := map[string]func() error{
+		http.MethodGet:    func() error { return c.ReadJson("/api/foo", nil, opts...) },
+		http.MethodPost:   func() error { return c.CreateJson("/api/foo", "x", nil, opts...) },
+		http.MethodPut:    func() error { return c.UpdateJson("/api/foo", "x", nil, opts...) },
+		http.MethodPatch:  func() error { return c.PatchJson("/api/foo", "x", nil, opts...) },
+		http.MethodDelete: func() error { return c.DeleteJson("/api/foo", nil, opts...) },
+	}
+	for method, call := range calls {
+		if err := call(); err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if got.Method != method {
+			t.Errorf("Expected %s, got %s", method, got.Method)
+		}
+		if got.Header.Get("Accept-Language") != "fr" || got.Header.Get("If-Match") != `"v1"` || got.Header.Get("Idempotency-Key") != "k1" {
+			t.Errorf("%s: unexpected headers %v", method, got.Header)
+		}
+		if got.URL.Query().Get("expand") != "owner" {
+			t.Errorf("%s: unexpected query %q", method, got.URL.RawQuery)
+		}
+	}
+}
+
+func TestClient_WithCallTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	start := time.Now()
+	err := c.ReadJson("/api/foo", nil, WithCallTimeout(20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the call to be cut short, took %s", elapsed)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DownloadOptions configures Download and DownloadFile.
+type DownloadOptions struct {
+	// Preflight issues a HEAD first and fails with a DownloadSizeError,
+	// before any byte is transferred, when the announced Content-Length
+	// exceeds MaxBytes or, for DownloadFile, the free disk space.
+	Preflight bool
+
+	// MaxBytes, when positive, caps the size of the download. It is also
+	// enforced while streaming, for servers that announce no length.
+	MaxBytes int64
+
+	// Resume makes DownloadFile continue an interrupted download from the
+	// partial file it left behind, with a Range request. Servers answering
+	// with the full body restart the download.
+	Resume bool
+
+	// SHA256 and MD5, when set, are the expected hex digests of the whole
+	// download. A mismatch fails with a ChecksumError and discards the
+	// partial file.
+	SHA256 string
+	MD5    string
+
+	// Progress, when set, is called as the body is written with the bytes
+	// written so far, including resumed ones, and the total, or -1 if the
+	// server announces no length.
+	Progress func(written, total int64)
+
+	// Request options applied to the HEAD and GET requests.
+	Options []RequestOption
+}
+
+// ChecksumError reports a download whose digest does not match.
+type ChecksumError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// checksums hashes a download for the digests requested in opts.
+type checksums struct {
+	opts   *DownloadOptions
+	sha256 hash.Hash
+	md5    hash.Hash
+}
+
+func newChecksums(opts *DownloadOptions) *checksums {
+	cs := &checksums{opts: opts}
+	if opts.SHA256 != "" {
+		cs.sha256 = sha256.New()
+	}
+	if opts.MD5 != "" {
+		cs.md5 = md5.New()
+	}
+	return cs
+}
+
+func (cs *checksums) Write(p []byte) (int, error) {
+	if cs.sha256 != nil {
+		cs.sha256.Write(p)
+	}
+	if cs.md5 != nil {
+		cs.md5.Write(p)
+	}
+	return len(p), nil
+}
+
+func (cs *checksums) reset() {
+	if cs.sha256 != nil {
+		cs.sha256.Reset()
+	}
+	if cs.md5 != nil {
+		cs.md5.Reset()
+	}
+}
+
+func (cs *checksums) verify() error {
+	for _, c := range []struct {
+		name, want string
+		h          hash.Hash
+	}{{"sha256", cs.opts.SHA256, cs.sha256}, {"md5", cs.opts.MD5, cs.md5}} {
+		if c.h == nil {
+			continue
+		}
+		if got := hex.EncodeToString(c.h.Sum(nil)); !strings.EqualFold(got, c.want) {
+			return &ChecksumError{Algorithm: c.name, Expected: c.want, Actual: got}
+		}
+	}
+	return nil
+}
+
+// DownloadSizeError reports a download too large for its limit or its
+// destination.
+type DownloadSizeError struct {
+	URL string
+
+	// Size is the announced Content-Length, or -1 when the limit was hit
+	// while streaming.
+	Size int64
+
+	// Limit is the limit exceeded: MaxBytes, or the free disk space when
+	// Disk is set.
+	Limit int64
+	Disk  bool
+}
+
+func (e *DownloadSizeError) Error() string {
+	limit := fmt.Sprintf("the %d byte limit", e.Limit)
+	if e.Disk {
+		limit = fmt.Sprintf("the %d bytes free on disk", e.Limit)
+	}
+	if e.Size < 0 {
+		return fmt.Sprintf("download of %s exceeds %s", e.URL, limit)
+	}
+	return fmt.Sprintf("download of %s is %d bytes, exceeding %s", e.URL, e.Size, limit)
+}
+
+// Download GETs uri and streams the response body into w, returning the
+// number of bytes written. opts may be nil.
+func (c *Client) Download(ctx context.Context, uri string, w io.Writer, opts *DownloadOptions) (int64, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	if opts.Preflight {
+		if _, err := c.preflight(ctx, uri, opts, ""); err != nil {
+			return 0, err
+		}
+	}
+
+	cs := newChecksums(opts)
+	n, err := c.download(ctx, uri, io.MultiWriter(w, cs), opts, 0, nil)
+	if err != nil {
+		return n, err
+	}
+	return n, cs.verify()
+}
+
+// DownloadFile downloads uri to path. The body is written to path+".part"
+// and renamed once complete and verified, so path never holds a partial
+// file. With opts.Resume, a failed download keeps the partial file and the
+// next call continues from it.
+func (c *Client) DownloadFile(ctx context.Context, uri, path string, opts *DownloadOptions) (int64, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	if opts.Preflight {
+		if _, err := c.preflight(ctx, uri, opts, filepath.Dir(path)); err != nil {
+			return 0, err
+		}
+	}
+
+	part := path + ".part"
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if opts.Resume {
+		flags = os.O_RDWR | os.O_CREATE
+	}
+	f, err := os.OpenFile(part, flags, 0666)
+	if err != nil {
+		return 0, err
+	}
+
+	cs := newChecksums(opts)
+	offset, err := io.Copy(cs, f)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+
+	restart := func() error {
+		cs.reset()
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		_, err := f.Seek(0, io.SeekStart)
+		return err
+	}
+	n, err := c.download(ctx, uri, io.MultiWriter(f, cs), opts, offset, restart)
+	if err == nil {
+		err = cs.verify()
+		if err != nil {
+			f.Truncate(0)
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	var checksumErr *ChecksumError
+	if err != nil {
+		if !opts.Resume || errors.As(err, &checksumErr) {
+			os.Remove(part)
+		}
+		return n, err
+	}
+	return n, os.Rename(part, path)
+}
+
+// preflight HEADs uri and checks its Content-Length against the limits of
+// opts and the free space in dir, when dir is set. It returns the length, or
+// -1 when the server announces none.
+func (c *Client) preflight(ctx context.Context, uri string, opts *DownloadOptions, dir string) (int64, error) {
+	req, err := c.MakeRequestContext(ctx, http.MethodHead, uri)
+	if err != nil {
+		return 0, err
+	}
+	if err := newCallOptions(opts.Options).apply(req, c); err != nil {
+		return 0, err
+	}
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	if !isSuccess(res.StatusCode) {
+		return 0, newAPIError(res, nil, 0)
+	}
+
+	size := res.ContentLength
+	if size < 0 {
+		return size, nil
+	}
+	if opts.MaxBytes > 0 && size > opts.MaxBytes {
+		return size, &DownloadSizeError{URL: req.URL.String(), Size: size, Limit: opts.MaxBytes}
+	}
+	if dir != "" {
+		if free, ok := freeSpace(dir); ok && size > free {
+			return size, &DownloadSizeError{URL: req.URL.String(), Size: size, Limit: free, Disk: true}
+		}
+	}
+	return size, nil
+}
+
+// download streams uri into w. A positive offset asks for the bytes from
+// offset on; if the server sends the whole body instead, restart is called
+// before writing. It returns the total size of the download so far.
+func (c *Client) download(ctx context.Context, uri string, w io.Writer, opts *DownloadOptions, offset int64, restart func() error) (int64, error) {
+	req, err := c.MakeRequestContext(ctx, http.MethodGet, uri)
+	if err != nil {
+		return 0, err
+	}
+	if err := newCallOptions(opts.Options).apply(req, c); err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	start := time.Now()
+	var n int64
+	fail := func(err error) error {
+		return &RequestError{
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Attempt:   1,
+			Elapsed:   time.Since(start),
+			BytesRead: n,
+			Err:       err,
+		}
+	}
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return offset, fail(err)
+	}
+	defer res.Body.Close()
+
+	if offset > 0 && res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The partial file is already complete.
+		return offset, nil
+	}
+	if !isSuccess(res.StatusCode) {
+		body, _ := ioutil.ReadAll(res.Body)
+		return offset, fail(c.apiError(res, body))
+	}
+	if offset > 0 && !resumes(res, offset) {
+		if err := restart(); err != nil {
+			return offset, fail(err)
+		}
+		offset = 0
+	}
+
+	total := int64(-1)
+	if res.ContentLength >= 0 {
+		total = offset + res.ContentLength
+	}
+	if opts.MaxBytes > 0 && total > opts.MaxBytes {
+		return offset, fail(&DownloadSizeError{URL: req.URL.String(), Size: total, Limit: opts.MaxBytes})
+	}
+
+	var body io.Reader = res.Body
+	if opts.MaxBytes > 0 {
+		body = io.LimitReader(res.Body, opts.MaxBytes-offset+1)
+	}
+	if opts.Progress != nil {
+		w = &progressWriter{w: w, written: offset, total: total, fn: opts.Progress}
+	}
+	n, err = io.Copy(w, body)
+	if err != nil {
+		return offset + n, fail(err)
+	}
+	if opts.MaxBytes > 0 && offset+n > opts.MaxBytes {
+		return offset + n, fail(&DownloadSizeError{URL: req.URL.String(), Size: -1, Limit: opts.MaxBytes})
+	}
+	return offset + n, nil
+}
+
+// resumes reports whether res continues a download at offset.
+func resumes(res *http.Response, offset int64) bool {
+	if res.StatusCode != http.StatusPartialContent {
+		return false
+	}
+	unit, first, _, _, err := parseContentRange(res.Header.Get("Content-Range"))
+	return err == nil && unit == "bytes" && first == offset
+}
+
+// progressWriter reports the bytes written through it.
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	total   int64
+	fn      func(written, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.fn(p.written, p.total)
+	}
+	return n, err
+}
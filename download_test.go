@@ -0,0 +1,193 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDownloadServer(body string, gets *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			*gets++
+		}
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func TestClient_Download(t *testing.T) {
+	var gets int
+	server := newDownloadServer("hello world", &gets)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	var buf bytes.Buffer
+	n, err := c.Download(context.Background(), "/file", &buf, &DownloadOptions{Preflight: true, MaxBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 11 || buf.String() != "hello world" {
+		t.Errorf("Expected 11 bytes of \"hello world\", got %d bytes of %q", n, buf.String())
+	}
+}
+
+func TestClient_DownloadPreflightTooLarge(t *testing.T) {
+	var gets int
+	server := newDownloadServer("hello world", &gets)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	_, err := c.Download(context.Background(), "/file", ioutil.Discard, &DownloadOptions{Preflight: true, MaxBytes: 5})
+
+	var sizeErr *DownloadSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("Expected DownloadSizeError, got %v", err)
+	}
+	if sizeErr.Size != 11 || sizeErr.Limit != 5 || sizeErr.Disk {
+		t.Errorf("Unexpected error %+v", sizeErr)
+	}
+	if gets != 0 {
+		t.Errorf("Expected no GET after a failed preflight, got %d", gets)
+	}
+}
+
+func TestClient_DownloadLimitWhileStreaming(t *testing.T) {
+	var gets int
+	server := newDownloadServer("hello world", &gets)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	_, err := c.Download(context.Background(), "/chunked", ioutil.Discard, &DownloadOptions{MaxBytes: 5})
+
+	var sizeErr *DownloadSizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Size != -1 {
+		t.Fatalf("Expected a streaming DownloadSizeError, got %v", err)
+	}
+}
+
+func TestClient_DownloadFile(t *testing.T) {
+	var gets int
+	server := newDownloadServer("hello world", &gets)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "relax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.txt")
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if _, err := c.DownloadFile(context.Background(), "/file", path, &DownloadOptions{Preflight: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != "hello world" {
+		t.Errorf("Expected file contents \"hello world\", got %q", got)
+	}
+
+	_, err = c.DownloadFile(context.Background(), "/chunked", filepath.Join(dir, "big.txt"), &DownloadOptions{MaxBytes: 1})
+	if err == nil {
+		t.Fatal("Expected the download to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "big.txt.part")); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial file to be removed")
+	}
+}
+
+func TestClient_DownloadFileResume(t *testing.T) {
+	content := "hello world"
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := ioutil.WriteFile(path+".part", []byte("hello"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []int64
+	opts := &DownloadOptions{
+		Resume:   true,
+		SHA256:   "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		MD5:      "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		Progress: func(written, total int64) { progress = append(progress, written, total) },
+	}
+	c := newClientOrFatal(t, server.URL, apiKey)
+	n, err := c.DownloadFile(context.Background(), "/file", path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 11 {
+		t.Errorf("Expected 11 bytes, got %d", n)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=5-" {
+		t.Errorf("Expected a single request for bytes=5-, got %q", ranges)
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != content {
+		t.Errorf("Expected %q, got %q", content, got)
+	}
+	if l := len(progress); l < 2 || progress[l-2] != 11 || progress[l-1] != 11 {
+		t.Errorf("Expected progress to end at 11 of 11, got %v", progress)
+	}
+}
+
+func TestClient_DownloadFileResumeRestarts(t *testing.T) {
+	var gets int
+	server := newDownloadServer("hello world", &gets)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := ioutil.WriteFile(path+".part", []byte("stale"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	opts := &DownloadOptions{Resume: true, SHA256: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"}
+	if _, err := c.DownloadFile(context.Background(), "/file", path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != "hello world" {
+		t.Errorf("Expected the download to restart, got %q", got)
+	}
+}
+
+func TestClient_DownloadFileChecksumMismatch(t *testing.T) {
+	var gets int
+	server := newDownloadServer("hello world", &gets)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	c := newClientOrFatal(t, server.URL, apiKey)
+	_, err := c.DownloadFile(context.Background(), "/file", path, &DownloadOptions{Resume: true, MD5: "00"})
+
+	var sumErr *ChecksumError
+	if !errors.As(err, &sumErr) {
+		t.Fatalf("Expected ChecksumError, got %v", err)
+	}
+	if sumErr.Algorithm != "md5" || sumErr.Actual != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("Unexpected error %+v", sumErr)
+	}
+	for _, p := range []string{path, path + ".part"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", p)
+		}
+	}
+}
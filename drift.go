@@ -0,0 +1,182 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DriftDetector compares decoded JSON responses against the structs they
+// were decoded into and accumulates, per route, the JSON fields no struct
+// field picked up and the struct fields no response ever populated. It is
+// meant for diagnostics; every decode is paid for twice.
+type DriftDetector struct {
+	mu     sync.Mutex
+	routes map[string]*driftRoute
+}
+
+type driftRoute struct {
+	samples   int
+	declared  map[string]bool
+	populated map[string]bool
+	unknown   map[string]bool
+}
+
+// DriftReport is the accumulated drift of a single route. Field paths are
+// dotted JSON names, with "[]" marking array elements.
+type DriftReport struct {
+	Route          string
+	Samples        int
+	UnknownFields  []string
+	NeverPopulated []string
+}
+
+// NewDriftDetector returns an empty DriftDetector.
+func NewDriftDetector() *DriftDetector {
+	return &DriftDetector{routes: make(map[string]*driftRoute)}
+}
+
+// Report returns the drift seen so far, sorted by route.
+func (d *DriftDetector) Report() []DriftReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reports := make([]DriftReport, 0, len(d.routes))
+	for route, r := range d.routes {
+		report := DriftReport{Route: route, Samples: r.samples}
+		for f := range r.unknown {
+			report.UnknownFields = append(report.UnknownFields, f)
+		}
+		for f := range r.declared {
+			if !r.populated[f] {
+				report.NeverPopulated = append(report.NeverPopulated, f)
+			}
+		}
+		sort.Strings(report.UnknownFields)
+		sort.Strings(report.NeverPopulated)
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Route < reports[j].Route })
+	return reports
+}
+
+// observe records the drift between body and the type of target.
+func (d *DriftDetector) observe(route string, target interface{}, body []byte) {
+	var raw interface{}
+	if target == nil || json.Unmarshal(body, &raw) != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r, ok := d.routes[route]
+	if !ok {
+		r = &driftRoute{declared: map[string]bool{}, populated: map[string]bool{}, unknown: map[string]bool{}}
+		d.routes[route] = r
+	}
+	r.samples++
+	r.walk(reflect.TypeOf(target), raw, "")
+}
+
+func (r *driftRoute) walk(t reflect.Type, raw interface{}, prefix string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		matched := map[string]bool{}
+		r.walkFields(t, obj, prefix, matched)
+		for key := range obj {
+			if !matched[key] {
+				r.unknown[prefix+key] = true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for _, v := range arr {
+			r.walk(t.Elem(), v, strings.TrimSuffix(prefix, ".")+"[].")
+		}
+	}
+}
+
+func (r *driftRoute) walkFields(t reflect.Type, obj map[string]interface{}, prefix string, matched map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, skip := jsonFieldName(f)
+		if skip {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.walkFields(ft, obj, prefix, matched)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		path := prefix + name
+		r.declared[path] = true
+
+		key, ok := matchKey(obj, name)
+		if !ok {
+			continue
+		}
+		matched[key] = true
+		if obj[key] != nil {
+			r.populated[path] = true
+		}
+		r.walk(f.Type, obj[key], path+".")
+	}
+}
+
+// jsonFieldName returns the name from the json tag of f, and whether the
+// field is excluded from JSON.
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	if i := strings.Index(tag, ","); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag, false
+}
+
+// matchKey finds the key of obj encoding/json would decode into a field
+// called name: an exact match first, then a case-insensitive one.
+func matchKey(obj map[string]interface{}, name string) (string, bool) {
+	if _, ok := obj[name]; ok {
+		return name, true
+	}
+	for key := range obj {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClient_SchemaDrift(t *testing.T) {
+	type item struct {
+		ID    int    `json:"id"`
+		Label string `json:"label"`
+	}
+	type page struct {
+		Items   []item `json:"items"`
+		Cursor  string `json:"cursor,omitempty"`
+		Ignored string `json:"-"`
+	}
+
+	handler := responseHandler{
+		Method:  http.MethodGet,
+		Path:    "/api/items",
+		Message: `{"items": [{"id": 1, "label": "a", "color": "red"}], "total": 1}`,
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.SchemaDrift = NewDriftDetector()
+
+	var p page
+	if err := c.ReadJson("/api/items", &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReadJson("/api/items", &p); err != nil {
+		t.Fatal(err)
+	}
+
+	reports := c.SchemaDrift.Report()
+	if len(reports) != 1 {
+		t.Fatalf("Expected one route, got %+v", reports)
+	}
+
+	want := DriftReport{
+		Route:          "GET /api/items",
+		Samples:        2,
+		UnknownFields:  []string{"items[].color", "total"},
+		NeverPopulated: []string{"cursor"},
+	}
+	if !reflect.DeepEqual(reports[0], want) {
+		t.Errorf("Expected %+v, got %+v", want, reports[0])
+	}
+}
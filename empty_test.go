@@ -0,0 +1,120 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_EmptyResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/empty":
+			w.Write([]byte(" \n"))
+		default:
+			w.Write([]byte(`{"Foo":"bar"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	for _, path := range []string{"/no-content", "/empty"} {
+		data := Response{Foo: "unchanged"}
+		if err := c.DeleteJson(path, &data); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if !c.LastEmpty {
+			t.Errorf("%s: expected LastEmpty to be set", path)
+		}
+		if data.Foo != "unchanged" {
+			t.Errorf("%s: expected response to be left alone, got %q", path, data.Foo)
+		}
+	}
+
+	var data Response
+	if err := c.ReadJson("/", &data); err != nil {
+		t.Fatal(err)
+	}
+	if c.LastEmpty || data.Foo != "bar" {
+		t.Errorf("Expected a decoded, non-empty response")
+	}
+}
+
+func TestClient_NilResponseIsSent(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"Foo":"bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if err := c.CreateJson("/api/foo", "bar", nil); err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `"bar"` {
+		t.Errorf("Expected the request to be sent, got %q", body)
+	}
+}
+
+func TestClient_NilResponseReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if err := c.DeleteJson("/api/foo", nil); err == nil {
+		t.Error("Expected an error for a 400 with a nil response")
+	}
+}
+
+func TestClient_FireAndForget(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		received <- r.URL.Path
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	ctx, cancel := context.WithCancel(context.Background())
+	failed := make(chan error, 1)
+	err := c.CreateJsonContext(ctx, "/api/foo", "bar", nil, FireAndForget(func(err error) { failed <- err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cancelling the caller's context doesn't abandon the request.
+	cancel()
+	release <- struct{}{}
+
+	select {
+	case path := <-received:
+		if path != "/api/foo" {
+			t.Errorf("Expected /api/foo, got %s", path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the request to be sent")
+	}
+	select {
+	case err := <-failed:
+		if _, ok := err.(*RequestError); !ok {
+			t.Errorf("Expected a RequestError, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected onError to be called")
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ErrorExtractor inspects the body of a successful response and returns a
+// non-nil error when it reports a failure anyway, as APIs answering 200 with
+// {"ok": false, "error": {...}} do. The error is returned from the call, and
+// the body is neither decoded nor cached.
+type ErrorExtractor func(res *http.Response, body []byte) error
+
+// EnvelopeError is the error returned by EnvelopeErrors. Raw holds the error
+// field as sent; Code and Message are filled in when it carries them.
+type EnvelopeError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Raw        json.RawMessage
+
+	// Err is the error registered for Code in Client.ErrorCodes, if any.
+	Err error
+}
+
+func (e *EnvelopeError) Error() string {
+	switch {
+	case e.Code != "" && e.Message != "":
+		return fmt.Sprintf("api error %s: %s", e.Code, e.Message)
+	case e.Message != "":
+		return fmt.Sprintf("api error: %s", e.Message)
+	case e.Code != "":
+		return fmt.Sprintf("api error %s", e.Code)
+	}
+	return fmt.Sprintf("api error: %s", bodyPreview(e.Raw, DefaultErrorPreviewBytes))
+}
+
+// Unwrap returns the error registered for the code.
+func (e *EnvelopeError) Unwrap() error {
+	return e.Err
+}
+
+// EnvelopeErrors returns an ErrorExtractor for JSON envelopes that flag
+// failure with the boolean okField and describe it in errorField. A body
+// fails when okField is false, or, if okField is empty, whenever errorField
+// is present and not null. The error field may be a string or an object
+// with code and message (or msg) members.
+func EnvelopeErrors(okField, errorField string) ErrorExtractor {
+	return func(res *http.Response, body []byte) error {
+		var envelope map[string]json.RawMessage
+		if json.Unmarshal(body, &envelope) != nil {
+			return nil
+		}
+
+		raw := envelope[errorField]
+		hasError := len(raw) > 0 && !bytes.Equal(raw, []byte("null"))
+		if okField != "" {
+			var ok bool
+			if v, found := envelope[okField]; !found || json.Unmarshal(v, &ok) != nil || ok {
+				return nil
+			}
+		} else if !hasError {
+			return nil
+		}
+
+		e := &EnvelopeError{StatusCode: res.StatusCode, Raw: raw}
+		if !hasError {
+			return e
+		}
+		if json.Unmarshal(raw, &e.Message) == nil {
+			return e
+		}
+		var fields struct {
+			Code    json.RawMessage `json:"code"`
+			Message string          `json:"message"`
+			Msg     string          `json:"msg"`
+		}
+		if json.Unmarshal(raw, &fields) == nil {
+			e.Message = fields.Message
+			if e.Message == "" {
+				e.Message = fields.Msg
+			}
+			if json.Unmarshal(fields.Code, &e.Code) != nil {
+				e.Code = string(fields.Code)
+			}
+		}
+		return e
+	}
+}
+
+// WithErrorExtractor checks the response of this call with fn instead of
+// the client's ErrorExtractor.
+func WithErrorExtractor(fn ErrorExtractor) RequestOption {
+	return func(o *callOptions) {
+		o.errorExtractor = fn
+	}
+}
+
+// errorExtractorFor returns the extractor for a call: the call's, a
+// preset's, or the client's.
+func (c *Client) errorExtractorFor(o *callOptions) ErrorExtractor {
+	if o.errorExtractor != nil {
+		return o.errorExtractor
+	}
+	return c.ErrorExtractor
+}
+
+// extractError runs the call's ErrorExtractor over a successful response.
+func (c *Client) extractError(res *http.Response, body []byte, o *callOptions) error {
+	fn := c.errorExtractorFor(o)
+	if fn == nil || isEmptyBody(body) {
+		return nil
+	}
+	err := fn(res, body)
+	if e, ok := err.(*EnvelopeError); ok && e.Code != "" && e.Err == nil {
+		e.Err = c.ErrorCodes[e.Code]
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_EnvelopeErrors(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Path: "/api/foo",
+		Message: `{"ok": false, "error": {"code": 42, "message": "quota exceeded"}}`}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ErrorExtractor = EnvelopeErrors("ok", "error")
+
+	var data Response
+	err := c.ReadJson("/api/foo", &data)
+
+	var envErr *EnvelopeError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvelopeError, got %v", err)
+	}
+	if envErr.Code != "42" || envErr.Message != "quota exceeded" || envErr.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected error %+v", envErr)
+	}
+}
+
+func TestClient_EnvelopeErrorsOK(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Path: "/api/foo",
+		Message: `{"ok": true, "error": null, "Foo": "bar"}`}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ErrorExtractor = EnvelopeErrors("ok", "error")
+
+	var data Response
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "bar" {
+		t.Errorf("Expected data.Foo to be \"bar\", got %q", data.Foo)
+	}
+}
+
+func TestClient_ErrorExtractorPrecedence(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Path: "/api/foo", Message: `{"error": "nope"}`}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	errPreset := errors.New("preset")
+	errCall := errors.New("call")
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ErrorExtractor = EnvelopeErrors("", "error")
+	WithPreset("strict", &RequestPreset{ErrorExtractor: func(*http.Response, []byte) error { return errPreset }})(c)
+
+	var envErr *EnvelopeError
+	if err := c.ReadJson("/api/foo", nil); !errors.As(err, &envErr) || envErr.Message != "nope" {
+		t.Errorf("Expected the client extractor to report \"nope\", got %v", err)
+	}
+	if err := c.ReadJson("/api/foo", nil, Preset("strict")); !errors.Is(err, errPreset) {
+		t.Errorf("Expected the preset extractor, got %v", err)
+	}
+	call := WithErrorExtractor(func(*http.Response, []byte) error { return errCall })
+	if err := c.ReadJson("/api/foo", nil, Preset("strict"), call); !errors.Is(err, errCall) {
+		t.Errorf("Expected the call extractor, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// ErrorCategory groups the codes of an ErrorCatalog. It is an error so
+// that errors.Is matches every CatalogError of the category:
+//
+//	if errors.Is(err, relax.ErrorCategory("auth")) {
+//		...
+//	}
+type ErrorCategory string
+
+func (c ErrorCategory) Error() string {
+	return "error category " + string(c)
+}
+
+// CatalogError is the error value registered for a code of a vendor's
+// error catalog. The APIError of a response carrying the code unwraps to
+// it, so application code can switch on it instead of parsing codes:
+//
+//	var ce *relax.CatalogError
+//	if errors.As(err, &ce) {
+//		switch ce.Code {
+//		...
+//		}
+//	}
+type CatalogError struct {
+	Code        string        `json:"code"`
+	Description string        `json:"description,omitempty"`
+	Category    ErrorCategory `json:"category,omitempty"`
+}
+
+func (e *CatalogError) Error() string {
+	if e.Description == "" {
+		return "error " + e.Code
+	}
+	return fmt.Sprintf("%s (%s)", e.Description, e.Code)
+}
+
+// Is reports whether target is the category of e or a CatalogError with
+// the same code.
+func (e *CatalogError) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrorCategory:
+		return e.Category != "" && t == e.Category
+	case *CatalogError:
+		return t.Code == e.Code
+	}
+	return false
+}
+
+// ErrorCatalog maps the error codes of an API to their errors.
+type ErrorCatalog map[string]*CatalogError
+
+// ParseErrorCatalog reads a JSON error catalog: an object mapping each
+// code to its description, or to an object with "description" and
+// "category" members.
+//
+//	{"E1001": {"description": "Token expired", "category": "auth"},
+//	 "E2001": "Unknown customer"}
+func ParseErrorCatalog(r io.Reader) (ErrorCatalog, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error catalog: %w", err)
+	}
+	cat := make(ErrorCatalog, len(raw))
+	for code, v := range raw {
+		e := &CatalogError{}
+		if err := json.Unmarshal(v, &e.Description); err != nil {
+			if err := json.Unmarshal(v, e); err != nil {
+				return nil, fmt.Errorf("error catalog: code %s: %w", code, err)
+			}
+		}
+		e.Code = code
+		cat[code] = e
+	}
+	return cat, nil
+}
+
+// LoadErrorCatalog reads the JSON error catalog in the file at path. See
+// ParseErrorCatalog.
+func LoadErrorCatalog(path string) (ErrorCatalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseErrorCatalog(f)
+}
+
+// Add registers the error for code with its description and category, for
+// catalogs built in code.
+func (cat ErrorCatalog) Add(code, description string, category ErrorCategory) *CatalogError {
+	e := &CatalogError{Code: code, Description: description, Category: category}
+	cat[code] = e
+	return e
+}
+
+// Codes returns the codes of cat, sorted.
+func (cat ErrorCatalog) Codes() []string {
+	codes := make([]string, 0, len(cat))
+	for code := range cat {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// WithErrorCatalog registers the errors of cat as with WithErrorCode.
+func WithErrorCatalog(cat ErrorCatalog) Option {
+	return func(c *Client) {
+		codes := c.ownErrorCodes()
+		for code, e := range cat {
+			codes[code] = e
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCatalog = `{
+	"E1001": {"description": "Token expired", "category": "auth"},
+	"E1002": {"description": "Token revoked", "category": "auth"},
+	"E2001": "Unknown customer"
+}`
+
+func TestParseErrorCatalog(t *testing.T) {
+	cat, err := ParseErrorCatalog(strings.NewReader(testCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cat.Codes(); !reflect.DeepEqual(got, []string{"E1001", "E1002", "E2001"}) {
+		t.Errorf("Expected 3 codes, got %v", got)
+	}
+	if e := cat["E1001"]; e.Code != "E1001" || e.Description != "Token expired" || e.Category != "auth" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if e := cat["E2001"]; e.Description != "Unknown customer" || e.Category != "" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if s := cat["E2001"].Error(); s != "Unknown customer (E2001)" {
+		t.Errorf("Unexpected message %q", s)
+	}
+
+	if _, err := ParseErrorCatalog(strings.NewReader(`{"E1": 3}`)); err == nil {
+		t.Error("Expected an error for a malformed entry")
+	}
+}
+
+func TestClient_ErrorCatalog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"code": "` + r.URL.Query().Get("code") + `"}}`))
+	}))
+	defer server.Close()
+
+	cat, err := ParseErrorCatalog(strings.NewReader(testCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited := cat.Add("E3001", "Too many requests", "quota")
+
+	c, err := NewClient(server.URL, apiKey, WithErrorCatalog(cat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.ReadJson("/api/me?code=E1002", nil)
+	var ce *CatalogError
+	if !errors.As(err, &ce) || ce.Code != "E1002" {
+		t.Fatalf("Expected a CatalogError for E1002, got %v", err)
+	}
+	if !errors.Is(err, ErrorCategory("auth")) {
+		t.Errorf("Expected the auth category to match, got %v", err)
+	}
+	if errors.Is(err, ErrorCategory("quota")) {
+		t.Error("Expected the quota category not to match")
+	}
+	if !errors.Is(err, cat["E1002"]) || errors.Is(err, cat["E1001"]) {
+		t.Error("Expected only the E1002 error to match")
+	}
+
+	err = c.ReadJson("/api/me?code=E3001", nil)
+	if !errors.Is(err, limited) || !errors.Is(err, ErrorCategory("quota")) {
+		t.Errorf("Expected the added error, got %v", err)
+	}
+
+	err = c.ReadJson("/api/me?code=E9999", nil)
+	if errors.As(err, &ce) {
+		t.Errorf("Expected no CatalogError for an unknown code, got %v", ce)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WithAcceptLanguage sends an Accept-Language header listing tags in order
+// of preference, e.g. WithAcceptLanguage("fr-CA", "fr", "en") sends
+// "fr-CA, fr;q=0.9, en;q=0.8".
+func WithAcceptLanguage(tags ...string) Option {
+	return func(c *Client) {
+		c.ownDefaultHeader().Set("Accept-Language", AcceptLanguage(tags...))
+	}
+}
+
+// AcceptLanguage formats tags as an Accept-Language value, giving each tag
+// after the first a lower quality.
+func AcceptLanguage(tags ...string) string {
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		q := 10 - i
+		switch {
+		case i == 0:
+			parts[i] = tag
+		case q < 1:
+			parts[i] = tag + ";q=0.1"
+		default:
+			parts[i] = fmt.Sprintf("%s;q=0.%d", tag, q)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithErrorCode makes errors for responses carrying code unwrap to err, so
+// they can be matched with errors.Is or errors.As however the message is
+// worded or translated. See Client.ErrorCodes.
+func WithErrorCode(code string, err error) Option {
+	return func(c *Client) {
+		c.ownErrorCodes()[code] = err
+	}
+}
+
+// ownErrorCodes makes ErrorCodes safe to modify.
+func (c *Client) ownErrorCodes() map[string]error {
+	if c.owned&ownsErrorCodes == 0 {
+		codes := make(map[string]error, len(c.ErrorCodes)+1)
+		for k, v := range c.ErrorCodes {
+			codes[k] = v
+		}
+		c.ErrorCodes = codes
+		c.owned |= ownsErrorCodes
+	}
+	return c.ErrorCodes
+}
+
+// ErrorCode returns the machine readable code of a JSON error body: the
+// top level "code", "error_code" or "errorCode" member, or the "code" of
+// the "error" object or of the first element of "errors". Numeric codes are
+// returned in decimal. It returns "" when there is none.
+func ErrorCode(body []byte) string {
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(body, &doc) != nil {
+		return ""
+	}
+	for _, name := range []string{"code", "error_code", "errorCode"} {
+		if code := codeString(doc[name]); code != "" {
+			return code
+		}
+	}
+
+	var nested struct {
+		Code json.RawMessage `json:"code"`
+	}
+	if json.Unmarshal(doc["error"], &nested) == nil {
+		if code := codeString(nested.Code); code != "" {
+			return code
+		}
+	}
+	var list []struct {
+		Code json.RawMessage `json:"code"`
+	}
+	if json.Unmarshal(doc["errors"], &list) == nil && len(list) > 0 {
+		return codeString(list[0].Code)
+	}
+	return ""
+}
+
+// codeString returns raw as a string if it is a JSON string or number.
+func codeString(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
+
+// errorCodeOf returns the code of an error response.
+func (c *Client) errorCodeOf(res *http.Response, body []byte) string {
+	if c.ErrorCodeOf != nil {
+		return c.ErrorCodeOf(res, body)
+	}
+	return ErrorCode(body)
+}
+
+// apiError returns the APIError for res, with its code resolved through
+// ErrorCodes.
+func (c *Client) apiError(res *http.Response, body []byte) *APIError {
+	e := newAPIError(res, body, c.errorPreviewBytes())
+	e.Code = c.errorCodeOf(res, body)
+	if e.Code != "" {
+		e.Err = c.ErrorCodes[e.Code]
+	}
+	return e
+}
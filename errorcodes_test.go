@@ -0,0 +1,77 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var errOutOfStock = errors.New("out of stock")
+
+func TestClient_ErrorCodes(t *testing.T) {
+	var lang string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang = r.Header.Get("Accept-Language")
+		switch r.URL.Path {
+		case "/api/order":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": {"code": "OUT_OF_STOCK", "message": "Rupture de stock"}}`))
+		case "/api/envelope":
+			w.Write([]byte(`{"ok": false, "error": {"code": 1042, "message": "Plus de stock"}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": "UNKNOWN"}`))
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey,
+		WithAcceptLanguage("fr-CA", "fr", "en"),
+		WithErrorCode("OUT_OF_STOCK", errOutOfStock),
+		WithErrorCode("1042", errOutOfStock),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.CreateJson("/api/order", "x", nil)
+	if !errors.Is(err, errOutOfStock) {
+		t.Errorf("Expected errOutOfStock, got %v", err)
+	}
+	if lang != "fr-CA, fr;q=0.9, en;q=0.8" {
+		t.Errorf("Unexpected Accept-Language %q", lang)
+	}
+
+	err = c.ReadJson("/api/envelope", nil, WithErrorExtractor(EnvelopeErrors("ok", "error")))
+	if !errors.Is(err, errOutOfStock) {
+		t.Errorf("Expected envelope error to map to errOutOfStock, got %v", err)
+	}
+
+	err = c.ReadJson("/api/other", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "UNKNOWN" || apiErr.Err != nil {
+		t.Errorf("Expected unregistered code to be kept, got %v", err)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := map[string]string{
+		`{"code": "A"}`:                   "A",
+		`{"error_code": 42}`:              "42",
+		`{"errorCode": "B"}`:              "B",
+		`{"error": {"code": "C"}}`:        "C",
+		`{"errors": [{"code": "D"}, {}]}`: "D",
+		`{"error": "no code"}`:            "",
+		`not json`:                        "",
+	}
+	for body, want := range tests {
+		if got := ErrorCode([]byte(body)); got != want {
+			t.Errorf("%s: expected %q, got %q", body, want, got)
+		}
+	}
+}
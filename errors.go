@@ -0,0 +1,210 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RequestError annotates a failed request with what is known about it at the
+// time of failure, so error logs are actionable without debug dumping.
+type RequestError struct {
+	Method    string
+	URL       string
+	Attempt   int
+	Elapsed   time.Duration
+	BytesRead int64
+	Err       error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %s (attempt %d, %s elapsed, %d bytes read)",
+		e.Method, e.URL, e.Err, e.Attempt, e.Elapsed, e.BytesRead)
+}
+
+// Unwrap returns the underlying error.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultErrorPreviewBytes is the body preview size used when
+// Client.ErrorPreviewBytes is zero.
+const DefaultErrorPreviewBytes = 256
+
+// DecodeError reports a response body that could not be decoded. Preview is
+// a truncated, sanitized copy of the body.
+type DecodeError struct {
+	Err     error
+	Preview string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Preview == "" {
+		return fmt.Sprintf("could not decode response: %s", e.Err)
+	}
+	return fmt.Sprintf("could not decode response: %s; body: %s", e.Err, e.Preview)
+}
+
+// Unwrap returns the underlying decode error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// errorPreviewBytes returns the configured preview size; negative disables
+// previews.
+func (c *Client) errorPreviewBytes() int {
+	if c.ErrorPreviewBytes == 0 {
+		return DefaultErrorPreviewBytes
+	}
+	return c.ErrorPreviewBytes
+}
+
+// bodyPreview returns at most max bytes of body with control characters and
+// invalid UTF-8 replaced, noting how much was cut.
+func bodyPreview(body []byte, max int) string {
+	return previewOf(body, len(body), max)
+}
+
+// previewOf is like bodyPreview for a body of size bytes starting with
+// head.
+func previewOf(head []byte, size int, max int) string {
+	body := head
+	if max <= 0 || len(body) == 0 {
+		return ""
+	}
+
+	cut := body
+	if len(cut) > max {
+		cut = body[:max]
+		// Back off so a multi-byte rune is not split.
+		for i := 0; i < utf8.UTFMax-1 && len(cut) > 0 && !utf8.RuneStart(body[len(cut)]); i++ {
+			cut = cut[:len(cut)-1]
+		}
+	}
+	rest := size - len(cut)
+
+	var b strings.Builder
+	for len(cut) > 0 {
+		r, size := utf8.DecodeRune(cut)
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			b.WriteRune(utf8.RuneError)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case unicode.IsControl(r):
+			b.WriteRune(utf8.RuneError)
+		default:
+			b.WriteRune(r)
+		}
+		cut = cut[size:]
+	}
+
+	if rest > 0 {
+		fmt.Fprintf(&b, "... (%d more bytes)", rest)
+	}
+	return b.String()
+}
+
+// APIError is returned for responses with a non-2xx status. The JSON
+// helpers return it wrapped in a RequestError; use errors.As or the Is*
+// helpers to inspect it.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+
+	// Preview is the truncated, sanitized body used in Error.
+	Preview string
+
+	// Code is the error code found in the body, if any. See ErrorCode.
+	Code string
+
+	// Err is the error registered for Code in Client.ErrorCodes, if any.
+	Err error
+}
+
+func newAPIError(res *http.Response, body []byte, previewBytes int) *APIError {
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Header:     res.Header,
+		Body:       body,
+		Preview:    bodyPreview(body, previewBytes),
+	}
+}
+
+func (e *APIError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Preview == "" {
+		return fmt.Sprintf("unexpected status %s", status)
+	}
+	return fmt.Sprintf("unexpected status %s: %s", status, e.Preview)
+}
+
+// Unwrap returns the error registered for the response's code.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func isSuccess(code int) bool {
+	return code >= 200 && code < 300
+}
+
+// StatusCode returns the status of the APIError in err's chain, or 0 if
+// there is none.
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized reports whether err is an APIError with status 401.
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether err is an APIError with status 403.
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsConflict reports whether err is an APIError with status 409.
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// IsPreconditionFailed reports whether err is an APIError with status 412,
+// as returned when a conditional update finds the resource changed.
+func IsPreconditionFailed(err error) bool {
+	return StatusCode(err) == http.StatusPreconditionFailed
+}
+
+// IsRateLimited reports whether err is an APIError with status 429.
+func IsRateLimited(err error) bool {
+	return StatusCode(err) == http.StatusTooManyRequests
+}
+
+// IsServerError reports whether err is an APIError with a 5xx status.
+func IsServerError(err error) bool {
+	code := StatusCode(err)
+	return code >= 500 && code < 600
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_RequestErrorOnInvalidJSON(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: "not json", Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var data Response
+	err := c.ReadJson("/api/foo", &data)
+
+	var rerr *RequestError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Expected *RequestError, got %v", err)
+	}
+
+	if rerr.Method != http.MethodGet || rerr.URL != server.URL+"/api/foo" {
+		t.Errorf("Unexpected request %s %s", rerr.Method, rerr.URL)
+	}
+	if rerr.Attempt != 1 {
+		t.Errorf("Expected attempt 1, got %d", rerr.Attempt)
+	}
+	if rerr.BytesRead != int64(len("not json")) {
+		t.Errorf("Expected %d bytes read, got %d", len("not json"), rerr.BytesRead)
+	}
+	if !strings.Contains(err.Error(), "attempt 1") {
+		t.Errorf("Expected attempt in message, got %q", err)
+	}
+}
+
+func TestClient_RequestErrorOnTransportFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	c := newClientOrFatal(t, server.URL, apiKey)
+	server.Close()
+
+	err := c.ReadJson("/api/foo", nil)
+
+	var rerr *RequestError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Expected *RequestError, got %v", err)
+	}
+	if rerr.BytesRead != 0 {
+		t.Errorf("Expected 0 bytes read, got %d", rerr.BytesRead)
+	}
+}
+
+func TestClient_DecodeErrorPreview(t *testing.T) {
+	body := "<!DOCTYPE html>\n<html>" + strings.Repeat("x", 500) + "</html>"
+	handler := responseHandler{Method: http.MethodGet, Message: body, Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ErrorPreviewBytes = 22
+
+	var data Response
+	err := c.ReadJson("/api/foo", &data)
+
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatalf("Expected *DecodeError, got %v", err)
+	}
+
+	want := fmt.Sprintf("<!DOCTYPE html> <html>... (%d more bytes)", len(body)-22)
+	if derr.Preview != want {
+		t.Errorf("Expected preview %q, got %q", want, derr.Preview)
+	}
+
+	c.ErrorPreviewBytes = -1
+	err = c.ReadJson("/api/foo", &data)
+	if !errors.As(err, &derr) || derr.Preview != "" {
+		t.Errorf("Expected no preview when disabled, got %v", err)
+	}
+}
+
+func TestBodyPreview(t *testing.T) {
+	tests := []struct {
+		body string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"", 10, ""},
+		{"abc", 0, ""},
+		{"tab\there", 10, "tab here"},
+		{"bell\a", 10, "bell�"},
+		{"bad\xffutf8", 10, "bad�utf8"},
+		{"héllo", 2, "h... (5 more bytes)"},
+	}
+	for _, tt := range tests {
+		if got := bodyPreview([]byte(tt.body), tt.max); got != tt.want {
+			t.Errorf("bodyPreview(%q, %d) = %q, want %q", tt.body, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestClient_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/secret":
+			http.Error(w, "{\"error\": \"no token\"}", http.StatusUnauthorized)
+		case "/api/broken":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			w.Header().Set("X-Trace", "t1")
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var data Response
+	err := c.ReadJson("/api/missing", &data)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Header.Get("X-Trace") != "t1" {
+		t.Errorf("Unexpected APIError %+v", apiErr)
+	}
+	if string(apiErr.Body) != "404 page not found\n" {
+		t.Errorf("Unexpected body %q", apiErr.Body)
+	}
+	if !IsNotFound(err) || IsUnauthorized(err) {
+		t.Errorf("Expected IsNotFound only")
+	}
+	if !strings.Contains(err.Error(), "unexpected status 404 Not Found: 404 page not found") {
+		t.Errorf("Unexpected message %q", err)
+	}
+
+	if err := c.ReadJson("/api/secret", &data); !IsUnauthorized(err) {
+		t.Errorf("Expected IsUnauthorized, got %v", err)
+	}
+	if err := c.DeleteJson("/api/broken", nil); !IsServerError(err) || StatusCode(err) != 500 {
+		t.Errorf("Expected IsServerError, got %v", err)
+	}
+	if StatusCode(errors.New("other")) != 0 {
+		t.Errorf("Expected 0 for errors without status")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ExperimentsEnv is the environment variable listing, comma separated, the
+// experiments NewClient enables in addition to those given
+// EnableExperimental, so a deployment can turn them on without a code
+// change.
+const ExperimentsEnv = "RELAX_EXPERIMENTS"
+
+// ErrUnknownExperiment is returned by NewClient and Profile.NewClient, and
+// by the requests of a Clone, for an experiment this version of relax does
+// not have, usually a typo or one that graduated and was removed.
+var ErrUnknownExperiment = errors.New("unknown experiment")
+
+// Experimental subsystems ship dark: they are registered here when they
+// land and do nothing until a client enables them by name. Once stable
+// they are enabled by default and their name removed.
+var experiments = struct {
+	sync.RWMutex
+	doc map[string]string
+}{doc: map[string]string{}}
+
+// experimental registers the experiment name, described by doc, and
+// returns name. Subsystems call it from a package variable and check the
+// result with Client.experiment:
+//
+//	var expHedging = experimental("hedging", "send slow GETs again to another connection")
+func experimental(name, doc string) string {
+	experiments.Lock()
+	defer experiments.Unlock()
+	experiments.doc[name] = doc
+	return name
+}
+
+// Experiments returns the experiments this version of relax has, mapped to
+// their description.
+func Experiments() map[string]string {
+	experiments.RLock()
+	defer experiments.RUnlock()
+	known := make(map[string]string, len(experiments.doc))
+	for name, doc := range experiments.doc {
+		known[name] = doc
+	}
+	return known
+}
+
+// EnableExperimental enables the named experiments. They may change or
+// go away in any release. See Experiments for those available.
+//
+// A name that is not known fails NewClient with ErrUnknownExperiment, and
+// every request of a client made by Clone, so a typo does not leave an
+// experiment silently off.
+func EnableExperimental(names ...string) Option {
+	return func(c *Client) {
+		c.Experimental = append(c.Experimental[:len(c.Experimental):len(c.Experimental)], names...)
+	}
+}
+
+// checkExperiments returns an ErrUnknownExperiment for the first of names
+// that is not registered.
+func checkExperiments(names []string) error {
+	experiments.RLock()
+	defer experiments.RUnlock()
+	for _, name := range names {
+		if _, ok := experiments.doc[name]; !ok {
+			known := make([]string, 0, len(experiments.doc))
+			for k := range experiments.doc {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("%w %q, expected one of [%s]", ErrUnknownExperiment, name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// enableExperiments adds the experiments listed in ExperimentsEnv to those
+// of c and checks that those of c exist. Unknown names in ExperimentsEnv
+// are only reported to the OnError of the Logging hook, so a stale
+// deployment setting does not stop every client from being made.
+func (c *Client) enableExperiments() error {
+	if err := checkExperiments(c.Experimental); err != nil {
+		return err
+	}
+	for _, name := range strings.Split(os.Getenv(ExperimentsEnv), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if err := checkExperiments([]string{name}); err != nil {
+			if c.Logging != nil {
+				c.Logging.Hook.OnError(&LogEvent{Err: fmt.Errorf("ignoring %w from %s", err, ExperimentsEnv)})
+			}
+			continue
+		}
+		c.Experimental = append(c.Experimental[:len(c.Experimental):len(c.Experimental)], name)
+	}
+	return nil
+}
+
+// experiment reports whether the experiment name is enabled for c.
+func (c *Client) experiment(name string) bool {
+	for _, n := range c.Experimental {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledExperiments returns the distinct experiments of c, sorted.
+func (c *Client) enabledExperiments() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range c.Experimental {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
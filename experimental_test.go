@@ -0,0 +1,159 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withExperiment registers the experiment name for the duration of the
+// test.
+func withExperiment(t *testing.T, name string) string {
+	experimental(name, "a test experiment")
+	t.Cleanup(func() {
+		experiments.Lock()
+		delete(experiments.doc, name)
+		experiments.Unlock()
+	})
+	return name
+}
+
+func TestClient_EnableExperimental(t *testing.T) {
+	t.Setenv(ExperimentsEnv, "")
+	wings := withExperiment(t, "test-wings")
+	withExperiment(t, "test-fins")
+
+	if doc := Experiments()[wings]; doc != "a test experiment" {
+		t.Errorf("Expected the registered experiment to be listed, got %q", doc)
+	}
+
+	c, err := NewClient("http://example.com", apiKey, EnableExperimental(wings, wings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.experiment(wings) || c.experiment("test-fins") {
+		t.Errorf("Expected only %s to be enabled, got %v", wings, c.Experimental)
+	}
+	if got := c.Describe().Experiments; len(got) != 1 || got[0] != wings {
+		t.Errorf("Expected the experiment once in the description, got %v", got)
+	}
+	if !strings.Contains(c.Describe().String(), "experiments: test-wings\n") {
+		t.Errorf("Expected an experiments line in\n%s", c.Describe())
+	}
+
+	d := c.Clone(EnableExperimental("test-fins"))
+	if !d.experiment(wings) || !d.experiment("test-fins") {
+		t.Errorf("Expected the clone to add to the experiments of its parent, got %v", d.Experimental)
+	}
+	if c.experiment("test-fins") {
+		t.Errorf("Expected the parent not to see the experiments of its clone")
+	}
+
+	plain := newClientOrFatal(t, "http://example.com", apiKey)
+	if plain.experiment(wings) || len(plain.Describe().Experiments) != 0 {
+		t.Errorf("Expected experiments to be off by default")
+	}
+}
+
+func TestClient_ExperimentsEnv(t *testing.T) {
+	withExperiment(t, "test-wings")
+	withExperiment(t, "test-fins")
+	t.Setenv(ExperimentsEnv, " test-fins, ,test-wings")
+
+	c, err := NewClient("http://example.com", apiKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.experiment("test-wings") || !c.experiment("test-fins") {
+		t.Errorf("Expected the experiments of %s to be enabled, got %v", ExperimentsEnv, c.Experimental)
+	}
+}
+
+func TestClient_UnknownExperiment(t *testing.T) {
+	withExperiment(t, "test-wings")
+	t.Setenv(ExperimentsEnv, "")
+
+	_, err := NewClient("http://example.com", apiKey, func(c *Client) { c.Experimental = []string{"test-wigns"} })
+	if !errors.Is(err, ErrUnknownExperiment) {
+		t.Fatalf("Expected ErrUnknownExperiment, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"test-wigns"`) || !strings.Contains(err.Error(), "test-wings") {
+		t.Errorf("Expected the error to name the experiment and those known, got %v", err)
+	}
+
+	if _, err := NewClient("http://example.com", apiKey, EnableExperimental("test-wigns")); !errors.Is(err, ErrUnknownExperiment) {
+		t.Errorf("Expected EnableExperimental to fail NewClient, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	c := newClientOrFatal(t, server.URL, apiKey)
+	d := c.Clone(EnableExperimental("test-wigns"))
+	for _, cl := range []*Client{d, d.Clone()} {
+		if err := cl.ReadJson("/api/foo", nil); !errors.Is(err, ErrUnknownExperiment) {
+			t.Errorf("Expected the requests of the clone to fail, got %v", err)
+		}
+	}
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Errorf("Expected the parent to be unaffected, got %v", err)
+	}
+}
+
+func TestClient_UnknownExperimentEnv(t *testing.T) {
+	withExperiment(t, "test-wings")
+	t.Setenv(ExperimentsEnv, "nope,test-wings")
+
+	var warnings []error
+	hook := HookFuncs{Error: func(e *LogEvent) { warnings = append(warnings, e.Err) }}
+	c, err := NewClient("http://example.com", apiKey, func(c *Client) { c.Logging = &LogConfig{Hook: hook} })
+	if err != nil {
+		t.Fatalf("Expected unknown names from %s not to fail NewClient, got %v", ExperimentsEnv, err)
+	}
+	if !c.experiment("test-wings") || c.experiment("nope") {
+		t.Errorf("Expected only the known experiment to be enabled, got %v", c.Experimental)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrUnknownExperiment) || !strings.Contains(warnings[0].Error(), `"nope"`) {
+		t.Errorf("Expected one warning through the Logging hook, got %v", warnings)
+	}
+
+	if _, err := NewClient("http://example.com", apiKey); err != nil {
+		t.Errorf("Expected a client without Logging to ignore the name quietly, got %v", err)
+	}
+}
+
+func TestProfile_Experiments(t *testing.T) {
+	withExperiment(t, "test-wings")
+	t.Setenv(ExperimentsEnv, "")
+
+	profiles, err := ParseProfiles(strings.NewReader(`{"dev": {"base_url": "http://example.com", "api_key_env": "RELAX_TEST_KEY", "experiments": ["test-wings"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RELAX_TEST_KEY", "key")
+	c, err := profiles.NewClient("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.experiment("test-wings") {
+		t.Errorf("Expected the experiments of the profile to be enabled, got %v", c.Experimental)
+	}
+}
+
+func TestProfile_UnknownExperiment(t *testing.T) {
+	t.Setenv(ExperimentsEnv, "")
+	profiles, err := ParseProfiles(strings.NewReader(`{"dev": {"base_url": "http://example.com", "experiments": ["test-wigns"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := profiles.NewClient("dev", WithAuthenticator(BearerAuth("t"))); !errors.Is(err, ErrUnknownExperiment) {
+		t.Errorf("Expected ErrUnknownExperiment for the profile, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// FailoverMode selects the endpoint a request is sent to first.
+type FailoverMode int
+
+const (
+	// FailoverOrdered always tries the base URL first, then the fallbacks
+	// in order. This is the default.
+	FailoverOrdered FailoverMode = iota
+
+	// FailoverRoundRobin starts each request at the next endpoint in turn,
+	// spreading load over all of them.
+	FailoverRoundRobin
+)
+
+// FailoverConfig serves the API from several base URLs, e.g. one per
+// region. A request to the client's base URL that fails is sent again to
+// the next endpoint, with the part of its path below the base URL kept.
+// Failover happens within each attempt, so with a RetryPolicy every retry
+// tries the endpoints again.
+type FailoverConfig struct {
+	// Fallbacks are the base URLs tried after the client's own.
+	Fallbacks []string
+
+	Mode FailoverMode
+
+	// IsFailure, when set, decides which responses move on to the next
+	// endpoint. Defaults to network errors and 5xx responses.
+	IsFailure func(res *http.Response, err error) bool
+
+	// NonIdempotent also fails over POST and PATCH requests that carry no
+	// idempotency key, which may repeat their side effects.
+	NonIdempotent bool
+}
+
+// WithFailover sends requests that fail to the fallback base URLs, as
+// configured by cfg.
+func WithFailover(cfg *FailoverConfig) Option {
+	return func(c *Client) {
+		c.Failover = cfg
+	}
+}
+
+// WithFallbackURLs fails over to fallbacks, in order.
+func WithFallbackURLs(fallbacks ...string) Option {
+	return WithFailover(&FailoverConfig{Fallbacks: fallbacks})
+}
+
+func (f *FailoverConfig) failed(res *http.Response, err error) bool {
+	if f.IsFailure != nil {
+		return f.IsFailure(res, err)
+	}
+	return err != nil || res.StatusCode >= 500
+}
+
+// endpoints returns the base URLs to try, starting at the one mode
+// selects.
+func (c *Client) endpoints() ([]*url.URL, error) {
+	all := []*url.URL{c.url}
+	for _, s := range c.Failover.Fallbacks {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("fallback URL %q: %w", s, err)
+		}
+		if !u.IsAbs() {
+			return nil, fmt.Errorf("fallback URL %q is not absolute", s)
+		}
+		all = append(all, u)
+	}
+	if c.Failover.Mode == FailoverRoundRobin {
+		start := int(atomic.AddUint32(&c.failoverNext, 1)-1) % len(all)
+		all = append(all[start:], all[:start]...)
+	}
+	return all, nil
+}
+
+// sendWithFailover sends r to its endpoint and, if that fails, to the
+// others in turn. Requests not made to the base URL are sent as they are.
+func (c *Client) sendWithFailover(r *http.Request) (*http.Response, error) {
+	if err := c.checkMaintenance(r); err != nil {
+		return nil, err
+	}
+	f := c.Failover
+	if f == nil || len(f.Fallbacks) == 0 || r.URL.Host != c.url.Host {
+		return c.sendWithKeys(r)
+	}
+	endpoints, err := c.endpoints()
+	if err != nil {
+		return nil, err
+	}
+	canRepeat := f.NonIdempotent || isIdempotent(r, c.idempotencyHeader())
+
+	var res *http.Response
+	for i, base := range endpoints {
+		if i > 0 {
+			if err := rewindBody(r); err != nil {
+				return nil, err
+			}
+		}
+		res, err = c.sendWithKeys(retarget(r, c.url, base))
+		last := i == len(endpoints)-1
+		if last || !canRepeat || r.Context().Err() != nil || !f.failed(res, err) {
+			break
+		}
+		if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+			break
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+	}
+	return res, err
+}
+
+// retarget returns a copy of r sent to target instead of base.
+func retarget(r *http.Request, base, target *url.URL) *http.Request {
+	if target == base {
+		return r
+	}
+	out := r.Clone(r.Context())
+	u := *r.URL
+	u.Scheme, u.Host, u.User = target.Scheme, target.Host, target.User
+
+	p := u.EscapedPath()
+	from := strings.TrimRight(base.EscapedPath(), "/")
+	if strings.HasPrefix(p, from) {
+		p = strings.TrimRight(target.EscapedPath(), "/") + p[len(from):]
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = unescaped, p
+		}
+	}
+	if out.Host == r.URL.Host {
+		out.Host = ""
+	}
+	out.URL = &u
+	return out
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newRegionServer answers with status, echoing its name and the request
+// path into Foo when it succeeds.
+func newRegionServer(name string, status int, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if status != http.StatusOK {
+			http.Error(w, "down", status)
+			return
+		}
+		w.Write([]byte(`{"Foo": "` + name + ` ` + r.URL.Path + `"}`))
+	}))
+}
+
+func TestClient_Failover(t *testing.T) {
+	var primaryCalls, fallbackCalls int32
+	primary := newRegionServer("primary", http.StatusServiceUnavailable, &primaryCalls)
+	defer primary.Close()
+	fallback := newRegionServer("fallback", http.StatusOK, &fallbackCalls)
+	defer fallback.Close()
+
+	c, err := NewClient(primary.URL+"/v1/", apiKey, WithFallbackURLs(fallback.URL+"/eu/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response Response
+	if err := c.ReadJson("users/42", &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "fallback /eu/v1/users/42" {
+		t.Errorf("Expected the fallback to answer below its base path, got %q", response.Foo)
+	}
+
+	atomic.StoreInt32(&fallbackCalls, 0)
+	err = c.CreateJson("users", "bar", &response)
+	if !IsServerError(err) || atomic.LoadInt32(&fallbackCalls) != 0 {
+		t.Errorf("Expected a POST without idempotency key not to fail over, got %v", err)
+	}
+	if err := c.CreateJson("users", "bar", &response, WithIdempotencyKey("k")); err != nil {
+		t.Errorf("Expected a POST with idempotency key to fail over, got %v", err)
+	}
+}
+
+func TestClient_FailoverNetworkError(t *testing.T) {
+	var calls int32
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	up := newRegionServer("up", http.StatusOK, &calls)
+	defer up.Close()
+
+	c, err := NewClient(down.URL, apiKey, WithFallbackURLs(up.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	if err := c.ReadJson("/api/foo", &response); err != nil || response.Foo != "up /api/foo" {
+		t.Errorf("Expected the fallback to answer, got %q, %v", response.Foo, err)
+	}
+}
+
+func TestClient_FailoverRoundRobin(t *testing.T) {
+	var aCalls, bCalls int32
+	a := newRegionServer("a", http.StatusOK, &aCalls)
+	defer a.Close()
+	b := newRegionServer("b", http.StatusOK, &bCalls)
+	defer b.Close()
+
+	c, err := NewClient(a.URL, apiKey, WithFailover(&FailoverConfig{Fallbacks: []string{b.URL}, Mode: FailoverRoundRobin}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := c.ReadJson("/api/foo", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if aCalls != 2 || bCalls != 2 {
+		t.Errorf("Expected requests to alternate, got %d and %d", aCalls, bCalls)
+	}
+}
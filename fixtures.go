@@ -0,0 +1,151 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FixtureConfig saves the latest real response of every route and status
+// to a directory, as a starting point for the golden files and replay
+// cassettes of a new integration. It is a development aid, usually turned
+// on by a flag or environment variable:
+//
+//	relax.WithFixtureCapture(os.Getenv("CAPTURE_FIXTURES"))
+//
+// A GET of /users/{id} answered with 200 is saved as users/{id}/GET.200.json,
+// using the route given WithRoute or WithPathParams, or else the URL path.
+// Next to each body, GET.200.meta.json holds its ArchiveRecord with
+// Set-Cookie removed. Bodies not read to the end are not saved.
+type FixtureConfig struct {
+	Dir string
+
+	// Match, when set, selects the responses to save. Defaults to all.
+	Match func(r *http.Request, res *http.Response) bool
+
+	// OnError, when set, is told about fixtures that could not be saved.
+	OnError func(err error)
+}
+
+// WithFixtureCapture saves responses as fixtures in dir. An empty dir
+// leaves capture off.
+func WithFixtureCapture(dir string) Option {
+	return func(c *Client) {
+		if dir == "" {
+			c.Fixtures = nil
+			return
+		}
+		c.Fixtures = &FixtureConfig{Dir: dir}
+	}
+}
+
+// archive returns the archive configuration writing the fixtures.
+func (f *FixtureConfig) archive() *ArchiveConfig {
+	return &ArchiveConfig{Sink: fixtureSink{dir: f.Dir}, Match: f.Match, OnError: f.OnError}
+}
+
+type fixtureSink struct {
+	dir string
+}
+
+func (s fixtureSink) Open(ctx context.Context, rec *ArchiveRecord) (io.WriteCloser, error) {
+	route, _ := ctx.Value(routeContextKey{}).(string)
+	if route == "" {
+		u, err := url.Parse(rec.URL)
+		if err != nil {
+			return nil, err
+		}
+		route = u.Path
+	}
+	dir := filepath.Join(s.dir, fixtureDir(route))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s.%d", rec.Method, rec.StatusCode))
+	f, err := os.Create(base + fixtureExt(rec.Header.Get("Content-Type")) + ".part")
+	if err != nil {
+		return nil, err
+	}
+	return &fixtureFile{File: f, base: base, rec: rec}, nil
+}
+
+// fixtureDir turns route into a relative directory, one per segment.
+func fixtureDir(route string) string {
+	var parts []string
+	for _, seg := range strings.Split(route, "/") {
+		seg = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`<>:"\|?*`, r) || r < ' ' {
+				return '_'
+			}
+			return r
+		}, seg)
+		switch seg {
+		case "", ".":
+			continue
+		case "..":
+			seg = "__"
+		}
+		parts = append(parts, seg)
+	}
+	if len(parts) == 0 {
+		return "_root"
+	}
+	return filepath.Join(parts...)
+}
+
+// fixtureExt returns the file extension for bodies of contentType.
+func fixtureExt(contentType string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		return ".json"
+	case mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml"):
+		return ".xml"
+	case mt == "text/html":
+		return ".html"
+	case strings.HasPrefix(mt, "text/"):
+		return ".txt"
+	}
+	return ".bin"
+}
+
+// fixtureFile moves its body into place, replacing the previous fixture,
+// once the whole body was read.
+type fixtureFile struct {
+	*os.File
+	base string
+	rec  *ArchiveRecord
+}
+
+func (f *fixtureFile) Close() error {
+	part := f.File.Name()
+	if err := f.File.Close(); err != nil {
+		os.Remove(part)
+		return err
+	}
+	if f.rec.Truncated {
+		return os.Remove(part)
+	}
+	if err := os.Rename(part, strings.TrimSuffix(part, ".part")); err != nil {
+		return err
+	}
+	rec := *f.rec
+	rec.Header = rec.Header.Clone()
+	rec.Header.Del("Set-Cookie")
+	meta, err := json.MarshalIndent(&rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.base+".meta.json", meta)
+}
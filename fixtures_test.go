@@ -0,0 +1,91 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient_FixtureCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.URL.Path == "/api/users/404" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "not found"}`))
+			return
+		}
+		w.Write([]byte(`{"Foo": "` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := newClientOrFatal(t, server.URL, apiKey)
+	WithFixtureCapture(dir)(c)
+
+	var response Response
+	if err := c.ReadJson("/api/users/{id}", &response, WithPathParams(Path{"id": 1})); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReadJson("/api/users/{id}", &response, WithPathParams(Path{"id": 2})); err != nil {
+		t.Fatal(err)
+	}
+	c.ReadJson("/api/users/404", &response)
+	if err := c.ReadJson("/", &response); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := ioutil.ReadFile(filepath.Join(dir, "api", "users", "{id}", "GET.200.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"Foo": "/api/users/2"}` {
+		t.Errorf("Expected the latest response of the route, got %s", body)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "api", "users", "404", "GET.404.json")); err != nil {
+		t.Errorf("Expected error responses to be saved by status: %s", err)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "_root", "GET.200.json")); err != nil {
+		t.Errorf("Expected the root path to be saved: %s", err)
+	}
+
+	meta, err := ioutil.ReadFile(filepath.Join(dir, "api", "users", "{id}", "GET.200.meta.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec ArchiveRecord
+	if err := json.Unmarshal(meta, &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.StatusCode != 200 || rec.Header.Get("Set-Cookie") != "" || rec.Header.Get("Content-Type") == "" {
+		t.Errorf("Unexpected fixture record %+v", rec)
+	}
+}
+
+func TestWithFixtureCapture_Empty(t *testing.T) {
+	c := newClientOrFatal(t, "http://example.com", apiKey)
+	WithFixtureCapture("")(c)
+	if c.Fixtures != nil {
+		t.Error("Expected an empty directory to leave capture off")
+	}
+}
+
+func TestFixtureDir(t *testing.T) {
+	for route, want := range map[string]string{
+		"/users/{id}/":   filepath.Join("users", "{id}"),
+		"/":              "_root",
+		"/a/../b:c":      filepath.Join("a", "__", "b_c"),
+		"users/./orders": filepath.Join("users", "orders"),
+	} {
+		if got := fixtureDir(route); got != want {
+			t.Errorf("Expected %q for %q, got %q", want, route, got)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FormContentType is the Content-Type of bodies encoded by EncodeForm.
+const FormContentType = "application/x-www-form-urlencoded"
+
+// EncodeForm encodes v as form values in the bracket syntax of Rack and
+// PHP. v may be url.Values, a map with string keys or a struct, or a
+// pointer to one of them.
+//
+// Struct fields are encoded under their `form` tag, with the options of the
+// `url` tag of EncodeQuery:
+//
+//	type Order struct {
+//		Email   string            `form:"email"`
+//		Note    string            `form:"note,omitempty"`
+//		Tags    []string          `form:"tags"`    // tags[]=a&tags[]=b
+//		Address Address           `form:"address"` // address[city]=Paris
+//		Items   []Item            `form:"items"`   // items[0][sku]=A1
+//		Meta    map[string]string `form:"meta"`    // meta[source]=web
+//	}
+func EncodeForm(v interface{}) (url.Values, error) {
+	values := make(url.Values)
+	if v == nil {
+		return values, nil
+	}
+	if uv, ok := v.(url.Values); ok {
+		for k, vs := range uv {
+			values[k] = append(values[k], vs...)
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	switch {
+	case rv.Kind() == reflect.Struct && !isScalar(rv.Type()):
+		return values, encodeFormStruct(values, "", rv)
+	case rv.Kind() == reflect.Map:
+		return values, encodeFormMap(values, "", rv)
+	}
+	return nil, fmt.Errorf("cannot encode %s as form", rv.Type())
+}
+
+// Form wraps v so the JSON helpers send it as a form body encoded by
+// EncodeForm instead of with the call's Codec:
+//
+//	c.CreateJson("/orders", relax.Form(order), &created)
+//
+// The response is still decoded as usual.
+func Form(v interface{}) interface{} {
+	return formBody{v}
+}
+
+type formBody struct {
+	v interface{}
+}
+
+// setFormBody encodes f as the form body of r.
+func setFormBody(r *http.Request, f formBody) error {
+	values, err := EncodeForm(f.v)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", FormContentType)
+	setBody(r, []byte(values.Encode()))
+	return nil
+}
+
+// formKey returns name nested below prefix.
+func formKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "[" + name + "]"
+}
+
+func encodeFormStruct(values url.Values, prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+
+		tag := sf.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+
+		if sf.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !isScalar(fv.Type()) {
+				if err := encodeFormStruct(values, prefix, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if err := encodeFormValue(values, formKey(prefix, name), fv, hasTagOption(opts, "omitempty"), hasTagOption(opts, "comma")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeFormMap(values url.Values, prefix string, rv reflect.Value) error {
+	if rv.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot encode %s as form: keys must be strings", rv.Type())
+	}
+	iter := rv.MapRange()
+	for iter.Next() {
+		if err := encodeFormValue(values, formKey(prefix, iter.Key().String()), iter.Value(), false, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeFormValue(values url.Values, key string, fv reflect.Value, omitempty, comma bool) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	if omitempty && fv.IsZero() {
+		return nil
+	}
+	if isScalar(fv.Type()) {
+		return addFormScalar(values, key, fv)
+	}
+
+	switch fv.Kind() {
+	case reflect.Struct:
+		return encodeFormStruct(values, key, fv)
+	case reflect.Map:
+		return encodeFormMap(values, key, fv)
+	case reflect.Slice, reflect.Array:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			return addFormScalar(values, key, fv)
+		}
+		if comma {
+			return (&QueryEncoding{Lists: ListComma}).encodeField(values, key, fv, omitempty)
+		}
+		for i := 0; i < fv.Len(); i++ {
+			elem := fv.Index(i)
+			for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+				if elem.IsNil() {
+					break
+				}
+				elem = elem.Elem()
+			}
+			if k := elem.Kind(); (k == reflect.Struct || k == reflect.Map || k == reflect.Slice || k == reflect.Array) && !isScalar(elem.Type()) {
+				// Indexes keep the fields of each element together.
+				if err := encodeFormValue(values, key+"["+strconv.Itoa(i)+"]", elem, false, false); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := addFormScalar(values, key+"[]", elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return addFormScalar(values, key, fv)
+}
+
+func addFormScalar(values url.Values, key string, v reflect.Value) error {
+	s, err := queryString(v)
+	if err != nil {
+		return fmt.Errorf("form field %s: %s", key, err)
+	}
+	values.Add(key, s)
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type formAddress struct {
+	City string `form:"city"`
+	Zip  string `form:"zip,omitempty"`
+}
+
+type formItem struct {
+	SKU string `form:"sku"`
+	Qty int    `form:"qty"`
+}
+
+type formOrder struct {
+	Email   string            `form:"email"`
+	Note    string            `form:"note,omitempty"`
+	Tags    []string          `form:"tags"`
+	IDs     []int             `form:"ids,comma"`
+	Address formAddress       `form:"address"`
+	Items   []formItem        `form:"items"`
+	Meta    map[string]string `form:"meta"`
+	Secret  string            `form:"-"`
+	Plain   bool
+}
+
+func TestEncodeForm(t *testing.T) {
+	values, err := EncodeForm(&formOrder{
+		Email:   "a@example.com",
+		Tags:    []string{"x", "y"},
+		IDs:     []int{1, 2},
+		Address: formAddress{City: "Paris"},
+		Items:   []formItem{{"A1", 2}, {"B2", 1}},
+		Meta:    map[string]string{"source": "web"},
+		Secret:  "s",
+		Plain:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := url.Values{
+		"email":         {"a@example.com"},
+		"tags[]":        {"x", "y"},
+		"ids":           {"1,2"},
+		"address[city]": {"Paris"},
+		"items[0][sku]": {"A1"},
+		"items[0][qty]": {"2"},
+		"items[1][sku]": {"B2"},
+		"items[1][qty]": {"1"},
+		"meta[source]":  {"web"},
+		"Plain":         {"true"},
+	}
+	if values.Encode() != want.Encode() {
+		t.Errorf("Expected %s, got %s", want.Encode(), values.Encode())
+	}
+
+	if _, err := EncodeForm(42); err == nil {
+		t.Errorf("Expected a scalar to fail")
+	}
+}
+
+func TestClient_CreateForm(t *testing.T) {
+	var contentType string
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"Foo": "created"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	var response Response
+	if err := c.CreateJson("/api/orders", Form(formOrder{Email: "a@example.com", Tags: []string{"x"}}), &response); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != FormContentType {
+		t.Errorf("Expected a form body, got %q", contentType)
+	}
+	if form.Get("email") != "a@example.com" || form.Get("tags[]") != "x" {
+		t.Errorf("Unexpected form %v", form)
+	}
+	if response.Foo != "created" {
+		t.Errorf("Expected the JSON response to be decoded, got %q", response.Foo)
+	}
+}
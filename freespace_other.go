@@ -0,0 +1,13 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package relax
+
+// freeSpace is not implemented on this platform; disk space checks are
+// skipped.
+func freeSpace(dir string) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// HeaderMergeMode selects how values for the same header from different
+// layers combine.
+//
+// Layers are applied in a fixed order, lowest precedence first:
+//
+//  1. Client.DefaultHeader
+//  2. Client.ContextHeaders
+//  3. the request itself: per-call options, then anything set on the
+//     *http.Request before GetResponse
+//
+// Authentication and request ID headers are applied after merging.
+type HeaderMergeMode int
+
+const (
+	// HeaderReplace lets a higher layer replace all values from lower
+	// layers. This is the default.
+	HeaderReplace HeaderMergeMode = iota
+
+	// HeaderAppend keeps the values of every layer, lowest layer first.
+	HeaderAppend
+
+	// HeaderKeep keeps the values of the lowest layer that set the header,
+	// so e.g. a default cannot be overridden per call.
+	HeaderKeep
+)
+
+// HeaderPolicy controls header merging. The zero value replaces and
+// canonicalizes.
+type HeaderPolicy struct {
+	// Default is the mode for headers not listed in Modes.
+	Default HeaderMergeMode
+
+	// Modes sets the mode per header. Names are matched case-insensitively.
+	Modes map[string]HeaderMergeMode
+
+	// PreserveCase sends header names with the case of the layer that first
+	// set them instead of canonicalizing them, for servers that wrongly
+	// treat names as case-sensitive.
+	PreserveCase bool
+}
+
+func (p *HeaderPolicy) mode(name string) HeaderMergeMode {
+	if p == nil {
+		return HeaderReplace
+	}
+	for k, m := range p.Modes {
+		if strings.EqualFold(k, name) {
+			return m
+		}
+	}
+	return p.Default
+}
+
+// merge merges layer into dst according to the policy.
+func (p *HeaderPolicy) merge(dst, layer http.Header) {
+	for name, values := range layer {
+		key, exists := findHeader(dst, name)
+		if !exists {
+			key = name
+			if p == nil || !p.PreserveCase {
+				key = textproto.CanonicalMIMEHeaderKey(name)
+			}
+		}
+
+		switch p.mode(name) {
+		case HeaderAppend:
+			dst[key] = append(dst[key], values...)
+		case HeaderKeep:
+			if !exists {
+				dst[key] = append([]string(nil), values...)
+			}
+		default:
+			dst[key] = append([]string(nil), values...)
+		}
+	}
+}
+
+// findHeader returns the key under which h stores name, matching
+// case-insensitively.
+func findHeader(h http.Header, name string) (string, bool) {
+	if _, ok := h[name]; ok {
+		return name, true
+	}
+	for k := range h {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// mergeHeaders replaces the headers of r with the merge of all header
+// layers.
+func (c *Client) mergeHeaders(r *http.Request) {
+	h := make(http.Header)
+	c.HeaderPolicy.merge(h, c.DefaultHeader)
+	c.HeaderPolicy.merge(h, c.contextHeaders(r))
+	if o := overridesOf(r); o != nil {
+		c.HeaderPolicy.merge(h, o.Header)
+	}
+	c.HeaderPolicy.merge(h, r.Header)
+	r.Header = h
+}
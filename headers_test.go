@@ -0,0 +1,68 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestClient_HeaderPrecedence(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.DefaultHeader = http.Header{"Accept-Language": {"en"}, "X-Tags": {"default"}, "X-Fixed": {"platform"}}
+	c.ContextHeaders = map[string]HeaderExtractor{
+		"accept-language": func(ctx context.Context) (string, bool) { return "fr", true },
+	}
+	c.HeaderPolicy = &HeaderPolicy{Modes: map[string]HeaderMergeMode{
+		"x-tags":  HeaderAppend,
+		"X-Fixed": HeaderKeep,
+	}}
+
+	req, err := c.MakeRequest(http.MethodGet, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Tags", "call")
+	req.Header.Set("X-Fixed", "override")
+
+	if _, err := c.GetResponse(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := got.Header["Accept-Language"]; !reflect.DeepEqual(v, []string{"fr"}) {
+		t.Errorf("Expected context header to replace default, got %v", v)
+	}
+	if v := got.Header["X-Tags"]; !reflect.DeepEqual(v, []string{"default", "call"}) {
+		t.Errorf("Expected appended values in layer order, got %v", v)
+	}
+	if v := got.Header["X-Fixed"]; !reflect.DeepEqual(v, []string{"platform"}) {
+		t.Errorf("Expected kept default, got %v", v)
+	}
+}
+
+func TestHeaderPolicy_PreserveCase(t *testing.T) {
+	p := &HeaderPolicy{PreserveCase: true}
+	h := http.Header{}
+
+	p.merge(h, http.Header{"x-api-TOKEN": {"a"}})
+	p.merge(h, http.Header{"X-Api-Token": {"b"}})
+
+	if !reflect.DeepEqual(h, http.Header{"x-api-TOKEN": {"b"}}) {
+		t.Errorf("Expected original case with replaced value, got %v", h)
+	}
+
+	var canonical *HeaderPolicy
+	h = http.Header{}
+	canonical.merge(h, http.Header{"x-api-TOKEN": {"a"}})
+	if _, ok := h["X-Api-Token"]; !ok {
+		t.Errorf("Expected canonical key, got %v", h)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHealthAlpha is the smoothing factor used when HealthConfig.Alpha is
+// zero.
+const DefaultHealthAlpha = 0.2
+
+// HealthConfig enables exponentially smoothed health tracking per route.
+type HealthConfig struct {
+	// Alpha is the weight of each new sample, between 0 and 1. Higher
+	// values react faster. Defaults to DefaultHealthAlpha.
+	Alpha float64
+
+	// LatencyTarget is the latency a healthy route should stay under. When
+	// set, routes slower than it have their score reduced proportionally.
+	LatencyTarget time.Duration
+}
+
+// Health is the smoothed state of a single route.
+type Health struct {
+	Route       string
+	Samples     int64
+	SuccessRate float64
+	Latency     time.Duration
+
+	// Score is SuccessRate, scaled down by how far Latency exceeds the
+	// configured LatencyTarget. 1 is perfectly healthy, 0 is down.
+	Score float64
+}
+
+type healthTracker struct {
+	mu     sync.Mutex
+	routes map[string]*Health
+}
+
+// routeKey identifies the route of r for per-route bookkeeping: the method
+// and the route given WithRoute or WithPathParams, or else the URL path.
+// Give a route to calls on paths holding IDs, or every ID is a route.
+func routeKey(r *http.Request) string {
+	if route := routeOf(r); route != "" {
+		return r.Method + " " + route
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// isFailure reports whether a response or error counts against a route.
+// Requests the client refused itself say nothing of the server.
+func isFailure(res *http.Response, err error) bool {
+	if err != nil {
+		return !isLocalRefusal(err)
+	}
+	return res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+}
+
+// isLocalRefusal reports whether err is the client's throttle refusing a
+// request, which never reached the server.
+func isLocalRefusal(err error) bool {
+	return errors.Is(err, ErrThrottleQueueFull) || errors.Is(err, ErrThrottleWaitExceeded)
+}
+
+func (h *healthTracker) record(cfg *HealthConfig, route string, ok bool, latency time.Duration) {
+	alpha := cfg.Alpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultHealthAlpha
+	}
+	success := 0.0
+	if ok {
+		success = 1
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.routes == nil {
+		h.routes = make(map[string]*Health)
+	}
+	s, found := h.routes[route]
+	if !found {
+		s = &Health{Route: route, SuccessRate: success, Latency: latency}
+		h.routes[route] = s
+	} else {
+		s.SuccessRate = alpha*success + (1-alpha)*s.SuccessRate
+		s.Latency = time.Duration(alpha*float64(latency) + (1-alpha)*float64(s.Latency))
+	}
+	s.Samples++
+
+	s.Score = s.SuccessRate
+	if cfg.LatencyTarget > 0 && s.Latency > cfg.LatencyTarget {
+		s.Score *= float64(cfg.LatencyTarget) / float64(s.Latency)
+	}
+}
+
+// Health returns the health of route, given as "METHOD /path", or with the
+// route given WithRoute in place of the path when there is one. The bool is
+// false when nothing has been recorded for it.
+func (c *Client) Health(route string) (Health, bool) {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+
+	s, ok := c.health.routes[route]
+	if !ok {
+		return Health{}, false
+	}
+	return *s, true
+}
+
+// HealthScores returns the health of every route seen so far, sorted by
+// route.
+func (c *Client) HealthScores() []Health {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+
+	scores := make([]Health, 0, len(c.health.routes))
+	for _, s := range c.health.routes {
+		scores = append(scores, *s)
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].Route < scores[j].Route })
+	return scores
+}
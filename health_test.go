@@ -0,0 +1,64 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_HealthTracking(t *testing.T) {
+	var fail int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.HealthTracking = &HealthConfig{Alpha: 0.5}
+
+	if _, ok := c.Health("GET /api/foo"); ok {
+		t.Fatalf("Expected no health before any request")
+	}
+
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	h, ok := c.Health("GET /api/foo")
+	if !ok || h.Score != 1 || h.Samples != 1 {
+		t.Errorf("Expected a perfect score after success, got %+v", h)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	c.ReadJson("/api/foo", nil)
+	c.ReadJson("/api/foo", nil)
+
+	h, _ = c.Health("GET /api/foo")
+	if h.SuccessRate != 0.25 || h.Samples != 3 {
+		t.Errorf("Expected success rate 0.25 after two failures, got %+v", h)
+	}
+
+	if scores := c.HealthScores(); len(scores) != 1 || scores[0].Route != "GET /api/foo" {
+		t.Errorf("Unexpected scores %+v", scores)
+	}
+}
+
+func TestHealthTracker_LatencyTarget(t *testing.T) {
+	var h healthTracker
+	cfg := &HealthConfig{LatencyTarget: 100 * time.Millisecond}
+
+	h.record(cfg, "GET /", true, 200*time.Millisecond)
+
+	if s := h.routes["GET /"]; s.Score != 0.5 {
+		t.Errorf("Expected slow route to score 0.5, got %v", s.Score)
+	}
+}
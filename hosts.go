@@ -0,0 +1,41 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+var dialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// newTransport returns a copy of http.DefaultTransport that dials through
+// the client so Hosts overrides apply.
+func (c *Client) newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = c.dialContext
+	return t
+}
+
+// dialContext dials addr, replacing its host with the entry from c.Hosts if
+// there is one. The request URL is untouched, so TLS still verifies the
+// certificate against the original host name.
+func (c *Client) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if len(c.Hosts) > 0 {
+		host, port, err := net.SplitHostPort(addr)
+		if err == nil {
+			if override, ok := c.Hosts[host]; ok {
+				if _, _, err := net.SplitHostPort(override); err == nil {
+					addr = override
+				} else {
+					addr = net.JoinHostPort(override, port)
+				}
+			}
+		}
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
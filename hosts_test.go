@@ -0,0 +1,40 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClient_Hosts(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: "{\"Foo\": \"bar\"}", Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	su, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClientOrFatal(t, "http://api.vendor.invalid:"+su.Port(), apiKey)
+	c.Hosts = map[string]string{"api.vendor.invalid": su.Hostname()}
+
+	var data Response
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Foo != "bar" {
+		t.Errorf("Expected data.Foo to be \"bar\", got \"%s\"", data.Foo)
+	}
+
+	c.Hosts = map[string]string{"api.vendor.invalid": su.Host}
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+}
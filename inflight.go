@@ -0,0 +1,78 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// inflight counts requests whose response body has not been closed yet.
+type inflight struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // closed when n drops to zero
+}
+
+func (f *inflight) add() {
+	f.mu.Lock()
+	if f.n == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.n++
+	f.mu.Unlock()
+}
+
+func (f *inflight) done() {
+	f.mu.Lock()
+	f.n--
+	if f.n == 0 {
+		close(f.idle)
+	}
+	f.mu.Unlock()
+}
+
+// trackedBody marks the request finished when the body is closed.
+type trackedBody struct {
+	io.ReadCloser
+	once sync.Once
+	f    *inflight
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.f.done)
+	return err
+}
+
+// InFlight returns the number of requests that have been sent but whose
+// response body has not been closed yet.
+func (c *Client) InFlight() int {
+	c.inflight.mu.Lock()
+	defer c.inflight.mu.Unlock()
+	return c.inflight.n
+}
+
+// Wait blocks until no requests are in flight or ctx is done, in which case
+// it returns ctx.Err(). Responses from GetResponse count until their body is
+// closed, and FireAndForget calls until they complete.
+func (c *Client) Wait(ctx context.Context) error {
+	for {
+		c.inflight.mu.Lock()
+		if c.inflight.n == 0 {
+			c.inflight.mu.Unlock()
+			return nil
+		}
+		idle := c.inflight.idle
+		c.inflight.mu.Unlock()
+
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_InFlightAndWait(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.ReadJson("/", nil)
+	}()
+	<-arrived
+
+	if n := c.InFlight(); n != 1 {
+		t.Errorf("Expected 1 request in flight, got %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	close(release)
+	if err := c.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := c.InFlight(); n != 0 {
+		t.Errorf("Expected no requests in flight, got %d", n)
+	}
+}
+
+func TestClient_WaitFireAndForget(t *testing.T) {
+	var handled int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&handled, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if err := c.CreateJson("/", "x", nil, FireAndForget(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.InFlight(); n != 1 {
+		t.Errorf("Expected the fire-and-forget call to be in flight, got %d", n)
+	}
+	if err := c.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&handled) != 1 {
+		t.Errorf("Expected Wait to return after the fire-and-forget call completed")
+	}
+}
+
+func TestClient_WaitDuringRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(50 * time.Millisecond)}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.ReadJson("/", nil)
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := c.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected Wait to return after the retry, got %d calls", n)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if n := c.InFlight(); n != 0 {
+		t.Errorf("Expected no requests in flight, got %d", n)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+)
+
+// InformationalFunc is called with each 1xx interim response received
+// before the final response of r, such as 103 Early Hints. It runs on the
+// connection's goroutine and should return quickly. Interim responses
+// never reach decoding.
+type InformationalFunc func(r *http.Request, code int, header http.Header)
+
+// WithInformational calls fn with the interim responses of every request.
+func WithInformational(fn InformationalFunc) Option {
+	return func(c *Client) {
+		c.Informational = fn
+	}
+}
+
+// OnInformational calls fn with the interim responses of this call, in
+// addition to the client's Informational.
+func OnInformational(fn InformationalFunc) RequestOption {
+	return func(o *callOptions) {
+		o.informational = fn
+	}
+}
+
+// WithExpectContinue sends Expect: 100-continue, so the server can reject
+// the request, e.g. for its size or credentials, before the body is sent.
+func WithExpectContinue() RequestOption {
+	return func(o *callOptions) {
+		o.setHeader("Expect", "100-continue")
+	}
+}
+
+type informationalContextKey struct{}
+
+func withInformational(r *http.Request, fn InformationalFunc) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), informationalContextKey{}, fn))
+}
+
+// traceInformational reports the interim responses of the attempt r to
+// the client's and the call's InformationalFuncs.
+func (c *Client) traceInformational(r *http.Request) *http.Request {
+	call, _ := r.Context().Value(informationalContextKey{}).(InformationalFunc)
+	if c.Informational == nil && call == nil {
+		return r
+	}
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			h := http.Header(header).Clone()
+			if c.Informational != nil {
+				c.Informational(r, code, h)
+			}
+			if call != nil {
+				call(r, code, h)
+			}
+			return nil
+		},
+	}
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+}
+
+// Link is a target of a Link header, as sent in 103 Early Hints.
+type Link struct {
+	URL string
+	Rel string
+
+	// Params holds the other parameters, like "as" or "crossorigin",
+	// by lowercase name.
+	Params map[string]string
+}
+
+// ParseLinks returns the targets of the Link headers of h in order, e.g.
+// the resources a 103 Early Hints response asks to preload.
+func ParseLinks(h http.Header) []Link {
+	var links []Link
+	for _, v := range h.Values("Link") {
+		for _, s := range strings.Split(v, ",") {
+			parts := strings.Split(s, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			link := Link{URL: target[1 : len(target)-1], Params: make(map[string]string)}
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				name := strings.ToLower(strings.TrimSpace(kv[0]))
+				value := ""
+				if len(kv) == 2 {
+					value = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+				}
+				if name == "rel" {
+					link.Rel = value
+				} else if name != "" {
+					link.Params[name] = value
+				}
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
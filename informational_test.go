@@ -0,0 +1,62 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_EarlyHints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</style.css>; rel=preload; as=style, </app.js>; rel=preload; as=script`)
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	var clientCodes []int
+	var hinted []Link
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Informational = func(r *http.Request, code int, header http.Header) {
+		clientCodes = append(clientCodes, code)
+	}
+
+	var response Response
+	err := c.ReadJson("/api/foo", &response, OnInformational(func(r *http.Request, code int, header http.Header) {
+		hinted = append(hinted, ParseLinks(header)...)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Foo != "bar" {
+		t.Errorf("Expected the final response to be decoded, got %q", response.Foo)
+	}
+	if len(clientCodes) != 1 || clientCodes[0] != http.StatusEarlyHints {
+		t.Errorf("Expected one 103, got %v", clientCodes)
+	}
+	if len(hinted) != 2 || hinted[0].URL != "/style.css" || hinted[0].Rel != "preload" || hinted[1].Params["as"] != "script" {
+		t.Errorf("Unexpected hints %+v", hinted)
+	}
+}
+
+func TestClient_ExpectContinue(t *testing.T) {
+	var expect string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect = r.Header.Get("Expect")
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if err := c.CreateJson("/api/foo", "body", nil, WithExpectContinue()); err != nil {
+		t.Fatal(err)
+	}
+	if expect != "100-continue" {
+		t.Errorf("Expected Expect: 100-continue, got %q", expect)
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// DefaultJournalRedact lists the headers whose values a Journal replaces
+// with "[REDACTED]" when Journal.Redact is nil.
+var DefaultJournalRedact = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+const redacted = "[REDACTED]"
+
+// JournalEntry is one request attempt recorded by a Journal.
+type JournalEntry struct {
+	Time           time.Time     `json:"time"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url"`
+	RequestHeader  http.Header   `json:"request_header,omitempty"`
+	RequestBody    string        `json:"request_body,omitempty"`
+	Status         int           `json:"status,omitempty"`
+	ResponseHeader http.Header   `json:"response_header,omitempty"`
+	ResponseBody   string        `json:"response_body,omitempty"`
+	Duration       time.Duration `json:"duration"`
+	Error          string        `json:"error,omitempty"`
+	size           int
+	dropped        bool
+}
+
+// Journal keeps the most recent request attempts in memory, bounded by
+// count and bytes, so they can be dumped when diagnosing an incident after
+// the fact. Set it on Client.Journal.
+type Journal struct {
+	// MaxEntries and MaxBytes bound the journal; the oldest entries are
+	// dropped first. Zero means no limit on that dimension.
+	MaxEntries int
+	MaxBytes   int
+
+	// MaxBodyBytes caps the request and response body kept per entry.
+	// Zero keeps no bodies.
+	MaxBodyBytes int
+
+	// Redact lists headers, and query parameters, whose values are not
+	// recorded. Defaults to DefaultJournalRedact.
+	Redact []string
+
+	// RedactFields lists JSON object keys, matched case-insensitively at
+	// any depth, whose values are replaced in recorded bodies, e.g.
+	// "password" or "access_token". Bodies are then read whole to be
+	// redacted before MaxBodyBytes applies.
+	RedactFields []string
+
+	mu      sync.Mutex
+	entries []*JournalEntry
+	bytes   int
+}
+
+// NewJournal returns a Journal keeping at most maxEntries entries and
+// maxBytes bytes.
+func NewJournal(maxEntries, maxBytes int) *Journal {
+	return &Journal{MaxEntries: maxEntries, MaxBytes: maxBytes}
+}
+
+// Entries returns a copy of the recorded entries, oldest first.
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := make([]JournalEntry, len(j.entries))
+	for i, e := range j.entries {
+		out[i] = *e
+	}
+	return out
+}
+
+// Dump writes the recorded entries to w as JSON lines, oldest first.
+func (j *Journal) Dump(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range j.Entries() {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DumpOnSignal dumps the journal to w each time one of sigs is received,
+// e.g. syscall.SIGUSR1. Call the returned func to stop.
+func (j *Journal) DumpOnSignal(w io.Writer, sigs ...os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				j.Dump(w)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+func (j *Journal) redactList() []string {
+	if j.Redact == nil {
+		return DefaultJournalRedact
+	}
+	return j.Redact
+}
+
+func (j *Journal) redactHeader(h http.Header) http.Header {
+	return redactHeader(h, j.redactList())
+}
+
+func (j *Journal) redactURL(r *http.Request) string {
+	return redactRequestURL(r, j.redactList())
+}
+
+// redactHeader returns a copy of h with the values of names replaced.
+func redactHeader(h http.Header, names []string) http.Header {
+	if h == nil {
+		return nil
+	}
+	out := h.Clone()
+	for _, name := range names {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out[http.CanonicalHeaderKey(name)] = []string{redacted}
+		}
+	}
+	return out
+}
+
+// redactURL returns u without user info and with the values of the query
+// parameters in names replaced.
+func redactURL(u *url.URL, names []string) string {
+	cp := *u
+	cp.User = nil
+	q := cp.Query()
+	changed := false
+	for _, name := range names {
+		if _, ok := q[name]; ok {
+			q[name] = []string{redacted}
+			changed = true
+		}
+	}
+	if changed {
+		cp.RawQuery = q.Encode()
+	}
+	return cp.String()
+}
+
+// redactRequestURL is redactURL for the URL of r, also replacing the query
+// parameters the client authenticated r with, such as that of QueryAuth.
+func redactRequestURL(r *http.Request, names []string) string {
+	if cred := requestCredentials(r); cred != nil && len(cred.query) > 0 {
+		names = append(append([]string(nil), names...), cred.query...)
+	}
+	return redactURL(r.URL, names)
+}
+
+// record adds an entry for the attempt r and, when there is a response,
+// returns it with a body that captures what the caller reads.
+func (j *Journal) record(r *http.Request, res *http.Response, err error, start time.Time) *http.Response {
+	e := &JournalEntry{
+		Time:          start,
+		Method:        r.Method,
+		URL:           j.redactURL(r),
+		RequestHeader: j.redactHeader(r.Header),
+		Duration:      time.Since(start),
+	}
+	if j.MaxBodyBytes > 0 && r.GetBody != nil {
+		if body, gerr := r.GetBody(); gerr == nil {
+			var b []byte
+			if len(j.RedactFields) > 0 {
+				b, _ = ioutil.ReadAll(body)
+			} else {
+				b, _ = ioutil.ReadAll(io.LimitReader(body, int64(j.MaxBodyBytes)))
+			}
+			body.Close()
+			e.RequestBody = j.body(b)
+		}
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if res != nil {
+		e.Status = res.StatusCode
+		e.ResponseHeader = j.redactHeader(res.Header)
+	}
+	j.add(e)
+
+	if res != nil && j.MaxBodyBytes > 0 {
+		res.Body = &journalBody{ReadCloser: res.Body, j: j, e: e}
+	}
+	return res
+}
+
+// body redacts and truncates a body for recording.
+func (j *Journal) body(b []byte) string {
+	if len(j.RedactFields) > 0 {
+		b = redactJSONFields(b, j.RedactFields)
+	}
+	if len(b) > j.MaxBodyBytes {
+		b = b[:j.MaxBodyBytes]
+	}
+	return string(b)
+}
+
+func entrySize(e *JournalEntry) int {
+	n := len(e.Method) + len(e.URL) + len(e.RequestBody) + len(e.ResponseBody) + len(e.Error)
+	for _, h := range []http.Header{e.RequestHeader, e.ResponseHeader} {
+		for k, vs := range h {
+			n += len(k)
+			for _, v := range vs {
+				n += len(v)
+			}
+		}
+	}
+	return n
+}
+
+func (j *Journal) add(e *JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e.size = entrySize(e)
+	j.entries = append(j.entries, e)
+	j.bytes += e.size
+	j.trim()
+}
+
+// trim drops the oldest entries until the journal is within its bounds. The
+// newest entry is always kept.
+func (j *Journal) trim() {
+	for len(j.entries) > 1 &&
+		((j.MaxEntries > 0 && len(j.entries) > j.MaxEntries) || (j.MaxBytes > 0 && j.bytes > j.MaxBytes)) {
+		j.bytes -= j.entries[0].size
+		j.entries[0].dropped = true
+		j.entries[0] = nil
+		j.entries = j.entries[1:]
+	}
+}
+
+// journalBody captures up to MaxBodyBytes of a response body into its entry
+// when closed, or all of it to be redacted with RedactFields.
+type journalBody struct {
+	io.ReadCloser
+	j   *Journal
+	e   *JournalEntry
+	buf []byte
+}
+
+func (b *journalBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if len(b.j.RedactFields) > 0 {
+		b.buf = append(b.buf, p[:n]...)
+		return n, err
+	}
+	if room := b.j.MaxBodyBytes - len(b.buf); room > 0 && n > 0 {
+		if n < room {
+			room = n
+		}
+		b.buf = append(b.buf, p[:room]...)
+	}
+	return n, err
+}
+
+func (b *journalBody) Close() error {
+	b.j.mu.Lock()
+	if !b.e.dropped && b.e.ResponseBody == "" && len(b.buf) > 0 {
+		b.e.ResponseBody = b.j.body(b.buf)
+		old := b.e.size
+		b.e.size = entrySize(b.e)
+		b.j.bytes += b.e.size - old
+		b.j.trim()
+	}
+	b.j.mu.Unlock()
+	return b.ReadCloser.Close()
+}
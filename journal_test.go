@@ -0,0 +1,131 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Journal(t *testing.T) {
+	handler := responseHandler{Method: http.MethodPost, Message: "{\"Foo\": \"bar\"}", Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Journal = NewJournal(2, 0)
+	c.Journal.MaxBodyBytes = 8
+	c.Journal.Redact = append(DefaultJournalRedact, "token")
+
+	for i := 0; i < 3; i++ {
+		if err := c.CreateJson(fmt.Sprintf("/api/foo?n=%d&token=secret", i), Response{Foo: "x"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries := c.Journal.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	e := entries[1]
+	if !strings.Contains(e.URL, "n=2") || strings.Contains(e.URL, "secret") {
+		t.Errorf("Expected the newest, redacted URL, got %q", e.URL)
+	}
+	if v := e.RequestHeader.Get("Authorization"); v != redacted {
+		t.Errorf("Expected Authorization to be redacted, got %q", v)
+	}
+	if e.Status != 200 || e.RequestBody != `{"Foo":"` || e.ResponseBody != `{"Foo": ` {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Journal.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	var dumped JournalEntry
+	if err := json.Unmarshal([]byte(lines[0]), &dumped); err != nil {
+		t.Fatal(err)
+	}
+	if dumped.Method != "POST" {
+		t.Errorf("Expected a POST entry, got %q", dumped.Method)
+	}
+}
+
+func TestJournal_MaxBytes(t *testing.T) {
+	j := NewJournal(0, 40)
+	for i := 0; i < 5; i++ {
+		j.add(&JournalEntry{Method: "GET", URL: "https://example.com/a"})
+	}
+	if n := len(j.Entries()); n != 1 {
+		t.Errorf("Expected the byte bound to keep 1 entry, got %d", n)
+	}
+}
+
+func TestJournal_RedactFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"user": {"name": "ann", "Access_Token": "tok-123"}, "zpadding": "` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Journal = NewJournal(0, 0)
+	c.Journal.MaxBodyBytes = 60
+	c.Journal.RedactFields = []string{"password", "access_token"}
+
+	body := map[string]interface{}{"login": "ann", "password": "hunter2", "zpadding": strings.Repeat("y", 100)}
+	if err := c.CreateJson("/api/session", body, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	e := c.Journal.Entries()[0]
+	if strings.Contains(e.RequestBody, "hunter2") || !strings.Contains(e.RequestBody, `"password":"[REDACTED]"`) {
+		t.Errorf("Expected the password to be redacted, got %q", e.RequestBody)
+	}
+	if strings.Contains(e.ResponseBody, "tok-123") || !strings.Contains(e.ResponseBody, `"Access_Token":"[REDACTED]"`) {
+		t.Errorf("Expected the token to be redacted, got %q", e.ResponseBody)
+	}
+	if len(e.RequestBody) != 60 || len(e.ResponseBody) != 60 {
+		t.Errorf("Expected bodies truncated after redaction, got %d and %d bytes", len(e.RequestBody), len(e.ResponseBody))
+	}
+}
+
+func TestClient_JournalRedactsQueryAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	rec := &recordingTelemetry{}
+	sink := &bufferSink{}
+	c, err := NewClient(server.URL, apiKey, WithAuthenticator(QueryAuth("key", "s3cret")), WithTelemetry(&TelemetryConfig{Tracer: rec}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Journal = NewJournal(0, 0)
+	c.Archive = &ArchiveConfig{Sink: sink}
+
+	var data Response
+	if err := c.ReadJson("/api/foo?page=2", &data); err != nil {
+		t.Fatal(err)
+	}
+	for name, u := range map[string]string{
+		"journal":   c.Journal.Entries()[0].URL,
+		"telemetry": rec.spans[0].attrs["url.full"].(string),
+		"archive":   sink.records[0].URL,
+	} {
+		if strings.Contains(u, "s3cret") || !strings.Contains(u, "key=%5BREDACTED%5D") || !strings.Contains(u, "page=2") {
+			t.Errorf("Expected the key to be redacted in the %s URL, got %q", name, u)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"sync"
+)
+
+// JSONArray wraps seq so the JSON helpers send it as a JSON array, encoding
+// each element as the request body is sent instead of building the whole
+// payload in memory first:
+//
+//	c.CreateJson("/import", relax.JSONArray(rowsFromDB(ctx)), &report)
+//
+// An error from seq aborts the request, so the server never receives a
+// truncated but valid array. The body is sent as JSON whatever the call's
+// Codec, with chunked transfer encoding, and cannot be replayed: the
+// request is not retried, and Compression reads it into memory.
+func JSONArray[T any](seq iter.Seq2[T, error]) interface{} {
+	return streamBody{func(yield func(interface{}) bool) error {
+		for v, err := range seq {
+			if err != nil {
+				return err
+			}
+			if !yield(v) {
+				return nil
+			}
+		}
+		return nil
+	}}
+}
+
+// JSONArrayChan is like JSONArray for the values received from ch until it
+// is closed.
+func JSONArrayChan[T any](ch <-chan T) interface{} {
+	return streamBody{func(yield func(interface{}) bool) error {
+		for v := range ch {
+			if !yield(v) {
+				return nil
+			}
+		}
+		return nil
+	}}
+}
+
+type streamBody struct {
+	each func(yield func(interface{}) bool) error
+}
+
+// setStreamBody makes s the body of r.
+func setStreamBody(r *http.Request, s streamBody) {
+	r.Header.Set("Content-Type", JSONCodec.ContentType())
+	r.ContentLength = -1
+	r.Body = &jsonStreamReader{each: s.each}
+	r.GetBody = nil
+}
+
+// jsonStreamReader encodes its values into a pipe once first read, so no
+// goroutine is left behind for a request that is never sent.
+type jsonStreamReader struct {
+	each func(yield func(interface{}) bool) error
+
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (s *jsonStreamReader) start() {
+	pr, pw := io.Pipe()
+	s.pr = pr
+	go func() {
+		pw.CloseWithError(s.encode(pw))
+	}()
+}
+
+func (s *jsonStreamReader) encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := bw.WriteByte('['); err != nil {
+		return err
+	}
+	first := true
+	var werr error
+	err := s.each(func(v interface{}) bool {
+		b, err := json.Marshal(v)
+		if err != nil {
+			werr = err
+			return false
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		_, werr = bw.Write(b)
+		return werr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if werr != nil {
+		return werr
+	}
+	if err := bw.WriteByte(']'); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (s *jsonStreamReader) Read(p []byte) (int, error) {
+	s.once.Do(s.start)
+	return s.pr.Read(p)
+}
+
+// Close stops the encoding, which fails its next write.
+func (s *jsonStreamReader) Close() error {
+	s.once.Do(func() {})
+	if s.pr != nil {
+		return s.pr.Close()
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"io/ioutil"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newEchoBodyServer(t *testing.T, got *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*got = r.Header.Get("Content-Type") + " " + string(body)
+		if len(r.TransferEncoding) == 0 || r.TransferEncoding[0] != "chunked" {
+			*got += " (not chunked)"
+		}
+		w.Write([]byte(`{"Foo": "ok"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_JSONArray(t *testing.T) {
+	var got string
+	server := newEchoBodyServer(t, &got)
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	seq := func(yield func(Response, error) bool) {
+		for _, foo := range []string{"a", "b", "c"} {
+			if !yield(Response{Foo: foo}, nil) {
+				return
+			}
+		}
+	}
+	var data Response
+	if err := c.CreateJson("/import", JSONArray[Response](seq), &data); err != nil {
+		t.Fatal(err)
+	}
+	if want := `application/json [{"Foo":"a"},{"Foo":"b"},{"Foo":"c"}]`; got != want || data.Foo != "ok" {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	empty := func(yield func(int, error) bool) {}
+	if err := c.CreateJson("/import", JSONArray[int](empty), nil); err != nil || got != "application/json []" {
+		t.Errorf("Expected an empty array, got %q, %v", got, err)
+	}
+}
+
+func TestClient_JSONArrayChan(t *testing.T) {
+	var got string
+	server := newEchoBodyServer(t, &got)
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	ch := make(chan int)
+	go func() {
+		for i := 1; i <= 3; i++ {
+			ch <- i
+		}
+		close(ch)
+	}()
+	if err := c.CreateJson("/import", JSONArrayChan(ch), nil); err != nil || got != "application/json [1,2,3]" {
+		t.Errorf("Expected the channel values, got %q, %v", got, err)
+	}
+}
+
+func TestClient_JSONArrayError(t *testing.T) {
+	var got string
+	server := newEchoBodyServer(t, &got)
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, RetryNonIdempotent: true}
+
+	errBroken := errors.New("cursor broken")
+	var seq iter.Seq2[int, error] = func(yield func(int, error) bool) {
+		if yield(1, nil) {
+			yield(0, errBroken)
+		}
+	}
+	err := c.CreateJson("/import", JSONArray(seq), nil)
+	if !errors.Is(err, errBroken) {
+		t.Errorf("Expected the producer error, got %v", err)
+	}
+	if got != "" {
+		t.Errorf("Expected no complete body to reach the server, got %q", got)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+)
+
+// DefaultKindField is the discriminator field used when NewKinds is given
+// an empty one.
+const DefaultKindField = "type"
+
+// UnknownKindError reports an object whose discriminator selects no
+// registered type.
+type UnknownKindError struct {
+	Field string
+	Kind  string
+}
+
+func (e *UnknownKindError) Error() string {
+	if e.Kind == "" {
+		return fmt.Sprintf("object has no %q field", e.Field)
+	}
+	return fmt.Sprintf("unknown %s %q", e.Field, e.Kind)
+}
+
+// Kinds decodes JSON objects into implementations of the interface T,
+// choosing the concrete type by a discriminator field, for endpoints like
+// event feeds that mix object kinds in one list:
+//
+//	var events = relax.NewKinds[Event]("type").
+//		Register("created", &Created{}).
+//		Register("deleted", &Deleted{})
+//
+//	var feed []Event
+//	err := c.ReadJson("/events", &feed, relax.WithDecoder(events))
+//
+// As a Decoder it fills a *T or a *[]T and decodes anything else as plain
+// JSON. Types registered as pointers are decoded as pointers. Register all
+// kinds before decoding.
+type Kinds[T any] struct {
+	field    string
+	types    map[string]reflect.Type
+	fallback reflect.Type
+}
+
+// NewKinds returns a registry selecting types by field, or by
+// DefaultKindField if field is empty.
+func NewKinds[T any](field string) *Kinds[T] {
+	if field == "" {
+		field = DefaultKindField
+	}
+	return &Kinds[T]{field: field, types: make(map[string]reflect.Type)}
+}
+
+// Register decodes objects whose discriminator is kind into the type of
+// example.
+func (k *Kinds[T]) Register(kind string, example T) *Kinds[T] {
+	k.types[kind] = reflect.TypeOf(example)
+	return k
+}
+
+// Default decodes objects of unregistered kinds, or without a
+// discriminator, into the type of example instead of failing with an
+// UnknownKindError.
+func (k *Kinds[T]) Default(example T) *Kinds[T] {
+	k.fallback = reflect.TypeOf(example)
+	return k
+}
+
+// Unmarshal decodes the JSON object data into the type its discriminator
+// selects.
+func (k *Kinds[T]) Unmarshal(data []byte) (T, error) {
+	var zero T
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return zero, err
+	}
+	kind := ""
+	if raw, ok := fields[k.field]; ok {
+		if json.Unmarshal(raw, &kind) != nil {
+			// Numbers and other scalars select by their JSON text.
+			kind = string(raw)
+		}
+	}
+	typ, ok := k.types[kind]
+	if !ok {
+		if k.fallback == nil {
+			return zero, &UnknownKindError{Field: k.field, Kind: kind}
+		}
+		typ = k.fallback
+	}
+
+	var v, out reflect.Value
+	if typ.Kind() == reflect.Ptr {
+		v = reflect.New(typ.Elem())
+		out = v
+	} else {
+		v = reflect.New(typ)
+		out = v.Elem()
+	}
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return zero, fmt.Errorf("%s %q: %w", k.field, kind, err)
+	}
+	return out.Interface().(T), nil
+}
+
+// UnmarshalList decodes the JSON array data, each object into the type its
+// discriminator selects.
+func (k *Kinds[T]) UnmarshalList(data []byte) ([]T, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, err
+	}
+	if raws == nil {
+		return nil, nil
+	}
+	list := make([]T, len(raws))
+	for i, raw := range raws {
+		item, err := k.Unmarshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		list[i] = item
+	}
+	return list, nil
+}
+
+// Decode implements Decoder.
+func (k *Kinds[T]) Decode(r io.Reader, v interface{}) error {
+	switch out := v.(type) {
+	case *T:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		item, err := k.Unmarshal(data)
+		if err != nil {
+			return err
+		}
+		*out = item
+		return nil
+	case *[]T:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		list, err := k.UnmarshalList(data)
+		if err != nil {
+			return err
+		}
+		*out = list
+		return nil
+	}
+	return JSONDecoder.Decode(r, v)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testEvent interface {
+	eventID() string
+}
+
+type testCreated struct {
+	ID   string
+	Name string
+}
+
+func (e *testCreated) eventID() string { return e.ID }
+
+type testDeleted struct {
+	ID string
+}
+
+func (e testDeleted) eventID() string { return e.ID }
+
+type testOther struct {
+	ID   string
+	Type string
+}
+
+func (e testOther) eventID() string { return e.ID }
+
+func newTestKinds() *Kinds[testEvent] {
+	return NewKinds[testEvent]("").
+		Register("created", &testCreated{}).
+		Register("deleted", testDeleted{})
+}
+
+func TestClient_KindsDecodeList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"type": "created", "ID": "1", "Name": "a"}, {"type": "deleted", "ID": "2"}]`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	var feed []testEvent
+	if err := c.ReadJson("/events", &feed, WithDecoder(newTestKinds())); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(feed))
+	}
+	if e, ok := feed[0].(*testCreated); !ok || e.Name != "a" {
+		t.Errorf("Expected a *testCreated, got %#v", feed[0])
+	}
+	if e, ok := feed[1].(testDeleted); !ok || e.ID != "2" {
+		t.Errorf("Expected a testDeleted, got %#v", feed[1])
+	}
+}
+
+func TestKinds_Unmarshal(t *testing.T) {
+	k := newTestKinds()
+	e, err := k.Unmarshal([]byte(`{"ID": "1", "type": "created"}`))
+	if err != nil || e.eventID() != "1" {
+		t.Errorf("Expected the created event, got %#v, %v", e, err)
+	}
+
+	_, err = k.UnmarshalList([]byte(`[{"type": "deleted"}, {"type": "renamed"}]`))
+	var unknown *UnknownKindError
+	if !errors.As(err, &unknown) || unknown.Kind != "renamed" || err.Error() != `item 1: unknown type "renamed"` {
+		t.Errorf("Expected an unknown kind error, got %v", err)
+	}
+	if _, err := k.Unmarshal([]byte(`{"ID": "1"}`)); !errors.As(err, &unknown) || unknown.Kind != "" {
+		t.Errorf("Expected a missing discriminator to be reported, got %v", err)
+	}
+
+	k.Default(testOther{})
+	e, err = k.Unmarshal([]byte(`{"ID": "3", "type": "renamed"}`))
+	if o, ok := e.(testOther); err != nil || !ok || o.Type != "renamed" {
+		t.Errorf("Expected the default type, got %#v, %v", e, err)
+	}
+}
+
+func TestKinds_DecodeOtherValues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	var data Response
+	if err := c.ReadJson("/", &data, WithDecoder(newTestKinds())); err != nil || data.Foo != "bar" {
+		t.Errorf("Expected other values to decode as JSON, got %+v, %v", data, err)
+	}
+}
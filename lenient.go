@@ -0,0 +1,207 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF8 is passed to LenientJSONConfig.Warn when a body held
+// invalid UTF-8, which was replaced by U+FFFD before decoding.
+var ErrInvalidUTF8 = errors.New("body is not valid UTF-8")
+
+// DuplicateKeyMode selects how LenientJSON handles an object holding the
+// same key twice.
+type DuplicateKeyMode int
+
+const (
+	// DuplicateKeysLastWins keeps the last value, as encoding/json does.
+	DuplicateKeysLastWins DuplicateKeyMode = iota
+
+	// DuplicateKeysError fails decoding with a DuplicateKeyError.
+	DuplicateKeysError
+)
+
+// DuplicateKeyError reports an object holding Key more than once.
+type DuplicateKeyError struct {
+	// Path locates the object, like $.items[2].
+	Path string
+	Key  string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %q in %s", e.Key, e.Path)
+}
+
+// LenientJSONConfig configures LenientJSON.
+type LenientJSONConfig struct {
+	Duplicates DuplicateKeyMode
+
+	// Warn, when set, is told about what was repaired or tolerated: a
+	// *DuplicateKeyError for each duplicate kept under
+	// DuplicateKeysLastWins, and ErrInvalidUTF8.
+	Warn func(err error)
+}
+
+// LenientJSON returns a JSON codec for upstreams that occasionally send
+// malformed payloads. Before decoding, it replaces invalid UTF-8 by U+FFFD
+// and checks objects for duplicate keys, so such bodies either decode
+// predictably or fail with an error saying what is wrong:
+//
+//	c.Codec = relax.LenientJSON(relax.LenientJSONConfig{Duplicates: relax.DuplicateKeysError})
+//
+// Bodies are encoded as by JSONCodec.
+func LenientJSON(cfg LenientJSONConfig) Codec {
+	return lenientJSON{cfg}
+}
+
+type lenientJSON struct {
+	cfg LenientJSONConfig
+}
+
+func (l lenientJSON) Encode(w io.Writer, v interface{}) error {
+	return JSONCodec.Encode(w, v)
+}
+
+func (l lenientJSON) ContentType() string {
+	return JSONCodec.ContentType()
+}
+
+func (l lenientJSON) Decode(r io.Reader, v interface{}) error {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(body) {
+		body = bytes.ToValidUTF8(body, []byte("\uFFFD"))
+		l.warn(ErrInvalidUTF8)
+	}
+	err = checkDuplicateKeys(body, func(e *DuplicateKeyError) error {
+		if l.cfg.Duplicates == DuplicateKeysError {
+			return e
+		}
+		l.warn(e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (l lenientJSON) warn(err error) {
+	if l.cfg.Warn != nil {
+		l.cfg.Warn(err)
+	}
+}
+
+// jsonFrame is an object or array being scanned by checkDuplicateKeys.
+type jsonFrame struct {
+	path    string
+	object  bool
+	keys    map[string]bool
+	key     string
+	wantKey bool
+	index   int
+}
+
+// child returns the path of the value the frame is at.
+func (f *jsonFrame) child() string {
+	if f.object {
+		return jsonPathKey(f.path, f.key)
+	}
+	return f.path + "[" + strconv.Itoa(f.index) + "]"
+}
+
+// checkDuplicateKeys calls dup for every key found twice in an object of
+// data, stopping at the first error it returns. Syntax errors are left to
+// the decoder.
+func checkDuplicateKeys(data []byte, dup func(*DuplicateKeyError) error) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var stack []*jsonFrame
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := stack[len(stack)-1]; top.object {
+			top.wantKey = true
+		} else {
+			top.index++
+		}
+	}
+	push := func(object bool) {
+		path := "$"
+		if len(stack) > 0 {
+			path = stack[len(stack)-1].child()
+		}
+		f := &jsonFrame{path: path, object: object, wantKey: object}
+		if object {
+			f.keys = make(map[string]bool)
+		}
+		stack = append(stack, f)
+	}
+	pop := func() {
+		if len(stack) > 0 {
+			stack = stack[:len(stack)-1]
+		}
+		valueDone()
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].wantKey {
+			top := stack[n-1]
+			key, ok := tok.(string)
+			if !ok {
+				pop()
+				continue
+			}
+			if top.keys[key] {
+				if err := dup(&DuplicateKeyError{Path: top.path, Key: key}); err != nil {
+					return err
+				}
+			}
+			top.keys[key] = true
+			top.key = key
+			top.wantKey = false
+			continue
+		}
+		switch tok {
+		case json.Delim('{'):
+			push(true)
+		case json.Delim('['):
+			push(false)
+		case json.Delim(']'), json.Delim('}'):
+			pop()
+		default:
+			valueDone()
+		}
+	}
+}
+
+// jsonPathKey appends key to path in the syntax of lookupJSONPath.
+func jsonPathKey(path, key string) string {
+	for _, r := range key {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return path + "['" + key + "']"
+		}
+	}
+	if key == "" {
+		return path + "['']"
+	}
+	return path + "." + key
+}
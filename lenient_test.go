@@ -0,0 +1,80 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLenientJSON_Duplicates(t *testing.T) {
+	body := `{"a": 1, "items": [{"id": 1}, {"id": 2, "x": {"k": 1}, "id": 3}], "a": 2}`
+
+	var warnings []string
+	codec := LenientJSON(LenientJSONConfig{Warn: func(err error) {
+		warnings = append(warnings, err.Error())
+	}})
+	var v struct {
+		A     int
+		Items []struct{ ID int }
+	}
+	if err := codec.Decode(strings.NewReader(body), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 2 || v.Items[1].ID != 3 {
+		t.Errorf("Expected the last values to win, got %+v", v)
+	}
+	want := []string{`duplicate key "id" in $.items[1]`, `duplicate key "a" in $`}
+	if strings.Join(warnings, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected warnings %q, got %q", want, warnings)
+	}
+
+	codec = LenientJSON(LenientJSONConfig{Duplicates: DuplicateKeysError})
+	err := codec.Decode(strings.NewReader(body), &v)
+	var dup *DuplicateKeyError
+	if !errors.As(err, &dup) || dup.Path != "$.items[1]" || dup.Key != "id" {
+		t.Errorf("Expected a DuplicateKeyError for $.items[1].id, got %v", err)
+	}
+
+	if err := codec.Decode(strings.NewReader(`{"a b": {"c": 1, "c": 2}}`), &v); err == nil || err.Error() != `duplicate key "c" in $['a b']` {
+		t.Errorf("Expected a quoted path, got %v", err)
+	}
+}
+
+func TestLenientJSON_InvalidUTF8(t *testing.T) {
+	var warned error
+	codec := LenientJSON(LenientJSONConfig{Warn: func(err error) { warned = err }})
+
+	var v Response
+	if err := codec.Decode(strings.NewReader("{\"Foo\": \"caf\xe9\"}"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Foo != "caf\uFFFD" {
+		t.Errorf("Expected the invalid byte to be replaced, got %q", v.Foo)
+	}
+	if warned != ErrInvalidUTF8 {
+		t.Errorf("Expected ErrInvalidUTF8 to be reported, got %v", warned)
+	}
+}
+
+func TestClient_LenientJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "a", "Foo": "b"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Codec = LenientJSON(LenientJSONConfig{Duplicates: DuplicateKeysError})
+
+	var response Response
+	err := c.ReadJson("/", &response)
+	var dup *DuplicateKeyError
+	if !errors.As(err, &dup) || dup.Key != "Foo" {
+		t.Errorf("Expected the call to fail with a DuplicateKeyError, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// LocationMode controls how 201, 302 and 303 responses carrying a Location
+// header are handled by the JSON helpers.
+type LocationMode int
+
+const (
+	// LocationIgnore leaves redirects to the underlying http.Client. This is
+	// the default.
+	LocationIgnore LocationMode = iota
+
+	// LocationReturn stops at the 201, 302 or 303 response and records the
+	// parsed Location in the call's Result.
+	LocationReturn
+
+	// LocationFollow records the Location like LocationReturn and then issues
+	// a GET for it, decoding that response instead.
+	LocationFollow
+)
+
+var errTooManyRedirects = errors.New("stopped after 10 redirects")
+
+func isLocationStatus(code int) bool {
+	switch code {
+	case http.StatusCreated, http.StatusFound, http.StatusSeeOther:
+		return true
+	}
+	return false
+}
+
+// checkRedirect stops the http.Client from following 302 and 303 responses
+// when the client wants to see them, and otherwise records the hop and
+// defers to the policy of the http.Client given WithHTTPClient.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.Location != LocationIgnore && req.Response != nil && isLocationStatus(req.Response.StatusCode) {
+		return http.ErrUseLastResponse
+	}
+	hops := recordRedirect(req, via)
+	if err := c.checkPolicies(req); err != nil {
+		return err
+	}
+	if c.userCheckRedirect != nil {
+		return c.userCheckRedirect(req, via)
+	}
+	if len(via) >= 10 {
+		if hops == nil {
+			hops = hopsOf(req, via)
+		}
+		return &RedirectError{Hops: hops, Err: errTooManyRedirects}
+	}
+	return nil
+}
+
+// handleLocation records the Location of res and, in LocationFollow mode,
+// returns the response of a GET for it. The returned bool reports whether
+// res was a redirect that should not be decoded.
+func (c *Client) handleLocation(req *http.Request, res *http.Response) (*http.Response, bool, error) {
+	if c.Location == LocationIgnore || !isLocationStatus(res.StatusCode) {
+		return res, false, nil
+	}
+
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return res, false, nil
+	}
+
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, false, err
+	}
+	u = req.URL.ResolveReference(u)
+	if result := callResult(req); result != nil {
+		result.Location = u
+	}
+
+	if c.Location != LocationFollow {
+		return res, res.StatusCode != http.StatusCreated, nil
+	}
+
+	res.Body.Close()
+
+	follow, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	follow = follow.WithContext(req.Context())
+
+	res, err = c.GetResponse(follow)
+	if err != nil {
+		return nil, false, err
+	}
+	return res, false, nil
+}
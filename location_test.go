@@ -0,0 +1,70 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newLocationServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/things", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/api/things/1")
+		w.WriteHeader(http.StatusSeeOther)
+		w.Write([]byte("<html>see other</html>"))
+	})
+	mux.HandleFunc("/api/things/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "wrong method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("{\"Foo\": \"created\"}"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient_LocationReturn(t *testing.T) {
+	server := newLocationServer()
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Location = LocationReturn
+
+	var response Response
+	if err := c.CreateJson("/api/things", map[string]string{"Name": "x"}, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.LastLocation == nil || c.LastLocation.String() != server.URL+"/api/things/1" {
+		t.Errorf("Unexpected LastLocation %v", c.LastLocation)
+	}
+
+	if response.Foo != "" {
+		t.Errorf("Expected redirect body not to be decoded, got %q", response.Foo)
+	}
+}
+
+func TestClient_LocationFollow(t *testing.T) {
+	server := newLocationServer()
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Location = LocationFollow
+
+	var response Response
+	if err := c.CreateJson("/api/things", map[string]string{"Name": "x"}, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.LastLocation == nil || c.LastLocation.Path != "/api/things/1" {
+		t.Errorf("Unexpected LastLocation %v", c.LastLocation)
+	}
+
+	if response.Foo != "created" {
+		t.Errorf("Expected followed resource to be decoded, got %q", response.Foo)
+	}
+}
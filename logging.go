@@ -0,0 +1,253 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LogEvent describes a request attempt to a Hook. Headers, URL query
+// parameters and JSON body fields are redacted as configured in the
+// LogConfig; bodies are only set when LogConfig.MaxBodyBytes is positive.
+type LogEvent struct {
+	Method        string
+	URL           string
+	RequestHeader http.Header
+	RequestBody   []byte
+
+	// Set for OnResponse.
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+
+	// Latency is the time since the attempt started. Set for OnResponse
+	// and OnError.
+	Latency time.Duration
+
+	// Redirects are the redirects followed before the response or error.
+	Redirects []RedirectHop
+
+	// Err is set for OnError.
+	Err error
+}
+
+// Hook observes every request attempt the client makes.
+type Hook interface {
+	// OnRequest is called before the request is sent.
+	OnRequest(e *LogEvent)
+
+	// OnResponse is called when a response arrives or, when bodies are
+	// logged, once its body has been read and closed.
+	OnResponse(e *LogEvent)
+
+	// OnError is called when no response arrives. NewClient also reports
+	// unknown experiments named in ExperimentsEnv to it, with only Err
+	// set.
+	OnError(e *LogEvent)
+}
+
+// HookFuncs adapts functions to the Hook interface. Nil funcs are skipped.
+type HookFuncs struct {
+	Request  func(e *LogEvent)
+	Response func(e *LogEvent)
+	Error    func(e *LogEvent)
+}
+
+func (h HookFuncs) OnRequest(e *LogEvent) {
+	if h.Request != nil {
+		h.Request(e)
+	}
+}
+
+func (h HookFuncs) OnResponse(e *LogEvent) {
+	if h.Response != nil {
+		h.Response(e)
+	}
+}
+
+func (h HookFuncs) OnError(e *LogEvent) {
+	if h.Error != nil {
+		h.Error(e)
+	}
+}
+
+// LogConfig enables structured logging of requests through a Hook.
+type LogConfig struct {
+	Hook Hook
+
+	// MaxBodyBytes caps the request and response bodies included in
+	// events. Zero logs no bodies.
+	MaxBodyBytes int
+
+	// RedactHeaders lists headers, and query parameters, whose values are
+	// replaced with "[REDACTED]". Defaults to DefaultJournalRedact.
+	RedactHeaders []string
+
+	// RedactFields lists JSON object keys, matched case-insensitively at
+	// any depth, whose values are replaced in logged bodies. A body longer
+	// than MaxBodyBytes cannot be parsed once cut, so it is then logged as
+	// "[REDACTED]".
+	RedactFields []string
+}
+
+// WithLogging logs requests through hook. See Client.Logging.
+func WithLogging(hook Hook) Option {
+	return func(c *Client) {
+		c.Logging = &LogConfig{Hook: hook}
+	}
+}
+
+func (l *LogConfig) redactList() []string {
+	if l.RedactHeaders == nil {
+		return DefaultJournalRedact
+	}
+	return l.RedactHeaders
+}
+
+// request reports r to the hook and returns the event to complete.
+func (l *LogConfig) request(r *http.Request) *LogEvent {
+	e := &LogEvent{
+		Method:        r.Method,
+		URL:           redactRequestURL(r, l.redactList()),
+		RequestHeader: redactHeader(r.Header, l.redactList()),
+	}
+	if l.MaxBodyBytes > 0 && r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			b, _ := ioutil.ReadAll(io.LimitReader(body, int64(l.MaxBodyBytes)+1))
+			body.Close()
+			e.RequestBody = l.body(b)
+		}
+	}
+	l.Hook.OnRequest(e)
+	return e
+}
+
+// response completes e with the outcome of the attempt and reports it. When
+// bodies are logged the report waits until the body is closed.
+func (l *LogConfig) response(e *LogEvent, res *http.Response, err error, start time.Time) *http.Response {
+	if err != nil {
+		done := *e
+		done.Latency = time.Since(start)
+		done.Err = err
+		l.Hook.OnError(&done)
+		return res
+	}
+
+	done := *e
+	done.Status = res.StatusCode
+	done.ResponseHeader = redactHeader(res.Header, l.redactList())
+	if l.MaxBodyBytes <= 0 {
+		done.Latency = time.Since(start)
+		l.Hook.OnResponse(&done)
+		return res
+	}
+	res.Body = &loggedBody{ReadCloser: res.Body, l: l, e: &done, start: start}
+	return res
+}
+
+// body redacts and truncates a body for logging. b holds at most
+// MaxBodyBytes+1 bytes, the last only telling that the body was cut.
+func (l *LogConfig) body(b []byte) []byte {
+	if len(l.RedactFields) > 0 && len(b) > l.MaxBodyBytes {
+		return []byte(redacted)
+	}
+	if len(l.RedactFields) > 0 {
+		b = redactJSONFields(b, l.RedactFields)
+	}
+	if len(b) > l.MaxBodyBytes {
+		b = b[:l.MaxBodyBytes]
+	}
+	return b
+}
+
+// redactJSONFields replaces the values of the named keys anywhere in the
+// JSON document body. Bodies that are not JSON are returned unchanged.
+func redactJSONFields(body []byte, fields []string) []byte {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil {
+		return body
+	}
+
+	var walk func(v interface{}) bool
+	walk = func(v interface{}) (changed bool) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, item := range v {
+				if containsFold(fields, k) {
+					v[k] = redacted
+					changed = true
+				} else if walk(item) {
+					changed = true
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if walk(item) {
+					changed = true
+				}
+			}
+		}
+		return changed
+	}
+	if !walk(doc) {
+		return body
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// loggedBody captures up to MaxBodyBytes+1 bytes of a response body and
+// reports its event when closed.
+type loggedBody struct {
+	io.ReadCloser
+	l     *LogConfig
+	e     *LogEvent
+	start time.Time
+	buf   bytes.Buffer
+	done  bool
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.l.MaxBodyBytes + 1 - b.buf.Len(); room > 0 {
+		if room > n {
+			room = n
+		}
+		b.buf.Write(p[:room])
+	}
+	return n, err
+}
+
+func (b *loggedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.done {
+		b.done = true
+		b.e.ResponseBody = b.l.body(b.buf.Bytes())
+		b.e.Latency = time.Since(b.start)
+		b.l.Hook.OnResponse(b.e)
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_LoggingHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Foo": "bar", "token": "s3cret", "nested": [{"Password": "hunter2"}]}`))
+	}))
+	defer server.Close()
+
+	var requests, responses []*LogEvent
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Logging = &LogConfig{
+		Hook: HookFuncs{
+			Request:  func(e *LogEvent) { requests = append(requests, e) },
+			Response: func(e *LogEvent) { responses = append(responses, e) },
+		},
+		MaxBodyBytes: 1024,
+		RedactFields: []string{"token", "password"},
+	}
+
+	var data Response
+	if err := c.CreateJson("/api/foo?api_key=k&page=2", map[string]string{"name": "x", "password": "p"}, &data, WithQuery("X-Api-Key", "k")); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || len(responses) != 1 {
+		t.Fatalf("Expected one request and one response event, got %d and %d", len(requests), len(responses))
+	}
+
+	req := requests[0]
+	if req.Method != http.MethodPost || !strings.Contains(req.URL, "/api/foo") {
+		t.Errorf("Unexpected request event %+v", req)
+	}
+	if !strings.Contains(req.URL, "X-Api-Key=%5BREDACTED%5D") {
+		t.Errorf("Expected the api key parameter to be redacted, got %s", req.URL)
+	}
+	if v := req.RequestHeader.Get("Authorization"); v != "[REDACTED]" {
+		t.Errorf("Expected Authorization to be redacted, got %q", v)
+	}
+	if body := string(req.RequestBody); body != `{"name":"x","password":"[REDACTED]"}` {
+		t.Errorf("Unexpected request body %s", body)
+	}
+
+	res := responses[0]
+	if res.Status != http.StatusCreated || res.Latency <= 0 {
+		t.Errorf("Unexpected response event %+v", res)
+	}
+	if body := string(res.ResponseBody); strings.Contains(body, "s3cret") || strings.Contains(body, "hunter2") || !strings.Contains(body, `"Foo":"bar"`) {
+		t.Errorf("Expected sensitive fields to be redacted, got %s", body)
+	}
+}
+
+func TestClient_LoggingHookError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	var errs []*LogEvent
+	c := newClientOrFatal(t, server.URL, apiKey)
+	WithLogging(HookFuncs{Error: func(e *LogEvent) { errs = append(errs, e) }})(c)
+
+	if err := c.ReadJson("/api/foo", nil); err == nil {
+		t.Fatal("Expected the request to fail")
+	}
+	if len(errs) != 1 || errs[0].Err == nil {
+		t.Errorf("Expected one error event, got %+v", errs)
+	}
+}
+
+func TestClient_LoggingBodyLimit(t *testing.T) {
+	big := strings.Repeat("x", 64<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Foo": "` + big + `", "token": "s3cret"}`))
+	}))
+	defer server.Close()
+
+	var request, response *LogEvent
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Logging = &LogConfig{
+		Hook: HookFuncs{
+			Request:  func(e *LogEvent) { request = e },
+			Response: func(e *LogEvent) { response = e },
+		},
+		MaxBodyBytes: 10,
+	}
+
+	var data Response
+	if err := c.CreateJson("/api/foo", map[string]string{"name": big}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(request.RequestBody) != 10 || len(response.ResponseBody) != 10 {
+		t.Errorf("Expected bodies cut at 10 bytes, got %q and %q", request.RequestBody, response.ResponseBody)
+	}
+
+	b := &loggedBody{ReadCloser: ioutil.NopCloser(strings.NewReader(big)), l: c.Logging, e: &LogEvent{}}
+	ioutil.ReadAll(b)
+	if b.buf.Len() > 11 {
+		t.Errorf("Expected at most 11 bytes of the response to be kept, got %d", b.buf.Len())
+	}
+
+	c.Logging.RedactFields = []string{"token"}
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+	if string(response.ResponseBody) != "[REDACTED]" {
+		t.Errorf("Expected a cut body to be redacted whole, got %q", response.ResponseBody)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LongPollOptions configures LongPoll. The defaults fit Consul-style
+// blocking queries.
+type LongPollOptions struct {
+	// IndexHeader is the response header carrying the index of the data.
+	// Defaults to "X-Consul-Index".
+	IndexHeader string
+
+	// IndexParam is the query parameter the last index is sent back in.
+	// Defaults to "index".
+	IndexParam string
+
+	// WaitParam is the query parameter the timeout is sent in, formatted
+	// like "30s". Defaults to "wait".
+	WaitParam string
+
+	// Grace is how long past the timeout a request may take before it is
+	// abandoned and reissued. Defaults to a sixteenth of the timeout plus
+	// one second, matching the jitter servers add to blocking queries.
+	Grace time.Duration
+
+	// ErrorDelay, when positive, makes failed requests be retried after
+	// the delay instead of ending LongPoll.
+	ErrorDelay time.Duration
+
+	// Options are applied to every request.
+	Options []RequestOption
+}
+
+// LongPoll issues blocking GETs for uri, each held open by the server for
+// up to timeout, and reconnects as soon as one returns. Responses that time
+// out without new data, signalled by an empty body, 204, 304 or an
+// unchanged index, are skipped; every other body is passed to handler. It
+// runs until ctx is done, a request fails or handler returns an error.
+// Returning ErrStopPolling stops polling and makes LongPoll return nil.
+func (c *Client) LongPoll(ctx context.Context, uri string, timeout time.Duration, opts *LongPollOptions, handler func(msg json.RawMessage) error) error {
+	if opts == nil {
+		opts = &LongPollOptions{}
+	}
+	indexHeader := opts.IndexHeader
+	if indexHeader == "" {
+		indexHeader = "X-Consul-Index"
+	}
+	indexParam := opts.IndexParam
+	if indexParam == "" {
+		indexParam = "index"
+	}
+	waitParam := opts.WaitParam
+	if waitParam == "" {
+		waitParam = "wait"
+	}
+	grace := opts.Grace
+	if grace <= 0 {
+		grace = timeout/16 + time.Second
+	}
+
+	var index string
+	for {
+		ropts := append(opts.Options[:len(opts.Options):len(opts.Options)], WithQuery(waitParam, timeout.String()))
+		if index != "" {
+			ropts = append(ropts, WithQuery(indexParam, index))
+		}
+		msg, next, err := c.longPollOnce(ctx, uri, timeout+grace, ropts, indexHeader)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if opts.ErrorDelay <= 0 {
+				return err
+			}
+			if err := sleepCtx(ctx, opts.ErrorDelay); err != nil {
+				return err
+			}
+			continue
+		}
+
+		changed := next == "" || next != index
+		switch {
+		case next == "":
+			// The request timed out or the server reports no index; keep
+			// blocking on the last one.
+		case indexWentBack(index, next):
+			// The server's index was reset; start over rather than block
+			// on an index it will never reach.
+			index = ""
+			continue
+		default:
+			index = next
+		}
+		if msg == nil || !changed {
+			continue
+		}
+		if err := handler(msg); err != nil {
+			if err == ErrStopPolling {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// longPollOnce issues one blocking request, returning its body, or nil if
+// it timed out empty, and the index it reported.
+func (c *Client) longPollOnce(ctx context.Context, uri string, limit time.Duration, ropts []RequestOption, indexHeader string) (json.RawMessage, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	req, err := c.MakeRequestContext(ctx, http.MethodGet, uri)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := newCallOptions(ropts).apply(req, c); err != nil {
+		return nil, "", err
+	}
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	index := res.Header.Get(indexHeader)
+	switch {
+	case res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified:
+		return nil, index, nil
+	case !isSuccess(res.StatusCode):
+		return nil, "", c.apiError(res, body)
+	case isEmptyBody(body):
+		return nil, index, nil
+	}
+	return body, index, nil
+}
+
+// indexWentBack reports whether next is a numeric index below prev.
+func indexWentBack(prev, next string) bool {
+	p, err := strconv.ParseUint(prev, 10, 64)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.ParseUint(next, 10, 64)
+	return err == nil && n < p
+}
+
+// sleepCtx waits for d or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
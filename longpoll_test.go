@@ -0,0 +1,110 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_LongPoll(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		switch len(queries) {
+		case 1:
+			w.Header().Set("X-Consul-Index", "10")
+			w.Write([]byte(`{"Foo": "a"}`))
+		case 2:
+			// Timed out without changes: same index, same data.
+			w.Header().Set("X-Consul-Index", "10")
+			w.Write([]byte(`{"Foo": "a"}`))
+		case 3:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("X-Consul-Index", "12")
+			w.Write([]byte(`{"Foo": "b"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var got []string
+	err := c.LongPoll(context.Background(), "/v1/kv", 30*time.Second, nil, func(msg json.RawMessage) error {
+		var data Response
+		if err := json.Unmarshal(msg, &data); err != nil {
+			return err
+		}
+		got = append(got, data.Foo)
+		if data.Foo == "b" {
+			return ErrStopPolling
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("Expected changes a and b to be handled, got %v", got)
+	}
+	want := []string{"wait=30s", "index=10&wait=30s", "index=10&wait=30s", "index=10&wait=30s"}
+	if len(queries) != len(want) {
+		t.Fatalf("Expected %d requests, got %q", len(want), queries)
+	}
+	for i := range want {
+		if queries[i] != want[i] {
+			t.Errorf("Expected request %d to send %q, got %q", i, want[i], queries[i])
+		}
+	}
+}
+
+func TestClient_LongPollReconnectsAfterTimeout(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"Foo": "late"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var got string
+	opts := &LongPollOptions{Grace: 10 * time.Millisecond}
+	err := c.LongPoll(context.Background(), "/v1/kv", 10*time.Millisecond, opts, func(msg json.RawMessage) error {
+		got = string(msg)
+		return ErrStopPolling
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 || got != `{"Foo": "late"}` {
+		t.Errorf("Expected the abandoned request to be reissued, got %d calls and %q", calls, got)
+	}
+}
+
+func TestClient_LongPollError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	err := c.LongPoll(context.Background(), "/v1/kv", time.Second, nil, func(json.RawMessage) error { return nil })
+	if StatusCode(err) != http.StatusForbidden {
+		t.Errorf("Expected the 403 to end polling, got %v", err)
+	}
+}
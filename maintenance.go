@@ -0,0 +1,174 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrMaintenance is matched by the MaintenanceError of requests held back
+// during a maintenance window.
+var ErrMaintenance = errors.New("API is under maintenance")
+
+// DefaultMaintenanceCheckInterval is used when
+// MaintenanceConfig.CheckInterval is zero.
+const DefaultMaintenanceCheckInterval = time.Minute
+
+// MaintenanceWindow is a period during which the API is unavailable.
+type MaintenanceWindow struct {
+	Start time.Time
+
+	// End is when the window closes. A zero End leaves it open until the
+	// status reports otherwise.
+	End time.Time
+
+	Reason string
+}
+
+// Contains reports whether t falls within the window.
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && (w.End.IsZero() || t.Before(w.End))
+}
+
+// MaintenanceError is returned for a request not sent because the API is
+// under maintenance. It matches ErrMaintenance and is never retried.
+type MaintenanceError struct {
+	Method string
+	URL    string
+	Window MaintenanceWindow
+
+	// Queued reports that MaintenanceConfig.Queue accepted the request
+	// to be sent later.
+	Queued bool
+}
+
+func (e *MaintenanceError) Error() string {
+	msg := fmt.Sprintf("%s %s not sent: %s", e.Method, e.URL, ErrMaintenance)
+	if !e.Window.End.IsZero() {
+		msg += " until " + e.Window.End.UTC().Format(time.RFC3339)
+	}
+	if e.Window.Reason != "" {
+		msg += " (" + e.Window.Reason + ")"
+	}
+	if e.Queued {
+		msg += "; queued"
+	}
+	return msg
+}
+
+// Is reports whether target is ErrMaintenance.
+func (e *MaintenanceError) Is(target error) bool {
+	return target == ErrMaintenance
+}
+
+// MaintenanceConfig holds requests back while the API is under
+// maintenance, instead of sending them and burning retries on the errors
+// they would get. Windows are known in advance, reported by the vendor's
+// status endpoint, or both. Times are taken from the client's Clock.
+type MaintenanceConfig struct {
+	// Windows are the scheduled maintenance windows.
+	Windows []MaintenanceWindow
+
+	// Status, when set, returns the windows the vendor currently
+	// announces, e.g. by reading its status endpoint. It is called at most
+	// once per CheckInterval; when it fails, the windows it last returned
+	// are kept. It must not send its request through this client.
+	Status func(ctx context.Context) ([]MaintenanceWindow, error)
+
+	// CheckInterval is how long the result of Status is used. Defaults to
+	// DefaultMaintenanceCheckInterval.
+	CheckInterval time.Duration
+
+	// Allow, when set, lets the requests it returns true for through
+	// during a window, e.g. reads while the API is read-only.
+	Allow func(r *http.Request) bool
+
+	// Queue, when set, is handed the writes held back during a window,
+	// e.g. to store them in an outbox and send them once it closes. When
+	// it returns nil the call fails with a MaintenanceError whose Queued
+	// is set. GET and HEAD requests are never queued.
+	Queue func(r *http.Request, w MaintenanceWindow) error
+
+	// OnError, when set, is told about failures of Status.
+	OnError func(err error)
+}
+
+// WithMaintenance holds requests back during the maintenance windows
+// configured by cfg.
+func WithMaintenance(cfg *MaintenanceConfig) Option {
+	return func(c *Client) {
+		c.Maintenance = cfg
+	}
+}
+
+// maintenanceStatus caches the windows reported by MaintenanceConfig.Status.
+type maintenanceStatus struct {
+	mu      sync.Mutex
+	checked time.Time
+	windows []MaintenanceWindow
+}
+
+// maintenanceWindow returns the maintenance window containing now, if any.
+func (c *Client) maintenanceWindow(ctx context.Context, now time.Time) (MaintenanceWindow, bool) {
+	m := c.Maintenance
+	for _, w := range m.Windows {
+		if w.Contains(now) {
+			return w, true
+		}
+	}
+	if m.Status == nil {
+		return MaintenanceWindow{}, false
+	}
+
+	s := &c.maintenance
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	interval := m.CheckInterval
+	if interval <= 0 {
+		interval = DefaultMaintenanceCheckInterval
+	}
+	if s.checked.IsZero() || now.Sub(s.checked) >= interval || now.Before(s.checked) {
+		windows, err := m.Status(ctx)
+		if err != nil {
+			if m.OnError != nil {
+				m.OnError(fmt.Errorf("checking maintenance status: %w", err))
+			}
+		} else {
+			s.windows = windows
+		}
+		s.checked = now
+	}
+	for _, w := range s.windows {
+		if w.Contains(now) {
+			return w, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
+
+// checkMaintenance returns a MaintenanceError if r must not be sent now.
+func (c *Client) checkMaintenance(r *http.Request) error {
+	m := c.Maintenance
+	if m == nil {
+		return nil
+	}
+	w, ok := c.maintenanceWindow(r.Context(), c.now())
+	if !ok || m.Allow != nil && m.Allow(r) {
+		return nil
+	}
+	err := &MaintenanceError{Method: r.Method, URL: redactRequestURL(r, nil), Window: w}
+	if m.Queue != nil && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if qerr := m.Queue(r, w); qerr != nil {
+			return fmt.Errorf("%s %s: queueing for after maintenance: %w", r.Method, err.URL, qerr)
+		}
+		err.Queued = true
+	}
+	return err
+}
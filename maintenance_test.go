@@ -0,0 +1,115 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+}
+
+func TestClient_MaintenanceWindows(t *testing.T) {
+	var calls int32
+	server := newCountingServer(&calls)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Clock = ClockFunc(func() time.Time { return now })
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond)}
+	c.Maintenance = &MaintenanceConfig{
+		Windows: []MaintenanceWindow{{
+			Start:  time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC),
+			End:    time.Date(2024, 1, 2, 4, 0, 0, 0, time.UTC),
+			Reason: "database upgrade",
+		}},
+	}
+
+	var response Response
+	err := c.ReadJson("/api/foo", &response)
+	if !errors.Is(err, ErrMaintenance) {
+		t.Fatalf("Expected ErrMaintenance, got %v", err)
+	}
+	var merr *MaintenanceError
+	if !errors.As(err, &merr) || merr.Window.Reason != "database upgrade" || merr.Queued {
+		t.Errorf("Expected a MaintenanceError for the window, got %#v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("Expected no request during the window, got %d", n)
+	}
+
+	now = now.Add(time.Hour)
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Errorf("Expected requests after the window, got %s", err)
+	}
+}
+
+func TestClient_MaintenanceStatusAndQueue(t *testing.T) {
+	var calls int32
+	server := newCountingServer(&calls)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	checks := 0
+	down := true
+	var queued []string
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Clock = ClockFunc(func() time.Time { return now })
+	c.Maintenance = &MaintenanceConfig{
+		CheckInterval: time.Minute,
+		Status: func(ctx context.Context) ([]MaintenanceWindow, error) {
+			checks++
+			if down {
+				return []MaintenanceWindow{{Start: now.Add(-time.Minute)}}, nil
+			}
+			return nil, nil
+		},
+		Allow: func(r *http.Request) bool { return r.Method == http.MethodGet },
+		Queue: func(r *http.Request, w MaintenanceWindow) error {
+			queued = append(queued, r.Method+" "+r.URL.Path)
+			return nil
+		},
+	}
+
+	var response Response
+	if err := c.ReadJson("/api/foo", &response); err != nil {
+		t.Errorf("Expected allowed reads during maintenance, got %s", err)
+	}
+	err := c.CreateJson("/api/foo", "bar", &response)
+	var merr *MaintenanceError
+	if !errors.As(err, &merr) || !merr.Queued {
+		t.Fatalf("Expected a queued MaintenanceError, got %v", err)
+	}
+	if len(queued) != 1 || queued[0] != "POST /api/foo" {
+		t.Errorf("Expected the write to be queued, got %q", queued)
+	}
+	if checks != 1 {
+		t.Errorf("Expected the status to be checked once per interval, got %d", checks)
+	}
+
+	down = false
+	now = now.Add(time.Minute)
+	if err := c.CreateJson("/api/foo", "bar", &response); err != nil {
+		t.Errorf("Expected writes once the status clears, got %s", err)
+	}
+	if checks != 2 {
+		t.Errorf("Expected the status to be checked again, got %d", checks)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 requests to reach the server, got %d", n)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import "net/http"
+
+// RoundTripFunc sends a single request and returns its response.
+type RoundTripFunc func(r *http.Request) (*http.Response, error)
+
+// Middleware wraps a RoundTripFunc to add behavior such as logging, metrics
+// or request signing. It runs once per attempt, after authentication, default
+// headers and compression have been applied, so it sees the request exactly
+// as it goes on the wire. Use RequestBody to read the request body; a
+// replayable body read from req.Body directly is rewound before it is sent.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use appends mw to the middleware chain. The first middleware added is the
+// outermost: it sees the request first and the response last. Use is not
+// safe to call while requests are in flight.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+// WithMiddleware appends mw to the middleware chain.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) {
+		c.Use(mw...)
+	}
+}
+
+// roundTrip sends r through the middleware chain to the http.Client.
+func (c *Client) roundTrip(r *http.Request) (*http.Response, error) {
+	if c.URLSigner != nil {
+		if cred := requestCredentials(r); cred != nil {
+			unsigned := *r.URL
+			cred.unsigned = &unsigned
+		}
+		var err error
+		if r, err = c.signURL(r); err != nil {
+			return nil, err
+		}
+	}
+	next := RoundTripFunc(c.httpClientFor(r).Do)
+	if len(c.middleware) > 0 {
+		next = guardBody(r, next)
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		next = c.middleware[i](next)
+	}
+	return next(r)
+}
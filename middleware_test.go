@@ -0,0 +1,73 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClient_Use(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	var order []string
+	named := func(name string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				order = append(order, name+" in")
+				r.Header.Add("X-Chain", name)
+				res, err := next(r)
+				order = append(order, name+" out")
+				return res, err
+			}
+		}
+	}
+
+	c, err := NewClient(server.URL, apiKey, WithMiddleware(named("a")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Use(named("b"))
+
+	if err := c.CreateJson("/", Response{Foo: "bar"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "a in,b in,b out,a out"; strings.Join(order, ",") != want {
+		t.Errorf("Expected order %q, got %q", want, strings.Join(order, ","))
+	}
+	if chain := got.Header.Values("X-Chain"); strings.Join(chain, ",") != "a,b" {
+		t.Errorf("Expected X-Chain \"a,b\", got %q", chain)
+	}
+	if got.Header.Get("Authorization") == "" {
+		t.Errorf("Expected middleware to see the authorized request")
+	}
+}
+
+func TestClient_UseShortCircuit(t *testing.T) {
+	c := newClientOrFatal(t, goodURL, apiKey)
+	c.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"Foo":"stub"}`)),
+				Request:    r,
+			}, nil
+		}
+	})
+
+	var data Response
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "stub" {
+		t.Errorf("Expected data.Foo to be \"stub\", got %q", data.Foo)
+	}
+}
@@ -0,0 +1,625 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+// MarshalMsgpack encodes v as MessagePack. Structs become maps keyed by the
+// field's msgpack tag, else its json tag, else its name; the tag options
+// "omitempty" and "-" work as in encoding/json. []byte becomes bin, other
+// slices and arrays become arrays, and encoding.TextMarshaler values become
+// strings.
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+	var e msgpackEncoder
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// UnmarshalMsgpack decodes MessagePack data into v, which must be a non-nil
+// pointer. Map keys are matched to struct fields as in MarshalMsgpack,
+// falling back to a case-insensitive match; unknown keys are ignored.
+func UnmarshalMsgpack(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: decode into non-pointer %T", v)
+	}
+	d := msgpackDecoder{data: data}
+	val, err := d.value()
+	if err != nil {
+		return err
+	}
+	return msgpackAssign(rv.Elem(), val)
+}
+
+type msgpackField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var msgpackFieldCache sync.Map // reflect.Type -> []msgpackField
+
+// msgpackFields lists the encoded fields of struct type t, flattening
+// untagged embedded structs.
+func msgpackFields(t reflect.Type) []msgpackField {
+	if f, ok := msgpackFieldCache.Load(t); ok {
+		return f.([]msgpackField)
+	}
+
+	var fields []msgpackField
+	for _, sf := range reflect.VisibleFields(t) {
+		tag, ok := sf.Tag.Lookup("msgpack")
+		if !ok {
+			tag = sf.Tag.Get("json")
+		}
+		if tag == "-" || !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, msgpackField{
+			name:      name,
+			index:     sf.Index,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+
+	msgpackFieldCache.Store(t, fields)
+	return fields
+}
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) byte1(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *msgpackEncoder) uint(code byte, n uint64, size int) {
+	e.buf = append(e.buf, code)
+	switch size {
+	case 1:
+		e.buf = append(e.buf, byte(n))
+	case 2:
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case 4:
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	case 8:
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+// length writes a length header using the fix form when n fits under
+// fixMax, then the 8 (if code8 is non-zero), 16 and 32 bit forms.
+func (e *msgpackEncoder) length(n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n < fixMax:
+		e.byte1(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		e.uint(code8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.uint(code16, uint64(n), 2)
+	default:
+		e.uint(code32, uint64(n), 4)
+	}
+}
+
+func (e *msgpackEncoder) int(n int64) {
+	switch {
+	case n >= 0:
+		e.uint64(uint64(n))
+	case n >= -32:
+		e.byte1(byte(n))
+	case n >= math.MinInt8:
+		e.uint(0xd0, uint64(n), 1)
+	case n >= math.MinInt16:
+		e.uint(0xd1, uint64(n), 2)
+	case n >= math.MinInt32:
+		e.uint(0xd2, uint64(n), 4)
+	default:
+		e.uint(0xd3, uint64(n), 8)
+	}
+}
+
+func (e *msgpackEncoder) uint64(n uint64) {
+	switch {
+	case n < 128:
+		e.byte1(byte(n))
+	case n <= math.MaxUint8:
+		e.uint(0xcc, n, 1)
+	case n <= math.MaxUint16:
+		e.uint(0xcd, n, 2)
+	case n <= math.MaxUint32:
+		e.uint(0xce, n, 4)
+	default:
+		e.uint(0xcf, n, 8)
+	}
+}
+
+func (e *msgpackEncoder) string(s string) {
+	e.length(len(s), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.byte1(0xc0)
+		return nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			e.byte1(0xc0)
+			return nil
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.string(string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.byte1(0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.byte1(0xc3)
+		} else {
+			e.byte1(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.uint64(v.Uint())
+	case reflect.Float32:
+		e.uint(0xca, uint64(math.Float32bits(float32(v.Float()))), 4)
+	case reflect.Float64:
+		e.uint(0xcb, math.Float64bits(v.Float()), 8)
+	case reflect.String:
+		e.string(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			e.byte1(0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.length(v.Len(), 0, 0, 0xc4, 0xc5, 0xc6)
+			for i := 0; i < v.Len(); i++ {
+				e.byte1(byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		e.length(v.Len(), 0x90, 16, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.byte1(0xc0)
+			return nil
+		}
+		e.length(v.Len(), 0x80, 16, 0, 0xde, 0xdf)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var fields []msgpackField
+		for _, f := range msgpackFields(v.Type()) {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil || f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			fields = append(fields, f)
+		}
+		e.length(len(fields), 0x80, 16, 0, 0xde, 0xdf)
+		for _, f := range fields {
+			e.string(f.name)
+			if err := e.encode(v.FieldByIndex(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// msgpackDecoder decodes MessagePack into nil, bool, int64, uint64,
+// float64, string, []byte, []interface{} and map[interface{}]interface{}.
+type msgpackDecoder struct {
+	data []byte
+	off  int
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.off < n {
+		return nil, errMsgpackShort
+	}
+	b := d.data[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (d *msgpackDecoder) value() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	code := b[0]
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return d.mapOf(int(code & 0x0f))
+	case code&0xf0 == 0x90:
+		return d.arrayOf(int(code & 0x0f))
+	case code&0xe0 == 0xa0:
+		return d.str(int(code & 0x1f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (code - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n))
+		return append([]byte(nil), b...), err
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (code - 0xcc))
+	case 0xd0:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return int64(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayOf(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapOf(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type code 0x%02x", code)
+}
+
+func (d *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) arrayOf(n int) (interface{}, error) {
+	if n > len(d.data)-d.off {
+		return nil, errMsgpackShort
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (d *msgpackDecoder) mapOf(n int) (interface{}, error) {
+	if n > len(d.data)-d.off {
+		return nil, errMsgpackShort
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		switch k.(type) {
+		case []interface{}, map[interface{}]interface{}, []byte:
+			return nil, errors.New("msgpack: unsupported map key")
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// msgpackAssign stores the decoded value val into dst.
+func msgpackAssign(dst reflect.Value, val interface{}) error {
+	if val == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return msgpackAssign(dst.Elem(), val)
+	}
+	if s, ok := val.(string); ok && dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerType) {
+		return dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	mismatch := fmt.Errorf("msgpack: cannot decode %T into %s", val, dst.Type())
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return mismatch
+		}
+		dst.Set(reflect.ValueOf(msgpackGeneric(val)))
+	case reflect.Bool:
+		b, ok := val.(bool)
+		if !ok {
+			return mismatch
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch v := val.(type) {
+		case int64:
+			n = v
+		case uint64:
+			if v > math.MaxInt64 {
+				return mismatch
+			}
+			n = int64(v)
+		default:
+			return mismatch
+		}
+		if dst.OverflowInt(n) {
+			return mismatch
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch v := val.(type) {
+		case uint64:
+			n = v
+		case int64:
+			if v < 0 {
+				return mismatch
+			}
+			n = uint64(v)
+		default:
+			return mismatch
+		}
+		if dst.OverflowUint(n) {
+			return mismatch
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		switch v := val.(type) {
+		case float64:
+			dst.SetFloat(v)
+		case int64:
+			dst.SetFloat(float64(v))
+		case uint64:
+			dst.SetFloat(float64(v))
+		default:
+			return mismatch
+		}
+	case reflect.String:
+		switch v := val.(type) {
+		case string:
+			dst.SetString(v)
+		case []byte:
+			dst.SetString(string(v))
+		default:
+			return mismatch
+		}
+	case reflect.Slice:
+		if b, ok := val.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(b)
+			return nil
+		}
+		a, ok := val.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		s := reflect.MakeSlice(dst.Type(), len(a), len(a))
+		for i, item := range a {
+			if err := msgpackAssign(s.Index(i), item); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+	case reflect.Array:
+		a, ok := val.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		for i := 0; i < dst.Len(); i++ {
+			var item interface{}
+			if i < len(a) {
+				item = a[i]
+			}
+			if err := msgpackAssign(dst.Index(i), item); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := val.(map[interface{}]interface{})
+		if !ok {
+			return mismatch
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+		}
+		for k, v := range m {
+			kv := reflect.New(dst.Type().Key()).Elem()
+			if err := msgpackAssign(kv, k); err != nil {
+				return err
+			}
+			vv := reflect.New(dst.Type().Elem()).Elem()
+			if err := msgpackAssign(vv, v); err != nil {
+				return err
+			}
+			dst.SetMapIndex(kv, vv)
+		}
+	case reflect.Struct:
+		m, ok := val.(map[interface{}]interface{})
+		if !ok {
+			return mismatch
+		}
+		fields := msgpackFields(dst.Type())
+		for k, v := range m {
+			name, ok := k.(string)
+			if !ok {
+				continue
+			}
+			f := findMsgpackField(fields, name)
+			if f == nil {
+				continue
+			}
+			fv, err := dst.FieldByIndexErr(f.index)
+			if err != nil {
+				// Allocate nil embedded pointers on the way to the field.
+				fv = dst
+				for _, i := range f.index {
+					if fv.Kind() == reflect.Ptr {
+						if fv.IsNil() {
+							fv.Set(reflect.New(fv.Type().Elem()))
+						}
+						fv = fv.Elem()
+					}
+					fv = fv.Field(i)
+				}
+			}
+			if err := msgpackAssign(fv, v); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+func findMsgpackField(fields []msgpackField, name string) *msgpackField {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// msgpackGeneric converts decoded maps to map[string]interface{} where all
+// keys are strings, as encoding/json would produce.
+func msgpackGeneric(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = msgpackGeneric(v[i])
+		}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			s, ok := k.(string)
+			if !ok {
+				for k, item := range v {
+					v[k] = msgpackGeneric(item)
+				}
+				return v
+			}
+			m[s] = msgpackGeneric(item)
+		}
+		return m
+	}
+	return val
+}
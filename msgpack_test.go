@@ -0,0 +1,111 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalMsgpack(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{5, []byte{0x05}},
+		{-3, []byte{0xfd}},
+		{200, []byte{0xcc, 0xc8}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{70000, []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"hi", []byte{0xa2, 'h', 'i'}},
+		{[]byte{1, 2}, []byte{0xc4, 0x02, 1, 2}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]int{"a": 1}, []byte{0x81, 0xa1, 'a', 0x01}},
+	}
+	for _, tt := range tests {
+		got, err := MarshalMsgpack(tt.in)
+		if err != nil {
+			t.Errorf("MarshalMsgpack(%v): %v", tt.in, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("MarshalMsgpack(%v): expected % x, got % x", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	type Inner struct {
+		Tags []string `json:"tags"`
+	}
+	type record struct {
+		Inner
+		ID      int64             `msgpack:"id"`
+		Name    string            `json:"name"`
+		Score   float64           `json:"score"`
+		Big     uint64            `json:"big"`
+		Skip    string            `json:"-"`
+		Empty   string            `json:"empty,omitempty"`
+		Data    []byte            `json:"data"`
+		Attrs   map[string]string `json:"attrs"`
+		Created time.Time         `json:"created"`
+		Next    *record           `json:"next"`
+	}
+
+	in := record{
+		Inner:   Inner{Tags: []string{"a", "b"}},
+		ID:      -1 << 40,
+		Name:    "widget",
+		Score:   2.25,
+		Big:     1 << 63,
+		Skip:    "ignored",
+		Data:    []byte("raw"),
+		Attrs:   map[string]string{"color": "red"},
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Next:    &record{Name: "child"},
+	}
+	b, err := MarshalMsgpack(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out record
+	if err := UnmarshalMsgpack(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Skip = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+
+	var generic map[string]interface{}
+	if err := UnmarshalMsgpack(b, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if generic["name"] != "widget" || generic["id"] != int64(-1<<40) {
+		t.Errorf("Unexpected generic decode %v", generic)
+	}
+	if _, ok := generic["empty"]; ok {
+		t.Errorf("Expected omitempty field to be left out")
+	}
+}
+
+func TestUnmarshalMsgpackErrors(t *testing.T) {
+	var s string
+	if err := UnmarshalMsgpack([]byte{0xa5, 'a'}, &s); err == nil {
+		t.Errorf("Expected truncated data to fail")
+	}
+	if err := UnmarshalMsgpack([]byte{0x01}, &s); err == nil {
+		t.Errorf("Expected a type mismatch to fail")
+	}
+	if err := UnmarshalMsgpack([]byte{0xc0}, s); err == nil {
+		t.Errorf("Expected a non-pointer to fail")
+	}
+}
@@ -4,11 +4,383 @@
 
 package relax
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MultipartForm is a multipart/form-data body. It is streamed to the
+// server as it is sent, so files of any size are never held in memory.
 type MultipartForm struct {
 	Fields map[string]string
-	Files  map[string]string
+
+	// Values holds fields of any type, sent after Fields. Strings, numbers
+	// and bools are sent as plain text; anything else is encoded with
+	// FieldCodec, as for a JSON metadata part next to a file.
+	Values map[string]interface{}
+
+	// FieldCodec encodes structured Values. Defaults to JSONCodec.
+	FieldCodec Codec
+
+	// Files maps field names to paths of files to upload.
+	Files map[string]string
+
+	// Readers are uploaded after Files, in order.
+	Readers []MultipartFile
+
+	// Progress, when set, is called as the body is sent with the bytes sent
+	// so far and the total, or -1 if the size of a reader is unknown.
+	Progress func(sent, total int64)
+}
+
+// MultipartFile is a file part read from an io.Reader.
+type MultipartFile struct {
+	Field    string
+	FileName string
+	Reader   io.Reader
+
+	// Size is the number of bytes Reader yields, or -1 if unknown. Knowing
+	// every size lets the request carry a Content-Length.
+	Size int64
+
+	// ContentType defaults to application/octet-stream.
+	ContentType string
+
+	path string // set for Files, which are opened each time they are sent
 }
 
 func NewMultipartForm() *MultipartForm {
 	return &MultipartForm{Files: make(map[string]string), Fields: make(map[string]string)}
 }
+
+// AddValue adds a field of any type. See MultipartForm.Values.
+func (mpf *MultipartForm) AddValue(field string, v interface{}) {
+	if mpf.Values == nil {
+		mpf.Values = make(map[string]interface{})
+	}
+	mpf.Values[field] = v
+}
+
+// AddReader adds a file part read from r. size is the number of bytes r
+// yields, or -1 if unknown. If r is an io.Seeker the request can be resent,
+// e.g. by a retry policy.
+func (mpf *MultipartForm) AddReader(field, fileName string, r io.Reader, size int64) {
+	mpf.Readers = append(mpf.Readers, MultipartFile{Field: field, FileName: fileName, Reader: r, Size: size})
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (f *MultipartFile) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(f.Field), quoteEscaper.Replace(f.FileName)))
+	ct := f.ContentType
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	h.Set("Content-Type", ct)
+	return h
+}
+
+// multipartValue is an encoded entry of MultipartForm.Values.
+type multipartValue struct {
+	field       string
+	contentType string // empty for plain text
+	data        []byte
+}
+
+// encodeValues encodes the Values of mpf in field order.
+func (mpf *MultipartForm) encodeValues() ([]multipartValue, error) {
+	codec := mpf.FieldCodec
+	if codec == nil {
+		codec = JSONCodec
+	}
+
+	fields := make([]string, 0, len(mpf.Values))
+	for field := range mpf.Values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	values := make([]multipartValue, 0, len(fields))
+	for _, field := range fields {
+		v := mpf.Values[field]
+		switch v.(type) {
+		case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			values = append(values, multipartValue{field: field, data: []byte(fmt.Sprint(v))})
+			continue
+		}
+		var buf bytes.Buffer
+		if err := codec.Encode(&buf, v); err != nil {
+			return nil, fmt.Errorf("multipart field %s: %s", field, err)
+		}
+		values = append(values, multipartValue{field: field, contentType: codec.ContentType(), data: buf.Bytes()})
+	}
+	return values, nil
+}
+
+func (v *multipartValue) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(v.field)))
+	if v.contentType != "" {
+		h.Set("Content-Type", v.contentType)
+	}
+	return h
+}
+
+// multipartBody streams a MultipartForm.
+type multipartBody struct {
+	form     *MultipartForm
+	boundary string
+	values   []multipartValue
+	parts    []MultipartFile // Files followed by Readers
+	size     int64           // -1 if unknown
+
+	mu      sync.Mutex
+	last    *multipartStream // the stream read from last
+	started bool             // whether the parts were read from
+}
+
+// newMultipartBody checks that the files of mpf can be read, so missing
+// files fail before the request is sent, and works out the body size when
+// possible.
+func newMultipartBody(mpf *MultipartForm) (*multipartBody, error) {
+	b := &multipartBody{form: mpf, boundary: multipart.NewWriter(nil).Boundary()}
+
+	values, err := mpf.encodeValues()
+	if err != nil {
+		return nil, err
+	}
+	b.values = values
+
+	fields := make([]string, 0, len(mpf.Files))
+	for field := range mpf.Files {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		path := mpf.Files[field]
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error with file %s, %s", path, err)
+		}
+		size := int64(-1)
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			size = fi.Size()
+		}
+		f.Close()
+
+		b.parts = append(b.parts, MultipartFile{Field: field, FileName: filepath.Base(path), Size: size, path: path})
+	}
+	b.parts = append(b.parts, mpf.Readers...)
+
+	size, err := b.measure()
+	if err != nil {
+		return nil, err
+	}
+	b.size = size
+	return b, nil
+}
+
+// measure returns the exact size of the body, or -1 if a part size is
+// unknown, by writing the body with empty parts to a counter.
+func (b *multipartBody) measure() (int64, error) {
+	var content int64
+	for _, p := range b.parts {
+		if p.Size < 0 {
+			return -1, nil
+		}
+		content += p.Size
+	}
+
+	cw := &countingWriter{}
+	if err := b.writeTo(cw, false); err != nil {
+		return 0, err
+	}
+	return cw.n + content, nil
+}
+
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func (b *multipartBody) contentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+// writeTo writes the body to w, with part contents unless withContent is
+// false.
+func (b *multipartBody) writeTo(w io.Writer, withContent bool) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+
+	fields := make([]string, 0, len(b.form.Fields))
+	for field := range b.form.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if err := mw.WriteField(field, b.form.Fields[field]); err != nil {
+			return err
+		}
+	}
+	for i := range b.values {
+		pw, err := mw.CreatePart(b.values[i].header())
+		if err != nil {
+			return err
+		}
+		if _, err := pw.Write(b.values[i].data); err != nil {
+			return err
+		}
+	}
+
+	for i := range b.parts {
+		p := &b.parts[i]
+		pw, err := mw.CreatePart(p.header())
+		if err != nil {
+			return err
+		}
+		if !withContent {
+			continue
+		}
+		n, err := p.copyTo(pw)
+		if err != nil {
+			return err
+		}
+		if p.Size >= 0 && n != p.Size {
+			return fmt.Errorf("multipart file %s: expected %d bytes, read %d", p.FileName, p.Size, n)
+		}
+	}
+	return mw.Close()
+}
+
+// copyTo copies the content of the part to w, opening its file if it has
+// one.
+func (p *MultipartFile) copyTo(w io.Writer) (int64, error) {
+	if p.path == "" {
+		return io.Copy(w, p.Reader)
+	}
+	f, err := os.Open(p.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+var errMultipartNotReplayable = errors.New("multipart body cannot be replayed: a reader is not an io.Seeker")
+
+// replayable reports whether the body can be sent more than once, that is
+// whether every reader part is an io.Seeker.
+func (b *multipartBody) replayable() bool {
+	for _, p := range b.parts {
+		if _, ok := p.Reader.(io.Seeker); p.path == "" && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// open returns a reader streaming the body. Nothing is read from the parts
+// until it is, so a body that is opened but never sent holds no file open.
+// Reading a body stops the body opened before it and, if that one was read
+// from, seeks the readers back to their start.
+func (b *multipartBody) open() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	var r io.ReadCloser = &multipartStream{body: b, pr: pr, pw: pw}
+	if b.form.Progress != nil {
+		r = &progressReader{ReadCloser: r, total: b.size, fn: b.form.Progress}
+	}
+	return r, nil
+}
+
+// multipartStream is a body returned by multipartBody.open. Its writer
+// starts on the first Read.
+type multipartStream struct {
+	body *multipartBody
+	once sync.Once
+	pr   *io.PipeReader
+	pw   *io.PipeWriter
+	done chan struct{} // closed when the writer returns; nil if it never started
+}
+
+func (s *multipartStream) Read(p []byte) (int, error) {
+	s.once.Do(s.start)
+	return s.pr.Read(p)
+}
+
+// start stops the stream started before s, rewinds the parts if one was,
+// and starts writing the body.
+func (s *multipartStream) start() {
+	b := s.body
+	b.mu.Lock()
+	prev, rewind := b.last, b.started
+	b.last, b.started = s, true
+	b.mu.Unlock()
+
+	if prev != nil {
+		prev.Close()
+		if prev.done != nil {
+			<-prev.done
+		}
+	}
+	if rewind {
+		for _, p := range b.parts {
+			if p.path != "" {
+				continue
+			}
+			seeker, ok := p.Reader.(io.Seeker)
+			if !ok {
+				s.pw.CloseWithError(errMultipartNotReplayable)
+				return
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				s.pw.CloseWithError(err)
+				return
+			}
+		}
+	}
+
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.pw.CloseWithError(b.writeTo(s.pw, true))
+	}()
+}
+
+// Close stops the writer of s, or keeps it from starting.
+func (s *multipartStream) Close() error {
+	s.once.Do(func() {})
+	return s.pr.Close()
+}
+
+// progressReader reports the bytes read through it.
+type progressReader struct {
+	io.ReadCloser
+	sent  int64
+	total int64
+	fn    func(sent, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.sent += int64(n)
+		r.fn(r.sent, r.total)
+	}
+	return n, err
+}
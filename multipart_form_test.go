@@ -0,0 +1,262 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// multipartEcho answers with the names and sizes of the form parts it got.
+func multipartEcho(lengths *[]int64, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lengths = append(*lengths, r.ContentLength)
+		if atomic.AddInt32(calls, 1) == 1 && r.URL.Path == "/flaky" {
+			ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var parts []string
+		for name, files := range r.MultipartForm.File {
+			for _, fh := range files {
+				f, _ := fh.Open()
+				b, _ := ioutil.ReadAll(f)
+				f.Close()
+				parts = append(parts, name+"="+fh.Filename+":"+string(b))
+			}
+		}
+		for name, v := range r.MultipartForm.Value {
+			parts = append(parts, name+"="+v[0])
+		}
+		sort.Strings(parts)
+		w.Write([]byte(`{"Foo":"` + strings.Join(parts, ",") + `"}`))
+	}))
+}
+
+func TestClient_PostMultipartJsonStreams(t *testing.T) {
+	var lengths []int64
+	var calls int32
+	server := multipartEcho(&lengths, &calls)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "relax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(path, []byte("file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var sent, total int64
+	mpf := NewMultipartForm()
+	mpf.Fields["name"] = "x"
+	mpf.Files["doc"] = path
+	mpf.AddReader("blob", "b.bin", strings.NewReader("reader"), 6)
+	mpf.Progress = func(s, tot int64) { sent, total = s, tot }
+
+	var data Response
+	if err := c.PostMultipartJson("/upload", *mpf, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "blob=b.bin:reader,doc=a.txt:file,name=x" {
+		t.Errorf("Unexpected parts %q", data.Foo)
+	}
+	if lengths[0] <= 0 || sent != lengths[0] || total != lengths[0] {
+		t.Errorf("Expected a Content-Length matching the progress, got %d, %d of %d", lengths[0], sent, total)
+	}
+
+	mpf = NewMultipartForm()
+	mpf.AddReader("blob", "b.bin", ioutil.NopCloser(bytes.NewBufferString("unknown")), -1)
+	if err := c.PostMultipartJson("/upload", *mpf, &data); err != nil {
+		t.Fatal(err)
+	}
+	if lengths[1] != -1 || data.Foo != "blob=b.bin:unknown" {
+		t.Errorf("Expected a chunked upload, got length %d and %q", lengths[1], data.Foo)
+	}
+}
+
+func TestClient_PostMultipartJsonRetries(t *testing.T) {
+	var lengths []int64
+	var calls int32
+	server := multipartEcho(&lengths, &calls)
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithRetry(&RetryPolicy{
+		MaxAttempts:        2,
+		Backoff:            ConstantBackoff(0),
+		RetryNonIdempotent: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mpf := NewMultipartForm()
+	mpf.AddReader("blob", "b.bin", strings.NewReader("seekable"), 8)
+
+	var data Response
+	if err := c.PostMultipartJson("/flaky", *mpf, &data); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || data.Foo != "blob=b.bin:seekable" {
+		t.Errorf("Expected the seekable reader to be resent, got %d calls and %q", calls, data.Foo)
+	}
+}
+
+func TestClient_PostMultipartJsonLogged(t *testing.T) {
+	var lengths []int64
+	var calls int32
+	server := multipartEcho(&lengths, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Logging = &LogConfig{MaxBodyBytes: 10, Hook: HookFuncs{}}
+
+	big := strings.Repeat("x", 5000)
+	seekable := NewMultipartForm()
+	seekable.AddReader("a", "a.txt", strings.NewReader(big), int64(len(big)))
+	seekable.AddReader("b", "b.txt", strings.NewReader("stream"), -1)
+	streamed := NewMultipartForm()
+	streamed.AddReader("a", "a.txt", struct{ io.Reader }{strings.NewReader(big)}, int64(len(big)))
+	streamed.AddReader("b", "b.txt", struct{ io.Reader }{strings.NewReader("stream")}, -1)
+
+	for _, mpf := range []*MultipartForm{seekable, streamed} {
+		var data Response
+		if err := c.PostMultipartJson("/upload", *mpf, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data.Foo != "a=a.txt:"+big+",b=b.txt:stream" {
+			t.Errorf("Expected logging not to drain the readers, got %.40q", data.Foo)
+		}
+	}
+}
+
+func TestMultipartBody_OpenLazily(t *testing.T) {
+	mpf := NewMultipartForm()
+	r := strings.NewReader("content")
+	mpf.AddReader("a", "a.txt", r, 7)
+	b, err := newMultipartBody(mpf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := b.open()
+	second, _ := b.open()
+	second.Close()
+	if r.Len() != 7 {
+		t.Errorf("Expected no part to be read before the body is, %d bytes left", r.Len())
+	}
+
+	head := make([]byte, 1)
+	if _, err := first.Read(head); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := b.open()
+	data, err := ioutil.ReadAll(third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\r\n\r\ncontent\r\n") {
+		t.Errorf("Expected the reader to be sent whole again, got %q", data)
+	}
+	if _, err := ioutil.ReadAll(first); err == nil {
+		t.Errorf("Expected the body opened first to be stopped")
+	}
+}
+
+func TestClient_MakeMultipartRequestMissingFile(t *testing.T) {
+	c := newClientOrFatal(t, goodURL, apiKey)
+	mpf := NewMultipartForm()
+	mpf.Files["doc"] = "/does/not/exist"
+
+	if _, err := c.MakeMultipartRequest(http.MethodPost, "/upload", *mpf); err == nil {
+		t.Errorf("Expected a missing file to fail before sending")
+	}
+}
+
+func TestClient_PostMultipartJsonValues(t *testing.T) {
+	values := make(map[string]string)
+	types := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := ioutil.ReadAll(part)
+			values[part.FormName()] = string(b)
+			types[part.FormName()] = part.Header.Get("Content-Type")
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	type meta struct {
+		Title string `json:"title"`
+	}
+	mpf := NewMultipartForm()
+	mpf.AddValue("count", 3)
+	mpf.AddValue("draft", true)
+	mpf.AddValue("meta", meta{Title: "t"})
+	mpf.AddReader("blob", "b.bin", strings.NewReader("reader"), 6)
+
+	if err := c.PostMultipartJson("/upload", *mpf, nil); err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{"count": "3", "draft": "true", "meta": `{"title":"t"}`, "blob": "reader"} {
+		if got := values[field]; got != want {
+			t.Errorf("Expected %s to be %q, got %q", field, want, got)
+		}
+	}
+	if types["count"] != "" || types["meta"] != "application/json" {
+		t.Errorf("Expected only meta to carry a Content-Type, got %q", types)
+	}
+}
+
+type multipartMeta struct {
+	XMLName xml.Name `xml:"meta"`
+	Title   string   `xml:"title"`
+}
+
+func TestMultipartForm_FieldCodec(t *testing.T) {
+	mpf := NewMultipartForm()
+	mpf.FieldCodec = XMLCodec
+	mpf.AddValue("meta", multipartMeta{Title: "t"})
+
+	b, err := newMultipartBody(mpf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.writeTo(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	if body := buf.String(); !strings.Contains(body, "Content-Type: application/xml") || !strings.Contains(body, "<title>t</title>") {
+		t.Errorf("Expected an XML encoded part, got %q", body)
+	}
+}
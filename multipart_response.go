@@ -0,0 +1,105 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PartHandler is called for each part of a multipart response. The part is
+// only readable until the handler returns.
+type PartHandler func(p *multipart.Part) error
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadMultipart GETs uri and passes each part of the multipart response
+// (multipart/mixed, multipart/related, ...) to handler as it arrives, so
+// large bundles are never buffered whole. Returning an error from handler
+// stops reading.
+func (c *Client) ReadMultipart(uri string, handler PartHandler, opts ...RequestOption) error {
+	return c.ReadMultipartContext(context.Background(), uri, handler, opts...)
+}
+
+// ReadMultipartContext is like ReadMultipart but ctx bounds the request.
+func (c *Client) ReadMultipartContext(ctx context.Context, uri string, handler PartHandler, opts ...RequestOption) error {
+	req, err := c.MakeRequestContext(ctx, http.MethodGet, uri)
+	if err != nil {
+		return err
+	}
+	if err := newCallOptions(opts).apply(req, c); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	body := &countingReader{}
+	fail := func(err error) error {
+		return &RequestError{
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Attempt:   1,
+			Elapsed:   time.Since(start),
+			BytesRead: body.n,
+			Err:       err,
+		}
+	}
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer res.Body.Close()
+	body.r = res.Body
+
+	if !isSuccess(res.StatusCode) {
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return fail(err)
+		}
+		return fail(c.apiError(res, data))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return fail(err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return fail(fmt.Errorf("expected a multipart response, got %q", mediaType))
+	}
+
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		err = handler(p)
+		p.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+)
+
+func TestClient_ReadMultipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		for _, body := range []string{"{\"Foo\": \"one\"}", "{\"Foo\": \"two\"}"} {
+			pw, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json"}})
+			pw.Write([]byte(body))
+		}
+		mw.Close()
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var parts []string
+	err := c.ReadMultipart("/api/batch", func(p *multipart.Part) error {
+		b, err := ioutil.ReadAll(p)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, string(b))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != 2 || parts[1] != "{\"Foo\": \"two\"}" {
+		t.Errorf("Unexpected parts %q", parts)
+	}
+
+	stop := errors.New("stop")
+	err = c.ReadMultipart("/api/batch", func(p *multipart.Part) error {
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Expected handler error, got %v", err)
+	}
+}
+
+func TestClient_ReadMultipartRejectsOtherContent(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: "{}", Path: "/api/batch"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	err := c.ReadMultipart("/api/batch", func(p *multipart.Part) error { return nil })
+
+	var rerr *RequestError
+	if !errors.As(err, &rerr) {
+		t.Errorf("Expected *RequestError, got %v", err)
+	}
+}
+
+func TestClient_ReadMultipartAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "no such bundle"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	err := c.ReadMultipart("/api/bundle", func(p *multipart.Part) error {
+		t.Errorf("Expected no parts")
+		return nil
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || !IsNotFound(err) {
+		t.Fatalf("Expected a 404 APIError, got %v", err)
+	}
+	if string(apiErr.Body) != `{"error": "no such bundle"}` {
+		t.Errorf("Expected the error body, got %q", apiErr.Body)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// NegotiationConfig lets a call recover from an upstream that serves
+// another media type than asked for: when the response cannot be decoded,
+// or the server answers 406 Not Acceptable, the call is sent once more with
+// the Accept header of another codec, decoded with that codec.
+//
+// The media type asked for again is, in order: one of Codecs matching the
+// Content-Type of the undecodable response, one matching the Accept header
+// of the response, or, after a 406 or from a response that Varies on
+// Accept, the first of Codecs not tried yet. Without any of these the
+// server advertised no alternative and the error is returned as is.
+type NegotiationConfig struct {
+	// Codecs are the codecs that may be asked for, in order of
+	// preference. Defaults to JSONCodec, XMLCodec and MsgpackCodec.
+	Codecs []Codec
+
+	// NonIdempotent also sends again POST and PATCH requests without an
+	// idempotency key whose response could not be decoded, although the
+	// server acted on them. A 406 is always renegotiated, as the server
+	// refused the request.
+	NonIdempotent bool
+}
+
+// WithNegotiation retries calls with another media type as configured by
+// cfg.
+func WithNegotiation(cfg *NegotiationConfig) Option {
+	return func(c *Client) {
+		c.Negotiation = cfg
+	}
+}
+
+func (n *NegotiationConfig) codecs() []Codec {
+	if n.Codecs != nil {
+		return n.Codecs
+	}
+	return []Codec{JSONCodec, XMLCodec, MsgpackCodec}
+}
+
+// negotiate runs the call of req and, if its response called for it, runs
+// it again with another codec.
+func (c *Client) negotiate(req *http.Request, response interface{}, o *callOptions) error {
+	// The call changes the URL and headers of req, and reads its body.
+	retry := req.Clone(req.Context())
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	first := *o
+	if first.result == nil {
+		first.result = &Result{}
+	}
+	err := c.exchange(req, response, &first)
+	if err == nil || !replayable {
+		return err
+	}
+	codec := c.Negotiation.alternative(first.result, err, c.codecFor(o), isIdempotent(retry, c.idempotencyHeader()))
+	if codec == nil {
+		return err
+	}
+	if retry.GetBody != nil {
+		body, berr := retry.GetBody()
+		if berr != nil {
+			return err
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("Accept", codec.ContentType())
+
+	d := *o
+	d.codec, d.decoder = codec, nil
+	d.result = first.result
+	attempts := first.result.Attempts
+	err = c.exchange(retry, response, &d)
+	d.result.Attempts += attempts
+	d.result.Renegotiated = codec.ContentType()
+	return err
+}
+
+// alternative returns the codec to ask for after the outcome res and err
+// of a call decoded with current, or nil if the call should not be sent
+// again.
+func (n *NegotiationConfig) alternative(res *Result, err error, current Codec, idempotent bool) Codec {
+	var advertised []string
+	var decodeErr *DecodeError
+	switch {
+	case res.StatusCode == http.StatusNotAcceptable:
+	case errors.As(err, &decodeErr) && isSuccess(res.StatusCode) && !res.Cached:
+		if !idempotent && !n.NonIdempotent {
+			return nil
+		}
+		advertised = append(advertised, res.Header.Get("Content-Type"))
+	default:
+		return nil
+	}
+	advertised = append(advertised, headerList(res.Header, "Accept")...)
+
+	for _, t := range advertised {
+		for _, codec := range n.codecs() {
+			if codec.ContentType() != current.ContentType() && sameMediaType(codec.ContentType(), t) {
+				return codec
+			}
+		}
+	}
+	if res.StatusCode != http.StatusNotAcceptable && !containsFold(headerList(res.Header, "Vary"), "Accept") {
+		return nil
+	}
+	for _, codec := range n.codecs() {
+		if codec.ContentType() != current.ContentType() {
+			return codec
+		}
+	}
+	return nil
+}
+
+// headerList returns the comma separated values of the header name in h.
+func headerList(h http.Header, name string) []string {
+	var list []string
+	for _, v := range h.Values(name) {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				list = append(list, f)
+			}
+		}
+	}
+	return list
+}
+
+// sameMediaType reports whether the advertised media type is the format of
+// want, counting structured syntax suffixes: application/problem+json and
+// text/xml are JSON and XML.
+func sameMediaType(want, advertised string) bool {
+	mt, _, err := mime.ParseMediaType(advertised)
+	if err != nil {
+		return false
+	}
+	_, format, _ := strings.Cut(want, "/")
+	_, sub, _ := strings.Cut(mt, "/")
+	return mt == want || sub == format || strings.HasSuffix(sub, "+"+format)
+}
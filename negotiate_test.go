@@ -0,0 +1,159 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type negotiated struct {
+	Foo string `json:"foo" xml:"foo"`
+}
+
+// negotiatingServer answers XML mislabeled as JSON unless asked for XML
+// explicitly, and counts the requests.
+func negotiatingServer(calls *int, accepts *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		*accepts = append(*accepts, r.Header.Get("Accept"))
+		if r.Header.Get("Accept") == "application/xml" {
+			w.Header().Set("Content-Type", "application/xml")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Vary", "Accept")
+		}
+		w.Write([]byte("<negotiated><foo>bar</foo></negotiated>"))
+	}))
+}
+
+func TestClient_NegotiationDecodeFailure(t *testing.T) {
+	var calls int
+	var accepts []string
+	server := negotiatingServer(&calls, &accepts)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	var v negotiated
+	var decodeErr *DecodeError
+	if err := c.ReadJson("/api/item", &v); !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected a DecodeError without Negotiation, got %v", err)
+	}
+
+	calls, accepts = 0, nil
+	c.Negotiation = &NegotiationConfig{}
+	var result Result
+	if err := c.ReadJson("/api/item", &v, WithResult(&result)); err != nil {
+		t.Fatal(err)
+	}
+	if v.Foo != "bar" {
+		t.Errorf("Expected the XML representation to be decoded, got %+v", v)
+	}
+	if calls != 2 || accepts[1] != "application/xml" {
+		t.Errorf("Expected a second request accepting XML, got %d with %q", calls, accepts)
+	}
+	if result.Renegotiated != "application/xml" || result.Attempts != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestClient_NegotiationNotAcceptable(t *testing.T) {
+	var accepts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepts = append(accepts, r.Header.Get("Accept"))
+		if r.Header.Get("Accept") != "application/xml" {
+			w.Header().Set("Accept", "text/csv, application/xml")
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte("<negotiated><foo>bar</foo></negotiated>"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Negotiation = &NegotiationConfig{}
+
+	var v negotiated
+	if err := c.CreateJson("/api/items", map[string]string{"foo": "bar"}, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Foo != "bar" || len(accepts) != 2 {
+		t.Errorf("Expected the POST to be renegotiated once, got %+v after %q", v, accepts)
+	}
+}
+
+func TestClient_NegotiationNoAlternative(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>Bad Gateway</title></html>"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Negotiation = &NegotiationConfig{}
+
+	var v negotiated
+	if err := c.ReadJson("/api/item", &v); !errors.Is(err, ErrUnexpectedContent) {
+		t.Errorf("Expected the decode error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no renegotiation without an advertised alternative, got %d requests", calls)
+	}
+}
+
+func TestClient_NegotiationNonIdempotent(t *testing.T) {
+	var calls int
+	var accepts []string
+	server := negotiatingServer(&calls, &accepts)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Negotiation = &NegotiationConfig{}
+
+	var v negotiated
+	if err := c.CreateJson("/api/items", map[string]string{}, &v); err == nil {
+		t.Errorf("Expected the decode error of a POST")
+	}
+	if calls != 1 {
+		t.Errorf("Expected a POST not to be sent again, got %d requests", calls)
+	}
+
+	calls = 0
+	c.Negotiation.NonIdempotent = true
+	if err := c.CreateJson("/api/items", map[string]string{}, &v); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || v.Foo != "bar" {
+		t.Errorf("Expected NonIdempotent to renegotiate the POST, got %d requests and %+v", calls, v)
+	}
+}
+
+func TestSameMediaType(t *testing.T) {
+	tests := []struct {
+		want, advertised string
+		same             bool
+	}{
+		{"application/json", "application/json; charset=utf-8", true},
+		{"application/json", "application/problem+json", true},
+		{"application/xml", "text/xml", true},
+		{"application/xml", "application/atom+xml", true},
+		{"application/json", "text/html", false},
+		{"application/json", "", false},
+	}
+	for _, tt := range tests {
+		if got := sameMediaType(tt.want, tt.advertised); got != tt.same {
+			t.Errorf("Expected sameMediaType(%q, %q) to be %v", tt.want, tt.advertised, tt.same)
+		}
+	}
+	if got := headerList(http.Header{"Vary": {"Accept-Encoding, Accept", " Origin"}}, "Vary"); strings.Join(got, "|") != "Accept-Encoding|Accept|Origin" {
+		t.Errorf("Unexpected header list %q", got)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SlashPolicy controls trailing slashes on request paths.
+type SlashPolicy int
+
+const (
+	// SlashKeep leaves paths as given. This is the default.
+	SlashKeep SlashPolicy = iota
+
+	// SlashAdd ensures every path ends in "/".
+	SlashAdd
+
+	// SlashStrip removes trailing slashes, except from the root path.
+	SlashStrip
+)
+
+// URLNormalization rewrites request URLs before they are sent, so URIs
+// spelled differently by different callers share cache entries and
+// per-route policies.
+type URLNormalization struct {
+	TrailingSlash SlashPolicy
+
+	// LowercaseHost lowercases the host name.
+	LowercaseHost bool
+
+	// LowercasePath lowercases the path. Only use it with servers that
+	// treat paths case-insensitively.
+	LowercasePath bool
+
+	// CollapseSlashes replaces runs of "/" in the path with a single one.
+	CollapseSlashes bool
+}
+
+// WithURLNormalization sets the client's URL normalization.
+func WithURLNormalization(n *URLNormalization) Option {
+	return func(c *Client) {
+		c.Normalize = n
+	}
+}
+
+// apply normalizes u in place. Applying it twice changes nothing.
+func (n *URLNormalization) apply(u *url.URL) {
+	if n.LowercaseHost {
+		u.Host = strings.ToLower(u.Host)
+	}
+
+	p := u.EscapedPath()
+	if n.LowercasePath {
+		p = strings.ToLower(p)
+	}
+	if n.CollapseSlashes {
+		for strings.Contains(p, "//") {
+			p = strings.Replace(p, "//", "/", -1)
+		}
+	}
+	switch n.TrailingSlash {
+	case SlashAdd:
+		if !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
+	case SlashStrip:
+		if trimmed := strings.TrimRight(p, "/"); trimmed != "" || p == "" {
+			p = trimmed
+		} else {
+			p = "/"
+		}
+	}
+
+	if p == u.EscapedPath() {
+		return
+	}
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		u.Path, u.RawPath = unescaped, p
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestURLNormalization(t *testing.T) {
+	tests := []struct {
+		n    URLNormalization
+		in   string
+		want string
+	}{
+		{URLNormalization{}, "https://Host//a//b/", "https://Host//a//b/"},
+		{URLNormalization{LowercaseHost: true}, "https://API.Example.com/A", "https://api.example.com/A"},
+		{URLNormalization{LowercasePath: true}, "https://h/Users/ABC", "https://h/users/abc"},
+		{URLNormalization{CollapseSlashes: true}, "https://h//a///b?x=//", "https://h/a/b?x=//"},
+		{URLNormalization{TrailingSlash: SlashAdd}, "https://h/a?x=1", "https://h/a/?x=1"},
+		{URLNormalization{TrailingSlash: SlashAdd}, "https://h", "https://h/"},
+		{URLNormalization{TrailingSlash: SlashStrip}, "https://h/a//", "https://h/a"},
+		{URLNormalization{TrailingSlash: SlashStrip}, "https://h/", "https://h/"},
+		{URLNormalization{TrailingSlash: SlashStrip, CollapseSlashes: true}, "https://h/a%2Fb/", "https://h/a%2Fb"},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.n.apply(u)
+		if u.String() != tt.want {
+			t.Errorf("%+v: %s normalized to %s, want %s", tt.n, tt.in, u, tt.want)
+		}
+		tt.n.apply(u)
+		if u.String() != tt.want {
+			t.Errorf("%+v: normalizing %s twice gave %s", tt.n, tt.in, u)
+		}
+	}
+}
+
+func TestClient_Normalize(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithURLNormalization(&URLNormalization{
+		TrailingSlash:   SlashStrip,
+		CollapseSlashes: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ReadJson("/api//items/", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/api/items" {
+		t.Errorf("Expected /api/items, got %s", got.URL.Path)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/x//y/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetResponse(req); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/x/y" {
+		t.Errorf("Expected requests built elsewhere to be normalized, got %s", got.URL.Path)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenRefreshLeeway is how long before its expiry a token is
+// refreshed when TokenAuthenticator.Leeway is zero.
+const DefaultTokenRefreshLeeway = 10 * time.Second
+
+// Token is an OAuth2 access token.
+type Token struct {
+	AccessToken string
+
+	// TokenType is the scheme sent in the Authorization header. Defaults
+	// to Bearer.
+	TokenType string
+
+	// Expiry is when the token expires. The zero time means it does not.
+	Expiry time.Time
+}
+
+// TokenProvider fetches access tokens, e.g. from an OAuth2 token endpoint.
+// An oauth2.TokenSource is adapted with
+//
+//	relax.TokenProviderFunc(func(ctx context.Context) (*relax.Token, error) {
+//		t, err := src.Token()
+//		if err != nil {
+//			return nil, err
+//		}
+//		return &relax.Token{AccessToken: t.AccessToken, TokenType: t.Type(), Expiry: t.Expiry}, nil
+//	})
+type TokenProvider interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenProviderFunc adapts a function to the TokenProvider interface.
+type TokenProviderFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenProviderFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// TokenAuthenticator authenticates requests with tokens from Provider. It
+// caches the current token and fetches a new one shortly before it
+// expires; concurrent requests share a single fetch. A 401 response drops
+// the cached token, so a revoked token is replaced on the next attempt.
+type TokenAuthenticator struct {
+	Provider TokenProvider
+
+	// Leeway is how long before expiry a token is refreshed. Defaults to
+	// DefaultTokenRefreshLeeway.
+	Leeway time.Duration
+
+	mu     sync.Mutex
+	token  *Token
+	flight *tokenFlight
+}
+
+// tokenFlight is a token fetch in progress.
+type tokenFlight struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// OAuth2Auth returns an Authenticator sending tokens from p.
+func OAuth2Auth(p TokenProvider) *TokenAuthenticator {
+	return &TokenAuthenticator{Provider: p}
+}
+
+// WithTokenProvider authenticates every request with tokens from p. The
+// API key given to NewClient may then be empty.
+func WithTokenProvider(p TokenProvider) Option {
+	return WithAuthenticator(OAuth2Auth(p))
+}
+
+func (a *TokenAuthenticator) Apply(r *http.Request) error {
+	t, err := a.Token(r.Context())
+	if err != nil {
+		return err
+	}
+	scheme := t.TokenType
+	if scheme == "" || strings.EqualFold(scheme, "bearer") {
+		scheme = "Bearer"
+	}
+	r.Header.Set("Authorization", scheme+" "+t.AccessToken)
+	return nil
+}
+
+// Token returns the cached token, fetching a new one if it is missing or
+// about to expire.
+func (a *TokenAuthenticator) Token(ctx context.Context) (*Token, error) {
+	leeway := a.Leeway
+	if leeway <= 0 {
+		leeway = DefaultTokenRefreshLeeway
+	}
+
+	a.mu.Lock()
+	if t := a.token; t != nil && (t.Expiry.IsZero() || time.Now().Add(leeway).Before(t.Expiry)) {
+		a.mu.Unlock()
+		return t, nil
+	}
+	f := a.flight
+	if f == nil {
+		f = &tokenFlight{done: make(chan struct{})}
+		a.flight = f
+		// The fetch is shared, so it must not be cut short when the
+		// request that started it is cancelled.
+		go a.fetch(context.WithoutCancel(ctx), f)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.token, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *TokenAuthenticator) fetch(ctx context.Context, f *tokenFlight) {
+	t, err := a.Provider.Token(ctx)
+	if err == nil && (t == nil || t.AccessToken == "") {
+		err = errors.New("token provider returned no access token")
+	}
+	if err != nil {
+		err = fmt.Errorf("fetching access token: %w", err)
+	}
+
+	a.mu.Lock()
+	if err == nil {
+		a.token = t
+	}
+	a.flight = nil
+	a.mu.Unlock()
+
+	f.token, f.err = t, err
+	close(f.done)
+}
+
+// Invalidate drops the cached token so the next request fetches a new one.
+func (a *TokenAuthenticator) Invalidate() {
+	a.mu.Lock()
+	a.token = nil
+	a.mu.Unlock()
+}
+
+// rejected drops the cached token after the server refused it in r.
+func (a *TokenAuthenticator) rejected(r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != nil && strings.HasSuffix(r.Header.Get("Authorization"), " "+a.token.AccessToken) {
+		a.token = nil
+	}
+}
+
+// ClientCredentials fetches tokens with the OAuth2 client credentials
+// grant.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// HTTPClient sends the token requests. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func (cc *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cc.Scopes) > 0 {
+		form.Set("scope", strings.Join(cc.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cc.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cc.ClientID), url.QueryEscape(cc.ClientSecret))
+
+	hc := cc.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !isSuccess(res.StatusCode) {
+		return nil, newAPIError(res, body, DefaultErrorPreviewBytes)
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, &DecodeError{Err: err, Preview: bodyPreview(body, DefaultErrorPreviewBytes)}
+	}
+	t := &Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType}
+	if tr.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
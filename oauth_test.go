@@ -0,0 +1,134 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProvider hands out tokens "t1", "t2", ... valid for ttl.
+func countingProvider(fetches *int32, ttl time.Duration) TokenProvider {
+	return TokenProviderFunc(func(ctx context.Context) (*Token, error) {
+		n := atomic.AddInt32(fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Token{AccessToken: "t" + strconv.Itoa(int(n)), Expiry: time.Now().Add(ttl)}, nil
+	})
+}
+
+func TestClient_TokenProviderSingleFlight(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Header.Get("Authorization")]++
+		mu.Unlock()
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	var fetches int32
+	c, err := NewClient(server.URL, "", WithTokenProvider(countingProvider(&fetches, time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.ReadJson("/api/foo", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Expected 1 token fetch, got %d", n)
+	}
+	if seen["Bearer t1"] != 20 {
+		t.Errorf("Expected every request to carry the token, got %v", seen)
+	}
+}
+
+func TestClient_TokenProviderRefresh(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	var fetches int32
+	auth := OAuth2Auth(countingProvider(&fetches, 200*time.Millisecond))
+	auth.Leeway = 100 * time.Millisecond
+	c, err := NewClient(server.URL, "", WithAuthenticator(auth))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.ReadJson("/api/foo", nil)
+	c.ReadJson("/api/foo", nil)
+	if h := got.Header.Get("Authorization"); h != "Bearer t1" {
+		t.Errorf("Expected cached token, got %q", h)
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	c.ReadJson("/api/foo", nil)
+	if h := got.Header.Get("Authorization"); h != "Bearer t2" {
+		t.Errorf("Expected token refreshed before expiry, got %q", h)
+	}
+}
+
+func TestClient_TokenProviderUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer t1" {
+			http.Error(w, "revoked", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	var fetches int32
+	c, err := NewClient(server.URL, "", WithTokenProvider(countingProvider(&fetches, time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ReadJson("/api/foo", nil); err == nil {
+		t.Error("Expected the revoked token to be refused")
+	}
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Errorf("Expected a new token after a 401, got %v", err)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "id" || secret != "secret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"abc","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	cc := &ClientCredentials{TokenURL: server.URL, ClientID: "id", ClientSecret: "secret", Scopes: []string{"read", "write"}}
+	tok, err := cc.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "abc" || time.Until(tok.Expiry) < 59*time.Minute {
+		t.Errorf("Unexpected token %+v", tok)
+	}
+}
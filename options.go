@@ -0,0 +1,191 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a Client in NewClient.
+type Option func(*Client)
+
+// WithHTTPClient makes the client send requests through a copy of hc, e.g.
+// one with a proxy or custom TLS transport. Hosts overrides only apply when
+// hc has no Transport of its own.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTimeout limits the total time of each request, including reading the
+// response body. See WithTimeouts for limits on each phase of a request.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return WithDefaultHeader("User-Agent", ua)
+}
+
+// WithDefaultHeader adds a header value sent with every request. See
+// Client.DefaultHeader.
+func WithDefaultHeader(key, value string) Option {
+	return func(c *Client) {
+		c.ownDefaultHeader().Add(key, value)
+	}
+}
+
+// RequestOption customizes a single call.
+type RequestOption func(*callOptions)
+
+type callOptions struct {
+	decoder        Decoder
+	codec          Codec
+	errorExtractor ErrorExtractor
+	idempotencyKey string
+	presets        []string
+	query          url.Values
+	params         []interface{}
+	timing         *Timing
+	result         *Result
+	header         http.Header
+	timeout        time.Duration
+	route          string
+	pathParams     Path
+	informational  InformationalFunc
+	fireAndForget  bool
+	onError        func(error)
+	updateMode     UpdateMode
+	ifChanged      bool
+	err            error
+}
+
+func newCallOptions(opts []RequestOption) *callOptions {
+	o := &callOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// apply sets the request level options on r for c, sending the
+// idempotency key in c's idempotency header and encoding WithParams with
+// c's QueryEncoding. It fails if an option could not be built.
+func (o *callOptions) apply(r *http.Request, c *Client) error {
+	if o.err != nil {
+		return o.err
+	}
+	if o.pathParams != nil {
+		if o.route == "" {
+			o.route = r.URL.Path
+		}
+		if err := expandPath(r.URL, o.pathParams); err != nil {
+			return err
+		}
+	}
+	if o.idempotencyKey != "" {
+		r.Header.Set(c.idempotencyHeader(), o.idempotencyKey)
+	}
+	for k, v := range o.header {
+		r.Header[k] = v
+	}
+	if len(o.query) > 0 || len(o.params) > 0 {
+		q := r.URL.Query()
+		for _, p := range o.params {
+			values, err := c.QueryEncoding.Encode(p)
+			if err != nil {
+				return err
+			}
+			for k, v := range values {
+				q[k] = append(q[k], v...)
+			}
+		}
+		for k, v := range o.query {
+			q[k] = append(q[k], v...)
+		}
+		r.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+func (o *callOptions) setHeader(key, value string) {
+	if o.header == nil {
+		o.header = make(http.Header)
+	}
+	o.header.Set(key, value)
+}
+
+// WithDecoder decodes the response of this call with d instead of the
+// client's Decoder.
+func WithDecoder(d Decoder) RequestOption {
+	return func(o *callOptions) {
+		o.decoder = d
+	}
+}
+
+// WithIdempotencyKey sends key in the Idempotency-Key header, or the
+// client's IdempotencyHeader, so the server and the client's replay cache
+// can recognize a retried operation.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *callOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// FireAndForget sends the call in the background and returns nil at once,
+// without waiting for the response, whose body is discarded. The request
+// outlives the cancellation of its context but not its deadline. onError,
+// if not nil, is called from the background goroutine when the call fails.
+func FireAndForget(onError func(error)) RequestOption {
+	return func(o *callOptions) {
+		o.fireAndForget = true
+		o.onError = onError
+	}
+}
+
+// WithHeader sets a header on this call, e.g. WithHeader("If-Match",
+// etag). Like a header set on the request itself, it takes precedence over
+// presets and, unless HeaderPolicy says otherwise, DefaultHeader.
+func WithHeader(key, value string) RequestOption {
+	return func(o *callOptions) {
+		o.setHeader(key, value)
+	}
+}
+
+// WithCallTimeout bounds this call, including retries and decoding, to d.
+// Unlike WithTimeout it does not change the client.
+func WithCallTimeout(d time.Duration) RequestOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// WithQuery adds a query parameter to this call, escaping it properly.
+func WithQuery(key, value string) RequestOption {
+	return func(o *callOptions) {
+		if o.query == nil {
+			o.query = make(url.Values)
+		}
+		o.query.Add(key, value)
+	}
+}
+
+// WithQueryValues adds every parameter in values to this call.
+func WithQueryValues(values url.Values) RequestOption {
+	return func(o *callOptions) {
+		if o.query == nil {
+			o.query = make(url.Values)
+		}
+		for k, v := range values {
+			o.query[k] = append(o.query[k], v...)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Overrides replace client settings for every call made with a context
+// from ContextWithOverrides, for code deep in a call stack that is only
+// handed a context.
+type Overrides struct {
+	// Header values are set on every request, over the client's
+	// DefaultHeader and ContextHeaders but under the call's own headers.
+	Header http.Header
+
+	// Timeout, when nonzero, replaces the client's WithTimeout for each
+	// attempt. A negative Timeout removes it.
+	Timeout time.Duration
+
+	// Retry, when set, replaces the client's RetryPolicy.
+	Retry *RetryPolicy
+
+	// DisableRetries sends every request once, whatever the RetryPolicy.
+	DisableRetries bool
+}
+
+type overridesContextKey struct{}
+
+// ContextWithOverrides returns a copy of ctx whose calls apply o. The
+// fields set in o take precedence over the overrides ctx already carries;
+// headers are merged. Presets selected for a call take precedence over
+// the retry policy of o.
+//
+//	ctx = relax.ContextWithOverrides(ctx, relax.Overrides{DisableRetries: true})
+func ContextWithOverrides(ctx context.Context, o Overrides) context.Context {
+	merged := o
+	if prev, ok := OverridesFromContext(ctx); ok {
+		merged = prev
+		if len(o.Header) > 0 {
+			merged.Header = prev.Header.Clone()
+			if merged.Header == nil {
+				merged.Header = make(http.Header)
+			}
+			for k, v := range o.Header {
+				merged.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+			}
+		}
+		if o.Timeout != 0 {
+			merged.Timeout = o.Timeout
+		}
+		if o.Retry != nil {
+			merged.Retry = o.Retry
+			merged.DisableRetries = false
+		}
+		if o.DisableRetries {
+			merged.DisableRetries = true
+		}
+	}
+	return context.WithValue(ctx, overridesContextKey{}, &merged)
+}
+
+// OverridesFromContext returns the overrides ctx carries, if any.
+func OverridesFromContext(ctx context.Context) (Overrides, bool) {
+	o, ok := ctx.Value(overridesContextKey{}).(*Overrides)
+	if !ok {
+		return Overrides{}, false
+	}
+	return *o, true
+}
+
+// overridesOf returns the overrides of r, or nil.
+func overridesOf(r *http.Request) *Overrides {
+	o, _ := r.Context().Value(overridesContextKey{}).(*Overrides)
+	return o
+}
+
+// httpClientFor returns the http.Client sending r, with the timeout of its
+// overrides if any.
+func (c *Client) httpClientFor(r *http.Request) *http.Client {
+	o := overridesOf(r)
+	if o == nil || o.Timeout == 0 {
+		return c.client
+	}
+	hc := *c.client
+	hc.Timeout = o.Timeout
+	if hc.Timeout < 0 {
+		hc.Timeout = 0
+	}
+	return &hc
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_ContextOverridesHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.DefaultHeader = http.Header{"X-Source": {"client"}, "X-Kept": {"yes"}}
+
+	ctx := ContextWithOverrides(context.Background(), Overrides{Header: http.Header{"X-Source": {"ctx"}, "X-Call": {"ctx"}}})
+	ctx = ContextWithOverrides(ctx, Overrides{Header: http.Header{"X-Inner": {"1"}}})
+	if err := c.ReadJsonContext(ctx, "/", nil, WithHeader("X-Call", "call")); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"X-Source": "ctx", "X-Kept": "yes", "X-Call": "call", "X-Inner": "1"} {
+		if got.Get(name) != want {
+			t.Errorf("Expected %s: %s, got %q", name, want, got.Get(name))
+		}
+	}
+}
+
+func TestClient_ContextOverridesRetries(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(5, http.StatusServiceUnavailable, "", &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(0)}
+
+	ctx := ContextWithOverrides(context.Background(), Overrides{DisableRetries: true})
+	if err := c.ReadJsonContext(ctx, "/", nil); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single attempt, got %d", calls)
+	}
+
+	calls = 0
+	ctx = ContextWithOverrides(ctx, Overrides{Retry: &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(0)}})
+	c.ReadJsonContext(ctx, "/", nil)
+	if calls != 2 {
+		t.Errorf("Expected the overriding policy to make 2 attempts, got %d", calls)
+	}
+}
+
+func TestClient_ContextOverridesTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReadJson("/", nil); err == nil {
+		t.Fatal("Expected the client timeout to apply")
+	}
+	ctx := ContextWithOverrides(context.Background(), Overrides{Timeout: time.Second})
+	if err := c.ReadJsonContext(ctx, "/", nil); err != nil {
+		t.Errorf("Expected the overriding timeout to apply, got %v", err)
+	}
+	ctx = ContextWithOverrides(context.Background(), Overrides{Timeout: -1})
+	if err := c.ReadJsonContext(ctx, "/", nil); err != nil {
+		t.Errorf("Expected no timeout, got %v", err)
+	}
+}
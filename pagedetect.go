@@ -0,0 +1,275 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PageStrategy is how a Paginator finds the next page.
+type PageStrategy string
+
+const (
+	// PageNone means no next page was found: the collection was not
+	// paged, or nothing told how.
+	PageNone PageStrategy = ""
+
+	// PageLink follows rel="next" Link headers.
+	PageLink PageStrategy = "link"
+
+	// PageNextURL follows a next page URL in the body, such as
+	// {"next": "https://..."} or HAL's {"_links": {"next": {"href": ...}}}.
+	PageNextURL PageStrategy = "next url"
+
+	PageRange  PageStrategy = "range"
+	PageCursor PageStrategy = "cursor"
+	PageOffset PageStrategy = "offset"
+	PageNumber PageStrategy = "page"
+)
+
+// Strategy returns how p finds the next page: the one configured in its
+// PageOptions, the one detected from the first page with Detect, or
+// PageLink once it followed a Link header. It is PageNone until the first
+// page is fetched.
+func (p *Paginator) Strategy() PageStrategy {
+	return p.strategy
+}
+
+// configured returns the strategy set in the options.
+func (p *Paginator) configured() PageStrategy {
+	switch {
+	case p.opts.RangeUnit != "":
+		return PageRange
+	case p.opts.CursorParam != "":
+		return PageCursor
+	case p.opts.OffsetParam != "":
+		return PageOffset
+	case p.opts.PageParam != "":
+		return PageNumber
+	}
+	return PageNone
+}
+
+// pageHints locate paging metadata in page bodies, as paths of JSON
+// object keys.
+type pageHints struct {
+	next     []string
+	total    []string
+	lastPage []string
+}
+
+// Where detect looks for paging metadata: the top level of a page, then
+// these objects in it.
+var pageMetaFields = []string{"meta", "pagination", "paging", "page_info", "pageInfo", "links", "_links"}
+
+var (
+	pageItemsFields    = []string{"items", "data", "results", "records", "entries", "values", "content"}
+	pageNextURLFields  = []string{"next", "next_url", "nextUrl", "next_page_url", "nextPageUrl", "nextLink", "@odata.nextLink"}
+	pageTotalFields    = []string{"total", "total_count", "totalCount", "total_items", "totalItems"}
+	pageLastPageFields = []string{"total_pages", "totalPages", "last_page", "lastPage", "page_count", "pageCount"}
+
+	// The fields holding a cursor, an offset or a page number, with the
+	// query parameter sending it back.
+	pageCursorFields = [][2]string{
+		{"next_cursor", "cursor"},
+		{"nextCursor", "cursor"},
+		{"next_page_token", "page_token"},
+		{"nextPageToken", "pageToken"},
+		{"endCursor", "after"},
+	}
+	pageOffsetFields = [][2]string{{"offset", "offset"}, {"skip", "skip"}, {"start", "start"}}
+	pageNumberFields = [][2]string{
+		{"page", "page"},
+		{"current_page", "page"},
+		{"currentPage", "page"},
+		{"page_number", "page_number"},
+		{"pageNumber", "pageNumber"},
+	}
+)
+
+// detect works out the paging of the collection from its first page.
+// Arrays carry no metadata, so only Link headers page them.
+func (p *Paginator) detect(body []byte) {
+	doc, ok := decodePage(body).(map[string]interface{})
+	if !ok {
+		return
+	}
+	if p.opts.ItemsField == "" {
+		p.opts.ItemsField = itemsFieldOf(doc)
+	}
+
+	if path := findPageField(doc, pageNextURLFields, isPageURL); path != nil {
+		p.hints.next = path
+		p.strategy = PageNextURL
+		return
+	}
+	for _, f := range pageCursorFields {
+		if path := findPageField(doc, []string{f[0]}, isCursor); path != nil {
+			p.opts.CursorParam = f[1]
+			p.opts.Cursor = func(body []byte) (string, error) {
+				return cursorAt(decodePage(body), path), nil
+			}
+			p.strategy = PageCursor
+			return
+		}
+	}
+
+	// Offsets and page numbers end on an empty page, so the items must
+	// be found to count them.
+	if p.opts.ItemsField == "" {
+		return
+	}
+	for _, f := range pageOffsetFields {
+		if path := findPageField(doc, []string{f[0]}, isNumber); path != nil {
+			p.opts.OffsetParam = f[1]
+			p.offset, _ = numberAt(doc, path)
+			p.hints.total = findPageField(doc, pageTotalFields, isNumber)
+			p.strategy = PageOffset
+			return
+		}
+	}
+	for _, f := range pageNumberFields {
+		if path := findPageField(doc, []string{f[0]}, isNumber); path != nil {
+			p.opts.PageParam = f[1]
+			p.page, _ = numberAt(doc, path)
+			p.hints.lastPage = findPageField(doc, pageLastPageFields, isNumber)
+			p.strategy = PageNumber
+			return
+		}
+	}
+}
+
+// nextField returns the next page URL found in body, or nil at the end.
+func (p *Paginator) nextField(current *url.URL, body []byte) (*url.URL, error) {
+	next, _ := valueAt(decodePage(body), p.hints.next).(string)
+	if next == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		return nil, err
+	}
+	return current.ResolveReference(u), nil
+}
+
+// number returns the number at path in body, if path is set.
+func (h pageHints) number(body []byte, path []string) (int, bool) {
+	if path == nil {
+		return 0, false
+	}
+	return numberAt(decodePage(body), path)
+}
+
+func decodePage(body []byte) interface{} {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil {
+		return nil
+	}
+	return doc
+}
+
+// itemsFieldOf returns the field of doc holding the items: a well-known
+// name, or its only array.
+func itemsFieldOf(doc map[string]interface{}) string {
+	for _, name := range pageItemsFields {
+		if _, ok := doc[name].([]interface{}); ok {
+			return name
+		}
+	}
+	var found string
+	for name, v := range doc {
+		if _, ok := v.([]interface{}); ok {
+			if found != "" {
+				return ""
+			}
+			found = name
+		}
+	}
+	return found
+}
+
+// findPageField returns the path of the first of names whose value ok
+// accepts, at the top level of doc or in one of its pageMetaFields. A HAL
+// link object is looked into for its href.
+func findPageField(doc map[string]interface{}, names []string, ok func(interface{}) bool) []string {
+	objects := [][]string{nil}
+	for _, meta := range pageMetaFields {
+		if _, isObject := doc[meta].(map[string]interface{}); isObject {
+			objects = append(objects, []string{meta})
+		}
+	}
+	for _, prefix := range objects {
+		obj := valueAt(doc, prefix).(map[string]interface{})
+		for _, name := range names {
+			path := append(prefix[:len(prefix):len(prefix)], name)
+			v, present := obj[name]
+			if link, isObject := v.(map[string]interface{}); isObject {
+				v, present = link["href"]
+				path = append(path, "href")
+			}
+			if present && ok(v) {
+				return path
+			}
+		}
+	}
+	return nil
+}
+
+func valueAt(doc interface{}, path []string) interface{} {
+	for _, key := range path {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = obj[key]
+	}
+	return doc
+}
+
+func numberAt(doc interface{}, path []string) (int, bool) {
+	n, ok := valueAt(doc, path).(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(n.String())
+	return i, err == nil
+}
+
+// cursorAt returns the cursor at path, numbers verbatim.
+func cursorAt(doc interface{}, path []string) string {
+	switch v := valueAt(doc, path).(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func isPageURL(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && (strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "/") || strings.HasPrefix(s, "?"))
+}
+
+// isCursor accepts cursors, and the null of the last page so a single page
+// collection is detected too.
+func isCursor(v interface{}) bool {
+	switch v.(type) {
+	case string, json.Number, nil:
+		return true
+	}
+	return false
+}
+
+func isNumber(v interface{}) bool {
+	_, ok := v.(json.Number)
+	return ok
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPaginator_Detect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/next":
+			if q.Get("p") == "" {
+				w.Write([]byte(`{"data": [1, 2], "links": {"next": "/next?p=2"}}`))
+				return
+			}
+			w.Write([]byte(`{"data": [3], "links": {"next": null}}`))
+		case "/hal":
+			if q.Get("p") == "" {
+				w.Write([]byte(`{"_embedded": [1, 2], "_links": {"next": {"href": "/hal?p=2"}}}`))
+				return
+			}
+			w.Write([]byte(`{"_embedded": [3], "_links": {}}`))
+		case "/cursor":
+			switch q.Get("page_token") {
+			case "":
+				w.Write([]byte(`{"items": [1, 2], "meta": {"next_page_token": "t2"}}`))
+			case "t2":
+				w.Write([]byte(`{"items": [3], "meta": {"next_page_token": null}}`))
+			}
+		case "/offset":
+			offset, _ := strconv.Atoi(q.Get("offset"))
+			items := []int{}
+			for i := offset; i < offset+2 && i < 5; i++ {
+				items = append(items, i+1)
+			}
+			b, _ := json.Marshal(items)
+			fmt.Fprintf(w, `{"results": %s, "offset": %d, "limit": 2, "total": 5}`, b, offset)
+		case "/pages":
+			page, _ := strconv.Atoi(q.Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			fmt.Fprintf(w, `{"records": [%d], "pagination": {"current_page": %d, "total_pages": 3}}`, page, page)
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	for _, tc := range []struct {
+		uri      string
+		strategy PageStrategy
+		items    string
+		pages    int
+	}{
+		{"/next", PageNextURL, "1 2 3", 2},
+		{"/hal", PageNextURL, "1 2 3", 2},
+		{"/cursor", PageCursor, "1 2 3", 2},
+		{"/offset", PageOffset, "1 2 3 4 5", 3},
+		{"/pages", PageNumber, "1 2 3", 3},
+	} {
+		p := c.Paginate(tc.uri, &PageOptions{Detect: true})
+		var items []string
+		var page json.RawMessage
+		for p.Next(&page) {
+			raw, err := p.pageItems(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var nums []int
+			json.Unmarshal(raw, &nums)
+			for _, n := range nums {
+				items = append(items, strconv.Itoa(n))
+			}
+		}
+		if err := p.Err(); err != nil {
+			t.Errorf("%s: %v", tc.uri, err)
+		}
+		if p.Strategy() != tc.strategy || strings.Join(items, " ") != tc.items || p.Pages() != tc.pages {
+			t.Errorf("%s: expected %q paging of %s in %d pages, got %q paging of %v in %d", tc.uri, tc.strategy, tc.items, tc.pages, p.Strategy(), items, p.Pages())
+		}
+	}
+}
+
+func TestPaginator_DetectOverride(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("page") == "3" {
+			w.Write([]byte(`{"items": [], "next_cursor": "x"}`))
+			return
+		}
+		w.Write([]byte(`{"items": [1], "next_cursor": "x"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	p := c.Paginate("/", &PageOptions{Detect: true, PageParam: "page", ItemsField: "items"})
+	var page json.RawMessage
+	for p.Next(&page) {
+	}
+	if p.Strategy() != PageNumber || strings.Join(queries, ",") != "page=1,page=2,page=3" {
+		t.Errorf("Expected the configured page numbers to win, got %q with %v", p.Strategy(), queries)
+	}
+
+	queries = nil
+	p = c.Paginate("/", nil)
+	for p.Next(&page) {
+	}
+	if p.Strategy() != PageNone || len(queries) != 1 {
+		t.Errorf("Expected no detection without Detect, got %q after %d pages", p.Strategy(), len(queries))
+	}
+}
+
+func TestResource_ListAllDetect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "" {
+			w.Write([]byte(`{"widgets": [{"ID": "a"}, {"ID": "b"}], "nextCursor": "2"}`))
+			return
+		}
+		w.Write([]byte(`{"widgets": [{"ID": "c"}], "nextCursor": ""}`))
+	}))
+	defer server.Close()
+
+	widgets := NewResource[widget](newClientOrFatal(t, server.URL, apiKey), "/widgets")
+	widgets.Pages = &PageOptions{Detect: true}
+	list, err := widgets.ListAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[2].ID != "c" {
+		t.Errorf("Expected 3 widgets, got %+v", list)
+	}
+}
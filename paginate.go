@@ -0,0 +1,516 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PageOptions describes how a collection is paged. A rel="next" Link
+// header (RFC 5988) is always followed when the server sends one; the
+// range, cursor, offset and page settings are used otherwise, in that
+// order, and Detect works out the paging of APIs configured with none.
+type PageOptions struct {
+	// RangeUnit enables Range header paging, e.g. "items" sends
+	// "Range: items=0-99" and reads the Content-Range of the response.
+	// PerPage sets the range size and defaults to 100.
+	RangeUnit string
+
+	// CursorParam is the query parameter carrying the cursor, e.g. "cursor".
+	CursorParam string
+
+	// CursorField is the top-level JSON field of a page holding the next
+	// cursor, e.g. "next_cursor". Ignored when Cursor is set.
+	CursorField string
+
+	// Cursor extracts the next cursor from a page body. An empty cursor ends
+	// the iteration.
+	Cursor func(body []byte) (string, error)
+
+	// OffsetParam is the item offset query parameter, e.g. "offset". The
+	// offset advances by the number of items of each page; an empty page,
+	// or a short one when PerPage is positive, ends the iteration.
+	OffsetParam string
+
+	// PageParam is the page number query parameter, e.g. "page".
+	PageParam string
+
+	// StartPage is the number of the first page. Defaults to 1.
+	StartPage int
+
+	// PerPageParam and PerPage set the page size, e.g. "per_page" and 100.
+	// When PerPage is positive, a page with fewer items ends the iteration.
+	PerPageParam string
+	PerPage      int
+
+	// ItemsField is the top-level JSON field holding the items of a page.
+	// When empty, the page itself is expected to be a JSON array. Page
+	// numbering stops at the first page without items.
+	ItemsField string
+
+	// MaxPages, when positive, limits the number of pages fetched. The
+	// iteration ends without error when it is reached; see Limits.
+	MaxPages int
+
+	// Limits, when set, bounds the iteration and fails it with a
+	// PaginationLimitError when more pages remain past a limit.
+	Limits *PageLimits
+
+	// Detect works out the paging from the first page when none of
+	// RangeUnit, CursorParam, Cursor, OffsetParam and PageParam is set,
+	// which overrides it. See Paginator.Strategy.
+	Detect bool
+}
+
+// ErrPaginationLimit is matched by the PaginationLimitError ending an
+// iteration that exceeded its PageLimits.
+var ErrPaginationLimit = errors.New("pagination limit reached")
+
+// PageLimits are hard limits on a pagination, so a runaway collection
+// cannot turn a routine sync into a crawl of hours. Zero fields are
+// unlimited. Items and bytes are counted page by page and checked before
+// the next page is fetched, so the last page may bring them past the
+// limit.
+type PageLimits struct {
+	MaxPages int
+
+	// MaxItems counts the items of pages as PageOptions.ItemsField
+	// locates them.
+	MaxItems int
+
+	// MaxBytes counts the bytes of page bodies.
+	MaxBytes int64
+
+	// MaxDuration bounds the time since the first page was requested,
+	// including a page request in progress.
+	MaxDuration time.Duration
+}
+
+// PaginationLimitError reports the PageLimits field that was exceeded,
+// with the progress made until then.
+type PaginationLimitError struct {
+	// Limit is "pages", "items", "bytes" or "duration".
+	Limit string
+
+	Pages   int
+	Items   int
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+func (e *PaginationLimitError) Error() string {
+	return fmt.Sprintf("%s on %s after %d pages, %d items and %d bytes in %s",
+		ErrPaginationLimit, e.Limit, e.Pages, e.Items, e.Bytes, e.Elapsed.Round(time.Millisecond))
+}
+
+// Is reports whether target is ErrPaginationLimit.
+func (e *PaginationLimitError) Is(target error) bool {
+	return target == ErrPaginationLimit
+}
+
+// Paginator walks the pages of a collection. Use it like bufio.Scanner:
+//
+//	p := c.Paginate("/api/items", &relax.PageOptions{PageParam: "page"})
+//	var page []Item
+//	for p.Next(&page) {
+//		...
+//	}
+//	if err := p.Err(); err != nil {
+//		...
+//	}
+type Paginator struct {
+	c     *Client
+	ctx   context.Context
+	opts  PageOptions
+	ropts []RequestOption
+
+	next   *url.URL
+	page   int
+	offset int
+	first  int64 // start of the next Range
+	pages  int
+	err    error
+
+	// The strategy in use and, when detected, where the body holds the
+	// next URL and the number of pages or items.
+	strategy PageStrategy
+	detected bool
+	hints    pageHints
+
+	// Progress counted for Limits.
+	items   int
+	bytes   int64
+	started time.Time
+}
+
+// Paginate returns a Paginator over the collection at uri. opts may be nil,
+// in which case only Link headers are followed.
+func (c *Client) Paginate(uri string, opts *PageOptions, ropts ...RequestOption) *Paginator {
+	return c.PaginateContext(context.Background(), uri, opts, ropts...)
+}
+
+// PaginateContext is Paginate with a context used for every page request.
+func (c *Client) PaginateContext(ctx context.Context, uri string, opts *PageOptions, ropts ...RequestOption) *Paginator {
+	p := &Paginator{c: c, ctx: ctx, ropts: ropts}
+	if opts != nil {
+		p.opts = *opts
+	}
+	p.page = p.opts.StartPage
+	if p.page == 0 {
+		p.page = 1
+	}
+
+	query, err := c.GetQuery(uri)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	p.next, p.err = url.Parse(query)
+	if p.err == nil {
+		p.next.RawQuery = p.setQuery(p.next.Query(), "").Encode()
+	}
+	return p
+}
+
+// Next fetches the next page and decodes it into v. It returns false when
+// there are no more pages or an error occurred; see Err.
+func (p *Paginator) Next(v interface{}) bool {
+	if p.err != nil || p.next == nil {
+		return false
+	}
+	if p.opts.MaxPages > 0 && p.pages >= p.opts.MaxPages {
+		return false
+	}
+	if p.started.IsZero() {
+		p.started = time.Now()
+	}
+	if p.err = p.checkLimits(); p.err != nil {
+		return false
+	}
+
+	ctx := p.ctx
+	if l := p.opts.Limits; l != nil && l.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, p.started.Add(l.MaxDuration))
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.next.String(), nil)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	if p.opts.RangeUnit != "" {
+		req.Header.Set("Range", fmt.Sprintf("%s=%d-%d", p.opts.RangeUnit, p.first, p.first+p.rangeSize()-1))
+	}
+	o := newCallOptions(p.ropts)
+	if o.result == nil {
+		o.result = &Result{}
+	}
+	if p.err = p.c.jsonResponse(req, v, o); p.err != nil {
+		if p.opts.RangeUnit != "" && p.pages > 0 && StatusCode(p.err) == http.StatusRequestedRangeNotSatisfiable {
+			// The previous page was the last one.
+			p.err = nil
+		} else if ctx.Err() == context.DeadlineExceeded && p.ctx.Err() == nil {
+			p.err = p.limitError("duration")
+		}
+		return false
+	}
+	p.pages++
+	if !p.detected {
+		p.detected = true
+		p.strategy = p.configured()
+		if p.strategy == PageNone && p.opts.Detect {
+			p.detect(o.result.Body)
+		}
+	}
+	if p.err = p.count(o.result.Body); p.err != nil {
+		return false
+	}
+
+	current := p.next
+	p.next, p.err = p.nextURL(current, o.result.Response, o.result.Body)
+	return p.err == nil
+}
+
+// Err returns the first error met while paging, if any.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// Pages returns the number of pages fetched so far.
+func (p *Paginator) Pages() int {
+	return p.pages
+}
+
+// checkLimits returns a PaginationLimitError if fetching another page
+// would exceed Limits.
+func (p *Paginator) checkLimits() error {
+	l := p.opts.Limits
+	switch {
+	case l == nil:
+		return nil
+	case l.MaxPages > 0 && p.pages >= l.MaxPages:
+		return p.limitError("pages")
+	case l.MaxItems > 0 && p.items >= l.MaxItems:
+		return p.limitError("items")
+	case l.MaxBytes > 0 && p.bytes >= l.MaxBytes:
+		return p.limitError("bytes")
+	case l.MaxDuration > 0 && time.Since(p.started) >= l.MaxDuration:
+		return p.limitError("duration")
+	}
+	return nil
+}
+
+func (p *Paginator) limitError(limit string) error {
+	return &PaginationLimitError{Limit: limit, Pages: p.pages, Items: p.items, Bytes: p.bytes, Elapsed: time.Since(p.started)}
+}
+
+// count adds the page body to the progress counted for Limits.
+func (p *Paginator) count(body []byte) error {
+	l := p.opts.Limits
+	if l == nil {
+		return nil
+	}
+	p.bytes += int64(len(body))
+	if l.MaxItems > 0 {
+		n, err := p.countItems(body)
+		if err != nil {
+			return fmt.Errorf("counting items for MaxItems: %w", err)
+		}
+		p.items += n
+	}
+	return nil
+}
+
+// nextURL works out the URL of the page after current, or nil at the end.
+func (p *Paginator) nextURL(current *url.URL, res *http.Response, body []byte) (*url.URL, error) {
+	if res != nil {
+		if links := res.Header.Values("Link"); len(links) > 0 {
+			p.strategy = PageLink
+			next, ok := parseLinks(links)["next"]
+			if !ok {
+				return nil, nil
+			}
+			u, err := url.Parse(next)
+			if err != nil {
+				return nil, err
+			}
+			return current.ResolveReference(u), nil
+		}
+	}
+	if p.hints.next != nil {
+		return p.nextField(current, body)
+	}
+
+	if p.opts.RangeUnit != "" {
+		return p.nextRange(current, res, body)
+	}
+
+	if p.opts.CursorParam != "" {
+		cursor, err := p.cursor(body)
+		if err != nil || cursor == "" {
+			return nil, err
+		}
+		u := *current
+		u.RawQuery = p.setQuery(current.Query(), cursor).Encode()
+		return &u, nil
+	}
+
+	if p.opts.OffsetParam != "" {
+		n, err := p.countItems(body)
+		if err != nil || n == 0 || (p.opts.PerPage > 0 && n < p.opts.PerPage) {
+			return nil, err
+		}
+		p.offset += n
+		if total, ok := p.hints.number(body, p.hints.total); ok && p.offset >= total {
+			return nil, nil
+		}
+		u := *current
+		u.RawQuery = p.setQuery(current.Query(), "").Encode()
+		return &u, nil
+	}
+
+	if p.opts.PageParam != "" {
+		n, err := p.countItems(body)
+		if err != nil || n == 0 || (p.opts.PerPage > 0 && n < p.opts.PerPage) {
+			return nil, err
+		}
+		if last, ok := p.hints.number(body, p.hints.lastPage); ok && p.page >= last {
+			return nil, nil
+		}
+		p.page++
+		u := *current
+		u.RawQuery = p.setQuery(current.Query(), "").Encode()
+		return &u, nil
+	}
+
+	return nil, nil
+}
+
+func (p *Paginator) rangeSize() int64 {
+	if p.opts.PerPage > 0 {
+		return int64(p.opts.PerPage)
+	}
+	return 100
+}
+
+// nextRange advances past the Content-Range of res. A 200 response means
+// the server sent the whole collection.
+func (p *Paginator) nextRange(current *url.URL, res *http.Response, body []byte) (*url.URL, error) {
+	if res == nil || res.StatusCode != http.StatusPartialContent {
+		return nil, nil
+	}
+	_, _, last, total, err := parseContentRange(res.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+	if total >= 0 && last+1 >= total {
+		return nil, nil
+	}
+	if total < 0 {
+		n, err := p.countItems(body)
+		if err != nil || int64(n) < p.rangeSize() {
+			return nil, err
+		}
+	}
+	p.first = last + 1
+	return current, nil
+}
+
+// parseContentRange parses a Content-Range value such as "items 0-99/1234".
+// total is -1 when the server sends "*".
+func parseContentRange(v string) (unit string, first, last, total int64, err error) {
+	bad := fmt.Errorf("invalid Content-Range %q", v)
+	unit, spec, ok := strings.Cut(strings.TrimSpace(v), " ")
+	if !ok {
+		return "", 0, 0, 0, bad
+	}
+	span, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return "", 0, 0, 0, bad
+	}
+	from, to, ok := strings.Cut(span, "-")
+	if !ok {
+		return "", 0, 0, 0, bad
+	}
+	if first, err = strconv.ParseInt(from, 10, 64); err != nil {
+		return "", 0, 0, 0, bad
+	}
+	if last, err = strconv.ParseInt(to, 10, 64); err != nil {
+		return "", 0, 0, 0, bad
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return "", 0, 0, 0, bad
+		}
+	}
+	return unit, first, last, total, nil
+}
+
+// setQuery sets the page, page size and cursor parameters on q.
+func (p *Paginator) setQuery(q url.Values, cursor string) url.Values {
+	if p.opts.PageParam != "" && p.opts.CursorParam == "" {
+		q.Set(p.opts.PageParam, strconv.Itoa(p.page))
+	}
+	if p.opts.OffsetParam != "" && p.opts.CursorParam == "" {
+		q.Set(p.opts.OffsetParam, strconv.Itoa(p.offset))
+	}
+	if p.opts.PerPageParam != "" && p.opts.PerPage > 0 {
+		q.Set(p.opts.PerPageParam, strconv.Itoa(p.opts.PerPage))
+	}
+	if cursor != "" {
+		q.Set(p.opts.CursorParam, cursor)
+	}
+	return q
+}
+
+func (p *Paginator) cursor(body []byte) (string, error) {
+	if p.opts.Cursor != nil {
+		return p.opts.Cursor(body)
+	}
+	if p.opts.CursorField == "" || isEmptyBody(body) {
+		return "", nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", err
+	}
+	raw, ok := fields[p.opts.CursorField]
+	if !ok || string(raw) == "null" {
+		return "", nil
+	}
+	var cursor string
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		// Numeric cursors are used verbatim.
+		return string(raw), nil
+	}
+	return cursor, nil
+}
+
+func (p *Paginator) countItems(body []byte) (int, error) {
+	raw, err := p.pageItems(body)
+	if err != nil || raw == nil {
+		return 0, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// pageItems returns the items of a page as ItemsField locates them, or nil
+// if it has none.
+func (p *Paginator) pageItems(body []byte) (json.RawMessage, error) {
+	if isEmptyBody(body) {
+		return nil, nil
+	}
+	raw := json.RawMessage(body)
+	if p.opts.ItemsField != "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, err
+		}
+		raw = fields[p.opts.ItemsField]
+	}
+	return raw, nil
+}
+
+// parseLinks parses RFC 5988 Link header values into a map of rel to
+// target. Each rel of a space separated list is recorded.
+func parseLinks(values []string) map[string]string {
+	links := make(map[string]string)
+	for _, v := range values {
+		for _, link := range strings.Split(v, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(kv[1], `"`)) {
+					links[strings.ToLower(rel)] = target
+				}
+			}
+		}
+	}
+	return links
+}
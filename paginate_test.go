@@ -0,0 +1,239 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_PaginateLinkHeader(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/items?p=%d>; rel="next", <%s/items?p=2>; rel="last"`, server.URL, page+1, server.URL))
+		}
+		fmt.Fprintf(w, `[%d]`, page)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	p := c.Paginate("/items", nil)
+
+	var got []int
+	var page []int
+	for p.Next(&page) {
+		got = append(got, page...)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[0 1 2]" {
+		t.Errorf("Expected [0 1 2], got %v", got)
+	}
+}
+
+func TestClient_PaginateCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filter") != "x" {
+			http.Error(w, "missing filter", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"items":["a","b"],"next":"c2"}`))
+		case "c2":
+			w.Write([]byte(`{"items":["c"],"next":null}`))
+		default:
+			http.Error(w, "bad cursor", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	p := c.Paginate("/items?filter=x", &PageOptions{CursorParam: "cursor", CursorField: "next"})
+
+	var got []string
+	for {
+		var page struct{ Items []string }
+		if !p.Next(&page) {
+			break
+		}
+		got = append(got, page.Items...)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[a b c]" || p.Pages() != 2 {
+		t.Errorf("Expected [a b c] over 2 pages, got %v over %d", got, p.Pages())
+	}
+}
+
+func TestClient_PaginatePageNumbers(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Write([]byte(`{"data":[1,2]}`))
+		case "2":
+			w.Write([]byte(`{"data":[3]}`))
+		default:
+			w.Write([]byte(`{"data":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	p := c.Paginate("/items", &PageOptions{PageParam: "page", PerPageParam: "per_page", PerPage: 2, ItemsField: "data"})
+
+	var page struct{ Data []int }
+	for p.Next(&page) {
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(requests) != "[page=1&per_page=2 page=2&per_page=2]" {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+}
+
+func TestClient_PaginateError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	p := c.Paginate("/items", &PageOptions{PageParam: "page"})
+
+	var page []int
+	if p.Next(&page) {
+		t.Fatal("Expected Next to fail")
+	}
+	if !IsNotFound(p.Err()) {
+		t.Errorf("Expected a not found error, got %v", p.Err())
+	}
+}
+
+func TestParseLinks(t *testing.T) {
+	links := parseLinks([]string{`<https://a/2>; rel="next prefetch", <https://a/9>;rel=last`})
+	if links["next"] != "https://a/2" || links["prefetch"] != "https://a/2" || links["last"] != "https://a/9" {
+		t.Errorf("Unexpected links %v", links)
+	}
+}
+
+func TestClient_PaginateRange(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4}
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		var first, last int
+		fmt.Sscanf(r.Header.Get("Range"), "items=%d-%d", &first, &last)
+		if last >= len(items) {
+			last = len(items) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("items %d-%d/%d", first, last, len(items)))
+		w.WriteHeader(http.StatusPartialContent)
+		fmt.Fprint(w, strings.Replace(fmt.Sprint(items[first:last+1]), " ", ",", -1))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	p := c.Paginate("/items", &PageOptions{RangeUnit: "items", PerPage: 2})
+
+	var got []int
+	var page []int
+	for p.Next(&page) {
+		got = append(got, page...)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[0 1 2 3 4]" {
+		t.Errorf("Expected all items, got %v", got)
+	}
+	if fmt.Sprint(ranges) != "[items=0-1 items=2-3 items=4-5]" {
+		t.Errorf("Unexpected ranges %v", ranges)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	unit, first, last, total, err := parseContentRange("items 0-99/*")
+	if err != nil || unit != "items" || first != 0 || last != 99 || total != -1 {
+		t.Errorf("Unexpected result %s %d %d %d %v", unit, first, last, total, err)
+	}
+	if _, _, _, _, err := parseContentRange("items 0-99"); err == nil {
+		t.Errorf("Expected an error for a missing total")
+	}
+}
+
+func TestClient_PaginateLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 3 && r.URL.Query().Get("slow") != "" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		// An endless collection of three items per page.
+		fmt.Fprintf(w, `[%d, %d, %d]`, page*3, page*3+1, page*3+2)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	for _, tt := range []struct {
+		uri    string
+		limits PageLimits
+		limit  string
+		pages  int
+	}{
+		{"/items", PageLimits{MaxPages: 4}, "pages", 4},
+		{"/items", PageLimits{MaxItems: 7}, "items", 3},
+		{"/items", PageLimits{MaxBytes: 18}, "bytes", 2},
+		{"/items?slow=1", PageLimits{MaxDuration: 50 * time.Millisecond}, "duration", 2},
+	} {
+		limits := tt.limits
+		p := c.Paginate(tt.uri, &PageOptions{PageParam: "page", Limits: &limits})
+		var page []int
+		for p.Next(&page) {
+		}
+		var limitErr *PaginationLimitError
+		if !errors.Is(p.Err(), ErrPaginationLimit) || !errors.As(p.Err(), &limitErr) {
+			t.Errorf("%+v: expected a pagination limit error, got %v", tt.limits, p.Err())
+			continue
+		}
+		if limitErr.Limit != tt.limit || limitErr.Pages != tt.pages || p.Pages() != tt.pages {
+			t.Errorf("%+v: expected the %s limit after %d pages, got %v", tt.limits, tt.limit, tt.pages, limitErr)
+		}
+	}
+}
+
+func TestClient_PaginateLimitsNotReached(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "1" {
+			w.Write([]byte(`[1, 2]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	p := c.Paginate("/items", &PageOptions{PageParam: "page", PerPageParam: "per_page", PerPage: 3, Limits: &PageLimits{MaxPages: 1, MaxItems: 2}})
+	var page []int
+	for p.Next(&page) {
+	}
+	if err := p.Err(); err != nil {
+		t.Errorf("Expected the collection to end within its limits, got %v", err)
+	}
+}
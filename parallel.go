@@ -0,0 +1,130 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DefaultParallelism is the number of calls DoAll runs at once when
+// ParallelOptions.Concurrency is zero.
+const DefaultParallelism = 8
+
+// Call describes one request of DoAll.
+type Call struct {
+	// Method defaults to GET.
+	Method string
+	URI    string
+
+	// Body, when not nil, is encoded as the request body.
+	Body interface{}
+
+	// Response receives the decoded response, as in DoContext.
+	Response interface{}
+
+	Options []RequestOption
+}
+
+// CallOutcome is the outcome of one call of DoAll.
+type CallOutcome struct {
+	Result Result
+	Err    error
+}
+
+// ParallelOptions configures DoAll.
+type ParallelOptions struct {
+	// Concurrency is the number of calls in flight at once. Defaults to
+	// DefaultParallelism.
+	Concurrency int
+
+	// FailFast cancels the calls still pending or in flight once one
+	// fails.
+	FailFast bool
+}
+
+// CallError reports a failed call of DoAll.
+type CallError struct {
+	Index  int
+	Method string
+	URI    string
+	Err    error
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("call %d (%s %s): %s", e.Index, e.Method, e.URI, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// DoAll runs calls concurrently and returns their outcomes in the order of
+// calls, once all have finished. If any failed, the error joins their
+// CallErrors. opts may be nil.
+func (c *Client) DoAll(ctx context.Context, calls []Call, opts *ParallelOptions) ([]CallOutcome, error) {
+	if opts == nil {
+		opts = &ParallelOptions{}
+	}
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = DefaultParallelism
+	}
+	if workers > len(calls) {
+		workers = len(calls)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outcomes := make([]CallOutcome, len(calls))
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				out := &outcomes[i]
+				if err := ctx.Err(); err != nil {
+					out.Err = err
+					continue
+				}
+				out.Err = c.doCall(ctx, &calls[i], &out.Result)
+				if out.Err != nil && opts.FailFast {
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := range calls {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for i, out := range outcomes {
+		if out.Err != nil {
+			errs = append(errs, &CallError{Index: i, Method: callMethod(&calls[i]), URI: calls[i].URI, Err: out.Err})
+		}
+	}
+	return outcomes, errors.Join(errs...)
+}
+
+func (c *Client) doCall(ctx context.Context, call *Call, result *Result) error {
+	opts := append(call.Options[:len(call.Options):len(call.Options)], WithResult(result))
+	return c.DoContext(ctx, callMethod(call), call.URI, call.Body, call.Response, opts...)
+}
+
+func callMethod(call *Call) string {
+	if call.Method == "" {
+		return http.MethodGet
+	}
+	return call.Method
+}
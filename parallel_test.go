@@ -0,0 +1,95 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_DoAll(t *testing.T) {
+	var active, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Path == "/api/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"Foo": "` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	responses := make([]Response, 20)
+	calls := make([]Call, 20)
+	for i := range calls {
+		calls[i] = Call{URI: "/api/" + strconv.Itoa(i), Response: &responses[i]}
+	}
+	calls[7].URI = "/api/missing"
+
+	outcomes, err := c.DoAll(context.Background(), calls, &ParallelOptions{Concurrency: 4})
+	var ce *CallError
+	if !errors.As(err, &ce) || ce.Index != 7 {
+		t.Fatalf("Expected a CallError for call 7, got %v", err)
+	}
+	if _, ok := ce.Err.(*RequestError); !ok {
+		t.Errorf("Expected the call's error to be kept, got %T", ce.Err)
+	}
+	for i, out := range outcomes {
+		if i == 7 {
+			if out.Err == nil || out.Result.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected call 7 to fail with 404, got %v", out.Err)
+			}
+			continue
+		}
+		if out.Err != nil || responses[i].Foo != "/api/"+strconv.Itoa(i) || out.Result.StatusCode != http.StatusOK {
+			t.Errorf("Call %d: unexpected outcome %v, %q", i, out.Err, responses[i].Foo)
+		}
+	}
+	if p := atomic.LoadInt32(&peak); p > 4 {
+		t.Errorf("Expected at most 4 calls in flight, got %d", p)
+	}
+}
+
+func TestClient_DoAllFailFast(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	specs := make([]Call, 10)
+	for i := range specs {
+		specs[i] = Call{Method: http.MethodDelete, URI: "/api/foo"}
+	}
+
+	outcomes, err := c.DoAll(context.Background(), specs, &ParallelOptions{Concurrency: 1, FailFast: true})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+	if !errors.Is(outcomes[9].Err, context.Canceled) {
+		t.Errorf("Expected remaining calls to be cancelled, got %v", outcomes[9].Err)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// JoinMode selects how request URIs are combined with the base URL.
+type JoinMode int
+
+const (
+	// JoinResolve resolves URIs against the base URL as a browser would
+	// (RFC 3986). A URI starting with "/" replaces the base path, so with a
+	// base of https://host/api/v1, "/users" becomes https://host/users.
+	// This is the default.
+	JoinResolve JoinMode = iota
+
+	// JoinAppend appends the URI path to the base path, so with a base of
+	// https://host/api/v1 both "/users" and "users" become
+	// https://host/api/v1/users. Query parameters of the base URL are kept
+	// unless the URI sets them.
+	JoinAppend
+)
+
+// WithPathJoin sets how URIs are joined to the base URL.
+func WithPathJoin(mode JoinMode) Option {
+	return func(c *Client) {
+		c.PathJoin = mode
+	}
+}
+
+// JoinPath escapes each segment and joins them with "/", so values holding
+// slashes, spaces or "?" stay within their segment:
+//
+//	relax.JoinPath("users", "a/b c") == "users/a%2Fb%20c"
+func JoinPath(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	return strings.Join(escaped, "/")
+}
+
+// Path holds the values of the {name} placeholders of a URI path template.
+// Values are formatted with fmt.Sprint and escaped as a single segment.
+type Path map[string]interface{}
+
+// Expand returns template with its placeholders replaced by the escaped
+// values of p:
+//
+//	relax.Path{"id": 42, "slug": "a/b c"}.Expand("/users/{id}/posts/{slug}") == "/users/42/posts/a%2Fb%20c"
+//
+// It fails if a placeholder has no value or an empty one.
+func (p Path) Expand(template string) (string, error) {
+	_, raw, err := p.expand(template)
+	return raw, err
+}
+
+// expand returns template expanded both unescaped and escaped, like the
+// Path and RawPath of a URL.
+func (p Path) expand(template string) (path, raw string, err error) {
+	var pb, rb strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated path parameter in %q", template)
+		}
+		end += start
+
+		static := template[:start]
+		pb.WriteString(static)
+		rb.WriteString((&url.URL{Path: static}).EscapedPath())
+
+		name := template[start+1 : end]
+		v, ok := p[name]
+		if !ok {
+			return "", "", fmt.Errorf("missing path parameter %q", name)
+		}
+		s := fmt.Sprint(v)
+		if s == "" {
+			return "", "", fmt.Errorf("empty path parameter %q", name)
+		}
+		pb.WriteString(s)
+		rb.WriteString(url.PathEscape(s))
+		template = template[end+1:]
+	}
+	pb.WriteString(template)
+	rb.WriteString((&url.URL{Path: template}).EscapedPath())
+	return pb.String(), rb.String(), nil
+}
+
+// WithPathParams expands the {name} placeholders in the path of the
+// call's URI with p, so
+//
+//	c.ReadJson("/users/{id}/posts/{slug}", &post, relax.WithPathParams(relax.Path{"id": 42, "slug": slug}))
+//
+// requests /users/42/posts/a%2Fb when slug is "a/b". Unless WithRoute is given,
+// the unexpanded path is the call's route for telemetry.
+func WithPathParams(p Path) RequestOption {
+	return func(o *callOptions) {
+		o.pathParams = p
+	}
+}
+
+// expandPath expands the placeholders in the path of u with p.
+func expandPath(u *url.URL, p Path) error {
+	path, raw, err := p.expand(u.Path)
+	if err != nil {
+		return err
+	}
+	u.Path, u.RawPath = path, raw
+	return nil
+}
+
+// appendURL appends the path of ref to base.
+func appendURL(base, ref *url.URL) *url.URL {
+	u := *base
+	u.User = base.User
+	u.Fragment = ref.Fragment
+	u.RawFragment = ref.RawFragment
+
+	if ref.Path != "" {
+		p := strings.TrimRight(base.EscapedPath(), "/") + "/" + strings.TrimLeft(ref.EscapedPath(), "/")
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = unescaped, p
+		}
+	}
+
+	switch {
+	case base.RawQuery == "":
+		u.RawQuery = ref.RawQuery
+	case ref.RawQuery != "":
+		q := base.Query()
+		for k, v := range ref.Query() {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+	}
+	return &u
+}
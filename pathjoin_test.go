@@ -0,0 +1,94 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_GetQueryJoinModes(t *testing.T) {
+	tests := []struct {
+		base string
+		mode JoinMode
+		uri  string
+		want string
+	}{
+		{"https://host/api/v1", JoinResolve, "/users", "https://host/users"},
+		{"https://host/api/v1/", JoinResolve, "users", "https://host/api/v1/users"},
+		{"https://host/api/v1", JoinAppend, "/users", "https://host/api/v1/users"},
+		{"https://host/api/v1/", JoinAppend, "users?page=2", "https://host/api/v1/users?page=2"},
+		{"https://host/api/v1", JoinAppend, "", "https://host/api/v1"},
+		{"https://host/api/v1?key=k", JoinAppend, "/users", "https://host/api/v1/users?key=k"},
+		{"https://host/api/v1?key=k", JoinAppend, "/users?page=2", "https://host/api/v1/users?key=k&page=2"},
+		{"https://host/api/v1", JoinAppend, "/" + JoinPath("users", "a/b c"), "https://host/api/v1/users/a%2Fb%20c"},
+	}
+
+	for _, tt := range tests {
+		c, err := NewClient(tt.base, apiKey, WithPathJoin(tt.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.GetQuery(tt.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("GetQuery(%q) with base %q and mode %d = %q, want %q", tt.uri, tt.base, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	if got := JoinPath("users", "42", "a?b"); got != "users/42/a%3Fb" {
+		t.Errorf("Unexpected path %q", got)
+	}
+}
+
+func TestPath_Expand(t *testing.T) {
+	p := Path{"id": 42, "slug": "a/b c?"}
+	got, err := p.Expand("/users/{id}/posts/{slug}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "/users/42/posts/a%2Fb%20c%3F" {
+		t.Errorf("Unexpected path %q", got)
+	}
+
+	for _, template := range []string{"/users/{name}", "/users/{id", "/users/{empty}"} {
+		if _, err := (Path{"id": 1, "empty": ""}).Expand(template); err == nil {
+			t.Errorf("Expected %q to fail", template)
+		}
+	}
+}
+
+func TestClient_WithPathParams(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.EscapedPath()
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	rec := &recordingTelemetry{}
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Telemetry = &TelemetryConfig{Meter: rec}
+
+	var response Response
+	if err := c.ReadJson("/api/users/{id}/posts/{postID}", &response, WithPathParams(Path{"id": 42, "postID": "a/b c"})); err != nil {
+		t.Fatal(err)
+	}
+	if got != "/api/users/42/posts/a%2Fb%20c" {
+		t.Errorf("Expected escaped segments, got %q", got)
+	}
+	if route := rec.metrics[0].Route; route != "/api/users/{id}/posts/{postID}" {
+		t.Errorf("Expected the template as route, got %q", route)
+	}
+
+	if err := c.ReadJson("/api/users/{id}", &response, WithPathParams(Path{})); err == nil {
+		t.Errorf("Expected a missing parameter to fail")
+	}
+}
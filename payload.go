@@ -0,0 +1,250 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultPayloadSamples is used when PayloadConfig.MinSamples is zero.
+	DefaultPayloadSamples = 20
+
+	// payloadAlpha is the weight of each body in the moving average size
+	// of its route.
+	payloadAlpha = 0.05
+)
+
+// PayloadDirection tells request bodies from response bodies.
+type PayloadDirection string
+
+const (
+	PayloadRequest  PayloadDirection = "request"
+	PayloadResponse PayloadDirection = "response"
+)
+
+// PayloadLimits are the largest bodies of a route that are not reported.
+// Zero means no limit.
+type PayloadLimits struct {
+	MaxRequestBytes  int64
+	MaxResponseBytes int64
+}
+
+// PayloadConfig reports request and response bodies that exceed size
+// thresholds, or that grew well past the usual size of their route, so
+// payloads that creep up unnoticed are caught. Response sizes are those
+// read by the caller, after decompression, and are known once the body is
+// read or closed. Request sizes are known for bodies of known length,
+// which is every body but a JSONArray stream.
+type PayloadConfig struct {
+	// PayloadLimits apply to every route without limits of its own.
+	PayloadLimits
+
+	// Routes maps a URL path prefix to the limits of the routes under it.
+	// The longest matching prefix wins.
+	Routes map[string]PayloadLimits
+
+	// Growth, when above 1, reports bodies larger than Growth times the
+	// moving average size of their route and direction, once MinSamples
+	// bodies have been seen.
+	Growth float64
+
+	// MinSamples is the number of bodies of a route seen before Growth
+	// applies. Defaults to DefaultPayloadSamples.
+	MinSamples int
+
+	// OnAlert is called with every body exceeding a threshold. It must
+	// not block.
+	OnAlert func(PayloadAlert)
+}
+
+// PayloadAlert reports a body exceeding a threshold.
+type PayloadAlert struct {
+	Method string
+	URL    string
+
+	// Route is "METHOD /path", or the route given WithRoute in place of
+	// the path, as in RouteStats.
+	Route     string
+	Direction PayloadDirection
+	Bytes     int64
+
+	// Limit is the threshold exceeded: the configured limit, or Growth
+	// times Average.
+	Limit int64
+
+	// Average is the moving average size of the route when Growth was
+	// exceeded, or zero.
+	Average int64
+}
+
+// PayloadMetric describes the body sizes of one finished request attempt.
+// RequestBytes is -1 when unknown.
+type PayloadMetric struct {
+	Method        string
+	Route         string
+	RequestBytes  int64
+	ResponseBytes int64
+}
+
+// PayloadMeter is implemented by a TelemetryConfig.Meter that also records
+// body sizes, e.g. into size histograms.
+type PayloadMeter interface {
+	RecordPayload(ctx context.Context, m PayloadMetric)
+}
+
+// WithPayloadAlerts sets Client.Payloads.
+func WithPayloadAlerts(cfg *PayloadConfig) Option {
+	return func(c *Client) {
+		c.Payloads = cfg
+	}
+}
+
+// limits returns the limits of the route of path.
+func (cfg *PayloadConfig) limits(path string) PayloadLimits {
+	var best string
+	limits, matched := cfg.PayloadLimits, false
+	for prefix, l := range cfg.Routes {
+		if strings.HasPrefix(path, prefix) && (!matched || len(prefix) > len(best)) {
+			best, limits, matched = prefix, l, true
+		}
+	}
+	return limits
+}
+
+// payloadTracker keeps the moving average body sizes of each route. It is
+// updated on every response, so it takes no locks.
+type payloadTracker struct {
+	routes sync.Map // key -> *payloadAverage
+}
+
+type payloadAverage struct {
+	n    atomic.Int64
+	mean atomic.Uint64 // float64 bits
+}
+
+// observe adds a body of n bytes to the average of key and returns the
+// average before it, and how many bodies it is over.
+func (t *payloadTracker) observe(key string, n int64) (float64, int) {
+	v, ok := t.routes.Load(key)
+	if !ok {
+		v, _ = t.routes.LoadOrStore(key, &payloadAverage{})
+	}
+	a := v.(*payloadAverage)
+	seen := a.n.Add(1) - 1
+	for {
+		bits := a.mean.Load()
+		mean := math.Float64frombits(bits)
+		next := float64(n)
+		if seen > 0 {
+			next = mean + payloadAlpha*(float64(n)-mean)
+		}
+		if a.mean.CompareAndSwap(bits, math.Float64bits(next)) {
+			return mean, int(seen)
+		}
+	}
+}
+
+// tracksPayloads reports whether body sizes are needed.
+func (c *Client) tracksPayloads() bool {
+	if c.Payloads != nil || c.CollectStats {
+		return true
+	}
+	if c.Telemetry != nil {
+		_, ok := c.Telemetry.Meter.(PayloadMeter)
+		return ok
+	}
+	return false
+}
+
+// checkPayload reports a body of n bytes of r if it exceeds a threshold.
+func (c *Client) checkPayload(r *http.Request, dir PayloadDirection, n int64) {
+	cfg := c.Payloads
+	if cfg == nil || n < 0 {
+		return
+	}
+	route := routeKey(r)
+	alert := PayloadAlert{Method: r.Method, URL: redactRequestURL(r, nil), Route: route, Direction: dir, Bytes: n}
+
+	limits := cfg.limits(r.URL.Path)
+	limit := limits.MaxResponseBytes
+	if dir == PayloadRequest {
+		limit = limits.MaxRequestBytes
+	}
+	mean, seen := c.payloads.observe(string(dir)+" "+route, n)
+	minSamples := cfg.MinSamples
+	if minSamples <= 0 {
+		minSamples = DefaultPayloadSamples
+	}
+
+	switch {
+	case limit > 0 && n > limit:
+		alert.Limit = limit
+	case cfg.Growth > 1 && seen >= minSamples && float64(n) > cfg.Growth*mean:
+		alert.Limit = int64(cfg.Growth * mean)
+		alert.Average = int64(mean)
+	default:
+		return
+	}
+	if cfg.OnAlert != nil {
+		cfg.OnAlert(alert)
+	}
+}
+
+// trackPayload checks the request body of r and makes res report its
+// size once read.
+func (c *Client) trackPayload(r *http.Request, res *http.Response) *http.Response {
+	requestBytes := r.ContentLength
+	if !hasBody(r) {
+		requestBytes = 0
+	}
+	res.Body = &countedBody{ReadCloser: res.Body, done: func(n int64) {
+		c.checkPayload(r, PayloadResponse, n)
+		if c.CollectStats {
+			c.stats.payload(routeKey(r), requestBytes, n)
+		}
+		if c.Telemetry != nil {
+			if m, ok := c.Telemetry.Meter.(PayloadMeter); ok {
+				m.RecordPayload(r.Context(), PayloadMetric{
+					Method:        r.Method,
+					Route:         routeOf(r),
+					RequestBytes:  requestBytes,
+					ResponseBytes: n,
+				})
+			}
+		}
+	}}
+	return res
+}
+
+// countedBody calls done with the number of bytes read once the body is
+// read to the end or closed.
+type countedBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+func (b *countedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.n) })
+	}
+	return n, err
+}
+
+func (b *countedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.n) })
+	return err
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newSizedServer answers with a JSON string of the size in the n query
+// parameter.
+func newSizedServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		w.Write([]byte(`{"Foo": "` + strings.Repeat("x", n) + `"}`))
+	}))
+}
+
+func TestClient_PayloadLimits(t *testing.T) {
+	server := newSizedServer()
+	defer server.Close()
+
+	var alerts []PayloadAlert
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Payloads = &PayloadConfig{
+		PayloadLimits: PayloadLimits{MaxRequestBytes: 10, MaxResponseBytes: 100},
+		Routes:        map[string]PayloadLimits{"/api/big": {MaxResponseBytes: 1000}},
+		OnAlert:       func(a PayloadAlert) { alerts = append(alerts, a) },
+	}
+
+	var response Response
+	for _, uri := range []string{"/api/foo?n=10", "/api/foo?n=200", "/api/big?n=200"} {
+		if err := c.ReadJson(uri, &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %+v", alerts)
+	}
+	if a := alerts[0]; a.Route != "GET /api/foo" || a.Direction != PayloadResponse || a.Bytes != 211 || a.Limit != 100 {
+		t.Errorf("Unexpected alert %+v", a)
+	}
+
+	alerts = nil
+	if err := c.CreateJson("/api/foo", map[string]string{"a": "0123456789"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Direction != PayloadRequest || alerts[0].Bytes != 18 || alerts[0].Limit != 10 {
+		t.Errorf("Expected a request alert, got %+v", alerts)
+	}
+}
+
+func TestClient_PayloadGrowth(t *testing.T) {
+	server := newSizedServer()
+	defer server.Close()
+
+	var alerts []PayloadAlert
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Payloads = &PayloadConfig{Growth: 10, MinSamples: 5, OnAlert: func(a PayloadAlert) { alerts = append(alerts, a) }}
+
+	var response Response
+	for _, n := range []int{88, 88, 88, 88, 2000, 88, 2000} {
+		if err := c.ReadJson("/api/foo?n="+strconv.Itoa(n), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first large body comes before MinSamples bodies were seen.
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %+v", alerts)
+	}
+	if a := alerts[0]; a.Bytes != 2011 || a.Average <= 0 || a.Limit < 10*a.Average {
+		t.Errorf("Unexpected alert %+v", a)
+	}
+}
+
+type payloadRecorder struct {
+	mu      sync.Mutex
+	metrics []PayloadMetric
+}
+
+func (p *payloadRecorder) RecordRequest(ctx context.Context, m RequestMetric) {}
+
+func (p *payloadRecorder) RecordPayload(ctx context.Context, m PayloadMetric) {
+	p.mu.Lock()
+	p.metrics = append(p.metrics, m)
+	p.mu.Unlock()
+}
+
+func TestClient_PayloadStats(t *testing.T) {
+	server := newSizedServer()
+	defer server.Close()
+
+	rec := &payloadRecorder{}
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.CollectStats = true
+	c.Telemetry = &TelemetryConfig{Meter: rec}
+
+	var response Response
+	for _, n := range []int{89, 89, 989} {
+		if err := c.ReadJson("/api/foo?n="+strconv.Itoa(n), &response, WithRoute("/api/foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.CreateJson("/api/foo", "abc", nil)
+
+	stats := c.Stats()
+	if len(stats.Routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %+v", stats.Routes)
+	}
+	get, post := stats.Routes[0], stats.Routes[1]
+	if s := get.ResponseBytes; s.Count != 3 || s.Total != 1200 || s.Max != 1000 || s.P50 != 100 || s.P99 != 1000 {
+		t.Errorf("Unexpected response sizes %+v", s)
+	}
+	if s := get.RequestBytes; s.Count != 3 || s.Max != 0 {
+		t.Errorf("Unexpected request sizes %+v", s)
+	}
+	if s := post.RequestBytes; s.Count != 1 || s.Total != 5 {
+		t.Errorf("Unexpected request sizes %+v", s)
+	}
+
+	if len(rec.metrics) != 4 {
+		t.Fatalf("Expected 4 payload metrics, got %+v", rec.metrics)
+	}
+	if m := rec.metrics[2]; m.Route != "/api/foo" || m.Method != "GET" || m.ResponseBytes != 1000 || m.RequestBytes != 0 {
+		t.Errorf("Unexpected metric %+v", m)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Policy vets every request before it is sent, including each attempt
+// and redirect. It sees the request after authentication and default
+// headers are applied. Returning an error blocks the request.
+type Policy interface {
+	Check(r *http.Request) error
+}
+
+// PolicyFunc adapts a function to the Policy interface.
+type PolicyFunc func(r *http.Request) error
+
+func (f PolicyFunc) Check(r *http.Request) error {
+	return f(r)
+}
+
+// PolicyError is returned for a request blocked by a Policy. It is never
+// retried.
+type PolicyError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s %s blocked by policy: %s", e.Method, e.URL, e.Err)
+}
+
+// Unwrap returns the error of the policy.
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+var globalPolicies struct {
+	sync.RWMutex
+	list []*Policy
+}
+
+// RegisterPolicy enforces p on the requests of every Client in the
+// program, in addition to their own Policies, so a shared SDK can impose
+// rules its callers cannot switch off. It returns a function removing p.
+func RegisterPolicy(p Policy) (unregister func()) {
+	entry := &p
+	globalPolicies.Lock()
+	globalPolicies.list = append(globalPolicies.list, entry)
+	globalPolicies.Unlock()
+
+	return func() {
+		globalPolicies.Lock()
+		defer globalPolicies.Unlock()
+
+		for i, e := range globalPolicies.list {
+			if e == entry {
+				globalPolicies.list = append(globalPolicies.list[:i:i], globalPolicies.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// WithPolicy adds p to the client's Policies.
+func WithPolicy(p ...Policy) Option {
+	return func(c *Client) {
+		c.Policies = append(c.Policies[:len(c.Policies):len(c.Policies)], p...)
+	}
+}
+
+// checkPolicies runs the global policies, then the client's, against r.
+func (c *Client) checkPolicies(r *http.Request) error {
+	for _, p := range c.allPolicies() {
+		if err := p.Check(r); err != nil {
+			return &PolicyError{Method: r.Method, URL: redactRequestURL(r, nil), Err: err}
+		}
+	}
+	return nil
+}
+
+// allPolicies returns the registered policies followed by the client's.
+func (c *Client) allPolicies() []Policy {
+	globalPolicies.RLock()
+	global := globalPolicies.list
+	globalPolicies.RUnlock()
+
+	if len(global) == 0 {
+		return c.Policies
+	}
+	all := make([]Policy, 0, len(global)+len(c.Policies))
+	for _, p := range global {
+		all = append(all, *p)
+	}
+	return append(all, c.Policies...)
+}
+
+// RequireHTTPS blocks requests not made over HTTPS. Plain HTTP to the
+// loopback hosts in allow, e.g. "localhost" or "127.0.0.1", is permitted.
+func RequireHTTPS(allow ...string) Policy {
+	return PolicyFunc(func(r *http.Request) error {
+		if r.URL.Scheme == "https" {
+			return nil
+		}
+		for _, host := range allow {
+			if strings.EqualFold(r.URL.Hostname(), host) {
+				return nil
+			}
+		}
+		return fmt.Errorf("scheme %s is not allowed", r.URL.Scheme)
+	})
+}
+
+// BlockHosts blocks requests to the given hosts. A host starting with "*."
+// also blocks its subdomains.
+func BlockHosts(hosts ...string) Policy {
+	return PolicyFunc(func(r *http.Request) error {
+		name := strings.ToLower(r.URL.Hostname())
+		for _, host := range hosts {
+			host = strings.ToLower(host)
+			if name == host || strings.HasPrefix(host, "*.") && (name == host[2:] || strings.HasSuffix(name, host[1:])) {
+				return fmt.Errorf("host %s is blocked", name)
+			}
+		}
+		return nil
+	})
+}
+
+// RequireHeaders blocks requests missing any of the named headers.
+func RequireHeaders(names ...string) Policy {
+	return PolicyFunc(func(r *http.Request) error {
+		for _, name := range names {
+			if r.Header.Get(name) == "" {
+				return fmt.Errorf("header %s is required", name)
+			}
+		}
+		return nil
+	})
+}
+
+var errBodyTooLarge = errors.New("request body exceeds the size limit")
+
+// MaxRequestBody blocks requests whose body is larger than n bytes. A body
+// of unknown length fails while being sent once it passes n.
+func MaxRequestBody(n int64) Policy {
+	return PolicyFunc(func(r *http.Request) error {
+		if r.ContentLength > n {
+			return fmt.Errorf("request body of %d bytes exceeds the %d byte limit", r.ContentLength, n)
+		}
+		if r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody {
+			r.Body = &cappedBody{ReadCloser: r.Body, left: n}
+		}
+		return nil
+	})
+}
+
+// cappedBody fails reads once more than left bytes have been read.
+type cappedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *cappedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
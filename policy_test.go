@@ -0,0 +1,150 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Policies(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		blocked bool
+	}{
+		{"https", RequireHTTPS(), true},
+		{"https loopback", RequireHTTPS("127.0.0.1"), false},
+		{"blocked host", BlockHosts("127.0.0.1"), true},
+		{"blocked subdomain", BlockHosts("*.example.com"), false},
+		{"required header", RequireHeaders("X-Tenant"), true},
+		{"auth header", RequireHeaders("Authorization"), false},
+		{"body size", MaxRequestBody(4), true},
+		{"body fits", MaxRequestBody(64), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			c := newClientOrFatal(t, server.URL, apiKey)
+			c.Policies = []Policy{tt.policy}
+			c.Retry = &RetryPolicy{MaxAttempts: 3}
+
+			err := c.CreateJson("/api/foo", "a long body", nil)
+			var pe *PolicyError
+			if blocked := errors.As(err, &pe); blocked != tt.blocked {
+				t.Fatalf("Expected blocked=%v, got %v", tt.blocked, err)
+			}
+			want := int32(1)
+			if tt.blocked {
+				want = 0
+			}
+			if n := atomic.LoadInt32(&calls); n != want {
+				t.Errorf("Expected %d calls, got %d", want, n)
+			}
+		})
+	}
+}
+
+func TestBlockHostsSubdomains(t *testing.T) {
+	p := BlockHosts("*.example.com")
+	for host, blocked := range map[string]bool{
+		"example.com":      true,
+		"api.example.com":  true,
+		"API.Example.com":  true,
+		"notexample.com":   false,
+		"example.com.evil": false,
+		"api.example.org":  false,
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "https://"+host+"/", nil)
+		if err := p.Check(r); (err != nil) != blocked {
+			t.Errorf("%s: expected blocked=%v, got %v", host, blocked, err)
+		}
+	}
+}
+
+func TestRegisterPolicy(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	unregister := RegisterPolicy(PolicyFunc(func(r *http.Request) error {
+		if r.Method == http.MethodDelete {
+			return errors.New("deletes are not allowed")
+		}
+		return nil
+	}))
+
+	err := c.DeleteJson("/api/foo", nil)
+	if err == nil || !strings.Contains(err.Error(), "deletes are not allowed") {
+		t.Errorf("Expected global policy to block the request, got %v", err)
+	}
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Errorf("Expected GET to pass, got %v", err)
+	}
+
+	unregister()
+	if err := c.DeleteJson("/api/foo", nil); err != nil {
+		t.Errorf("Expected DELETE to pass after unregistering, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 calls, got %d", n)
+	}
+}
+
+func TestClient_PolicyRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/foo" {
+			http.Redirect(w, r, "http://blocked.test/", http.StatusTemporaryRedirect)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Policies = []Policy{BlockHosts("blocked.test")}
+	var pe *PolicyError
+	if err := c.ReadJson("/api/foo", nil); !errors.As(err, &pe) {
+		t.Errorf("Expected the redirect to be blocked, got %v", err)
+	}
+}
+
+func TestClient_MaxRequestBodyStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Policies = []Policy{MaxRequestBody(4)}
+
+	req, err := c.MakeRequest(http.MethodPost, "/api/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Body = ioutil.NopCloser(strings.NewReader("far too long"))
+	req.ContentLength = -1
+	if _, err := c.GetResponse(req); !errors.Is(err, errBodyTooLarge) {
+		t.Errorf("Expected errBodyTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrStopPolling can be returned by a poll handler to stop polling without
+// failing the call.
+var ErrStopPolling = errors.New("stop polling")
+
+// DefaultPollInterval is used when PollOptions.Interval is zero.
+const DefaultPollInterval = 5 * time.Second
+
+// PollOptions configures Poll.
+type PollOptions struct {
+	// Interval is the time between the end of one poll and the start of the
+	// next. Defaults to DefaultPollInterval.
+	Interval time.Duration
+
+	// SkipUnchanged skips the handler when the response body hashes the
+	// same as the last one handled, so slow-changing resources don't churn
+	// downstream consumers.
+	SkipUnchanged bool
+
+	// Adaptive, when set, adapts the interval to how often the resource
+	// changes instead of keeping it fixed.
+	Adaptive *AdaptiveInterval
+
+	// Options are applied to every poll request.
+	Options []RequestOption
+}
+
+// AdaptiveInterval backs polling off while a resource is unchanged and
+// tightens it again once it changes. Each poll moves the interval towards
+// its target by exponential smoothing: twice the interval while unchanged,
+// Min once changed. Changes are detected by hashing the response body.
+type AdaptiveInterval struct {
+	// Min is the shortest interval, the one polling starts at. Defaults to
+	// PollOptions.Interval.
+	Min time.Duration
+
+	// Max is the longest interval. Defaults to 10 times Min.
+	Max time.Duration
+
+	// Smoothing is the weight of each poll's target against the current
+	// interval, between 0 and 1: the higher, the faster the interval
+	// adapts. Defaults to 0.5.
+	Smoothing float64
+}
+
+// withDefaults returns a copy of a with its defaults applied, Min
+// defaulting to interval.
+func (a AdaptiveInterval) withDefaults(interval time.Duration) *AdaptiveInterval {
+	if a.Min <= 0 {
+		a.Min = interval
+	}
+	if a.Max < a.Min {
+		a.Max = 10 * a.Min
+	}
+	if a.Smoothing <= 0 || a.Smoothing > 1 {
+		a.Smoothing = 0.5
+	}
+	return &a
+}
+
+// next returns the interval following cur, given whether the last poll saw
+// a change.
+func (a *AdaptiveInterval) next(cur time.Duration, changed bool) time.Duration {
+	target := a.Min
+	if !changed {
+		target = 2 * cur
+	}
+	next := cur + time.Duration(a.Smoothing*float64(target-cur))
+	if next < a.Min {
+		next = a.Min
+	}
+	if next > a.Max {
+		next = a.Max
+	}
+	return next
+}
+
+// Poll GETs uri every Interval and passes each response body to handler
+// until ctx is done, a request fails or handler returns an error. Returning
+// ErrStopPolling stops polling and makes Poll return nil.
+func (c *Client) Poll(ctx context.Context, uri string, opts *PollOptions, handler func(msg json.RawMessage) error) error {
+	if opts == nil {
+		opts = &PollOptions{}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	var adaptive *AdaptiveInterval
+	if opts.Adaptive != nil {
+		adaptive = opts.Adaptive.withDefaults(interval)
+		interval = adaptive.Min
+	}
+
+	var last [sha256.Size]byte
+	var seen bool
+	for {
+		var msg json.RawMessage
+		if err := c.ReadJsonContext(ctx, uri, &msg, opts.Options...); err != nil {
+			return err
+		}
+
+		changed := true
+		if opts.SkipUnchanged || adaptive != nil {
+			sum := sha256.Sum256(msg)
+			changed = !seen || sum != last
+			last, seen = sum, true
+		}
+		if adaptive != nil {
+			interval = adaptive.next(interval, changed)
+		}
+		if changed || !opts.SkipUnchanged {
+			if err := handler(msg); err != nil {
+				if err == ErrStopPolling {
+					return nil
+				}
+				return err
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_PollSkipUnchanged(t *testing.T) {
+	bodies := []string{`{"v":1}`, `{"v":1}`, `{"v":2}`, `{"v":2}`, `{"v":3}`}
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(bodies[calls]))
+		calls++
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var got []string
+	err := c.Poll(context.Background(), "/status", &PollOptions{Interval: time.Millisecond, SkipUnchanged: true}, func(msg json.RawMessage) error {
+		got = append(got, string(msg))
+		if len(got) == 3 {
+			return ErrStopPolling
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 5 {
+		t.Errorf("Expected 5 polls, got %d", calls)
+	}
+	if want := `{"v":1},{"v":2},{"v":3}`; strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+func TestClient_PollStops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var n int
+	err := c.Poll(ctx, "/", &PollOptions{Interval: time.Millisecond}, func(json.RawMessage) error {
+		if n++; n == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	failing := fmt.Errorf("downstream failed")
+	err = c.Poll(context.Background(), "/", nil, func(json.RawMessage) error { return failing })
+	if err != failing {
+		t.Errorf("Expected the handler error, got %v", err)
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	a := (&AdaptiveInterval{Max: 8 * time.Second, Smoothing: 1}).withDefaults(time.Second)
+	cur := a.Min
+	var got []time.Duration
+	for _, changed := range []bool{false, false, false, false, true} {
+		cur = a.next(cur, changed)
+		got = append(got, cur)
+	}
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, time.Second}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	a = (&AdaptiveInterval{}).withDefaults(time.Second)
+	if a.Max != 10*time.Second || a.Smoothing != 0.5 {
+		t.Errorf("Unexpected defaults %+v", a)
+	}
+	if got := a.next(time.Second, false); got != 1500*time.Millisecond {
+		t.Errorf("Expected the interval to back off smoothly, got %v", got)
+	}
+	if got := a.next(9*time.Second, true); got != 5*time.Second {
+		t.Errorf("Expected the interval to tighten smoothly, got %v", got)
+	}
+}
+
+func TestClient_PollAdaptive(t *testing.T) {
+	var calls int
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+		calls++
+		fmt.Fprintf(w, `{"v":%d}`, calls/5)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var handled int
+	opts := &PollOptions{Adaptive: &AdaptiveInterval{Min: 5 * time.Millisecond, Max: 40 * time.Millisecond, Smoothing: 1}}
+	err := c.Poll(context.Background(), "/status", opts, func(json.RawMessage) error {
+		if handled++; handled == 6 {
+			return ErrStopPolling
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 6 {
+		t.Errorf("Expected every poll to be handled, got %d polls", calls)
+	}
+	// Polls 2-4 are unchanged, so the gaps grow 10, 20, 40ms; poll 5
+	// changes, so the next one comes after 5ms.
+	if gap := times[4].Sub(times[3]); gap < 40*time.Millisecond {
+		t.Errorf("Expected polling to back off to 40ms, got %v", gap)
+	}
+	if gap := times[5].Sub(times[4]); gap >= 40*time.Millisecond {
+		t.Errorf("Expected polling to tighten after a change, got %v", gap)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RequestPreset is a named set of call settings, registered once on the
+// Client and selected per call with Preset, so a class of calls (exports,
+// webhooks, bulk imports) behaves the same everywhere.
+type RequestPreset struct {
+	// Header values are set on the request unless it already carries them.
+	Header http.Header
+
+	// Query parameters are added unless the request URI already sets them.
+	Query url.Values
+
+	// Timeout, when positive, bounds the whole call including decoding.
+	Timeout time.Duration
+
+	// Retry, when set, replaces the client's RetryPolicy for the call.
+	Retry *RetryPolicy
+
+	// ErrorExtractor, when set, replaces the client's ErrorExtractor for
+	// the call unless the call sets its own.
+	ErrorExtractor ErrorExtractor
+}
+
+// WithPreset registers p under name. See Client.Presets.
+func WithPreset(name string, p *RequestPreset) Option {
+	return func(c *Client) {
+		c.ownPresets()[name] = p
+	}
+}
+
+// Preset applies the presets registered under names to this call. When
+// presets disagree, the later one wins.
+func Preset(names ...string) RequestOption {
+	return func(o *callOptions) {
+		o.presets = append(o.presets, names...)
+	}
+}
+
+type retryPolicyContextKey struct{}
+
+// retryPolicy returns the policy for r: a preset's policy if one was
+// selected for the call, then that of the context's Overrides, otherwise
+// the client's.
+func (c *Client) retryPolicy(r *http.Request) *RetryPolicy {
+	if p, ok := r.Context().Value(retryPolicyContextKey{}).(*RetryPolicy); ok {
+		return p
+	}
+	if o := overridesOf(r); o != nil {
+		if o.DisableRetries {
+			return nil
+		}
+		if o.Retry != nil {
+			return o.Retry
+		}
+	}
+	return c.Retry
+}
+
+// applyPresets applies the presets selected in o to r. The returned cancel
+// func releases the preset timeout and must always be called.
+func (c *Client) applyPresets(r *http.Request, o *callOptions) (*http.Request, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	if len(o.presets) == 0 {
+		return r, cancel, nil
+	}
+
+	presets := make([]*RequestPreset, len(o.presets))
+	for i, name := range o.presets {
+		p, ok := c.Presets[name]
+		if !ok {
+			return r, cancel, fmt.Errorf("unknown preset %q", name)
+		}
+		presets[i] = p
+	}
+
+	explicit := r.Header.Clone()
+	query := r.URL.Query()
+	explicitQuery := r.URL.Query()
+
+	ctx := r.Context()
+	var timeout time.Duration
+	callExtractor := o.errorExtractor != nil
+	for _, p := range presets {
+		for k, v := range p.Header {
+			if _, ok := explicit[http.CanonicalHeaderKey(k)]; !ok {
+				r.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+			}
+		}
+		for k, v := range p.Query {
+			if _, ok := explicitQuery[k]; !ok {
+				query[k] = append([]string(nil), v...)
+			}
+		}
+		if p.Timeout > 0 {
+			timeout = p.Timeout
+		}
+		if p.Retry != nil {
+			ctx = context.WithValue(ctx, retryPolicyContextKey{}, p.Retry)
+		}
+		if p.ErrorExtractor != nil && !callExtractor {
+			o.errorExtractor = p.ErrorExtractor
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	return r.WithContext(ctx), cancel, nil
+}
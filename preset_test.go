@@ -0,0 +1,94 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Preset(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey,
+		WithPreset("report-export", &RequestPreset{
+			Header: http.Header{"Accept": {"text/csv"}, "X-Priority": {"low"}},
+			Query:  map[string][]string{"format": {"csv"}, "limit": {"100"}},
+		}),
+		WithPreset("urgent", &RequestPreset{
+			Header: http.Header{"X-Priority": {"high"}},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ReadJson("/reports?limit=5", nil, Preset("report-export", "urgent")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := got.Header.Get("Accept"); v != "text/csv" {
+		t.Errorf("Expected Accept \"text/csv\", got %q", v)
+	}
+	if v := got.Header.Get("X-Priority"); v != "high" {
+		t.Errorf("Expected the later preset to win, got %q", v)
+	}
+	if q := got.URL.Query(); q.Get("format") != "csv" || q.Get("limit") != "5" {
+		t.Errorf("Expected format=csv and the URI's limit=5, got %q", got.URL.RawQuery)
+	}
+
+	if err := c.ReadJson("/reports", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Get("X-Priority") != "" {
+		t.Errorf("Expected presets to apply only when selected")
+	}
+}
+
+func TestClient_PresetUnknown(t *testing.T) {
+	c := newClientOrFatal(t, goodURL, apiKey)
+	err := c.ReadJson("/", nil, Preset("missing"))
+	if err == nil || err.(*RequestError).Err.Error() != `unknown preset "missing"` {
+		t.Errorf("Expected unknown preset error, got %v", err)
+	}
+}
+
+func TestClient_PresetTimeoutAndRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithPreset("patient", &RequestPreset{
+		Timeout: 50 * time.Millisecond,
+		Retry:   &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(0)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.ReadJsonContext(context.Background(), "/", nil, Preset("patient"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected the preset retry policy to make 2 attempts, got %d", n)
+	}
+}
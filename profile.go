@@ -0,0 +1,200 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ProfileEnv is the environment variable naming the profile to use when
+// none is given to Profiles.Select or Profiles.NewClient.
+const ProfileEnv = "RELAX_PROFILE"
+
+// ErrNoProfile is returned when no profile is named and ProfileEnv is
+// not set.
+var ErrNoProfile = errors.New("no profile selected")
+
+// Profile is the configuration of one environment of an API, such as dev,
+// staging or prod, so application code never hardcodes endpoints or
+// credentials. Credentials are referenced, not stored: the API key is read
+// from an environment variable or a file, e.g. a mounted secret.
+type Profile struct {
+	// Name is set from the key of the profile in Profiles.
+	Name string `json:"-"`
+
+	BaseURL string `json:"base_url"`
+
+	// APIKeyEnv and APIKeyFile name where the API key is read from. The
+	// variable wins when both are set and it is not empty.
+	APIKeyEnv  string `json:"api_key_env,omitempty"`
+	APIKeyFile string `json:"api_key_file,omitempty"`
+
+	// RootCAFiles, when set, are the PEM files of the CAs servers are
+	// verified against instead of the system roots.
+	RootCAFiles []string `json:"root_ca_files,omitempty"`
+
+	// ClientCertFile and ClientKeyFile are the PEM certificate and key
+	// presented for mutual TLS.
+	ClientCertFile string `json:"client_cert_file,omitempty"`
+	ClientKeyFile  string `json:"client_key_file,omitempty"`
+
+	// InsecureSkipVerify disables server verification. See
+	// WithInsecureSkipVerify.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// Experiments are enabled with EnableExperimental.
+	Experiments []string `json:"experiments,omitempty"`
+
+	// Options are applied after the settings of the profile, for
+	// configuration that cannot be written as JSON.
+	Options []Option `json:"-"`
+}
+
+// Profiles maps profile names to profiles.
+type Profiles map[string]*Profile
+
+// ParseProfiles reads JSON profiles, an object keyed by profile name:
+//
+//	{"staging": {"base_url": "https://staging.example.com/api", "api_key_env": "EXAMPLE_STAGING_KEY"},
+//	 "prod": {"base_url": "https://example.com/api", "api_key_file": "/run/secrets/example_key"}}
+func ParseProfiles(r io.Reader) (Profiles, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var p Profiles
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("profiles: %w", err)
+	}
+	for name, profile := range p {
+		if profile == nil || profile.BaseURL == "" {
+			return nil, fmt.Errorf("profiles: %s has no base_url", name)
+		}
+		profile.Name = name
+	}
+	return p, nil
+}
+
+// LoadProfiles reads the JSON profiles in the file at path. See
+// ParseProfiles.
+func LoadProfiles(path string) (Profiles, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseProfiles(f)
+}
+
+// Names returns the profile names, sorted.
+func (p Profiles) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the profile called name or, when name is empty, the one
+// named by ProfileEnv.
+func (p Profiles) Select(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: set %s to one of %s", ErrNoProfile, ProfileEnv, strings.Join(p.Names(), ", "))
+	}
+	profile, ok := p[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(p.Names(), ", "))
+	}
+	if profile.Name == "" {
+		profile.Name = name
+	}
+	return profile, nil
+}
+
+// NewClient returns a client for the selected profile. See Select and
+// Profile.NewClient.
+func (p Profiles) NewClient(name string, opts ...Option) (*Client, error) {
+	profile, err := p.Select(name)
+	if err != nil {
+		return nil, err
+	}
+	return profile.NewClient(opts...)
+}
+
+// APIKey reads the API key of p. It is empty when p references none, for
+// profiles authenticating WithAuthenticator.
+func (p *Profile) APIKey() (string, error) {
+	if p.APIKeyEnv != "" {
+		if key := os.Getenv(p.APIKeyEnv); key != "" {
+			return key, nil
+		}
+		if p.APIKeyFile == "" {
+			return "", fmt.Errorf("profile %s: %s is not set", p.Name, p.APIKeyEnv)
+		}
+	}
+	if p.APIKeyFile != "" {
+		b, err := ioutil.ReadFile(p.APIKeyFile)
+		if err != nil {
+			return "", fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return "", nil
+}
+
+// NewClient returns a client for the base URL of p, with its API key and
+// TLS settings, followed by p.Options and opts.
+func (p *Profile) NewClient(opts ...Option) (*Client, error) {
+	key, err := p.APIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Option
+	if len(p.RootCAFiles) > 0 {
+		pool, err := LoadCertPool(p.RootCAFiles...)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		all = append(all, WithRootCAs(pool))
+	}
+	if p.ClientCertFile != "" || p.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.ClientCertFile, p.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		all = append(all, WithClientCertificate(cert))
+	}
+	if p.InsecureSkipVerify {
+		all = append(all, WithInsecureSkipVerify())
+	}
+	if len(p.Experiments) > 0 {
+		if err := checkExperiments(p.Experiments); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		all = append(all, EnableExperimental(p.Experiments...))
+	}
+	all = append(all, func(c *Client) { c.Environment = p.Name })
+	all = append(all, p.Options...)
+	all = append(all, opts...)
+
+	c, err := NewClient(p.BaseURL, key, all...)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	return c, nil
+}
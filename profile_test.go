@@ -0,0 +1,84 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	var auth string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"Foo": "staging"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	keyFile := filepath.Join(dir, "key")
+	ioutil.WriteFile(keyFile, []byte("file-key\n"), 0600)
+
+	doc := fmt.Sprintf(`{
+		"staging": {"base_url": %q, "api_key_env": "RELAX_TEST_STAGING_KEY", "root_ca_files": [%q]},
+		"prod": {"base_url": "https://example.com/api", "api_key_env": "RELAX_TEST_PROD_KEY", "api_key_file": %q}
+	}`, server.URL, ca, keyFile)
+	profiles, err := ParseProfiles(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(profiles.Names()) != "[prod staging]" {
+		t.Errorf("Unexpected profiles %v", profiles.Names())
+	}
+
+	t.Setenv(ProfileEnv, "")
+	if _, err := profiles.NewClient(""); !errors.Is(err, ErrNoProfile) {
+		t.Errorf("Expected ErrNoProfile, got %v", err)
+	}
+	if _, err := profiles.NewClient("dev"); err == nil || !strings.Contains(err.Error(), "prod, staging") {
+		t.Errorf("Expected an unknown profile to list the known ones, got %v", err)
+	}
+	if _, err := profiles.NewClient("staging"); err == nil || !strings.Contains(err.Error(), "RELAX_TEST_STAGING_KEY") {
+		t.Errorf("Expected a missing key variable to fail, got %v", err)
+	}
+
+	t.Setenv(ProfileEnv, "staging")
+	t.Setenv("RELAX_TEST_STAGING_KEY", "env-key")
+	c, err := profiles.NewClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	if err := c.ReadJson("/", &response); err != nil {
+		t.Fatalf("Expected the profile CA to be trusted, got %v", err)
+	}
+	if response.Foo != "staging" || auth != `Token token="env-key"` {
+		t.Errorf("Expected the staging key to be sent, got %q", auth)
+	}
+	if d := c.Describe(); d.Environment != "staging" || !strings.Contains(d.String(), "environment: staging\n") {
+		t.Errorf("Expected the description to name the environment, got %+v", d)
+	}
+	if c.Clone().Environment != "staging" {
+		t.Error("Expected clones to keep the environment")
+	}
+
+	prod, err := profiles["prod"].APIKey()
+	if err != nil || prod != "file-key" {
+		t.Errorf("Expected the key to fall back to the file, got %q, %v", prod, err)
+	}
+
+	if _, err := ParseProfiles(strings.NewReader(`{"dev": {}}`)); err == nil {
+		t.Error("Expected a profile without base_url to fail")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"regexp"
+)
+
+var queryVarPattern = regexp.MustCompile(`\{([A-Za-z0-9_.-]+)\}`)
+
+// applyDefaultQuery merges c.DefaultQuery into the URL of r. Parameters the
+// request already carries win, so applying it twice is harmless. Values may
+// contain {name} placeholders filled from c.QueryVars; a value whose
+// placeholder cannot be filled is dropped.
+func (c *Client) applyDefaultQuery(r *http.Request) {
+	if len(c.DefaultQuery) == 0 {
+		return
+	}
+
+	q := r.URL.Query()
+	changed := false
+	for key, values := range c.DefaultQuery {
+		if _, ok := q[key]; ok {
+			continue
+		}
+		for _, v := range values {
+			if v, ok := c.expandQueryVars(r, v); ok {
+				q.Add(key, v)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+func (c *Client) expandQueryVars(r *http.Request, v string) (string, bool) {
+	ok := true
+	v = queryVarPattern.ReplaceAllStringFunc(v, func(m string) string {
+		extract, found := c.QueryVars[m[1:len(m)-1]]
+		if !found {
+			ok = false
+			return m
+		}
+		s, found := extract(r.Context())
+		if !found {
+			ok = false
+		}
+		return s
+	})
+	return v, ok
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestClient_DefaultQuery(t *testing.T) {
+	type key string
+
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.DefaultQuery = url.Values{
+		"api-version": {"2024-01"},
+		"expand":      {"owner", "tags"},
+		"locale":      {"{locale}"},
+		"tenant":      {"t-{tenant}"},
+	}
+	c.QueryVars = map[string]HeaderExtractor{
+		"locale": FromContextKey(key("locale")),
+	}
+
+	req, err := c.MakeRequest(http.MethodGet, "/api/foo?expand=all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(context.WithValue(context.Background(), key("locale"), "de-DE"))
+
+	if _, err := c.GetResponse(req); err != nil {
+		t.Fatal(err)
+	}
+
+	q := got.URL.Query()
+	if v := q.Get("api-version"); v != "2024-01" {
+		t.Errorf("Expected api-version 2024-01, got %q", v)
+	}
+	if v := q["expand"]; len(v) != 1 || v[0] != "all" {
+		t.Errorf("Expected request expand to win, got %v", v)
+	}
+	if v := q.Get("locale"); v != "de-DE" {
+		t.Errorf("Expected templated locale, got %q", v)
+	}
+	if _, ok := q["tenant"]; ok {
+		t.Errorf("Expected unresolved template to be dropped, got %v", q["tenant"])
+	}
+
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.URL.Query()["expand"]; len(v) != 2 || v[0] != "owner" || v[1] != "tags" {
+		t.Errorf("Expected multi-value default, got %v", v)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListFormat is how a QueryEncoding sends slices and arrays.
+type ListFormat int
+
+const (
+	// ListRepeat repeats the key: tag=a&tag=b. It is the default.
+	ListRepeat ListFormat = iota
+
+	// ListComma joins the values: tag=a,b.
+	ListComma
+
+	// ListBrackets repeats the key with brackets, as PHP and Rails
+	// expect: tag[]=a&tag[]=b.
+	ListBrackets
+
+	// ListIndexed numbers the values: tag[0]=a&tag[1]=b.
+	ListIndexed
+)
+
+// TimeFormat is how a QueryEncoding sends time.Time values: a layout for
+// time.Time.Format, or TimeUnix or TimeUnixMilli for epoch timestamps.
+type TimeFormat string
+
+const (
+	// TimeUnix sends the seconds since the Unix epoch.
+	TimeUnix TimeFormat = "unix"
+
+	// TimeUnixMilli sends the milliseconds since the Unix epoch.
+	TimeUnixMilli TimeFormat = "unixmilli"
+)
+
+// QueryEncoding controls how WithParams and ReadJsonWithParams serialize
+// query parameter values, as APIs disagree on lists and timestamps. The
+// zero value is the encoding of EncodeQuery.
+//
+// A struct field may override the list or time format of its encoding
+// with a tag option: repeat, comma, brackets or indexed for lists, and
+// rfc3339, unix or unixmilli for times.
+//
+// A QueryEncoding must not be modified once it is in use.
+type QueryEncoding struct {
+	Lists ListFormat
+
+	// Times defaults to time.RFC3339.
+	Times TimeFormat
+
+	// Encoders encode the values of their type, before any other rule,
+	// e.g. a money type sent as cents. See RegisterQueryEncoder.
+	Encoders map[reflect.Type]func(v interface{}) (string, error)
+}
+
+var defaultQueryEncoding = &QueryEncoding{}
+
+// WithQueryEncoding makes the client encode the parameters of WithParams
+// and ReadJsonWithParams with e.
+func WithQueryEncoding(e *QueryEncoding) Option {
+	return func(c *Client) {
+		c.QueryEncoding = e
+	}
+}
+
+// RegisterQueryEncoder makes e encode the values of type T with fn:
+//
+//	relax.RegisterQueryEncoder(enc, func(d time.Duration) (string, error) {
+//		return strconv.Itoa(int(d.Seconds())), nil
+//	})
+//
+// Pointers to T are dereferenced first, so only the value type needs an
+// encoder.
+func RegisterQueryEncoder[T any](e *QueryEncoding, fn func(T) (string, error)) {
+	if e.Encoders == nil {
+		e.Encoders = make(map[reflect.Type]func(interface{}) (string, error))
+	}
+	e.Encoders[reflect.TypeOf((*T)(nil)).Elem()] = func(v interface{}) (string, error) {
+		return fn(v.(T))
+	}
+}
+
+// withTagOptions returns e with the list and time formats of the field tag
+// options opts, or e itself if they set none.
+func (e *QueryEncoding) withTagOptions(opts string) *QueryEncoding {
+	d := *e
+	for _, o := range strings.Split(opts, ",") {
+		switch o {
+		case "repeat":
+			d.Lists = ListRepeat
+		case "comma":
+			d.Lists = ListComma
+		case "brackets":
+			d.Lists = ListBrackets
+		case "indexed":
+			d.Lists = ListIndexed
+		case "rfc3339":
+			d.Times = time.RFC3339
+		case "unix":
+			d.Times = TimeUnix
+		case "unixmilli":
+			d.Times = TimeUnixMilli
+		}
+	}
+	if d.Lists == e.Lists && d.Times == e.Times {
+		return e
+	}
+	return &d
+}
+
+// addList adds the encoded elements of a list to values under name.
+func (e *QueryEncoding) addList(values url.Values, name string, parts []string) {
+	switch e.Lists {
+	case ListComma:
+		values.Add(name, strings.Join(parts, ","))
+	case ListBrackets:
+		for _, s := range parts {
+			values.Add(name+"[]", s)
+		}
+	case ListIndexed:
+		for i, s := range parts {
+			values.Add(name+"["+strconv.Itoa(i)+"]", s)
+		}
+	default:
+		for _, s := range parts {
+			values.Add(name, s)
+		}
+	}
+}
+
+func (e *QueryEncoding) formatTime(t time.Time) string {
+	switch e.Times {
+	case "":
+		return t.Format(time.RFC3339)
+	case TimeUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.Format(string(e.Times))
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type cents int
+
+type searchOpts struct {
+	Tags   []string  `url:"tag"`
+	IDs    []int     `url:"ids,comma"`
+	Kinds  []string  `url:"kind,brackets"`
+	Since  time.Time `url:"since"`
+	Until  time.Time `url:"until,rfc3339"`
+	Budget *cents    `url:"budget,omitempty"`
+}
+
+func TestQueryEncoding_Lists(t *testing.T) {
+	opts := searchOpts{Tags: []string{"a", "b"}, IDs: []int{1, 2}, Kinds: []string{"x"}}
+	tests := []struct {
+		lists ListFormat
+		want  string
+	}{
+		{ListRepeat, "ids=1%2C2&kind%5B%5D=x&tag=a&tag=b"},
+		{ListComma, "ids=1%2C2&kind%5B%5D=x&tag=a%2Cb"},
+		{ListBrackets, "ids=1%2C2&kind%5B%5D=x&tag%5B%5D=a&tag%5B%5D=b"},
+		{ListIndexed, "ids=1%2C2&kind%5B%5D=x&tag%5B0%5D=a&tag%5B1%5D=b"},
+	}
+	for _, tt := range tests {
+		got, err := (&QueryEncoding{Lists: tt.lists}).Encode(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.Del("since")
+		got.Del("until")
+		if got.Encode() != tt.want {
+			t.Errorf("Expected %s for format %d, got %s", tt.want, tt.lists, got.Encode())
+		}
+	}
+}
+
+func TestQueryEncoding_Times(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	tests := []struct {
+		times TimeFormat
+		want  string
+	}{
+		{"", "2020-01-02T03:04:05Z"},
+		{TimeUnix, "1577934245"},
+		{TimeUnixMilli, "1577934245006"},
+		{"2006-01-02", "2020-01-02"},
+	}
+	for _, tt := range tests {
+		got, err := (&QueryEncoding{Times: tt.times}).Encode(searchOpts{Since: at, Until: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Get("since") != tt.want {
+			t.Errorf("Expected since=%s for %q, got %s", tt.want, tt.times, got.Get("since"))
+		}
+		if got.Get("until") != "2020-01-02T03:04:05Z" {
+			t.Errorf("Expected the rfc3339 tag option to win for %q, got %s", tt.times, got.Get("until"))
+		}
+	}
+
+	got, err := EncodeQuery(struct {
+		At time.Time `url:"at,unixmilli"`
+	}{at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("at") != "1577934245006" {
+		t.Errorf("Expected the unixmilli tag option to apply by default, got %s", got.Get("at"))
+	}
+}
+
+func TestQueryEncoding_Encoders(t *testing.T) {
+	enc := &QueryEncoding{}
+	RegisterQueryEncoder(enc, func(c cents) (string, error) {
+		return strconv.FormatFloat(float64(c)/100, 'f', 2, 64), nil
+	})
+	RegisterQueryEncoder(enc, func(d time.Duration) (string, error) {
+		return strconv.Itoa(int(d.Seconds())), nil
+	})
+
+	budget := cents(1250)
+	got, err := enc.Encode(map[string]interface{}{
+		"budget": &budget,
+		"wait":   90 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Encode() != "budget=12.50&wait=90" {
+		t.Errorf("Unexpected encoding %s", got.Encode())
+	}
+
+	got, err = EncodeQuery(map[string]interface{}{"wait": 90 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("wait") != "1m30s" {
+		t.Errorf("Expected encoders to only apply to their encoding, got %s", got.Get("wait"))
+	}
+}
+
+func TestClient_QueryEncoding(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	enc := &QueryEncoding{Lists: ListComma, Times: TimeUnix}
+	c, err := NewClient(server.URL, apiKey, WithQueryEncoding(enc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := searchOpts{Tags: []string{"a", "b"}, Since: time.Unix(1600000000, 0).UTC()}
+	if err := c.ReadJsonWithParams("/api/items?fixed=1", opts, nil); err != nil {
+		t.Fatal(err)
+	}
+	q := got.URL.Query()
+	if q.Get("fixed") != "1" || q.Get("tag") != "a,b" || q.Get("since") != "1600000000" {
+		t.Errorf("Unexpected query %q", got.URL.RawQuery)
+	}
+
+	d := c.Clone()
+	if d.QueryEncoding != enc {
+		t.Errorf("Expected Clone to keep the query encoding")
+	}
+	if features := c.Describe().Features; !containsFold(features, "query encoding") {
+		t.Errorf("Expected the query encoding feature in %v", features)
+	}
+
+	plain := newClientOrFatal(t, server.URL, apiKey)
+	if err := plain.ReadJson("/api/items", nil, WithParams(opts)); err != nil {
+		t.Fatal(err)
+	}
+	if q := got.URL.Query(); len(q["tag"]) != 2 || q.Get("since") != "2020-09-13T12:26:40Z" {
+		t.Errorf("Expected the default encoding without QueryEncoding, got %q", got.URL.RawQuery)
+	}
+}
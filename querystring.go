@@ -0,0 +1,252 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncodeQuery encodes v as query parameters. v may be url.Values, a map
+// with string keys or a struct, or a pointer to one of them.
+//
+// Struct fields are encoded under their `url` tag, as in go-querystring:
+//
+//	type ListOpts struct {
+//		Page   int       `url:"page,omitempty"`
+//		Status string    `url:"status"`
+//		Tags   []string  `url:"tag"`           // tag=a&tag=b
+//		IDs    []int     `url:"ids,comma"`     // ids=1,2
+//		Kinds  []string  `url:"kind,brackets"` // kind[]=a&kind[]=b
+//		Since  time.Time `url:"since,unix"`    // since=1577934245
+//		Secret string    `url:"-"`             // never sent
+//	}
+//
+// Untagged exported fields use their name. Embedded structs are flattened,
+// nil pointers are skipped, time.Time is encoded as RFC 3339 and types
+// implementing encoding.TextMarshaler or fmt.Stringer encode themselves.
+// See QueryEncoding for the options of a field and to change the defaults.
+func EncodeQuery(v interface{}) (url.Values, error) {
+	return defaultQueryEncoding.Encode(v)
+}
+
+// Encode encodes v as query parameters like EncodeQuery, with the list and
+// time formats and the encoders of e. A nil e is the default encoding.
+func (e *QueryEncoding) Encode(v interface{}) (url.Values, error) {
+	if e == nil {
+		e = defaultQueryEncoding
+	}
+	values := make(url.Values)
+	if v == nil {
+		return values, nil
+	}
+	if uv, ok := v.(url.Values); ok {
+		for k, vs := range uv {
+			values[k] = append(values[k], vs...)
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return values, e.encodeStruct(values, rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot encode %s as query: keys must be strings", rv.Type())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := e.encodeField(values, iter.Key().String(), iter.Value(), false); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("cannot encode %s as query", rv.Type())
+}
+
+// WithParams adds the query parameters encoded from v to this call, with
+// the client's QueryEncoding. See EncodeQuery.
+func WithParams(v interface{}) RequestOption {
+	return func(o *callOptions) {
+		o.params = append(o.params, v)
+	}
+}
+
+// ReadJsonWithParams GETs uri with the query parameters encoded from params
+// and decodes the response into response.
+func (c *Client) ReadJsonWithParams(uri string, params interface{}, response interface{}, opts ...RequestOption) error {
+	return c.ReadJsonWithParamsContext(context.Background(), uri, params, response, opts...)
+}
+
+// ReadJsonWithParamsContext is like ReadJsonWithParams but ctx bounds the
+// request.
+func (c *Client) ReadJsonWithParamsContext(ctx context.Context, uri string, params interface{}, response interface{}, opts ...RequestOption) error {
+	return c.ReadJsonContext(ctx, uri, response, append([]RequestOption{WithParams(params)}, opts...)...)
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+func (e *QueryEncoding) encodeStruct(values url.Values, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+
+		tag := sf.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		omitempty := hasTagOption(opts, "omitempty")
+
+		if sf.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !e.isScalar(fv.Type()) {
+				if err := e.encodeStruct(values, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if err := e.withTagOptions(opts).encodeField(values, name, fv, omitempty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasTagOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isScalar reports whether values of t encode as a single string.
+func isScalar(t reflect.Type) bool {
+	return t == timeType || t.Implements(textMarshalerType) || t.Implements(stringerType)
+}
+
+// isScalar is like the isScalar function, also counting the types e has an
+// encoder for.
+func (e *QueryEncoding) isScalar(t reflect.Type) bool {
+	_, ok := e.Encoders[t]
+	return ok || isScalar(t)
+}
+
+func (e *QueryEncoding) encodeField(values url.Values, name string, fv reflect.Value, omitempty bool) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	if omitempty && fv.IsZero() {
+		return nil
+	}
+
+	if !e.isScalar(fv.Type()) && (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() != reflect.Uint8 {
+		if fv.Len() == 0 && omitempty {
+			return nil
+		}
+		parts := make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			s, err := e.queryString(fv.Index(i))
+			if err != nil {
+				return fmt.Errorf("query parameter %s: %s", name, err)
+			}
+			parts = append(parts, s)
+		}
+		e.addList(values, name, parts)
+		return nil
+	}
+
+	s, err := e.queryString(fv)
+	if err != nil {
+		return fmt.Errorf("query parameter %s: %s", name, err)
+	}
+	values.Add(name, s)
+	return nil
+}
+
+func queryString(v reflect.Value) (string, error) {
+	return defaultQueryEncoding.queryString(v)
+}
+
+func (e *QueryEncoding) queryString(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	if enc, ok := e.Encoders[v.Type()]; ok {
+		return enc(v.Interface())
+	}
+	if v.Type() == timeType {
+		return e.formatTime(v.Interface().(time.Time)), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if v.Type().Implements(stringerType) {
+		return v.Interface().(fmt.Stringer).String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
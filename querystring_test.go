@@ -0,0 +1,93 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type pageOpts struct {
+	Page    int `url:"page,omitempty"`
+	PerPage int `url:"per_page,omitempty"`
+}
+
+type listOpts struct {
+	pageOpts
+	Status  string    `url:"status"`
+	Tags    []string  `url:"tag,omitempty"`
+	IDs     []int     `url:"ids,comma"`
+	Since   time.Time `url:"since,omitempty"`
+	Active  *bool     `url:"active"`
+	Limit   float64
+	Secret  string `url:"-"`
+	private string
+}
+
+func TestEncodeQuery(t *testing.T) {
+	yes := true
+	got, err := EncodeQuery(&listOpts{
+		pageOpts: pageOpts{Page: 2},
+		Status:   "a&b",
+		Tags:     []string{"x", "y"},
+		IDs:      []int{1, 2},
+		Since:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Active:   &yes,
+		Limit:    1.5,
+		Secret:   "s",
+		private:  "p",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Limit=1.5&active=true&ids=1%2C2&page=2&since=2020-01-02T03%3A04%3A05Z&status=a%26b&tag=x&tag=y"
+	if got.Encode() != want {
+		t.Errorf("Expected %s, got %s", want, got.Encode())
+	}
+
+	got, err = EncodeQuery(map[string]interface{}{"q": "go", "n": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Encode() != "n=3&q=go" {
+		t.Errorf("Unexpected map encoding %s", got.Encode())
+	}
+
+	if _, err := EncodeQuery(42); err == nil {
+		t.Errorf("Expected an error for a scalar")
+	}
+	if _, err := EncodeQuery(struct{ C chan int }{}); err == nil {
+		t.Errorf("Expected an error for an unsupported field")
+	}
+}
+
+func TestClient_ReadJsonWithParams(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	if err := c.ReadJsonWithParams("/api/items?fixed=1", listOpts{Status: "active"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if q := got.URL.Query(); q.Get("fixed") != "1" || q.Get("status") != "active" {
+		t.Errorf("Unexpected query %q", got.URL.RawQuery)
+	}
+
+	if err := c.ReadJson("/api/items", nil, WithParams(url.Values{"a": {"b"}})); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.RawQuery != "a=b" {
+		t.Errorf("Unexpected query %q", got.URL.RawQuery)
+	}
+
+	if err := c.ReadJson("/api/items", nil, WithParams(make(chan int))); err == nil {
+		t.Errorf("Expected an encoding error")
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateLimitRemainingHeader is used when
+	// RateLimitConfig.RemainingHeader is empty.
+	DefaultRateLimitRemainingHeader = "X-RateLimit-Remaining"
+
+	// DefaultRateLimitResetHeader is used when RateLimitConfig.ResetHeader
+	// is empty.
+	DefaultRateLimitResetHeader = "X-RateLimit-Reset"
+)
+
+// RateLimitConfig limits the rate at which the client sends requests, so
+// bulk loops stay under the server's quota instead of running into 429s.
+// Each attempt, including retries, takes one token from a bucket refilled
+// at Requests per Per.
+type RateLimitConfig struct {
+	// Requests and Per set the sustained rate, e.g. 10 per time.Second.
+	// When Requests is zero only the server headers are honored.
+	Requests int
+	Per      time.Duration
+
+	// Burst is the bucket size. Defaults to Requests.
+	Burst int
+
+	// FromHeaders makes the client pause until the reset time once the
+	// server reports no remaining requests.
+	FromHeaders bool
+
+	// RemainingHeader and ResetHeader name the server's rate limit headers.
+	// The reset value may be seconds until the reset or a Unix timestamp.
+	RemainingHeader string
+	ResetHeader     string
+
+	// PerTenant shares the rate among the tenants the requests are sent
+	// for, weighted by TenantWeights, instead of serving requests first
+	// come, first served. See WithTenant.
+	PerTenant bool
+
+	// TenantWeights weighs the share of each tenant. Unlisted tenants,
+	// including requests sent for no tenant, weigh 1.
+	TenantWeights map[string]float64
+
+	// TenantIdle is how long after its last request a tenant still counts
+	// towards the sharing. Defaults to Per.
+	TenantIdle time.Duration
+
+	// Store, when set, holds the limit for every client using it under
+	// Key, across processes, instead of each client limiting itself.
+	// PerTenant does not apply to it.
+	Store RateLimitStore
+
+	// Key names the limit in Store. Defaults to "relax:ratelimit:" and the
+	// host of the base URL.
+	Key string
+
+	// OnStoreError, when set, is told about failures of Store. The
+	// client's own limiter is used for requests whose reservation failed.
+	OnStoreError func(err error)
+}
+
+// WithRateLimit limits the client to n requests per interval.
+func WithRateLimit(n int, per time.Duration) Option {
+	return func(c *Client) {
+		c.RateLimit = &RateLimitConfig{Requests: n, Per: per}
+	}
+}
+
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	until  time.Time // set from server headers
+
+	tenants map[string]*tenantBucket
+}
+
+// reserve takes a token for tenant and returns how long to wait before
+// using it.
+func (l *rateLimiter) reserve(cfg *RateLimitConfig, tenant string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if cfg.PerTenant && cfg.Requests > 0 && cfg.Per > 0 {
+		wait = l.reserveTenant(cfg, tenant, now)
+	} else if cfg.Requests > 0 && cfg.Per > 0 {
+		burst := cfg.Burst
+		if burst <= 0 {
+			burst = cfg.Requests
+		}
+		rate := float64(cfg.Requests) / float64(cfg.Per)
+
+		if l.last.IsZero() {
+			l.tokens = float64(burst)
+		} else {
+			l.tokens += float64(now.Sub(l.last)) * rate
+			if l.tokens > float64(burst) {
+				l.tokens = float64(burst)
+			}
+		}
+		l.last = now
+
+		l.tokens--
+		if l.tokens < 0 {
+			wait = time.Duration(-l.tokens / rate)
+		}
+	}
+
+	if d := l.until.Sub(now); d > wait {
+		wait = d
+	}
+	return wait
+}
+
+// observe pauses the limiter as the headers of res ask.
+func (l *rateLimiter) observe(cfg *RateLimitConfig, res *http.Response) {
+	reset, ok := rateLimitPause(cfg, res, time.Now())
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if reset.After(l.until) {
+		l.until = reset
+	}
+}
+
+// rateLimitPause returns the reset time when res reports that no requests
+// remain, or the end of the Retry-After of a 429.
+func rateLimitPause(cfg *RateLimitConfig, res *http.Response, now time.Time) (time.Time, bool) {
+	remainingHeader, resetHeader := cfg.RemainingHeader, cfg.ResetHeader
+	if remainingHeader == "" {
+		remainingHeader = DefaultRateLimitRemainingHeader
+	}
+	if resetHeader == "" {
+		resetHeader = DefaultRateLimitResetHeader
+	}
+
+	var reset time.Time
+	if remaining, err := strconv.Atoi(res.Header.Get(remainingHeader)); err == nil && remaining <= 0 {
+		reset, _ = parseRateLimitReset(res.Header.Get(resetHeader), now)
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		if d, ok := parseRetryAfter(res.Header.Get("Retry-After"), now); ok && now.Add(d).After(reset) {
+			reset = now.Add(d)
+		}
+	}
+	return reset, !reset.IsZero()
+}
+
+// parseRateLimitReset parses a reset value given either as seconds from now
+// or, for values too large to be a delay, as a Unix timestamp.
+func parseRateLimitReset(v string, now time.Time) (time.Time, bool) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	if n > 1e9 {
+		return time.Unix(n, 0), true
+	}
+	return now.Add(time.Duration(n) * time.Second), true
+}
+
+// sendLimited sends r once the rate limiter allows it.
+func (c *Client) sendLimited(r *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	cfg := c.RateLimit
+	var wait time.Duration
+	shared := false
+	if cfg.Store != nil {
+		wait, shared = c.reserveShared(r, cfg)
+	}
+	if !shared {
+		wait = c.limiter.reserve(cfg, c.tenantOf(r))
+	}
+	if wait > 0 {
+		if err := sleepContext(r, wait); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := send(r)
+	if err == nil && cfg.FromHeaders {
+		if cfg.Store != nil {
+			c.observeShared(r, cfg, res)
+		} else {
+			c.limiter.observe(cfg, res)
+		}
+	}
+	return res, err
+}
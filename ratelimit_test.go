@@ -0,0 +1,86 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_RateLimit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL, apiKey, WithRateLimit(20, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RateLimit.Burst = 1
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := c.CreateJson("/", Response{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("Expected 4 requests at 20/s to take at least 150ms, took %s", elapsed)
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+}
+
+func TestClient_RateLimitFromHeaders(t *testing.T) {
+	var last time.Time
+	var gap time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !last.IsZero() {
+			gap = time.Since(last)
+		}
+		last = time.Now()
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1")
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.RateLimit = &RateLimitConfig{FromHeaders: true}
+
+	for i := 0; i < 2; i++ {
+		if err := c.ReadJson("/", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if gap < 900*time.Millisecond {
+		t.Errorf("Expected the client to wait for the reset, waited %s", gap)
+	}
+}
+
+func TestParseRateLimitReset(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	if got, ok := parseRateLimitReset("30", now); !ok || !got.Equal(now.Add(30*time.Second)) {
+		t.Errorf("Expected delta reset, got %v %v", got, ok)
+	}
+	ts := strconv.FormatInt(now.Add(time.Minute).Unix(), 10)
+	if got, ok := parseRateLimitReset(ts, now); !ok || !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected timestamp reset, got %v %v", got, ok)
+	}
+	if _, ok := parseRateLimitReset("soon", now); ok {
+		t.Errorf("Expected invalid reset to be rejected")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedirectHop is a redirect the http.Client followed. URLs are redacted as
+// in logs, see LogConfig.RedactHeaders.
+type RedirectHop struct {
+	Method     string        `json:"method"`
+	URL        string        `json:"url"`
+	StatusCode int           `json:"status"`
+	Location   string        `json:"location"`
+	Latency    time.Duration `json:"latency_ns"`
+
+	// AuthDropped reports that the Authorization header was not sent to
+	// Location, as the http.Client does when a redirect leaves the host.
+	AuthDropped bool `json:"auth_dropped,omitempty"`
+}
+
+// RedirectError is returned when the http.Client gives up following
+// redirects. Hops is the chain up to the redirect it refused.
+type RedirectError struct {
+	Hops []RedirectHop
+	Err  error
+}
+
+func (e *RedirectError) Error() string {
+	urls := make([]string, 0, len(e.Hops)+1)
+	for _, h := range e.Hops {
+		urls = append(urls, fmt.Sprintf("%s (%d)", h.URL, h.StatusCode))
+	}
+	if n := len(e.Hops); n > 0 {
+		urls = append(urls, e.Hops[n-1].Location)
+	}
+	return fmt.Sprintf("%s: %s", e.Err, strings.Join(urls, " -> "))
+}
+
+func (e *RedirectError) Unwrap() error {
+	return e.Err
+}
+
+type redirectsContextKey struct{}
+
+// redirectChain collects the hops of one attempt.
+type redirectChain struct {
+	mu     sync.Mutex
+	mark   time.Time
+	hops   []RedirectHop
+	redact []string
+}
+
+// traceRedirects returns r carrying a chain to record its redirects into,
+// or nil when nobody would look at them.
+func (c *Client) traceRedirects(r *http.Request, start time.Time) (*http.Request, *redirectChain) {
+	if c.Logging == nil && callResult(r) == nil {
+		return r, nil
+	}
+	chain := &redirectChain{mark: start, redact: DefaultJournalRedact}
+	if c.Logging != nil {
+		chain.redact = c.Logging.redactList()
+	}
+	return r.WithContext(context.WithValue(r.Context(), redirectsContextKey{}, chain)), chain
+}
+
+// record adds the redirect that led to req and returns the chain so far.
+func (rc *redirectChain) record(req *http.Request, via []*http.Request) []RedirectHop {
+	prev := via[len(via)-1]
+	hop := RedirectHop{
+		Method:      prev.Method,
+		URL:         redactRequestURL(prev, rc.redact),
+		Location:    redactRequestURL(req, rc.redact),
+		AuthDropped: via[0].Header.Get("Authorization") != "" && req.Header.Get("Authorization") == "",
+	}
+	if req.Response != nil {
+		hop.StatusCode = req.Response.StatusCode
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	now := time.Now()
+	hop.Latency = now.Sub(rc.mark)
+	rc.mark = now
+	rc.hops = append(rc.hops, hop)
+	return append([]RedirectHop(nil), rc.hops...)
+}
+
+// chain returns the recorded hops.
+func (rc *redirectChain) chain() []RedirectHop {
+	if rc == nil {
+		return nil
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]RedirectHop(nil), rc.hops...)
+}
+
+// hopsOf describes the chain of via and req without latencies, for
+// attempts that were not traced.
+func hopsOf(req *http.Request, via []*http.Request) []RedirectHop {
+	hops := make([]RedirectHop, len(via))
+	for i, v := range via {
+		next := req
+		if i+1 < len(via) {
+			next = via[i+1]
+		}
+		hops[i] = RedirectHop{Method: v.Method, URL: redactRequestURL(v, DefaultJournalRedact), Location: redactRequestURL(next, DefaultJournalRedact)}
+		if next.Response != nil {
+			hops[i].StatusCode = next.Response.StatusCode
+		}
+	}
+	return hops
+}
+
+// recordRedirect records the redirect that led to req, if the attempt is
+// traced, and returns the chain so far.
+func recordRedirect(req *http.Request, via []*http.Request) []RedirectHop {
+	rc, _ := req.Context().Value(redirectsContextKey{}).(*redirectChain)
+	if rc == nil {
+		return nil
+	}
+	return rc.record(req, via)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Redirects(t *testing.T) {
+	var auth string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"Foo": "moved"}`))
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b?token=secret", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusTemporaryRedirect)
+		case "/c":
+			w.Write([]byte(`{"Foo": "bar"}`))
+		case "/away":
+			http.Redirect(w, r, otherURL+"/d", http.StatusTemporaryRedirect)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusTemporaryRedirect)
+		}
+	}))
+	defer server.Close()
+
+	var logged []RedirectHop
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Logging = &LogConfig{RedactHeaders: []string{"token"}, Hook: HookFuncs{Response: func(e *LogEvent) { logged = e.Redirects }}}
+
+	var response Response
+	var result Result
+	if err := c.ReadJson("/a", &response, WithResult(&result)); err != nil {
+		t.Fatal(err)
+	}
+	hops := result.Redirects
+	if len(hops) != 2 || hops[0].StatusCode != 301 || hops[1].StatusCode != 307 {
+		t.Fatalf("Expected a 301 and a 307 hop, got %+v", hops)
+	}
+	if !strings.HasSuffix(hops[0].URL, "/a") || !strings.Contains(hops[0].Location, "/b?token=%5BREDACTED%5D") || !strings.HasSuffix(hops[1].Location, "/c") {
+		t.Errorf("Unexpected hop URLs %+v", hops)
+	}
+	if hops[0].Method != http.MethodGet || hops[0].Latency <= 0 || hops[0].AuthDropped {
+		t.Errorf("Unexpected hop %+v", hops[0])
+	}
+	if len(logged) != 2 {
+		t.Errorf("Expected the chain to be logged, got %+v", logged)
+	}
+
+	result = Result{}
+	if err := c.ReadJson("/away", &response, WithResult(&result), WithHeader("Authorization", "Bearer abc")); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Redirects) != 1 || !result.Redirects[0].AuthDropped || auth != "" {
+		t.Errorf("Expected the hop to another host to drop Authorization, got %+v", result.Redirects)
+	}
+
+	err := c.ReadJson("/loop", &response)
+	var re *RedirectError
+	if !errors.As(err, &re) || len(re.Hops) != 10 || !errors.Is(err, errTooManyRedirects) {
+		t.Fatalf("Expected a RedirectError with 10 hops, got %v", err)
+	}
+	if !strings.Contains(re.Error(), "/loop (307) -> ") {
+		t.Errorf("Expected the error to show the chain, got %q", re.Error())
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// Redacted replaces the values of scrubbed headers and query parameters in
+// cassettes.
+const Redacted = "REDACTED"
+
+// Mode selects whether a Recorder talks to the real API.
+type Mode int
+
+const (
+	// ModeReplay answers requests from the cassette only; requests it
+	// holds no interaction for fail. This is the mode for CI.
+	ModeReplay Mode = iota
+
+	// ModeRecord sends every request to the API and records the
+	// interactions, replacing the cassette when the Recorder is stopped.
+	ModeRecord
+
+	// ModeReplayOrRecord replays the interactions the cassette holds and
+	// records the requests it does not, adding them to the cassette.
+	ModeReplayOrRecord
+
+	// ModeDiff compares each request against the recorded request with
+	// the same method and path and replays its response, so a refactor
+	// that changes what the code sends shows up in Diffs. Requests the
+	// cassette holds no interaction for fail.
+	ModeDiff
+)
+
+// Cassette is a recording of HTTP interactions, stored as JSON.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response the API sent to it.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+
+	used bool
+}
+
+// RecordedRequest is the recorded form of a request, with scrubbed
+// headers and query parameters.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is the recorded form of a response.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Body is a recorded body. It is stored as a string when it is valid
+// UTF-8, so JSON bodies stay readable in the cassette, and as base64
+// otherwise.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var enc struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc.Base64)
+	if err != nil {
+		return err
+	}
+	*b = raw
+	return nil
+}
+
+// LoadCassette reads the cassette at path.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("relaxtest: cassette %s: %s", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes c to path, creating its directory.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper recording the interactions with a real
+// API to a cassette file, and replaying them later so tests run without
+// network access or credentials:
+//
+//	rec, err := relaxtest.NewRecorder("testdata/users.json", relaxtest.ModeReplay)
+//	...
+//	defer rec.Stop()
+//	c, _ := relax.NewClient("https://api.example.com", key, relax.WithHTTPClient(rec.Client()))
+//
+// Credentials are scrubbed before anything is written: the values of the
+// headers in ScrubHeaders and the query parameters in ScrubQuery are
+// replaced by Redacted. Replayed requests are matched by method, scrubbed
+// URL and body, each recorded interaction answering once and in the order
+// it was recorded, so a resource that changes between two identical
+// requests replays the same way. Bodies containing generated values, such
+// as random IDs or timestamps, need Match or deterministic generators.
+//
+// A Recorder is safe for concurrent use.
+type Recorder struct {
+	// Transport sends recorded requests. Defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// ScrubHeaders are scrubbed from requests and responses. Defaults
+	// to DefaultScrubHeaders.
+	ScrubHeaders []string
+
+	// ScrubQuery are the query parameters scrubbed from request URLs.
+	ScrubQuery []string
+
+	// Match, when set, replaces the default matching of requests to
+	// recorded interactions. req is the scrubbed form of the request.
+	Match func(req *RecordedRequest, recorded *Interaction) bool
+
+	// DiffIgnoreHeaders are not compared in ModeDiff. Defaults to
+	// DefaultDiffIgnoreHeaders.
+	DiffIgnoreHeaders []string
+
+	path     string
+	mode     Mode
+	mu       sync.Mutex
+	cassette *Cassette
+	dirty    bool
+	diffs    []RequestDiff
+}
+
+// DefaultScrubHeaders are the headers a Recorder scrubs by default.
+var DefaultScrubHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// NewRecorder returns a Recorder using the cassette at path in mode. The
+// cassette must exist in ModeReplay and ModeDiff.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, cassette: &Cassette{}}
+	if mode == ModeRecord {
+		return r, nil
+	}
+	c, err := LoadCassette(path)
+	switch {
+	case err == nil:
+		r.cassette = c
+	case os.IsNotExist(err) && mode == ModeReplayOrRecord:
+	default:
+		return nil, err
+	}
+	return r, nil
+}
+
+// Client returns an http.Client sending through r.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Mode returns the mode r was created with.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Cassette returns the interactions recorded or loaded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Stop saves the cassette if new interactions were recorded.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+	if err := r.cassette.Save(r.path); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+// RoundTrip answers req from the cassette or sends it to the API and
+// records the interaction, depending on the mode.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	recorded := RecordedRequest{
+		Method: req.Method,
+		URL:    r.scrubURL(req.URL),
+		Header: r.scrubHeader(req.Header),
+		Body:   body,
+	}
+
+	if r.mode == ModeDiff {
+		return r.compare(req, &recorded)
+	}
+	if r.mode != ModeRecord {
+		if res, ok := r.find(&recorded); ok {
+			return res.response(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("relaxtest: no recorded interaction for %s %s", req.Method, recorded.URL)
+		}
+	}
+
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	res, err := transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			Status: res.StatusCode,
+			Header: r.scrubHeader(res.Header),
+			Body:   resBody,
+		},
+		used: true,
+	})
+	r.dirty = true
+	r.mu.Unlock()
+	return res, nil
+}
+
+// find returns the response of the first unused interaction matching req
+// and marks it used.
+func (r *Recorder) find(req *RecordedRequest) (RecordedResponse, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.cassette.Interactions {
+		in := &r.cassette.Interactions[i]
+		if in.used {
+			continue
+		}
+		if r.Match != nil {
+			if !r.Match(req, in) {
+				continue
+			}
+		} else if in.Request.Method != req.Method || in.Request.URL != req.URL || !bytes.Equal(in.Request.Body, req.Body) {
+			continue
+		}
+		in.used = true
+		return in.Response, true
+	}
+	return RecordedResponse{}, false
+}
+
+func (r *Recorder) scrubHeaders() []string {
+	if r.ScrubHeaders != nil {
+		return r.ScrubHeaders
+	}
+	return DefaultScrubHeaders
+}
+
+func (r *Recorder) scrubHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.scrubHeaders() {
+		if vs := out.Values(name); len(vs) > 0 {
+			out.Del(name)
+			for range vs {
+				out.Add(name, Redacted)
+			}
+		}
+	}
+	return out
+}
+
+func (r *Recorder) scrubURL(u *url.URL) string {
+	s := *u
+	s.User = nil
+	if len(r.ScrubQuery) > 0 && s.RawQuery != "" {
+		q := s.Query()
+		changed := false
+		for _, name := range r.ScrubQuery {
+			if vs, ok := q[name]; ok {
+				for i := range vs {
+					vs[i] = Redacted
+				}
+				changed = true
+			}
+		}
+		if changed {
+			s.RawQuery = q.Encode()
+		}
+	}
+	return s.String()
+}
+
+func (rr *RecordedResponse) response(req *http.Request) *http.Response {
+	header := rr.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rr.Status, http.StatusText(rr.Status)),
+		StatusCode:    rr.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(rr.Body)),
+		ContentLength: int64(len(rr.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"call":%d,"method":%q}`, calls, r.Method)
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "api.json")
+
+	rec, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.ScrubQuery = []string{"token"}
+	c := rec.Client()
+	send := func(c *http.Client, method, body string) (string, error) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+"/items?token=abc&page=1", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer key")
+		res, err := c.Do(req)
+		if err != nil {
+			return "", err
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		return string(b), nil
+	}
+	for _, m := range []string{"GET", "GET", "POST"} {
+		if _, err := send(c, m, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"Bearer key", "s3cret", "token=abc"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q to be scrubbed from the cassette", secret)
+		}
+	}
+
+	rec, err = NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.ScrubQuery = []string{"token"}
+	c = rec.Client()
+	for i, want := range []string{`{"call":1,"method":"GET"}`, `{"call":2,"method":"GET"}`, `{"call":3,"method":"POST"}`} {
+		m := "GET"
+		if i == 2 {
+			m = "POST"
+		}
+		got, err := send(c, m, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Expected replay %d to be %s, got %s", i, want, got)
+		}
+	}
+	if _, err := send(c, "GET", ""); err == nil {
+		t.Error("Expected a request beyond the cassette to fail")
+	}
+	if _, err := send(c, "PUT", "x"); err == nil {
+		t.Error("Expected an unrecorded request to fail")
+	}
+	if calls != 3 {
+		t.Errorf("Expected replay not to reach the server, got %d calls", calls)
+	}
+}
+
+func TestRecorder_ReplayOrRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0xff, 0x00, 0xfe})
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "api.json")
+
+	if _, err := NewRecorder(path, ModeReplay); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing cassette to fail in replay mode, got %v", err)
+	}
+	rec, err := NewRecorder(path, ModeReplayOrRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Client().Get(server.URL + "/bin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cassette.Interactions); n != 1 {
+		t.Fatalf("Expected 1 interaction, got %d", n)
+	}
+	if got := cassette.Interactions[0].Response.Body; string(got) != "\xff\x00\xfe" {
+		t.Errorf("Expected a binary body to survive the cassette, got %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IDs generates predictable UUIDs, counting up from
+// 00000000-0000-4000-8000-000000000001. Hand it to relax.WithIDGenerator
+// so the idempotency keys and request IDs of a test are the same on every
+// run, e.g. to match a recorded cassette. It is safe for concurrent use.
+type IDs struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+// NewID returns the next ID.
+func (g *IDs) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012x", g.n)
+}
+
+// Clock is a clock that only moves when told to. Hand it to
+// relax.WithClock. It is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a Clock set to t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{now: t}
+}
+
+// Now returns the time the clock is set to.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set sets the clock to t.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
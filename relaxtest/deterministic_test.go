@@ -0,0 +1,32 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIDs(t *testing.T) {
+	var ids IDs
+	if id := ids.NewID(); id != "00000000-0000-4000-8000-000000000001" {
+		t.Errorf("Expected the first ID to be 1, got %s", id)
+	}
+	if id := ids.NewID(); id != "00000000-0000-4000-8000-000000000002" {
+		t.Errorf("Expected the second ID to be 2, got %s", id)
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	c := NewClock(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected %s, got %s", start, c.Now())
+	}
+	c.Advance(time.Minute)
+	if want := start.Add(time.Minute); !c.Now().Equal(want) {
+		t.Errorf("Expected %s, got %s", want, c.Now())
+	}
+}
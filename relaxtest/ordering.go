@@ -0,0 +1,101 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// A request is in flight from the moment the Transport receives it until
+// it answers, Delay included, so Delay stands in for server time when
+// asserting on ordering and concurrency. Calls are written "METHOD /path",
+// with the matching rules of On; a call without a method matches any.
+
+// MaxConcurrent returns the largest number of requests matching method and
+// path that were in flight at once.
+func (t *Transport) MaxConcurrent(method, path string) int {
+	type event struct {
+		seq   int
+		delta int
+	}
+	var events []event
+	for _, r := range t.Calls(method, path) {
+		events = append(events, event{r.started, 1})
+		if r.ended != 0 {
+			events = append(events, event{r.ended, -1})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].seq < events[j].seq })
+
+	n, max := 0, 0
+	for _, e := range events {
+		if n += e.delta; n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// AssertMaxConcurrent fails tb if more than n requests matching method and
+// path were in flight at once, e.g. to check the size of a worker pool.
+func (t *Transport) AssertMaxConcurrent(tb testing.TB, method, path string, n int) {
+	tb.Helper()
+	if got := t.MaxConcurrent(method, path); got > n {
+		tb.Errorf("Expected at most %d concurrent calls to %s %s, got %d", n, method, path, got)
+	}
+}
+
+// AssertParallel fails tb unless at least n requests matching method and
+// path were in flight at once.
+func (t *Transport) AssertParallel(tb testing.TB, method, path string, n int) {
+	tb.Helper()
+	if got := t.MaxConcurrent(method, path); got < n {
+		tb.Errorf("Expected at least %d concurrent calls to %s %s, got %d", n, method, path, got)
+	}
+}
+
+// AssertOrder fails tb unless each of calls was made, and every request
+// matching one had been answered before any request matching the next
+// started:
+//
+//	tr.AssertOrder(t, "POST /oauth/token", "GET /api/*")
+func (t *Transport) AssertOrder(tb testing.TB, calls ...string) {
+	tb.Helper()
+	var prev string
+	var prevEnd int
+	for i, call := range calls {
+		method, path := splitCall(call)
+		reqs := t.Calls(method, path)
+		if len(reqs) == 0 {
+			tb.Errorf("Expected a call to %s", call)
+			return
+		}
+		first, last := reqs[0].started, 0
+		for _, r := range reqs {
+			if r.started < first {
+				first = r.started
+			}
+			if r.ended == 0 || last == -1 {
+				last = -1
+			} else if r.ended > last {
+				last = r.ended
+			}
+		}
+		if i > 0 && (prevEnd == -1 || first < prevEnd) {
+			tb.Errorf("Expected every call to %s to finish before %s started", prev, call)
+		}
+		prev, prevEnd = call, last
+	}
+}
+
+// splitCall splits "METHOD /path" into its method and path.
+func splitCall(call string) (method, path string) {
+	if method, path, ok := strings.Cut(strings.TrimSpace(call), " "); ok {
+		return method, strings.TrimSpace(path)
+	}
+	return "", strings.TrimSpace(call)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a testing.TB collecting failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, format)
+}
+
+func TestTransport_Concurrency(t *testing.T) {
+	tr := NewTransport()
+	tr.On("POST", "/token").Respond(200, `{}`)
+	tr.On("GET", "/items/*").Respond(200, `{}`).Delay(20 * time.Millisecond)
+	c := tr.Client()
+
+	if _, err := get(t, c, "POST", "https://api.example.com/token", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three workers fetch six items.
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				res, err := c.Get("https://api.example.com/items/" + id)
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				res.Body.Close()
+			}
+		}()
+	}
+	for _, id := range strings.Split("a b c d e f", " ") {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+
+	if n := tr.MaxConcurrent("GET", "/items/*"); n != 3 {
+		t.Errorf("Expected 3 concurrent calls, got %d", n)
+	}
+	tr.AssertMaxConcurrent(t, "GET", "/items/*", 3)
+	tr.AssertParallel(t, "GET", "/items/*", 2)
+	tr.AssertOrder(t, "POST /token", "GET /items/*")
+
+	rec := &recorder{TB: t}
+	tr.AssertMaxConcurrent(rec, "GET", "/items/*", 2)
+	tr.AssertParallel(rec, "GET", "/items/*", 4)
+	tr.AssertOrder(rec, "GET /items/*", "POST /token")
+	tr.AssertOrder(rec, "POST /token", "DELETE /token")
+	if len(rec.failures) != 4 {
+		t.Errorf("Expected 4 failed assertions, got %d: %v", len(rec.failures), rec.failures)
+	}
+}
+
+func TestTransport_OrderOverlapping(t *testing.T) {
+	tr := NewTransport()
+	tr.On("GET", "/slow").Respond(200, `{}`).Delay(30 * time.Millisecond)
+	tr.On("GET", "/fast").Respond(200, `{}`)
+	c := tr.Client()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		get(t, c, "GET", "https://api.example.com/slow", "")
+	}()
+	time.Sleep(5 * time.Millisecond)
+	if _, err := get(t, c, "GET", "https://api.example.com/fast", ""); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	rec := &recorder{TB: t}
+	tr.AssertOrder(rec, "GET /slow", "GET /fast")
+	if len(rec.failures) != 1 {
+		t.Errorf("Expected overlapping calls to fail the order, got %v", rec.failures)
+	}
+	tr.AssertParallel(t, "", "*", 2)
+
+	tr.Reset()
+	if n := tr.MaxConcurrent("", "*"); n != 0 {
+		t.Errorf("Expected no calls after Reset, got %d", n)
+	}
+}
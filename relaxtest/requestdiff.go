@@ -0,0 +1,254 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// DefaultDiffIgnoreHeaders are the headers a Recorder does not compare in
+// ModeDiff by default, because they change from run to run.
+var DefaultDiffIgnoreHeaders = []string{
+	"User-Agent",
+	"Date",
+	"Idempotency-Key",
+	"X-Request-Id",
+	"Traceparent",
+	"Tracestate",
+}
+
+// RequestDiff is how a request sent in ModeDiff differs from the recorded
+// request it was paired with.
+type RequestDiff struct {
+	Method string
+	URL    string
+
+	// Index is the position of the recorded interaction in the cassette,
+	// or -1 for an unexpected request.
+	Index int
+
+	// Unexpected reports a request for which the cassette holds no
+	// interaction with the same method and path.
+	Unexpected bool
+
+	// Missing reports a recorded interaction no request was paired with.
+	Missing bool
+
+	Changes []Change
+}
+
+// Change is a difference in one header, query parameter or JSON body
+// field. Field is e.g. "header Accept", "query page" or "body .items[0].id";
+// Recorded and Sent are empty when the field is absent. Body fields are
+// shown as JSON.
+type Change struct {
+	Field    string
+	Recorded string
+	Sent     string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Field, c.Recorded, c.Sent)
+}
+
+func (d RequestDiff) String() string {
+	switch {
+	case d.Unexpected:
+		return fmt.Sprintf("%s %s: not recorded", d.Method, d.URL)
+	case d.Missing:
+		return fmt.Sprintf("%s %s: recorded but not sent", d.Method, d.URL)
+	}
+	lines := []string{fmt.Sprintf("%s %s:", d.Method, d.URL)}
+	for _, c := range d.Changes {
+		lines = append(lines, "  "+c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diffs returns how the requests sent in ModeDiff differ from the
+// cassette, in the order they were sent, followed by the recorded
+// interactions no request was paired with.
+func (r *Recorder) Diffs() []RequestDiff {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	diffs := append([]RequestDiff(nil), r.diffs...)
+	for i, in := range r.cassette.Interactions {
+		if !in.used {
+			diffs = append(diffs, RequestDiff{Method: in.Request.Method, URL: in.Request.URL, Index: i, Missing: true})
+		}
+	}
+	return diffs
+}
+
+// AssertNoDiff fails tb with every difference found in ModeDiff.
+func (r *Recorder) AssertNoDiff(tb testing.TB) {
+	tb.Helper()
+	for _, d := range r.Diffs() {
+		tb.Errorf("Request differs from the cassette: %s", d)
+	}
+}
+
+// compare pairs req with the first unused interaction of the same method
+// and path, records how they differ and answers with the recorded
+// response.
+func (r *Recorder) compare(req *http.Request, sent *RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := withoutQuery(sent.URL)
+	for i := range r.cassette.Interactions {
+		in := &r.cassette.Interactions[i]
+		if in.used || in.Request.Method != sent.Method || withoutQuery(in.Request.URL) != path {
+			continue
+		}
+		in.used = true
+		if changes := r.changes(&in.Request, sent); len(changes) > 0 {
+			r.diffs = append(r.diffs, RequestDiff{Method: sent.Method, URL: sent.URL, Index: i, Changes: changes})
+		}
+		return in.Response.response(req), nil
+	}
+	r.diffs = append(r.diffs, RequestDiff{Method: sent.Method, URL: sent.URL, Index: -1, Unexpected: true})
+	return nil, fmt.Errorf("relaxtest: no recorded interaction for %s %s", sent.Method, sent.URL)
+}
+
+func (r *Recorder) diffIgnoreHeaders() []string {
+	if r.DiffIgnoreHeaders != nil {
+		return r.DiffIgnoreHeaders
+	}
+	return DefaultDiffIgnoreHeaders
+}
+
+// changes lists the differences between the recorded and sent requests:
+// headers, then query parameters, then the body.
+func (r *Recorder) changes(recorded, sent *RecordedRequest) []Change {
+	var changes []Change
+
+	ignored := map[string]bool{}
+	for _, name := range r.diffIgnoreHeaders() {
+		ignored[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range unionKeys(recorded.Header, sent.Header) {
+		if ignored[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		was, is := strings.Join(recorded.Header.Values(name), ", "), strings.Join(sent.Header.Values(name), ", ")
+		if was != is {
+			changes = append(changes, Change{Field: "header " + http.CanonicalHeaderKey(name), Recorded: was, Sent: is})
+		}
+	}
+
+	wasQuery, isQuery := queryOf(recorded.URL), queryOf(sent.URL)
+	for _, name := range unionKeys(wasQuery, isQuery) {
+		was, is := strings.Join(wasQuery[name], ", "), strings.Join(isQuery[name], ", ")
+		if was != is {
+			changes = append(changes, Change{Field: "query " + name, Recorded: was, Sent: is})
+		}
+	}
+
+	if bytes.Equal(recorded.Body, sent.Body) {
+		return changes
+	}
+	var was, is interface{}
+	if json.Unmarshal(recorded.Body, &was) != nil || json.Unmarshal(sent.Body, &is) != nil {
+		return append(changes, Change{Field: "body", Recorded: string(recorded.Body), Sent: string(sent.Body)})
+	}
+	return diffJSON(changes, "body ", was, is)
+}
+
+// diffJSON appends the differences between two decoded JSON documents,
+// field by field.
+func diffJSON(changes []Change, path string, was, is interface{}) []Change {
+	switch w := was.(type) {
+	case map[string]interface{}:
+		i, ok := is.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range unionKeys(w, i) {
+			wv, wok := w[k]
+			iv, iok := i[k]
+			if !wok || !iok {
+				changes = append(changes, Change{Field: path + "." + k, Recorded: jsonString(wv, wok), Sent: jsonString(iv, iok)})
+				continue
+			}
+			changes = diffJSON(changes, path+"."+k, wv, iv)
+		}
+		return changes
+	case []interface{}:
+		i, ok := is.([]interface{})
+		if !ok {
+			break
+		}
+		for n := 0; n < len(w) || n < len(i); n++ {
+			p := fmt.Sprintf("%s[%d]", path, n)
+			if n >= len(w) || n >= len(i) {
+				changes = append(changes, Change{Field: p, Recorded: jsonIndex(w, n), Sent: jsonIndex(i, n)})
+				continue
+			}
+			changes = diffJSON(changes, p, w[n], i[n])
+		}
+		return changes
+	}
+	if !reflect.DeepEqual(was, is) {
+		changes = append(changes, Change{Field: strings.TrimSpace(path), Recorded: jsonString(was, true), Sent: jsonString(is, true)})
+	}
+	return changes
+}
+
+func jsonString(v interface{}, ok bool) string {
+	if !ok {
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func jsonIndex(a []interface{}, n int) string {
+	if n >= len(a) {
+		return ""
+	}
+	return jsonString(a[n], true)
+}
+
+// unionKeys returns the keys of a and b, sorted.
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func withoutQuery(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.RawQuery = ""
+	return u.String()
+}
+
+func queryOf(raw string) url.Values {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	return u.Query()
+}
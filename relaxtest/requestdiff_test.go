@@ -0,0 +1,92 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder_Diff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.json")
+	cassette := &Cassette{Interactions: []Interaction{
+		{
+			Request: RecordedRequest{
+				Method: "POST",
+				URL:    "https://api.example.com/orders?dry_run=false",
+				Header: http.Header{"Content-Type": {"application/json"}, "User-Agent": {"relax/1"}},
+				Body:   Body(`{"sku":"A1","qty":2,"tags":["x"]}`),
+			},
+			Response: RecordedResponse{Status: 201, Body: Body(`{"id":7}`)},
+		},
+		{
+			Request:  RecordedRequest{Method: "GET", URL: "https://api.example.com/orders/7"},
+			Response: RecordedResponse{Status: 200, Body: Body(`{"id":7}`)},
+		},
+		{
+			Request:  RecordedRequest{Method: "DELETE", URL: "https://api.example.com/orders/7"},
+			Response: RecordedResponse{Status: 204},
+		},
+	}}
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := NewRecorder(path, ModeDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := rec.Client()
+
+	req, _ := http.NewRequest("POST", "https://api.example.com/orders?dry_run=true", strings.NewReader(`{"qty":2,"sku":"A1","tags":["x","y"],"note":"hi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "relax/2")
+	req.Header.Set("X-Trace", "1")
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 201 {
+		t.Errorf("Expected the recorded response, got %d", res.StatusCode)
+	}
+	if _, err := c.Get("https://api.example.com/orders/7"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("https://api.example.com/customers/1"); err == nil {
+		t.Error("Expected an unrecorded request to fail")
+	}
+
+	diffs := rec.Diffs()
+	if len(diffs) != 3 {
+		t.Fatalf("Expected 3 diffs, got %v", diffs)
+	}
+	var got []string
+	for _, c := range diffs[0].Changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		`header X-Trace: "" -> "1"`,
+		`query dry_run: "false" -> "true"`,
+		`body .note: "" -> "\"hi\""`,
+		`body .tags[1]: "" -> "\"y\""`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected changes\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if !diffs[1].Unexpected || diffs[1].Index != -1 || !strings.HasSuffix(diffs[1].URL, "/customers/1") {
+		t.Errorf("Expected an unexpected request, got %+v", diffs[1])
+	}
+	if !diffs[2].Missing || diffs[2].Method != "DELETE" || diffs[2].Index != 2 {
+		t.Errorf("Expected the unsent DELETE to be missing, got %+v", diffs[2])
+	}
+
+	ft := &fakeTB{TB: t}
+	rec.AssertNoDiff(ft)
+	if !ft.failed {
+		t.Error("Expected AssertNoDiff to fail")
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Scenario is a scripted conversation, usually loaded from a JSON file so
+// flows can be written without Go code:
+//
+//	{
+//	  "name": "checkout",
+//	  "state": "empty",
+//	  "steps": [
+//	    {"state": "empty", "request": {"method": "GET", "path": "/cart"},
+//	     "response": {"status": 200, "json": {"items": []}}},
+//	    {"state": "empty", "request": {"method": "POST", "path": "/cart/items", "json": {"sku": "A1"}},
+//	     "response": {"status": 201}, "next": "filled"},
+//	    {"state": "filled", "request": {"method": "GET", "path": "/cart"},
+//	     "response": {"status": 200, "json": {"items": [{"sku": "A1"}]}}}
+//	  ]
+//	}
+//
+// A step answers a request when the scenario is in its state, or always if
+// it has none, and the request matches; the scenario then moves to the
+// step's next state. When Ordered is set the steps must instead be
+// requested one after another, each once. Requests no step answers fall
+// through to the routes registered with On.
+type Scenario struct {
+	Name string `json:"name"`
+
+	// State is the current state, initially the one the file starts in.
+	State string `json:"state"`
+
+	Ordered bool   `json:"ordered"`
+	Steps   []Step `json:"steps"`
+
+	initial string
+	next    int // the next step of an ordered scenario
+}
+
+// Step is one expected request of a Scenario and its scripted response.
+type Step struct {
+	State    string      `json:"state"`
+	Request  Expectation `json:"request"`
+	Response Reply       `json:"response"`
+	Next     string      `json:"next"`
+
+	// Times limits how often an unordered step answers. Zero means no
+	// limit.
+	Times int `json:"times"`
+
+	calls int
+}
+
+// Expectation matches requests. Empty fields match anything.
+type Expectation struct {
+	Method string `json:"method"`
+
+	// Path is matched like the path given to On.
+	Path    string            `json:"path"`
+	Query   map[string]string `json:"query"`
+	Headers map[string]string `json:"headers"`
+
+	// JSON, when set, must equal the request body as JSON.
+	JSON json.RawMessage `json:"json"`
+}
+
+// Reply is a scripted response.
+type Reply struct {
+	// Status defaults to 200.
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+
+	// Body is sent as is; JSON, when set, is sent instead with an
+	// application/json Content-Type.
+	Body string          `json:"body"`
+	JSON json.RawMessage `json:"json"`
+
+	// Delay, like "250ms", delays the response.
+	Delay string `json:"delay"`
+
+	// Error, when set, fails the request with this message as a network
+	// error would.
+	Error string `json:"error"`
+}
+
+// LoadScenario reads a Scenario from a JSON file and adds it to t.
+func (t *Transport) LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := ReadScenario(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	t.AddScenario(s)
+	return s, nil
+}
+
+// ReadScenario decodes and validates a Scenario from r.
+func ReadScenario(r io.Reader) (*Scenario, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var s Scenario
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	for i := range s.Steps {
+		if _, err := s.Steps[i].Response.build(); err != nil {
+			return nil, fmt.Errorf("step %d: %s", i, err)
+		}
+	}
+	return &s, nil
+}
+
+// AddScenario adds s to t. Scenarios are tried in the order they were
+// added, before any route.
+func (t *Transport) AddScenario(s *Scenario) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s.initial = s.State
+	t.scenarios = append(t.scenarios, s)
+}
+
+// AssertScenarios fails tb if an ordered scenario has steps left.
+func (t *Transport) AssertScenarios(tb testing.TB) {
+	tb.Helper()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range t.scenarios {
+		if s.Ordered && s.next < len(s.Steps) {
+			step := s.Steps[s.next]
+			tb.Errorf("Scenario %s: expected %s %s (step %d of %d)", s.Name, step.Request.Method, step.Request.Path, s.next+1, len(s.Steps))
+		}
+	}
+}
+
+// CurrentState returns the state of the scenario called name.
+func (t *Transport) CurrentState(name string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range t.scenarios {
+		if s.Name == name {
+			return s.State
+		}
+	}
+	return ""
+}
+
+func (s *Scenario) rewind() {
+	s.State = s.initial
+	s.next = 0
+	for i := range s.Steps {
+		s.Steps[i].calls = 0
+	}
+}
+
+// answer returns the response of the step answering req, if any. t.mu must
+// be held.
+func (s *Scenario) answer(req *http.Request, body []byte) *response {
+	if s.Ordered {
+		if s.next >= len(s.Steps) || !s.Steps[s.next].matches(s.State, req, body) {
+			return nil
+		}
+		s.next++
+		return s.take(&s.Steps[s.next-1])
+	}
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if (step.Times == 0 || step.calls < step.Times) && step.matches(s.State, req, body) {
+			return s.take(step)
+		}
+	}
+	return nil
+}
+
+func (s *Scenario) take(step *Step) *response {
+	step.calls++
+	if step.Next != "" {
+		s.State = step.Next
+	}
+	resp, _ := step.Response.build()
+	return resp
+}
+
+func (step *Step) matches(state string, req *http.Request, body []byte) bool {
+	if step.State != "" && step.State != state {
+		return false
+	}
+	e := &step.Request
+	route := &Route{method: strings.ToUpper(e.Method), path: e.Path}
+	if e.Path == "" {
+		route.path = "*"
+	}
+	if !route.match(req) {
+		return false
+	}
+	q := req.URL.Query()
+	for k, v := range e.Query {
+		if q.Get(k) != v {
+			return false
+		}
+	}
+	for k, v := range e.Headers {
+		if req.Header.Get(k) != v {
+			return false
+		}
+	}
+	if len(e.JSON) > 0 {
+		var got, want interface{}
+		if json.Unmarshal(body, &got) != nil || json.Unmarshal(e.JSON, &want) != nil {
+			return false
+		}
+		return reflect.DeepEqual(got, want)
+	}
+	return true
+}
+
+func (r *Reply) build() (*response, error) {
+	resp := &response{status: r.Status, header: http.Header{}, body: []byte(r.Body)}
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	if len(r.JSON) > 0 {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, r.JSON); err != nil {
+			return nil, err
+		}
+		resp.body = buf.Bytes()
+		resp.header.Set("Content-Type", "application/json")
+	}
+	for k, v := range r.Headers {
+		resp.header.Set(k, v)
+	}
+	if r.Delay != "" {
+		d, err := time.ParseDuration(r.Delay)
+		if err != nil {
+			return nil, err
+		}
+		resp.delay = d
+	}
+	if r.Error != "" {
+		resp.err = fmt.Errorf("relaxtest: %s", r.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTransport_LoadScenario(t *testing.T) {
+	tr := NewTransport()
+	if _, err := tr.LoadScenario("testdata/checkout.json"); err != nil {
+		t.Fatal(err)
+	}
+	c := tr.Client()
+
+	body := func(res *http.Response) string {
+		b, _ := ioutil.ReadAll(res.Body)
+		return string(b)
+	}
+
+	res, err := get(t, c, "GET", "https://api.example.com/cart", "")
+	if err != nil || body(res) != `{"items":[]}` {
+		t.Fatalf("Expected an empty cart, got %v", err)
+	}
+
+	// The body must match for the step to answer.
+	if _, err := get(t, c, "POST", "https://api.example.com/cart/items", `{"sku":"B2"}`); err == nil {
+		t.Error("Expected an unexpected body to find no step")
+	}
+	res, err = get(t, c, "POST", "https://api.example.com/cart/items", `{"sku": "A1"}`)
+	if err != nil || res.StatusCode != 201 || res.Header.Get("Location") != "/cart/items/1" {
+		t.Fatalf("Unexpected response to adding an item: %v", err)
+	}
+	if s := tr.CurrentState("checkout"); s != "filled" {
+		t.Errorf("Expected state filled, got %q", s)
+	}
+
+	res, _ = get(t, c, "GET", "https://api.example.com/cart", "")
+	if got := body(res); got != `{"items":[{"sku":"A1"}]}` {
+		t.Errorf("Expected the filled cart, got %s", got)
+	}
+
+	tr.Reset()
+	res, _ = get(t, c, "GET", "https://api.example.com/cart", "")
+	if got := body(res); got != `{"items":[]}` {
+		t.Errorf("Expected Reset to rewind the scenario, got %s", got)
+	}
+}
+
+func TestTransport_OrderedScenario(t *testing.T) {
+	s, err := ReadScenario(strings.NewReader(`{
+		"name": "login",
+		"ordered": true,
+		"steps": [
+			{"request": {"method": "POST", "path": "/login"}, "response": {"status": 200, "body": "token"}},
+			{"request": {"method": "GET", "path": "/me", "headers": {"Authorization": "Bearer token"}}, "response": {"status": 200}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTransport()
+	tr.AddScenario(s)
+	tr.On("GET", "/me").Respond(401, "")
+	c := tr.Client()
+
+	// Out of order, the request falls through to the route.
+	res, err := get(t, c, "GET", "https://api.example.com/me", "")
+	if err != nil || res.StatusCode != 401 {
+		t.Fatalf("Expected the route to answer, got %v", err)
+	}
+	get(t, c, "POST", "https://api.example.com/login", "")
+
+	ft := &fakeTB{TB: t}
+	tr.AssertScenarios(ft)
+	if !ft.failed {
+		t.Error("Expected AssertScenarios to report the missing step")
+	}
+
+	req, _ := http.NewRequest("GET", "https://api.example.com/me", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	if res, err := c.Do(req); err != nil || res.StatusCode != 200 {
+		t.Fatalf("Expected the second step to answer, got %v", err)
+	}
+	tr.AssertScenarios(t)
+}
+
+func TestReadScenarioErrors(t *testing.T) {
+	for _, doc := range []string{
+		`{"steps": [{"response": {"delay": "soon"}}]}`,
+		`{"steps": [{"reponse": {}}]}`,
+	} {
+		if _, err := ReadScenario(strings.NewReader(doc)); err == nil {
+			t.Errorf("Expected an error for %s", doc)
+		}
+	}
+}
+
+// fakeTB records failures instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	failed bool
+}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.failed = true
+}
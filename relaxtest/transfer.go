@@ -0,0 +1,430 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TransferServer is an HTTP server for testing uploads and downloads end
+// to end. It serves files with Range support under /files/, accepts tus
+// resumable uploads at /uploads and multipart forms at /multipart, and
+// records every protocol violation it sees, such as a chunk sent at the
+// wrong offset or a multipart body with a broken boundary:
+//
+//	srv := relaxtest.NewTransferServer()
+//	defer srv.Close()
+//	srv.AddFile("report.csv", data)
+//	srv.InterruptDownload("report.csv", 100)
+//	... download srv.URL+"/files/report.csv" with resumption ...
+//	srv.AssertNoViolations(t)
+//
+// Files are served with their SHA-256 in the X-Checksum-Sha256 header.
+type TransferServer struct {
+	*httptest.Server
+
+	// NoRanges makes file downloads ignore Range headers and answer with
+	// the whole file, as some servers do.
+	NoRanges bool
+
+	mu          sync.Mutex
+	files       map[string][]byte
+	interrupts  map[string]int64
+	uploads     map[string]*TusUpload
+	nextUpload  int
+	failPatches int
+	parts       []MultipartPart
+	violations  []string
+}
+
+// TusUpload is an upload received by a TransferServer.
+type TusUpload struct {
+	ID       string
+	Length   int64
+	Metadata map[string]string
+	Data     []byte
+
+	// Patches counts the chunks accepted.
+	Patches int
+}
+
+// Complete reports whether all Length bytes were received.
+func (u *TusUpload) Complete() bool {
+	return int64(len(u.Data)) == u.Length
+}
+
+// MultipartPart is a part of a multipart form received by a
+// TransferServer.
+type MultipartPart struct {
+	FormName    string `json:"name"`
+	FileName    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+	Body        []byte `json:"-"`
+}
+
+// NewTransferServer starts a TransferServer. Close it when done.
+func NewTransferServer() *TransferServer {
+	s := &TransferServer{
+		files:      map[string][]byte{},
+		interrupts: map[string]int64{},
+		uploads:    map[string]*TusUpload{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/", s.serveFile)
+	mux.HandleFunc("/uploads", s.createUpload)
+	mux.HandleFunc("/uploads/", s.serveUpload)
+	mux.HandleFunc("/multipart", s.receiveMultipart)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SHA256 returns the hex SHA-256 digest of data, as checked by the
+// transfer features.
+func SHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AddFile serves data at /files/name and returns its URL.
+func (s *TransferServer) AddFile(name string, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = data
+	return s.URL + "/files/" + name
+}
+
+// InterruptDownload makes the next download of name drop the connection
+// after sending after bytes of the body.
+func (s *TransferServer) InterruptDownload(name string, after int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interrupts[name] = after
+}
+
+// FailUploadChunks makes the next n upload chunks fail with 503 Service
+// Unavailable without storing them.
+func (s *TransferServer) FailUploadChunks(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failPatches = n
+}
+
+// Upload returns a copy of the upload with the URL u, or nil.
+func (s *TransferServer) Upload(u string) *TusUpload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	up, ok := s.uploads[u[strings.LastIndex(u, "/")+1:]]
+	if !ok {
+		return nil
+	}
+	cp := *up
+	cp.Data = append([]byte(nil), up.Data...)
+	return &cp
+}
+
+// ForgetUpload drops the upload with the URL u, as servers expiring
+// incomplete uploads do.
+func (s *TransferServer) ForgetUpload(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, u[strings.LastIndex(u, "/")+1:])
+}
+
+// Parts returns the parts of every multipart form received, in order.
+func (s *TransferServer) Parts() []MultipartPart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MultipartPart(nil), s.parts...)
+}
+
+// Violations returns the protocol violations seen so far.
+func (s *TransferServer) Violations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.violations...)
+}
+
+// AssertNoViolations fails tb with every protocol violation seen.
+func (s *TransferServer) AssertNoViolations(tb testing.TB) {
+	tb.Helper()
+	for _, v := range s.Violations() {
+		tb.Errorf("Transfer protocol violation: %s", v)
+	}
+}
+
+// violation records a violation and answers with status.
+func (s *TransferServer) violation(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	s.mu.Lock()
+	s.violations = append(s.violations, msg)
+	s.mu.Unlock()
+	http.Error(w, msg, status)
+}
+
+func (s *TransferServer) serveFile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/files/")
+	s.mu.Lock()
+	data, ok := s.files[name]
+	cut, interrupt := s.interrupts[name]
+	if interrupt && r.Method == http.MethodGet {
+		delete(s.interrupts, name)
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("X-Checksum-Sha256", SHA256(data))
+	h.Set("Content-Type", "application/octet-stream")
+
+	status := http.StatusOK
+	body := data
+	if rng := r.Header.Get("Range"); rng != "" && !s.NoRanges {
+		first, last, ok := parseRange(rng, int64(len(data)))
+		if !ok {
+			h.Set("Content-Range", fmt.Sprintf("bytes */%d", len(data)))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		status = http.StatusPartialContent
+		body = data[first : last+1]
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(data)))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if interrupt && r.Method == http.MethodGet && cut < int64(len(body)) {
+		w.Write(body[:cut])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	w.Write(body)
+}
+
+// parseRange parses a single byte range of a body of size bytes.
+func parseRange(rng string, size int64) (first, last int64, ok bool) {
+	spec := strings.TrimPrefix(rng, "bytes=")
+	if spec == rng || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	from, to, _ := strings.Cut(spec, "-")
+	var err error
+	if from == "" {
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, size > 0
+	}
+	if first, err = strconv.ParseInt(from, 10, 64); err != nil || first >= size {
+		return 0, 0, false
+	}
+	last = size - 1
+	if to != "" {
+		if last, err = strconv.ParseInt(to, 10, 64); err != nil || last < first {
+			return 0, 0, false
+		}
+		if last >= size {
+			last = size - 1
+		}
+	}
+	return first, last, true
+}
+
+func (s *TransferServer) createUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.violation(w, http.StatusMethodNotAllowed, "%s /uploads: uploads are created with POST", r.Method)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") == "" {
+		s.violation(w, http.StatusPreconditionFailed, "POST /uploads: missing Tus-Resumable")
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		s.violation(w, http.StatusBadRequest, "POST /uploads: invalid Upload-Length %q", r.Header.Get("Upload-Length"))
+		return
+	}
+	meta, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		s.violation(w, http.StatusBadRequest, "POST /uploads: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.nextUpload++
+	id := strconv.Itoa(s.nextUpload)
+	s.uploads[id] = &TusUpload{ID: id, Length: length, Metadata: meta}
+	s.mu.Unlock()
+
+	w.Header().Set("Location", s.URL+"/uploads/"+id)
+	w.Header().Set("Tus-Resumable", "1.0.0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func parseUploadMetadata(h string) (map[string]string, error) {
+	meta := map[string]string{}
+	if h == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(h, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		v, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q", key)
+		}
+		meta[key] = string(v)
+	}
+	return meta, nil
+}
+
+func (s *TransferServer) serveUpload(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/uploads/")
+	where := r.Method + " " + r.URL.Path
+	if r.Header.Get("Tus-Resumable") == "" {
+		s.violation(w, http.StatusPreconditionFailed, "%s: missing Tus-Resumable", where)
+		return
+	}
+
+	s.mu.Lock()
+	up, ok := s.uploads[id]
+	var offset int64
+	if ok {
+		offset = int64(len(up.Data))
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Tus-Resumable", "1.0.0")
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(up.Length, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		s.patchUpload(w, r, up, offset, where)
+	default:
+		s.violation(w, http.StatusMethodNotAllowed, "%s: uploads accept HEAD and PATCH", where)
+	}
+}
+
+func (s *TransferServer) patchUpload(w http.ResponseWriter, r *http.Request, up *TusUpload, offset int64, where string) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/offset+octet-stream" {
+		s.violation(w, http.StatusUnsupportedMediaType, "%s: Content-Type %q", where, ct)
+		return
+	}
+	sent, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || sent != offset {
+		s.violation(w, http.StatusConflict, "%s: Upload-Offset %q, the server holds %d bytes", where, r.Header.Get("Upload-Offset"), offset)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if offset+int64(len(data)) > up.Length {
+		s.violation(w, http.StatusRequestEntityTooLarge, "%s: chunk of %d bytes at %d overruns Upload-Length %d", where, len(data), offset, up.Length)
+		return
+	}
+
+	s.mu.Lock()
+	if s.failPatches > 0 {
+		s.failPatches--
+		s.mu.Unlock()
+		http.Error(w, "injected failure", http.StatusServiceUnavailable)
+		return
+	}
+	if held := int64(len(up.Data)); held != offset {
+		s.mu.Unlock()
+		s.violation(w, http.StatusConflict, "%s: concurrent chunks at offset %d", where, offset)
+		return
+	}
+	up.Data = append(up.Data, data...)
+	up.Patches++
+	offset = int64(len(up.Data))
+	s.mu.Unlock()
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TransferServer) receiveMultipart(w http.ResponseWriter, r *http.Request) {
+	where := r.Method + " /multipart"
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		s.violation(w, http.StatusUnsupportedMediaType, "%s: Content-Type %q is not multipart", where, r.Header.Get("Content-Type"))
+		return
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		s.violation(w, http.StatusBadRequest, "%s: missing boundary", where)
+		return
+	}
+
+	var parts []MultipartPart
+	mr := multipart.NewReader(r.Body, boundary)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.violation(w, http.StatusBadRequest, "%s: part %d: %s", where, len(parts), err)
+			return
+		}
+		body, err := ioutil.ReadAll(p)
+		if err != nil {
+			s.violation(w, http.StatusBadRequest, "%s: part %d: %s", where, len(parts), err)
+			return
+		}
+		parts = append(parts, MultipartPart{
+			FormName:    p.FormName(),
+			FileName:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Size:        len(body),
+			SHA256:      SHA256(body),
+			Body:        body,
+		})
+	}
+	if len(parts) == 0 {
+		s.violation(w, http.StatusBadRequest, "%s: no parts", where)
+		return
+	}
+
+	s.mu.Lock()
+	s.parts = append(s.parts, parts...)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(parts)
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTransferServer_Download(t *testing.T) {
+	srv := NewTransferServer()
+	defer srv.Close()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	u := srv.AddFile("big.bin", data)
+	srv.InterruptDownload("big.bin", 300)
+
+	res, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err == nil || len(got) != 300 {
+		t.Fatalf("Expected the download to break after 300 bytes, got %d: %v", len(got), err)
+	}
+	if res.Header.Get("X-Checksum-Sha256") != SHA256(data) {
+		t.Errorf("Expected the checksum header, got %q", res.Header.Get("X-Checksum-Sha256"))
+	}
+
+	req, _ := http.NewRequest("GET", u, nil)
+	req.Header.Set("Range", "bytes=300-")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent || res.Header.Get("Content-Range") != "bytes 300-999/1000" {
+		t.Errorf("Expected a partial response, got %d %q", res.StatusCode, res.Header.Get("Content-Range"))
+	}
+	if SHA256(append(got, rest...)) != SHA256(data) {
+		t.Error("Expected the resumed download to be complete")
+	}
+
+	req.Header.Set("Range", "bytes=1000-")
+	if res, _ := http.DefaultClient.Do(req); res.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("Expected 416 past the end, got %d", res.StatusCode)
+	}
+	srv.NoRanges = true
+	req.Header.Set("Range", "bytes=300-")
+	if res, _ := http.DefaultClient.Do(req); res.StatusCode != http.StatusOK || res.ContentLength != 1000 {
+		t.Errorf("Expected the whole file without range support, got %d", res.StatusCode)
+	}
+	srv.AssertNoViolations(t)
+}
+
+func TestTransferServer_Upload(t *testing.T) {
+	srv := NewTransferServer()
+	defer srv.Close()
+	tus := func(method, u string, header map[string]string, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, u, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+	chunk := func(offset string) map[string]string {
+		return map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset}
+	}
+
+	res := tus("POST", srv.URL+"/uploads", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename YS50eHQ="}, "")
+	loc := res.Header.Get("Location")
+	if res.StatusCode != http.StatusCreated || loc == "" {
+		t.Fatalf("Expected the upload to be created, got %d", res.StatusCode)
+	}
+	tus("PATCH", loc, chunk("0"), "hello")
+	srv.FailUploadChunks(1)
+	if res := tus("PATCH", loc, chunk("5"), "world"); res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected an injected failure, got %d", res.StatusCode)
+	}
+	if res := tus("HEAD", loc, nil, ""); res.Header.Get("Upload-Offset") != "5" {
+		t.Errorf("Expected to resume at 5, got %q", res.Header.Get("Upload-Offset"))
+	}
+	tus("PATCH", loc, chunk("5"), "world")
+
+	up := srv.Upload(loc)
+	if !up.Complete() || string(up.Data) != "helloworld" || up.Patches != 2 || up.Metadata["filename"] != "a.txt" {
+		t.Errorf("Unexpected upload %+v", up)
+	}
+	srv.AssertNoViolations(t)
+
+	if res := tus("PATCH", loc, chunk("3"), "x"); res.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a conflict at the wrong offset, got %d", res.StatusCode)
+	}
+	srv.ForgetUpload(loc)
+	if res := tus("HEAD", loc, nil, ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a forgotten upload to be gone, got %d", res.StatusCode)
+	}
+	if v := srv.Violations(); len(v) != 1 || !strings.Contains(v[0], "Upload-Offset \"3\"") {
+		t.Errorf("Expected the wrong offset to be a violation, got %v", v)
+	}
+}
+
+func TestTransferServer_Multipart(t *testing.T) {
+	srv := NewTransferServer()
+	defer srv.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("file", "report.csv")
+	fw.Write([]byte("a,b\n1,2\n"))
+	mw.Close()
+	res, err := http.Post(srv.URL+"/multipart", mw.FormDataContentType(), bytes.NewReader(body.Bytes()))
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the form to be accepted, got %v", err)
+	}
+	parts := srv.Parts()
+	if len(parts) != 2 || parts[1].FileName != "report.csv" || parts[1].SHA256 != SHA256([]byte("a,b\n1,2\n")) {
+		t.Errorf("Unexpected parts %+v", parts)
+	}
+	srv.AssertNoViolations(t)
+
+	truncated := body.Bytes()[:body.Len()-10]
+	res, _ = http.Post(srv.URL+"/multipart", mw.FormDataContentType(), bytes.NewReader(truncated))
+	if res.StatusCode != http.StatusBadRequest || len(srv.Violations()) != 1 {
+		t.Errorf("Expected a broken boundary to be a violation, got %d %v", res.StatusCode, srv.Violations())
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package relaxtest provides a fake HTTP transport for testing code built on
+// relax without running a server. Register routes with canned responses or
+// injected errors, hand the client to relax.WithHTTPClient, then assert on
+// the captured requests:
+//
+//	tr := relaxtest.NewTransport()
+//	tr.On("GET", "/api/users/42").RespondJSON(200, User{Name: "Ann"})
+//	c, _ := relax.NewClient("https://api.example.com", key, relax.WithHTTPClient(tr.Client()))
+//	...
+//	tr.AssertCalled(t, "GET", "/api/users/42", 1)
+//
+// AssertOrder, AssertParallel and AssertMaxConcurrent check how requests
+// overlapped, for code that batches calls or runs them from worker pools.
+//
+// Longer, stateful flows can be scripted in JSON files and loaded with
+// LoadScenario; see Scenario. Conversations with a real API can be recorded
+// to cassettes and replayed offline with a Recorder, which in ModeDiff also
+// reports how the requests sent differ from the recorded ones.
+//
+// Uploads and downloads are tested end to end against a TransferServer.
+package relaxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Request is a request captured by a Transport.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+
+	// started and ended order the request among the others; ended is zero
+	// while it is in flight.
+	started, ended int
+}
+
+// JSON decodes the captured body into v.
+func (r *Request) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+	err    error
+	delay  time.Duration
+}
+
+// Route matches requests and answers them with its responses in order, the
+// last one repeating.
+type Route struct {
+	method  string
+	path    string
+	matches []func(*http.Request) bool
+	times   int
+
+	responses []response
+	calls     int
+}
+
+// Transport is a fake http.RoundTripper. It is safe for concurrent use.
+type Transport struct {
+	mu        sync.Mutex
+	routes    []*Route
+	scenarios []*Scenario
+	requests  []Request
+
+	// seq orders the starts and ends of requests; gen counts Resets.
+	seq int
+	gen int
+}
+
+// NewTransport returns a Transport with no routes. Unmatched requests fail
+// with an error naming the request.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// Client returns an http.Client sending through t.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// On registers a route for method and path. An empty method or "*" matches
+// any method; a path ending in "*" matches by prefix. Routes are tried in
+// the order they were registered.
+func (t *Transport) On(method, path string) *Route {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := &Route{method: strings.ToUpper(method), path: path}
+	t.routes = append(t.routes, r)
+	return r
+}
+
+// WithQuery restricts the route to requests whose query has key=value.
+func (r *Route) WithQuery(key, value string) *Route {
+	return r.Match(func(req *http.Request) bool {
+		for _, v := range req.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithHeader restricts the route to requests carrying the header value.
+func (r *Route) WithHeader(key, value string) *Route {
+	return r.Match(func(req *http.Request) bool {
+		for _, v := range req.Header.Values(key) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Match restricts the route to requests for which fn returns true.
+func (r *Route) Match(fn func(*http.Request) bool) *Route {
+	r.matches = append(r.matches, fn)
+	return r
+}
+
+// Times limits the route to n requests, after which it no longer matches.
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Respond adds a response with the given status and body.
+func (r *Route) Respond(status int, body string) *Route {
+	r.responses = append(r.responses, response{status: status, header: http.Header{}, body: []byte(body)})
+	return r
+}
+
+// RespondJSON adds a response with v encoded as JSON. It panics if v cannot
+// be encoded.
+func (r *Route) RespondJSON(status int, v interface{}) *Route {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("relaxtest: could not encode response: %s", err))
+	}
+	r.responses = append(r.responses, response{
+		status: status,
+		header: http.Header{"Content-Type": {"application/json"}},
+		body:   body,
+	})
+	return r
+}
+
+// RespondError adds a response failing the request with err, as a network
+// error would.
+func (r *Route) RespondError(err error) *Route {
+	r.responses = append(r.responses, response{err: err})
+	return r
+}
+
+// Header sets a header on the most recently added response.
+func (r *Route) Header(key, value string) *Route {
+	if n := len(r.responses); n > 0 && r.responses[n-1].header != nil {
+		r.responses[n-1].header.Set(key, value)
+	}
+	return r
+}
+
+// Delay delays the most recently added response by d, or until the request
+// context is done.
+func (r *Route) Delay(d time.Duration) *Route {
+	if n := len(r.responses); n > 0 {
+		r.responses[n-1].delay = d
+	}
+	return r
+}
+
+func (r *Route) match(req *http.Request) bool {
+	if r.times > 0 && r.calls >= r.times {
+		return false
+	}
+	if r.method != "" && r.method != "*" && r.method != req.Method {
+		return false
+	}
+	if strings.HasSuffix(r.path, "*") {
+		if !strings.HasPrefix(req.URL.Path, strings.TrimSuffix(r.path, "*")) {
+			return false
+		}
+	} else if r.path != req.URL.Path {
+		return false
+	}
+	for _, fn := range r.matches {
+		if !fn(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// RoundTrip captures req and answers it from the first matching route.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.Lock()
+	u := *req.URL
+	t.seq++
+	t.requests = append(t.requests, Request{Method: req.Method, URL: &u, Header: req.Header.Clone(), Body: body, started: t.seq})
+	defer t.finish(len(t.requests)-1, t.gen)
+
+	var resp *response
+	for _, s := range t.scenarios {
+		if resp = s.answer(req, body); resp != nil {
+			break
+		}
+	}
+	for _, r := range t.routes {
+		if resp != nil {
+			break
+		}
+		if r.match(req) {
+			if len(r.responses) == 0 {
+				resp = &response{status: http.StatusOK, header: http.Header{}}
+			} else {
+				i := r.calls
+				if i >= len(r.responses) {
+					i = len(r.responses) - 1
+				}
+				resp = &r.responses[i]
+			}
+			r.calls++
+			break
+		}
+	}
+	t.mu.Unlock()
+
+	if resp == nil {
+		return nil, fmt.Errorf("relaxtest: no route for %s %s", req.Method, req.URL)
+	}
+	if resp.delay > 0 {
+		timer := time.NewTimer(resp.delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if resp.err != nil {
+		return nil, resp.err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.status, http.StatusText(resp.status)),
+		StatusCode:    resp.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        resp.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(resp.body)),
+		ContentLength: int64(len(resp.body)),
+		Request:       req,
+	}, nil
+}
+
+// finish marks the i-th request of generation gen as done.
+func (t *Transport) finish(i, gen int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if gen == t.gen {
+		t.seq++
+		t.requests[i].ended = t.seq
+	}
+}
+
+// Requests returns the captured requests in the order they were sent.
+func (t *Transport) Requests() []Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Request(nil), t.requests...)
+}
+
+// Calls returns the captured requests matching method and path, with the
+// same matching rules as On.
+func (t *Transport) Calls(method, path string) []Request {
+	probe := &Route{method: strings.ToUpper(method), path: path}
+	var out []Request
+	for _, r := range t.Requests() {
+		if probe.match(&http.Request{Method: r.Method, URL: r.URL, Header: r.Header}) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Last returns the last captured request matching method and path, or nil.
+func (t *Transport) Last(method, path string) *Request {
+	calls := t.Calls(method, path)
+	if len(calls) == 0 {
+		return nil
+	}
+	return &calls[len(calls)-1]
+}
+
+// Reset forgets the captured requests and the call counts of all routes,
+// and rewinds all scenarios.
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.requests = nil
+	t.gen++
+	for _, r := range t.routes {
+		r.calls = 0
+	}
+	for _, s := range t.scenarios {
+		s.rewind()
+	}
+}
+
+// AssertCalled fails tb unless exactly times requests matched method and
+// path.
+func (t *Transport) AssertCalled(tb testing.TB, method, path string, times int) {
+	tb.Helper()
+	if n := len(t.Calls(method, path)); n != times {
+		tb.Errorf("Expected %d calls to %s %s, got %d", times, method, path, n)
+	}
+}
+
+// AssertHeader fails tb unless the last request matching method and path
+// carried the header value.
+func (t *Transport) AssertHeader(tb testing.TB, method, path, key, value string) {
+	tb.Helper()
+	r := t.Last(method, path)
+	if r == nil {
+		tb.Errorf("Expected a call to %s %s", method, path)
+		return
+	}
+	if got := r.Header.Get(key); got != value {
+		tb.Errorf("Expected %s %s to send %s %q, got %q", method, path, key, value, got)
+	}
+}
+
+// AssertJSON fails tb unless the body of the last request matching method
+// and path is JSON equal to want.
+func (t *Transport) AssertJSON(tb testing.TB, method, path string, want interface{}) {
+	tb.Helper()
+	r := t.Last(method, path)
+	if r == nil {
+		tb.Errorf("Expected a call to %s %s", method, path)
+		return
+	}
+
+	var got, expected interface{}
+	if err := json.Unmarshal(r.Body, &got); err != nil {
+		tb.Errorf("Expected %s %s to send JSON, got %q: %s", method, path, r.Body, err)
+		return
+	}
+	b, err := json.Marshal(want)
+	if err != nil {
+		tb.Fatalf("Could not encode expected body: %s", err)
+	}
+	json.Unmarshal(b, &expected)
+
+	if !reflect.DeepEqual(got, expected) {
+		tb.Errorf("Expected %s %s to send %s, got %s", method, path, b, r.Body)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relaxtest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, c *http.Client, method, url, body string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Test", "yes")
+	return c.Do(req)
+}
+
+func TestTransport(t *testing.T) {
+	tr := NewTransport()
+	tr.On("GET", "/users/1").RespondJSON(200, map[string]string{"name": "ann"}).Header("ETag", `"1"`)
+	tr.On("GET", "/users/*").Respond(404, "not found")
+	tr.On("POST", "/users").Respond(503, "busy").Respond(201, `{"id":2}`)
+
+	c := tr.Client()
+
+	res, err := get(t, c, "GET", "https://api.example.com/users/1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != 200 || string(body) != `{"name":"ann"}` || res.Header.Get("ETag") != `"1"` {
+		t.Errorf("Unexpected response %d %q %v", res.StatusCode, body, res.Header)
+	}
+
+	if res, _ := get(t, c, "GET", "https://api.example.com/users/9", ""); res.StatusCode != 404 {
+		t.Errorf("Expected the prefix route to answer 404, got %d", res.StatusCode)
+	}
+
+	for _, want := range []int{503, 201, 201} {
+		res, err := get(t, c, "POST", "https://api.example.com/users", `{"name":"bob"}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != want {
+			t.Errorf("Expected %d, got %d", want, res.StatusCode)
+		}
+	}
+
+	if _, err := get(t, c, "DELETE", "https://api.example.com/users", ""); err == nil || !strings.Contains(err.Error(), "no route for DELETE") {
+		t.Errorf("Expected an unmatched route error, got %v", err)
+	}
+
+	tr.AssertCalled(t, "POST", "/users", 3)
+	tr.AssertCalled(t, "GET", "/users/*", 2)
+	tr.AssertHeader(t, "POST", "/users", "X-Test", "yes")
+	tr.AssertJSON(t, "POST", "/users", map[string]string{"name": "bob"})
+
+	if n := len(tr.Requests()); n != 6 {
+		t.Errorf("Expected 6 captured requests, got %d", n)
+	}
+	tr.Reset()
+	if n := len(tr.Requests()); n != 0 {
+		t.Errorf("Expected Reset to clear requests, got %d", n)
+	}
+}
+
+func TestTransport_Matchers(t *testing.T) {
+	tr := NewTransport()
+	tr.On("GET", "/items").WithQuery("page", "2").Respond(200, "two")
+	tr.On("GET", "/items").WithHeader("X-Test", "yes").Times(1).Respond(200, "header")
+	tr.On("*", "/items").Respond(200, "any")
+
+	c := tr.Client()
+	for _, tt := range []struct{ url, want string }{
+		{"https://h/items?page=2", "two"},
+		{"https://h/items", "header"},
+		{"https://h/items", "any"},
+	} {
+		res, err := get(t, c, "GET", tt.url, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := ioutil.ReadAll(res.Body); string(body) != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.url, tt.want, body)
+		}
+	}
+}
+
+func TestTransport_ErrorsAndDelays(t *testing.T) {
+	boom := errors.New("connection reset")
+	tr := NewTransport()
+	tr.On("GET", "/boom").RespondError(boom)
+	tr.On("GET", "/slow").Respond(200, "").Delay(time.Second)
+
+	c := tr.Client()
+	if _, err := get(t, c, "GET", "https://h/boom", ""); !errors.Is(err, boom) {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://h/slow", nil)
+	if _, err := c.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the delay to honor the context, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyHeader is the header carrying idempotency keys.
+const DefaultIdempotencyHeader = "Idempotency-Key"
+
+func (c *Client) idempotencyHeader() string {
+	if c.IdempotencyHeader != "" {
+		return c.IdempotencyHeader
+	}
+	return DefaultIdempotencyHeader
+}
+
+// replayCache remembers response bodies of requests sent with an
+// idempotency key, so a retried logical operation gets the original result.
+type replayCache struct {
+	mu      sync.Mutex
+	entries map[string]replayEntry
+}
+
+type replayEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// replayKey returns the cache key for r, or "" if r carries no idempotency
+// key in header.
+func replayKey(r *http.Request, header string) string {
+	key := r.Header.Get(header)
+	if key == "" {
+		return ""
+	}
+	return r.Method + " " + r.URL.String() + " " + key
+}
+
+func (rc *replayCache) get(key string) ([]byte, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	e, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(rc.entries, key)
+		return nil, false
+	}
+	return e.body, true
+}
+
+func (rc *replayCache) put(key string, body []byte, ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := time.Now()
+	if rc.entries == nil {
+		rc.entries = make(map[string]replayEntry)
+	}
+	for k, e := range rc.entries {
+		if now.After(e.expires) {
+			delete(rc.entries, k)
+		}
+	}
+	rc.entries[key] = replayEntry{body: body, expires: now.Add(ttl)}
+}
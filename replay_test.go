@@ -0,0 +1,101 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_ReplayTTL(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		fmt.Fprintf(w, "{\"Foo\": \"call-%d\"}", n)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ReplayTTL = 50 * time.Millisecond
+
+	data := map[string]string{"Name": "new_name"}
+
+	var first, second, other Response
+	if err := c.CreateJson("/api/foo", data, &first, WithIdempotencyKey("k1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateJson("/api/foo", data, &second, WithIdempotencyKey("k1")); err != nil {
+		t.Fatal(err)
+	}
+	if second.Foo != first.Foo || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected replayed result %q, got %q after %d calls", first.Foo, second.Foo, calls)
+	}
+
+	if err := c.CreateJson("/api/foo", data, &other, WithIdempotencyKey("k2")); err != nil {
+		t.Fatal(err)
+	}
+	if other.Foo == first.Foo {
+		t.Errorf("Expected a different key to reach the server")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if err := c.CreateJson("/api/foo", data, &second, WithIdempotencyKey("k1")); err != nil {
+		t.Fatal(err)
+	}
+	if second.Foo == first.Foo {
+		t.Errorf("Expected expired entry to reach the server")
+	}
+}
+
+func TestClient_ReplayRequiresKey(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ReplayTTL = time.Minute
+
+	c.CreateJson("/api/foo", nil, nil)
+	c.CreateJson("/api/foo", nil, nil)
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected requests without a key to always be sent, got %d calls", n)
+	}
+}
+
+func TestClient_ReplayNoContent(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.ReplayTTL = time.Minute
+
+	for i := 0; i < 2; i++ {
+		var result Result
+		var data Response
+		if err := c.DoContext(context.Background(), http.MethodDelete, "/api/foo/1", nil, &data, WithIdempotencyKey("k1"), WithResult(&result)); err != nil {
+			t.Fatal(err)
+		}
+		if !result.Empty {
+			t.Errorf("Expected an empty result on call %d", i+1)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected the 204 to be replayed, got %d calls", n)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// RequestBody returns the body of r without consuming it, for middleware
+// that logs or signs requests. A body that cannot be replayed is read into
+// memory and r is given a copy that can, so the request is still sent
+// whole and may be retried.
+func RequestBody(r *http.Request) ([]byte, error) {
+	if !hasBody(r) {
+		return nil, nil
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	setBody(r, b)
+	return b, nil
+}
+
+// WithBufferedBodies sets Client.BufferBodies.
+func WithBufferedBodies(max int64) Option {
+	return func(c *Client) {
+		c.BufferBodies = max
+	}
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody
+}
+
+// bufferBody reads the body of r into memory if it cannot be replayed and
+// holds at most max bytes. Larger bodies are left to stream.
+func bufferBody(r *http.Request, max int64) error {
+	if !hasBody(r) || r.GetBody != nil || r.ContentLength > max {
+		return nil
+	}
+	if isStreamBody(r.Body) {
+		return nil
+	}
+	head, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		r.Body.Close()
+		return err
+	}
+	if int64(len(head)) > max {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+		return nil
+	}
+	r.Body.Close()
+	setBody(r, head)
+	return nil
+}
+
+// isStreamBody reports whether body is a JSONArray stream, compressed or
+// not.
+func isStreamBody(body io.ReadCloser) bool {
+	switch b := body.(type) {
+	case *jsonStreamReader:
+		return true
+	case *compressedBody:
+		return isStreamBody(b.src)
+	}
+	return false
+}
+
+// watchedBody records whether a request body was read.
+type watchedBody struct {
+	io.ReadCloser
+	read bool
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	b.read = true
+	return b.ReadCloser.Read(p)
+}
+
+// guardBody rewinds the body of r before next sends it if a middleware
+// read it in the meantime, so reading req.Body in middleware does not
+// send an empty request. Bodies a middleware replaced are left alone.
+func guardBody(r *http.Request, next RoundTripFunc) RoundTripFunc {
+	if !hasBody(r) || r.GetBody == nil {
+		return next
+	}
+	body := &watchedBody{ReadCloser: r.Body}
+	r.Body = body
+	return func(r *http.Request) (*http.Response, error) {
+		if r.Body == io.ReadCloser(body) {
+			r.Body = body.ReadCloser
+			if body.read {
+				if err := rewindBody(r); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return next(r)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// opaqueReader hides the type of its reader so http.NewRequest cannot make
+// the body replayable.
+type opaqueReader struct {
+	io.Reader
+}
+
+func newEchoServer(received *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*received = string(body)
+		w.Write([]byte(`{"Foo": "ok"}`))
+	}))
+}
+
+func TestClient_MiddlewareReadsBody(t *testing.T) {
+	var received string
+	server := newEchoServer(&received)
+	defer server.Close()
+
+	var seen []string
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(r.Body)
+			seen = append(seen, string(b))
+			return next(r)
+		}
+	})
+
+	if err := c.CreateJson("/api/foo", map[string]string{"a": "b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || seen[0] != `{"a":"b"}` {
+		t.Errorf("Expected the middleware to read the body, got %q", seen)
+	}
+	if received != `{"a":"b"}` {
+		t.Errorf("Expected the server to receive the whole body, got %q", received)
+	}
+}
+
+func TestRequestBody(t *testing.T) {
+	var received string
+	server := newEchoServer(&received)
+	defer server.Close()
+
+	var signed string
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			b, err := RequestBody(r)
+			if err != nil {
+				return nil, err
+			}
+			signed = string(b)
+			return next(r)
+		}
+	})
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/foo", opaqueReader{strings.NewReader("payload")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.GetResponse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if signed != "payload" || received != "payload" {
+		t.Errorf("Expected the middleware and server to see the body, got %q and %q", signed, received)
+	}
+
+	if b, err := RequestBody(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil || b != nil {
+		t.Errorf("Expected no body, got %q, %v", b, err)
+	}
+}
+
+func TestClient_BufferBodies(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(1, http.StatusServiceUnavailable, "", &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Millisecond)}
+
+	send := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/api/foo", opaqueReader{strings.NewReader(body)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.GetResponse(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := send(`"x"`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected a plain reader not to be retried, got %d after %d calls", res.StatusCode, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	c.BufferBodies = 16
+	res = send(`"x"`)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != `{"Foo": "x"}` || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Expected a buffered body to be retried whole, got %q after %d calls", body, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	res = send(`"` + strings.Repeat("y", 20) + `"`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected a body over the limit to stream, got %d after %d calls", res.StatusCode, calls)
+	}
+}
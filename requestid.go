@@ -0,0 +1,103 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header used when RequestIDConfig.Header is empty.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+type requestIDContextKey struct{}
+
+// RequestIDConfig controls the correlation ID attached to outgoing requests.
+// Vendors disagree on the header (X-Request-ID, X-Correlation-ID,
+// traceparent) and on the format, so both are configurable.
+type RequestIDConfig struct {
+	// Header is the header name to set. Defaults to DefaultRequestIDHeader.
+	Header string
+
+	// Generate returns a new ID. Defaults to the client's IDs, or
+	// RandomRequestID.
+	Generate func() string
+
+	// ContextKey is looked up in the request context before generating a
+	// new ID, so an ID received upstream can be propagated. When nil, the
+	// key used by ContextWithRequestID is consulted.
+	ContextKey interface{}
+}
+
+// ContextWithRequestID returns a copy of ctx carrying id, which the client
+// propagates instead of generating a new request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RandomRequestID returns 16 random bytes encoded as hex.
+func RandomRequestID() string {
+	return randomHex(16)
+}
+
+// NewUUID returns a random version 4 UUID, like
+// "9b2f0c7e-4a1d-4e8b-b0a5-3c6d2e1f7a90".
+func NewUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("relax: could not read random bytes: %s", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// TraceparentRequestID returns a W3C traceparent value with a random trace
+// and parent ID. Use it with Header set to "traceparent".
+func TraceparentRequestID() string {
+	return fmt.Sprintf("00-%s-%s-01", randomHex(16), randomHex(8))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("relax: could not read random bytes: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// apply sets the request ID header on r unless it is already present.
+// Without Generate, new IDs come from ids when it is set.
+func (rc *RequestIDConfig) apply(r *http.Request, ids IDGenerator) {
+	header := rc.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	if r.Header.Get(header) != "" {
+		return
+	}
+
+	var key interface{} = requestIDContextKey{}
+	if rc.ContextKey != nil {
+		key = rc.ContextKey
+	}
+	if id, ok := r.Context().Value(key).(string); ok && id != "" {
+		r.Header.Set(header, id)
+		return
+	}
+
+	switch {
+	case rc.Generate != nil:
+		r.Header.Set(header, rc.Generate())
+	case ids != nil:
+		r.Header.Set(header, ids.NewID())
+	default:
+		r.Header.Set(header, RandomRequestID())
+	}
+}
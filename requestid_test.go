@@ -0,0 +1,118 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+// captureRequest returns a test server recording the last request it saw.
+func captureRequest(last **http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = r
+		w.Write([]byte("{}"))
+	}))
+}
+
+func TestClient_RequestIDDefaults(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.RequestID = &RequestIDConfig{}
+
+	if err := c.ReadJson("/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := got.Header.Get(DefaultRequestIDHeader); len(id) != 32 {
+		t.Errorf("Expected a 32 character hex ID, got %q", id)
+	}
+}
+
+func TestClient_RequestIDCustom(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.RequestID = &RequestIDConfig{Header: "traceparent", Generate: TraceparentRequestID}
+
+	if err := c.ReadJson("/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	re := regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`)
+	if id := got.Header.Get("traceparent"); !re.MatchString(id) {
+		t.Errorf("Expected a traceparent value, got %q", id)
+	}
+}
+
+func TestClient_RequestIDFromContext(t *testing.T) {
+	type key string
+
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.RequestID = &RequestIDConfig{Header: "X-Correlation-ID", ContextKey: key("corr")}
+
+	req, err := c.MakeRequest(http.MethodGet, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), key("corr"), "abc123"))
+
+	if _, err := c.GetResponse(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := got.Header.Get("X-Correlation-ID"); id != "abc123" {
+		t.Errorf("Expected propagated ID \"abc123\", got %q", id)
+	}
+
+	req, err = c.MakeRequest(http.MethodGet, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Correlation-ID", "preset")
+
+	if _, err := c.GetResponse(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := got.Header.Get("X-Correlation-ID"); id != "preset" {
+		t.Errorf("Expected existing header to be kept, got %q", id)
+	}
+}
+
+func TestContextWithRequestID(t *testing.T) {
+	var got *http.Request
+	server := captureRequest(&got)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.RequestID = &RequestIDConfig{}
+
+	req, err := c.MakeRequest(http.MethodGet, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ContextWithRequestID(context.Background(), "from-ctx"))
+
+	if _, err := c.GetResponse(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := got.Header.Get(DefaultRequestIDHeader); id != "from-ctx" {
+		t.Errorf("Expected \"from-ctx\", got %q", id)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Operation is a set of operations a resource supports.
+type Operation uint8
+
+// The operations of a Resource, sent as GET, POST, PUT, PATCH and DELETE.
+const (
+	OpRead Operation = 1 << iota
+	OpCreate
+	OpUpdate
+	OpPatch
+	OpDelete
+)
+
+var operationNames = []struct {
+	op     Operation
+	name   string
+	method string
+}{
+	{OpRead, "read", http.MethodGet},
+	{OpCreate, "create", http.MethodPost},
+	{OpUpdate, "update", http.MethodPut},
+	{OpPatch, "patch", http.MethodPatch},
+	{OpDelete, "delete", http.MethodDelete},
+}
+
+// Has reports whether o includes every operation in op.
+func (o Operation) Has(op Operation) bool {
+	return o&op == op
+}
+
+func (o Operation) String() string {
+	var names []string
+	for _, n := range operationNames {
+		if o.Has(n.op) {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// operationsFromAllow parses an Allow header into the operations it permits.
+func operationsFromAllow(values []string) Operation {
+	var ops Operation
+	for _, v := range values {
+		for _, method := range strings.Split(v, ",") {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			for _, n := range operationNames {
+				if n.method == method {
+					ops |= n.op
+				}
+			}
+		}
+	}
+	return ops
+}
+
+// NotAllowedError is returned by a Resource when the server does not permit
+// an operation on a path.
+type NotAllowedError struct {
+	Op      Operation
+	Path    string
+	Allowed Operation
+}
+
+func (e *NotAllowedError) Error() string {
+	return fmt.Sprintf("%s is not allowed on %s (allowed: %s)", e.Op, e.Path, e.Allowed)
+}
+
+// capabilityCache remembers the operations permitted per path, learned from
+// OPTIONS probes and from the Allow header of 405 responses.
+type capabilityCache struct {
+	mu  sync.Mutex
+	ops map[string]Operation
+}
+
+func (cc *capabilityCache) get(path string) (Operation, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	ops, ok := cc.ops[path]
+	return ops, ok
+}
+
+func (cc *capabilityCache) put(path string, ops Operation) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.ops == nil {
+		cc.ops = make(map[string]Operation)
+	}
+	cc.ops[path] = ops
+}
+
+// Resource is a typed view of a REST collection at a path, whose items live
+// at path/{id}.
+type Resource[T any] struct {
+	c    *Client
+	path string
+
+	// CheckAllowed makes Create, Update, Patch and Delete probe the server
+	// with OPTIONS, once per path, and fail locally with a NotAllowedError
+	// when the operation is not in the Allow header.
+	CheckAllowed bool
+
+	// Pages describes how ListAll pages through the collection. When nil,
+	// only rel="next" Link headers are followed.
+	Pages *PageOptions
+}
+
+// DefaultListAllPages bounds ListAll when Pages sets no Limits.
+const DefaultListAllPages = 1000
+
+// NewResource returns a Resource for the collection at path.
+func NewResource[T any](c *Client, path string) *Resource[T] {
+	return &Resource[T]{c: c, path: strings.TrimRight(path, "/")}
+}
+
+// Path returns the path of the item with id, or of the collection when id
+// is empty.
+func (r *Resource[T]) Path(id string) string {
+	if id == "" {
+		return r.path
+	}
+	return r.path + "/" + JoinPath(id)
+}
+
+// Get reads the item with id.
+func (r *Resource[T]) Get(ctx context.Context, id string, opts ...RequestOption) (T, error) {
+	var v T
+	err := r.c.ReadJsonContext(ctx, r.Path(id), &v, opts...)
+	return v, r.learn(r.Path(id), err)
+}
+
+// List reads the whole collection.
+func (r *Resource[T]) List(ctx context.Context, opts ...RequestOption) ([]T, error) {
+	var v []T
+	err := r.c.ReadJsonContext(ctx, r.path, &v, opts...)
+	return v, r.learn(r.path, err)
+}
+
+// ListAll reads the whole collection, following its pages as Pages
+// describes, and returns the items of every page in one slice. It fails
+// with a PaginationLimitError past Pages.Limits or, without any, past
+// DefaultListAllPages pages.
+func (r *Resource[T]) ListAll(ctx context.Context, opts ...RequestOption) ([]T, error) {
+	var po PageOptions
+	if r.Pages != nil {
+		po = *r.Pages
+	}
+	if po.Limits == nil {
+		po.Limits = &PageLimits{MaxPages: DefaultListAllPages}
+	}
+	p := r.c.PaginateContext(ctx, r.path, &po, opts...)
+
+	var all []T
+	if po.ItemsField == "" && !po.Detect {
+		var page []T
+		for p.Next(&page) {
+			all = append(all, page...)
+			page = nil
+		}
+		return all, r.learn(r.path, p.Err())
+	}
+	var page json.RawMessage
+	for p.Next(&page) {
+		raw, err := p.pageItems(page)
+		if err != nil {
+			return all, err
+		}
+		if raw == nil {
+			continue
+		}
+		var items []T
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return all, fmt.Errorf("decoding the items of page %d: %w", p.Pages(), err)
+		}
+		all = append(all, items...)
+	}
+	return all, r.learn(r.path, p.Err())
+}
+
+// Create POSTs v to the collection and returns the created item.
+func (r *Resource[T]) Create(ctx context.Context, v T, opts ...RequestOption) (T, error) {
+	var out T
+	if err := r.check(ctx, OpCreate, r.path); err != nil {
+		return out, err
+	}
+	err := r.c.CreateJsonContext(ctx, r.path, v, &out, opts...)
+	return out, r.learn(r.path, err)
+}
+
+// Update PUTs v to the item with id and returns the updated item.
+func (r *Resource[T]) Update(ctx context.Context, id string, v T, opts ...RequestOption) (T, error) {
+	var out T
+	if err := r.check(ctx, OpUpdate, r.Path(id)); err != nil {
+		return out, err
+	}
+	err := r.c.UpdateJsonContext(ctx, r.Path(id), v, &out, opts...)
+	return out, r.learn(r.Path(id), err)
+}
+
+// UpdateIfUnchanged is like Update but only succeeds if the item is
+// unchanged since the client last read or wrote it, using the validators
+// recorded in Client.Validators. It returns ErrNoValidators when none are
+// recorded; a changed item fails with a 412, see IsPreconditionFailed.
+func (r *Resource[T]) UpdateIfUnchanged(ctx context.Context, id string, v T, opts ...RequestOption) (T, error) {
+	stored, ok := r.Validators(id)
+	if !ok || stored.ETag == "" && stored.LastModified == "" {
+		var out T
+		return out, ErrNoValidators
+	}
+	return r.Update(ctx, id, v, append(opts[:len(opts):len(opts)], IfMatch(stored))...)
+}
+
+// Validators returns the validators recorded for the item with id by its
+// last read or write. It requires Client.Validators.
+func (r *Resource[T]) Validators(id string) (Validators, bool) {
+	return r.c.ValidatorsFor(r.Path(id))
+}
+
+// Delete deletes the item with id.
+func (r *Resource[T]) Delete(ctx context.Context, id string, opts ...RequestOption) error {
+	if err := r.check(ctx, OpDelete, r.Path(id)); err != nil {
+		return err
+	}
+	return r.learn(r.Path(id), r.c.DeleteJsonContext(ctx, r.Path(id), nil, opts...))
+}
+
+// Allowed returns the operations the server permits on the item with id, or
+// on the collection when id is empty. The answer is cached per path.
+func (r *Resource[T]) Allowed(ctx context.Context, id string) (Operation, error) {
+	return r.c.Allowed(ctx, r.Path(id))
+}
+
+// Allowed returns the operations the server permits on uri according to
+// the Allow header of an OPTIONS request. The answer is cached per path.
+func (c *Client) Allowed(ctx context.Context, uri string) (Operation, error) {
+	if ops, ok := c.capabilities.get(uri); ok {
+		return ops, nil
+	}
+
+	req, err := c.MakeRequestContext(ctx, http.MethodOptions, uri)
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	if !isSuccess(res.StatusCode) {
+		return 0, newAPIError(res, nil, 0)
+	}
+	allow := res.Header.Values("Allow")
+	if len(allow) == 0 {
+		return 0, fmt.Errorf("no Allow header in OPTIONS response for %s", uri)
+	}
+
+	ops := operationsFromAllow(allow)
+	c.capabilities.put(uri, ops)
+	return ops, nil
+}
+
+func (r *Resource[T]) check(ctx context.Context, op Operation, path string) error {
+	if !r.CheckAllowed {
+		return nil
+	}
+	ops, err := r.c.Allowed(ctx, path)
+	if err != nil {
+		return err
+	}
+	if !ops.Has(op) {
+		return &NotAllowedError{Op: op, Path: path, Allowed: ops}
+	}
+	return nil
+}
+
+// learn records the Allow header of a 405 so later calls fail fast.
+func (r *Resource[T]) learn(path string, err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusMethodNotAllowed {
+		if allow := apiErr.Header.Values("Allow"); len(allow) > 0 {
+			r.c.capabilities.put(path, operationsFromAllow(allow))
+		}
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type widget struct {
+	ID   string
+	Name string
+}
+
+func newWidgetServer(options *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodOptions:
+			*options++
+			if r.URL.Path == "/widgets" {
+				w.Header().Set("Allow", "GET, POST, OPTIONS")
+			} else {
+				w.Header().Set("Allow", "GET, OPTIONS")
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/widgets":
+			w.Write([]byte(`[{"ID":"1","Name":"a"}]`))
+		case r.Method == http.MethodGet && r.URL.Path == "/widgets/1":
+			w.Write([]byte(`{"ID":"1","Name":"a"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/widgets":
+			w.Write([]byte(`{"ID":"2","Name":"b"}`))
+		default:
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestResource(t *testing.T) {
+	var options int
+	server := newWidgetServer(&options)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	widgets := NewResource[widget](c, "/widgets/")
+	ctx := context.Background()
+
+	list, err := widgets.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "a" {
+		t.Errorf("Unexpected list %v", list)
+	}
+
+	got, err := widgets.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "1" {
+		t.Errorf("Expected widget 1, got %v", got)
+	}
+
+	created, err := widgets.Create(ctx, widget{Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "2" {
+		t.Errorf("Expected widget 2, got %v", created)
+	}
+
+	if p := widgets.Path("a/b"); p != "/widgets/a%2Fb" {
+		t.Errorf("Expected escaped item path, got %q", p)
+	}
+}
+
+func TestResource_CheckAllowed(t *testing.T) {
+	var options int
+	server := newWidgetServer(&options)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	widgets := NewResource[widget](c, "/widgets")
+	widgets.CheckAllowed = true
+	ctx := context.Background()
+
+	ops, err := widgets.Allowed(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ops.Has(OpRead|OpCreate) || ops.Has(OpDelete) {
+		t.Errorf("Expected read|create, got %s", ops)
+	}
+
+	if _, err := widgets.Create(ctx, widget{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = widgets.Delete(ctx, "1")
+		var notAllowed *NotAllowedError
+		if !errors.As(err, &notAllowed) {
+			t.Fatalf("Expected NotAllowedError, got %v", err)
+		}
+		if err.Error() != "delete is not allowed on /widgets/1 (allowed: read)" {
+			t.Errorf("Unexpected message %q", err)
+		}
+	}
+
+	if options != 2 {
+		t.Errorf("Expected one OPTIONS probe per path, got %d", options)
+	}
+}
+
+func TestResource_LearnsFrom405(t *testing.T) {
+	var options int
+	server := newWidgetServer(&options)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	widgets := NewResource[widget](c, "/widgets")
+
+	if _, err := widgets.Update(context.Background(), "1", widget{}); StatusCode(err) != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405, got %v", err)
+	}
+
+	widgets.CheckAllowed = true
+	var notAllowed *NotAllowedError
+	if _, err := widgets.Update(context.Background(), "1", widget{}); !errors.As(err, &notAllowed) {
+		t.Errorf("Expected NotAllowedError, got %v", err)
+	}
+	if options != 0 {
+		t.Errorf("Expected the 405 Allow header to avoid a probe, got %d probes", options)
+	}
+}
+
+func TestResource_ListAll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/linked":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", `</linked?page=2>; rel="next"`)
+				w.Write([]byte(`[{"ID":"1"},{"ID":"2"}]`))
+				return
+			}
+			w.Write([]byte(`[{"ID":"3"}]`))
+		case "/cursored":
+			switch r.URL.Query().Get("cursor") {
+			case "":
+				w.Write([]byte(`{"data":[{"ID":"a"},{"ID":"b"}],"next":"c2"}`))
+			case "c2":
+				w.Write([]byte(`{"data":[],"next":"c3"}`))
+			default:
+				w.Write([]byte(`{"data":[{"ID":"c"}]}`))
+			}
+		case "/endless":
+			w.Header().Set("Link", `</endless>; rel="next"`)
+			w.Write([]byte(`[{"ID":"x"}]`))
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	ctx := context.Background()
+	ids := func(list []widget) string {
+		var s string
+		for _, w := range list {
+			s += w.ID
+		}
+		return s
+	}
+
+	list, err := NewResource[widget](c, "/linked").ListAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(list) != "123" {
+		t.Errorf("Expected the Link pages flattened, got %v", list)
+	}
+
+	cursored := NewResource[widget](c, "/cursored")
+	cursored.Pages = &PageOptions{CursorParam: "cursor", CursorField: "next", ItemsField: "data"}
+	list, err = cursored.ListAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(list) != "abc" {
+		t.Errorf("Expected the cursor pages flattened, got %v", list)
+	}
+
+	endless := NewResource[widget](c, "/endless")
+	endless.Pages = &PageOptions{Limits: &PageLimits{MaxItems: 5}}
+	list, err = endless.ListAll(ctx)
+	if !errors.Is(err, ErrPaginationLimit) || len(list) != 5 {
+		t.Errorf("Expected the limit to stop after 5 items, got %d items and %v", len(list), err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Result describes the outcome of a single call. Unlike the client's
+// deprecated Last fields it belongs to the call, so it stays correct when
+// one Client is shared by many goroutines. Pass WithResult to receive it.
+type Result struct {
+	// StatusCode and Header are those of the final response. They are
+	// zero when the body came from the replay cache or a fresh Cache
+	// entry.
+	StatusCode int
+	Header     http.Header
+
+	// Body is the raw response body, or the stored body when the call
+	// was answered from Cache or the replay cache.
+	Body []byte
+
+	// Response is the final response. Its body has been consumed.
+	Response *http.Response
+
+	// Location is the Location of a 201, 302 or 303 response when
+	// Client.Location is not LocationIgnore.
+	Location *url.URL
+
+	// Redirects are the redirects the http.Client followed in the last
+	// attempt, in order.
+	Redirects []RedirectHop
+
+	// Key is the API key used by the last attempt.
+	Key KeySlot
+
+	// Attempts is the number of attempts made.
+	Attempts int
+
+	// Empty reports a 204 or an empty body, in which case nothing was
+	// decoded.
+	Empty bool
+
+	// Cached reports that the body was served from Cache, after the
+	// server answered 304 Not Modified or without asking it while fresh
+	// under CacheHints.
+	Cached bool
+
+	// NotModified reports that the server answered 304 Not Modified.
+	NotModified bool
+
+	// Renegotiated is the media type the call was sent again asking for,
+	// after its first response could not be decoded or was a 406. See
+	// NegotiationConfig.
+	Renegotiated string
+
+	// Timing is the timing breakdown, when collected.
+	Timing *Timing
+}
+
+// WithResult fills r with the outcome of this call, including when it
+// fails.
+func WithResult(r *Result) RequestOption {
+	return func(o *callOptions) {
+		o.result = r
+	}
+}
+
+type resultContextKey struct{}
+
+// callResult returns the Result of the call r belongs to, if any.
+func callResult(r *http.Request) *Result {
+	res, _ := r.Context().Value(resultContextKey{}).(*Result)
+	return res
+}
+
+func withResult(r *http.Request, res *Result) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), resultContextKey{}, res))
+}
+
+// publish copies res into the deprecated Last fields. The lock keeps
+// concurrent calls from racing on them; readers should use WithResult.
+func (c *Client) publish(res *Result) {
+	c.lastMu.Lock()
+	defer c.lastMu.Unlock()
+
+	c.LastResponse = res.Response
+	if c.KeepLastBody {
+		c.LastBody = res.Body
+	}
+	c.LastKey = res.Key
+	c.LastLocation = res.Location
+	c.LastEmpty = res.Empty
+	c.LastCached = res.Cached
+	c.LastTiming = res.Timing
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestClient_WithResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total", "3")
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.KeepLastBody = true
+
+	var result Result
+	var data Response
+	if err := c.ReadJson("/api/foo", &data, WithResult(&result)); err != nil {
+		t.Fatal(err)
+	}
+	if result.StatusCode != http.StatusOK || result.Header.Get("X-Total") != "3" {
+		t.Errorf("Unexpected status and header %d %v", result.StatusCode, result.Header)
+	}
+	if string(result.Body) != `{"Foo": "bar"}` || result.Attempts != 1 || result.Key != PrimaryKey {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if string(c.LastBody) != string(result.Body) || c.LastResponse != result.Response {
+		t.Errorf("Expected the Last fields to keep mirroring the last call")
+	}
+}
+
+func TestClient_WithResultOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var result Result
+	if err := c.ReadJson("/api/foo", nil, WithResult(&result)); StatusCode(err) != http.StatusGone {
+		t.Fatalf("Expected a 410, got %v", err)
+	}
+	if result.StatusCode != http.StatusGone || string(result.Body) != "gone\n" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestClient_ConcurrentResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+		fmt.Fprintf(w, `{"Foo": %q}`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Cache = NewMemoryStore(0)
+	c.CollectStats = true
+	c.TraceTiming = true
+	c.Journal = NewJournal(10, 0)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("/items/%d", i%5)
+
+			var result Result
+			var data Response
+			if err := c.ReadJson(path, &data, WithResult(&result)); err != nil {
+				errs <- err
+				return
+			}
+			if data.Foo != path || result.Header.Get("ETag") != `"`+path+`"` || result.Timing == nil {
+				errs <- fmt.Errorf("call for %s got %q with result %+v", path, data.Foo, result)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	var count int64
+	for _, r := range c.Stats().Routes {
+		count += r.Count
+	}
+	if count != 50 {
+		t.Errorf("Expected 50 requests in stats, got %d", count)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryStatusCodes are retried when RetryPolicy.StatusCodes is nil.
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultMaxRetryAfter is used when RetryPolicy.MaxRetryAfter is zero.
+const DefaultMaxRetryAfter = time.Minute
+
+// Backoff returns how long to wait before retry number attempt, starting
+// at 1.
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff doubles the delay from base up to max, picking a
+// random delay in the upper half of each step so clients don't retry in
+// lockstep.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if half := int64(d / 2); half > 0 {
+			d = time.Duration(half + rand.Int63n(half+1))
+		}
+		return d
+	}
+}
+
+// ConstantBackoff always waits d.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// RetryPolicy configures automatic retries inside GetResponse and the JSON
+// helpers. Every attempt is authenticated and sent anew, so request bodies
+// must be replayable; those built by this package are.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// Backoff computes the delay between attempts. Defaults to
+	// ExponentialBackoff(100ms, 10s).
+	Backoff Backoff
+
+	// StatusCodes are the response statuses worth retrying. Defaults to
+	// DefaultRetryStatusCodes.
+	StatusCodes []int
+
+	// RetryOn, when set, replaces the StatusCodes and network error check.
+	RetryOn func(res *http.Response, err error) bool
+
+	// MaxRetryAfter caps the Retry-After of a 429 or 503 that is honored.
+	// A longer Retry-After ends retrying. Defaults to DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
+
+	// RetryNonIdempotent also retries POST and PATCH requests that carry
+	// no Idempotency-Key, which may repeat their side effects.
+	RetryNonIdempotent bool
+
+	// IdempotencyKeys gives POST and PATCH requests without an idempotency
+	// key a random UUID one, sent with every attempt, so they can be
+	// retried without the server repeating their side effects.
+	IdempotencyKeys bool
+
+	// OnDecision, when set, is called after every failed attempt worth
+	// retrying with what the policy decided to do about it.
+	OnDecision func(e *RetryEvent)
+}
+
+// RetryDecision is what a RetryPolicy decided after a failed attempt.
+type RetryDecision int
+
+const (
+	// RetryScheduled means another attempt follows after RetryEvent.Delay.
+	RetryScheduled RetryDecision = iota
+
+	// RetryExhausted means the last allowed attempt failed.
+	RetryExhausted
+
+	// RetryAfterTooLong means the server asked to wait longer than
+	// MaxRetryAfter.
+	RetryAfterTooLong
+)
+
+func (d RetryDecision) String() string {
+	switch d {
+	case RetryExhausted:
+		return "exhausted"
+	case RetryAfterTooLong:
+		return "retry-after too long"
+	}
+	return "scheduled"
+}
+
+// RetryEvent describes a retry decision, so slow requests can be
+// explained.
+type RetryEvent struct {
+	Method string
+
+	// URL has its user info removed.
+	URL string
+
+	Decision RetryDecision
+
+	// Reason is why the attempt failed, like "status 503" or the error.
+	Reason     string
+	StatusCode int
+	Err        error
+
+	// Attempt is the number of the failed attempt, starting at 1, and
+	// AttemptsLeft the number the policy still allows.
+	Attempt      int
+	AttemptsLeft int
+
+	// Delay is the wait before the next attempt, including any
+	// Retry-After, when one is scheduled.
+	Delay time.Duration
+
+	// Elapsed is the time since the first attempt started.
+	Elapsed time.Duration
+}
+
+// WithRetry enables retries with p.
+func WithRetry(p *RetryPolicy) Option {
+	return func(c *Client) {
+		c.Retry = p
+	}
+}
+
+func isIdempotent(r *http.Request, header string) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPatch:
+		return r.Header.Get(header) != ""
+	}
+	return true
+}
+
+// canRetry reports whether r may be sent more than once, with idempotency
+// keys in header.
+func (p *RetryPolicy) canRetry(r *http.Request, header string) bool {
+	if p == nil || p.MaxAttempts < 2 {
+		return false
+	}
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+	return p.RetryNonIdempotent || isIdempotent(r, header)
+}
+
+func (p *RetryPolicy) shouldRetry(r *http.Request, res *http.Response, err error) bool {
+	var policyErr *PolicyError
+	if r.Context().Err() != nil || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrMaintenance) || errors.As(err, &policyErr) {
+		return false
+	}
+	if p.RetryOn != nil {
+		return p.RetryOn(res, err)
+	}
+	if err != nil {
+		return true
+	}
+
+	codes := p.StatusCodes
+	if codes == nil {
+		codes = DefaultRetryStatusCodes
+	}
+	for _, code := range codes {
+		if res.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait before the next attempt and false if the server
+// asked for a longer pause than the policy allows.
+func (p *RetryPolicy) delay(attempt int, res *http.Response) (time.Duration, bool) {
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+	}
+	d := backoff(attempt)
+
+	if res != nil && (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) {
+		if after, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			max := p.MaxRetryAfter
+			if max == 0 {
+				max = DefaultMaxRetryAfter
+			}
+			if after > max {
+				return 0, false
+			}
+			if after > d {
+				d = after
+			}
+		}
+	}
+	return d, true
+}
+
+// sendWithRetry sends r until it succeeds, the policy gives up or the
+// context is done. It returns the number of attempts made.
+func (c *Client) sendWithRetry(r *http.Request) (*http.Response, int, error) {
+	p := c.retryPolicy(r)
+	header := c.idempotencyHeader()
+	if p != nil && p.IdempotencyKeys && p.MaxAttempts >= 2 && !isIdempotent(r, header) {
+		// Set once, so every attempt carries the same key.
+		r.Header.Set(header, c.newID())
+	}
+	if !p.canRetry(r, header) {
+		res, err := c.sendWithFailover(r)
+		return res, 1, err
+	}
+
+	// A failed attempt stays in flight until the next one is made, so
+	// Wait does not return during the backoff.
+	holding := false
+	defer func() {
+		if holding {
+			c.inflight.done()
+		}
+	}()
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		res, err := c.sendWithFailover(r)
+		if holding {
+			c.inflight.done()
+			holding = false
+		}
+		if !p.shouldRetry(r, res, err) {
+			return res, attempt, err
+		}
+		if attempt >= p.MaxAttempts {
+			p.decided(r, RetryExhausted, attempt, res, err, 0, start)
+			return res, attempt, err
+		}
+		wait, ok := p.delay(attempt, res)
+		if !ok {
+			p.decided(r, RetryAfterTooLong, attempt, res, err, 0, start)
+			return res, attempt, err
+		}
+		p.decided(r, RetryScheduled, attempt, res, err, wait, start)
+
+		c.inflight.add()
+		holding = true
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		if err := rewindBody(r); err != nil {
+			return nil, attempt, err
+		}
+		if c.CollectStats {
+			c.stats.retry(routeKey(r))
+		}
+		if err := sleepContext(r, wait); err != nil {
+			return nil, attempt, err
+		}
+	}
+}
+
+// decided reports a decision about the failed attempt of r to OnDecision.
+func (p *RetryPolicy) decided(r *http.Request, d RetryDecision, attempt int, res *http.Response, err error, wait time.Duration, start time.Time) {
+	if p.OnDecision == nil {
+		return
+	}
+	e := &RetryEvent{
+		Method:       r.Method,
+		URL:          redactRequestURL(r, nil),
+		Decision:     d,
+		Err:          err,
+		Attempt:      attempt,
+		AttemptsLeft: p.MaxAttempts - attempt,
+		Delay:        wait,
+		Elapsed:      time.Since(start),
+	}
+	if d != RetryScheduled {
+		e.AttemptsLeft = 0
+	}
+	if res != nil {
+		e.StatusCode = res.StatusCode
+		e.Reason = fmt.Sprintf("status %d", res.StatusCode)
+	}
+	if err != nil {
+		e.Reason = err.Error()
+	}
+	p.OnDecision(e)
+}
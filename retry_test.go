@@ -0,0 +1,224 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer fails the first n requests with status, echoing the body
+// of later ones into Foo.
+func newFlakyServer(n int32, status int, retryAfter string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= n {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, "unavailable", status)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("{\"Foo\": " + string(body) + "}"))
+	}))
+}
+
+func TestClient_RetrySucceeds(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(2, http.StatusServiceUnavailable, "", &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond)}
+
+	var response Response
+	if err := c.UpdateJson("/api/foo", "bar", &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Foo != "bar" {
+		t.Errorf("Expected replayed body, got %q", response.Foo)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected 3 calls, got %d", n)
+	}
+}
+
+func TestClient_RetryNonIdempotent(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(1, http.StatusBadGateway, "", &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond)}
+
+	res, err := c.GetResponse(mustRequest(t, c, http.MethodPost, "/api/foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadGateway || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected POST without an idempotency key not to be retried")
+	}
+
+	if err := c.CreateJson("/api/foo", "bar", nil, WithIdempotencyKey("k")); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected POST with an idempotency key to be sent, got %d calls", n)
+	}
+}
+
+func TestClient_RetryIdempotencyKeys(t *testing.T) {
+	var keys []string
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, r.Header.Get("X-Request-Key"))
+		if calls++; calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.IdempotencyHeader = "X-Request-Key"
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond), IdempotencyKeys: true}
+
+	var response Response
+	if err := c.CreateJson("/api/foo", "bar", &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("Expected the POST to be retried, got %d calls", len(keys))
+	}
+	if len(keys[0]) != 36 || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Expected one generated key for every attempt, got %q", keys)
+	}
+
+	keys = nil
+	if err := c.CreateJson("/api/foo", "bar", &response, WithIdempotencyKey("mine")); err != nil {
+		t.Fatal(err)
+	}
+	if keys[0] != "mine" {
+		t.Errorf("Expected the caller's key to be kept, got %q", keys[0])
+	}
+}
+
+func TestClient_RetryDecisions(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(2, http.StatusServiceUnavailable, "", &calls)
+	defer server.Close()
+
+	var events []RetryEvent
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     ConstantBackoff(time.Millisecond),
+		OnDecision:  func(e *RetryEvent) { events = append(events, *e) },
+	}
+
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected a decision per failed attempt, got %d", len(events))
+	}
+	e := events[1]
+	if e.Decision != RetryScheduled || e.Attempt != 2 || e.AttemptsLeft != 1 || e.Delay != time.Millisecond {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if e.Reason != "status 503" || e.StatusCode != 503 || e.Method != "GET" || e.Elapsed <= 0 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+
+	events = nil
+	atomic.StoreInt32(&calls, -10)
+	c.ReadJson("/api/foo", nil)
+	if len(events) != 3 || events[2].Decision != RetryExhausted || events[2].AttemptsLeft != 0 {
+		t.Errorf("Expected the policy to give up, got %+v", events)
+	}
+}
+
+func TestClient_RetryDecisionRetryAfter(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(1, http.StatusTooManyRequests, "3600", &calls)
+	defer server.Close()
+
+	var decision RetryDecision = -1
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, OnDecision: func(e *RetryEvent) { decision = e.Decision }}
+
+	c.ReadJson("/api/foo", nil)
+	if decision != RetryAfterTooLong || decision.String() != "retry-after too long" {
+		t.Errorf("Expected the Retry-After to end retrying, got %v", decision)
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	id := NewUUID()
+	if len(id) != 36 || id[8] != '-' || id[14] != '4' || !strings.ContainsRune("89ab", rune(id[19])) {
+		t.Errorf("Expected a version 4 UUID, got %q", id)
+	}
+	if NewUUID() == id {
+		t.Errorf("Expected random UUIDs")
+	}
+}
+
+func TestClient_RetryAfterTooLong(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(1, http.StatusTooManyRequests, "3600", &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond)}
+
+	res, err := c.GetResponse(mustRequest(t, c, http.MethodGet, "/api/foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusTooManyRequests || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected a Retry-After beyond MaxRetryAfter to stop retrying")
+	}
+}
+
+func TestClient_RetryAttemptsInError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	c := newClientOrFatal(t, server.URL, apiKey)
+	server.Close()
+
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond)}
+
+	err := c.ReadJson("/api/foo", nil)
+
+	var rerr *RequestError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Expected *RequestError, got %v", err)
+	}
+	if rerr.Attempt != 3 {
+		t.Errorf("Expected 3 attempts, got %d", rerr.Attempt)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(100*time.Millisecond, time.Second)
+
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		d := b(attempt)
+		if d < want/2 || d > want {
+			t.Errorf("Expected attempt %d to wait between %s and %s, got %s", attempt, want/2, want, d)
+		}
+	}
+}
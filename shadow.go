@@ -0,0 +1,228 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultShadowTimeout is used when ShadowConfig.Timeout is zero.
+const DefaultShadowTimeout = 30 * time.Second
+
+// ShadowConfig configures Shadow.
+type ShadowConfig struct {
+	// BaseURL is the secondary API. Requests keep their path and query;
+	// only the scheme and host are replaced.
+	BaseURL *url.URL
+
+	// Rewrite, when set, adjusts the URL of each secondary request, e.g.
+	// for an API mounted at another path.
+	Rewrite func(u *url.URL)
+
+	// Prepare, when set, adjusts each secondary request before it is sent,
+	// e.g. to add the credentials of the secondary API.
+	Prepare func(r *http.Request)
+
+	// KeepCredentials sends the credentials of the primary API to the
+	// secondary one too. By default they are removed: the headers and
+	// query parameters set by the client's authentication, the signature
+	// of its URLSigner, and any Authorization, Proxy-Authorization or
+	// Cookie header.
+	KeepCredentials bool
+
+	// Percent is the share of the matching requests, from 0 to 100, sent
+	// to the secondary API.
+	Percent float64
+
+	// Route sends the selected requests to the secondary API instead of
+	// the primary and returns its responses, for A/B routing. Otherwise
+	// they are mirrored: sent to both, returning the primary's response.
+	Route bool
+
+	// Match, when set, selects the requests eligible for the secondary
+	// API. Defaults to GET, HEAD and OPTIONS requests when mirroring, as
+	// mirrored writes repeat their side effects, and to all requests when
+	// routing.
+	Match func(r *http.Request) bool
+
+	// Compare, when set, is called with the responses of each mirrored
+	// request once both have been read. The primary response body is read
+	// in full before it is returned, so leave streaming endpoints out with
+	// Match.
+	Compare func(result *ShadowResult)
+
+	// OnError, when set, is told about mirrored requests that failed
+	// without a Compare to report them to.
+	OnError func(err error)
+
+	// Timeout bounds each mirrored request, which outlives the call it
+	// mirrors. Defaults to DefaultShadowTimeout.
+	Timeout time.Duration
+}
+
+// ShadowResult holds the two responses to a mirrored request.
+type ShadowResult struct {
+	// Request is the primary request; its body has been consumed.
+	Request *http.Request
+
+	Primary ShadowResponse
+	Shadow  ShadowResponse
+}
+
+// ShadowResponse is one side of a ShadowResult. Err is set when no
+// complete response was received.
+type ShadowResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+	Err        error
+}
+
+// Shadow returns a middleware that sends a share of the requests to a
+// secondary API as well, to validate a migration before cutover:
+//
+//	c.Use(relax.Shadow(&relax.ShadowConfig{
+//		BaseURL: newVendor,
+//		Percent: 5,
+//		Compare: reportMismatch,
+//	}))
+//
+// Mirrored requests are sent in the background and their responses are
+// discarded unless Compare is set; they never fail the call. With Route
+// set, the selected requests go to the secondary API only.
+func Shadow(cfg *ShadowConfig) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if !cfg.selects(r) {
+				return next(r)
+			}
+			if cfg.Route {
+				return next(cfg.secondary(r.Context(), r))
+			}
+			return cfg.mirror(r, next)
+		}
+	}
+}
+
+// selects reports whether r goes to the secondary API.
+func (cfg *ShadowConfig) selects(r *http.Request) bool {
+	if cfg.BaseURL == nil || cfg.Percent <= 0 {
+		return false
+	}
+	if cfg.Match != nil {
+		if !cfg.Match(r) {
+			return false
+		}
+	} else if !cfg.Route {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			return false
+		}
+	}
+	return cfg.Percent >= 100 || rand.Float64()*100 < cfg.Percent
+}
+
+// secondary returns a copy of r addressed to the secondary API.
+func (cfg *ShadowConfig) secondary(ctx context.Context, r *http.Request) *http.Request {
+	s := r.Clone(ctx)
+	if !cfg.KeepCredentials {
+		stripCredentials(s)
+	}
+	s.URL.Scheme = cfg.BaseURL.Scheme
+	s.URL.Host = cfg.BaseURL.Host
+	s.URL.User = cfg.BaseURL.User
+	if cfg.Rewrite != nil {
+		cfg.Rewrite(s.URL)
+	}
+	s.Host = ""
+	if cfg.Prepare != nil {
+		cfg.Prepare(s)
+	}
+	return s
+}
+
+// mirror sends r to the primary API and a copy to the secondary one.
+func (cfg *ShadowConfig) mirror(r *http.Request, next RoundTripFunc) (*http.Response, error) {
+	var s *http.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		s = cfg.secondary(context.WithoutCancel(r.Context()), r)
+	} else if r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			s = cfg.secondary(context.WithoutCancel(r.Context()), r)
+			s.Body = body
+		}
+	}
+	if s == nil {
+		cfg.onError(fmt.Errorf("shadowing %s %s: %w", r.Method, redactURL(r.URL, nil), errBodyNotReplayable))
+		return next(r)
+	}
+
+	shadow := make(chan ShadowResponse, 1)
+	go func() {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = DefaultShadowTimeout
+		}
+		ctx, cancel := context.WithTimeout(s.Context(), timeout)
+		defer cancel()
+		shadow <- readShadowResponse(next, s.WithContext(ctx))
+	}()
+
+	if cfg.Compare == nil {
+		go func() {
+			if res := <-shadow; res.Err != nil {
+				cfg.onError(fmt.Errorf("shadowing %s %s: %w", s.Method, redactURL(s.URL, nil), res.Err))
+			}
+		}()
+		return next(r)
+	}
+
+	start := time.Now()
+	res, err := next(r)
+	primary := ShadowResponse{Err: err}
+	if err == nil {
+		primary.StatusCode, primary.Header = res.StatusCode, res.Header
+		primary.Body, primary.Err = ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = ioutil.NopCloser(bytes.NewReader(primary.Body))
+	}
+	primary.Duration = time.Since(start)
+	go func() {
+		cfg.Compare(&ShadowResult{Request: r, Primary: primary, Shadow: <-shadow})
+	}()
+	if primary.Err != nil && err == nil {
+		return nil, primary.Err
+	}
+	return res, err
+}
+
+func (cfg *ShadowConfig) onError(err error) {
+	if cfg.OnError != nil {
+		cfg.OnError(err)
+	}
+}
+
+// readShadowResponse sends s and reads its whole response.
+func readShadowResponse(next RoundTripFunc, s *http.Request) ShadowResponse {
+	start := time.Now()
+	res, err := next(s)
+	if err != nil {
+		return ShadowResponse{Duration: time.Since(start), Err: err}
+	}
+	defer res.Body.Close()
+	out := ShadowResponse{StatusCode: res.StatusCode, Header: res.Header}
+	out.Body, out.Err = ioutil.ReadAll(res.Body)
+	out.Duration = time.Since(start)
+	return out
+}
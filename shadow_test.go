@@ -0,0 +1,200 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newShadowServers(t *testing.T) (primary, secondary *httptest.Server, seen chan string) {
+	seen = make(chan string, 10)
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			seen <- name + " " + r.Method + " " + r.URL.Path + " " + r.Header.Get("X-Key")
+			w.Write([]byte(`{"Foo": "` + name + `"}`))
+		}
+	}
+	primary = httptest.NewServer(handler("primary"))
+	secondary = httptest.NewServer(handler("secondary"))
+	t.Cleanup(primary.Close)
+	t.Cleanup(secondary.Close)
+	return primary, secondary, seen
+}
+
+func receive(t *testing.T, seen chan string) string {
+	t.Helper()
+	select {
+	case s := <-seen:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("Expected a request")
+		return ""
+	}
+}
+
+func TestClient_ShadowCompare(t *testing.T) {
+	primary, secondary, seen := newShadowServers(t)
+	base, _ := url.Parse(secondary.URL)
+	results := make(chan *ShadowResult, 1)
+
+	c := newClientOrFatal(t, primary.URL, apiKey)
+	c.Use(Shadow(&ShadowConfig{
+		BaseURL: base,
+		Percent: 100,
+		Prepare: func(r *http.Request) { r.Header.Set("X-Key", "secondary-key") },
+		Compare: func(result *ShadowResult) { results <- result },
+	}))
+
+	var data Response
+	if err := c.ReadJson("/users/1", &data); err != nil || data.Foo != "primary" {
+		t.Fatalf("Expected the primary response, got %+v, %v", data, err)
+	}
+	got := map[string]bool{receive(t, seen): true, receive(t, seen): true}
+	if !got["primary GET /users/1 "] || !got["secondary GET /users/1 secondary-key"] {
+		t.Errorf("Expected the request on both APIs, got %v", got)
+	}
+
+	select {
+	case result := <-results:
+		if string(result.Primary.Body) != `{"Foo": "primary"}` || string(result.Shadow.Body) != `{"Foo": "secondary"}` {
+			t.Errorf("Expected both bodies, got %q and %q", result.Primary.Body, result.Shadow.Body)
+		}
+		if result.Shadow.StatusCode != http.StatusOK || result.Shadow.Err != nil {
+			t.Errorf("Expected a successful shadow response, got %+v", result.Shadow)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Compare to be called")
+	}
+
+	// Writes are not mirrored by default.
+	if err := c.CreateJson("/users", Response{Foo: "x"}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if s := receive(t, seen); s != "primary POST /users " {
+		t.Errorf("Expected the POST on the primary only, got %q", s)
+	}
+	select {
+	case s := <-seen:
+		t.Errorf("Expected no mirrored POST, got %q", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClient_ShadowRoute(t *testing.T) {
+	primary, secondary, seen := newShadowServers(t)
+	base, _ := url.Parse(secondary.URL)
+
+	c := newClientOrFatal(t, primary.URL, apiKey)
+	c.Use(Shadow(&ShadowConfig{BaseURL: base, Percent: 100, Route: true}))
+
+	var data Response
+	if err := c.CreateJson("/users", Response{Foo: "x"}, &data); err != nil || data.Foo != "secondary" {
+		t.Fatalf("Expected the secondary response, got %+v, %v", data, err)
+	}
+	if s := receive(t, seen); s != "secondary POST /users " {
+		t.Errorf("Expected the POST on the secondary only, got %q", s)
+	}
+}
+
+func TestClient_ShadowPercent(t *testing.T) {
+	primary, secondary, seen := newShadowServers(t)
+	base, _ := url.Parse(secondary.URL)
+
+	c := newClientOrFatal(t, primary.URL, apiKey)
+	c.Use(Shadow(&ShadowConfig{BaseURL: base, Percent: 0}))
+
+	if err := c.ReadJson("/", nil); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, seen)
+	select {
+	case s := <-seen:
+		t.Errorf("Expected nothing mirrored at 0%%, got %q", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClient_ShadowStripsCredentials(t *testing.T) {
+	sent := make(chan *http.Request, 10)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent <- r
+		w.Write([]byte(`{}`))
+	}))
+	defer secondary.Close()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer primary.Close()
+	base, _ := url.Parse(secondary.URL)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"token", nil},
+		{"query key", []Option{WithKeyAuth(func(key string) Authenticator { return QueryAuth("api_key", key) })}},
+		{"header key", []Option{WithKeyAuth(func(key string) Authenticator { return HeaderAuth("X-Api-Key", key) })}},
+		{"signed", []Option{WithURLSigner(&TokenSigner{Key: []byte("cdn-secret")})}},
+		{"signed in path", []Option{WithURLSigner(&TokenSigner{Key: []byte("cdn-secret"), InPath: true})}},
+	}
+	for _, tt := range tests {
+		for _, route := range []bool{false, true} {
+			c, err := NewClient(primary.URL, "secret-key", tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Use(Shadow(&ShadowConfig{
+				BaseURL: base,
+				Percent: 100,
+				Route:   route,
+				Prepare: func(r *http.Request) { r.Header.Set("X-Key", "secondary-key") },
+			}))
+			c.DefaultHeader = http.Header{"Cookie": {"session=primary"}}
+
+			if err := c.ReadJson("/users/1?page=2", nil); err != nil {
+				t.Fatal(err)
+			}
+			var r *http.Request
+			select {
+			case r = <-sent:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: expected a secondary request", tt.name)
+			}
+			if r.URL.Path != "/users/1" || r.URL.RawQuery != "page=2" {
+				t.Errorf("%s (route %v): expected the unsigned URL without the key, got %s", tt.name, route, r.URL)
+			}
+			for _, name := range []string{"Authorization", "X-Api-Key", "Cookie"} {
+				if v := r.Header.Get(name); v != "" {
+					t.Errorf("%s (route %v): expected no %s on the secondary, got %q", tt.name, route, name, v)
+				}
+			}
+			if r.Header.Get("X-Key") != "secondary-key" {
+				t.Errorf("%s (route %v): expected Prepare to add the secondary's key", tt.name, route)
+			}
+		}
+	}
+}
+
+func TestClient_ShadowKeepCredentials(t *testing.T) {
+	sent := make(chan string, 1)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent <- r.Header.Get("Authorization")
+	}))
+	defer secondary.Close()
+	base, _ := url.Parse(secondary.URL)
+
+	c := newClientOrFatal(t, secondary.URL, apiKey)
+	c.Use(Shadow(&ShadowConfig{BaseURL: base, Percent: 100, Route: true, KeepCredentials: true}))
+	if err := c.ReadJson("/", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-sent; got != `Token token="`+apiKey+`"` {
+		t.Errorf("Expected KeepCredentials to send the key, got %q", got)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitStore holds the state of rate limits shared by every instance
+// of a service, e.g. in Redis, so a fleet respects one vendor quota
+// together instead of each instance allowing the full rate. Set it as
+// RateLimitConfig.Store. Implementations must be safe for concurrent use.
+//
+// A limit is a bucket of burst slots, one freed every interval. The store
+// keeps, per key, the time at which the bucket is next fully free, tat,
+// and the time until which it is paused, and Reserve must atomically
+// compute, with the store's own clock as now:
+//
+//	tat = max(tat, now) + interval
+//	wait = max(tat - now - burst*interval, pause - now, 0)
+//
+// In Redis that is a short Lua script over a hash per key, run with EVAL
+// and the server TIME, so instance clocks need not agree.
+type RateLimitStore interface {
+	// Reserve takes a slot of the bucket under key and returns how long
+	// to wait before using it.
+	Reserve(ctx context.Context, key string, interval time.Duration, burst int) (time.Duration, error)
+
+	// Pause holds every reservation under key back until until, unless
+	// it already is for longer.
+	Pause(ctx context.Context, key string, until time.Time) error
+}
+
+// MemoryRateLimitStore is a RateLimitStore in memory, shared by the
+// clients of a single process.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*sharedBucket
+}
+
+type sharedBucket struct {
+	tat   time.Time
+	pause time.Time
+}
+
+func (s *MemoryRateLimitStore) bucket(key string) *sharedBucket {
+	if s.buckets == nil {
+		s.buckets = make(map[string]*sharedBucket)
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &sharedBucket{}
+		s.buckets[key] = b
+	}
+	return b
+}
+
+func (s *MemoryRateLimitStore) Reserve(ctx context.Context, key string, interval time.Duration, burst int) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.bucket(key)
+	now := time.Now()
+	if b.tat.Before(now) {
+		b.tat = now
+	}
+	b.tat = b.tat.Add(interval)
+	wait := b.tat.Sub(now) - time.Duration(burst)*interval
+	if d := b.pause.Sub(now); d > wait {
+		wait = d
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, nil
+}
+
+func (s *MemoryRateLimitStore) Pause(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b := s.bucket(key); until.After(b.pause) {
+		b.pause = until
+	}
+	return nil
+}
+
+// rateLimitKey returns the key of the client's limit in a RateLimitStore.
+func (c *Client) rateLimitKey(cfg *RateLimitConfig) string {
+	if cfg.Key != "" {
+		return cfg.Key
+	}
+	return "relax:ratelimit:" + c.url.Host
+}
+
+// reserveShared takes a slot for r from cfg.Store. It reports false if the
+// store failed, for the local limiter to take over.
+func (c *Client) reserveShared(r *http.Request, cfg *RateLimitConfig) (time.Duration, bool) {
+	var interval time.Duration
+	burst := cfg.Burst
+	if cfg.Requests > 0 && cfg.Per > 0 {
+		interval = cfg.Per / time.Duration(cfg.Requests)
+		if burst <= 0 {
+			burst = cfg.Requests
+		}
+	}
+	wait, err := cfg.Store.Reserve(r.Context(), c.rateLimitKey(cfg), interval, burst)
+	if err != nil {
+		cfg.storeError(fmt.Errorf("reserving a rate limit slot: %w", err))
+		return 0, false
+	}
+	return wait, true
+}
+
+// observeShared pauses the shared limit as the headers of res ask.
+func (c *Client) observeShared(r *http.Request, cfg *RateLimitConfig, res *http.Response) {
+	until, ok := rateLimitPause(cfg, res, time.Now())
+	if !ok {
+		return
+	}
+	if err := cfg.Store.Pause(r.Context(), c.rateLimitKey(cfg), until); err != nil {
+		cfg.storeError(fmt.Errorf("pausing the rate limit: %w", err))
+	}
+}
+
+func (cfg *RateLimitConfig) storeError(err error) {
+	if cfg.OnStoreError != nil {
+		cfg.OnStoreError(err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_RateLimitStoreShared(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	// Two instances sharing a quota of 20/s take as long as one client
+	// sending all of their requests.
+	store := &MemoryRateLimitStore{}
+	var clients []*Client
+	for i := 0; i < 2; i++ {
+		c := newClientOrFatal(t, server.URL, apiKey)
+		c.RateLimit = &RateLimitConfig{Requests: 20, Per: time.Second, Burst: 1, Store: store}
+		clients = append(clients, c)
+	}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := clients[i%2].ReadJson("/", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("Expected 4 requests at a shared 20/s to take at least 150ms, took %s", elapsed)
+	}
+}
+
+func TestClient_RateLimitStorePause(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	store := &MemoryRateLimitStore{}
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.RateLimit = &RateLimitConfig{FromHeaders: true, Store: store}
+	c.ReadJson("/", nil)
+
+	wait, err := store.Reserve(context.Background(), "relax:ratelimit:"+c.url.Host, 0, 0)
+	if err != nil || wait < 900*time.Millisecond {
+		t.Errorf("Expected the shared limit to be paused for the Retry-After, got %s, %v", wait, err)
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Reserve(ctx context.Context, key string, interval time.Duration, burst int) (time.Duration, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingRateLimitStore) Pause(ctx context.Context, key string, until time.Time) error {
+	return errors.New("connection refused")
+}
+
+func TestClient_RateLimitStoreFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	var errs []error
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.RateLimit = &RateLimitConfig{
+		Requests:     20,
+		Per:          time.Second,
+		Burst:        1,
+		Store:        failingRateLimitStore{},
+		OnStoreError: func(err error) { errs = append(errs, err) },
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := c.ReadJson("/", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 store errors, got %v", errs)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected the local limiter to take over, took %s", elapsed)
+	}
+}
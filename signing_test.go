@@ -0,0 +1,103 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testToken(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestTokenSigner(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := &TokenSigner{Key: []byte("secret"), TTL: time.Minute, Now: func() time.Time { return now }}
+
+	r := httptest.NewRequest(http.MethodGet, "https://cdn.example.com/files/a.zip?v=2", nil)
+	u, err := s.SignURL(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("expires") != "1700000060" || q.Get("v") != "2" {
+		t.Errorf("Unexpected query %q", u.RawQuery)
+	}
+	if want := testToken("secret", "/files/a.zip1700000060"); q.Get("token") != want {
+		t.Errorf("Expected token %s, got %s", want, q.Get("token"))
+	}
+	if r.URL.RawQuery != "v=2" {
+		t.Errorf("Expected the request to be left alone, got %s", r.URL)
+	}
+
+	s.InPath = true
+	u, err = s.SignURL(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/" + testToken("secret", "/files/a.zip1700000060") + "/1700000060/files/a.zip"; u.Path != want {
+		t.Errorf("Expected path %s, got %s", want, u.Path)
+	}
+
+	if _, err := (&TokenSigner{}).SignURL(r); err == nil {
+		t.Error("Expected an error without a key")
+	}
+}
+
+func TestClient_URLSigner(t *testing.T) {
+	var mu sync.Mutex
+	var seen []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.Query())
+		n := len(seen)
+		mu.Unlock()
+		if n == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	clock := time.Unix(1700000000, 0)
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Millisecond)}
+	c.URLSigner = &TokenSigner{Key: []byte("secret"), Now: func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}}
+
+	var response Response
+	if err := c.ReadJson("/files/a.json", &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(seen))
+	}
+	if seen[0].Get("expires") == seen[1].Get("expires") || seen[1].Get("token") == "" {
+		t.Errorf("Expected every attempt to be signed afresh, got %v", seen)
+	}
+	if c.LastResponse.Request.URL.Query().Get("token") != seen[1].Get("token") {
+		t.Errorf("Expected the response to carry the signed request")
+	}
+
+	failing := errors.New("no key")
+	c.URLSigner = URLSignerFunc(func(*http.Request) (*url.URL, error) { return nil, failing })
+	if err := c.ReadJson("/files/a.json", &response); !errors.Is(err, failing) {
+		t.Errorf("Expected the signer error, got %v", err)
+	}
+}
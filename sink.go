@@ -0,0 +1,60 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// writerTarget is a response target writing the raw body to an io.Writer.
+type writerTarget struct {
+	w io.Writer
+}
+
+// fileTarget is a response target saving the raw body to a file.
+type fileTarget struct {
+	path string
+}
+
+// ToWriter returns a response target that writes the raw response body to
+// w instead of decoding it. An empty body writes nothing. The body is
+// buffered first, like any response; use Download to stream large ones.
+//
+//	var buf bytes.Buffer
+//	err := c.ReadJson("/api/report", relax.ToWriter(&buf))
+func ToWriter(w io.Writer) interface{} {
+	return writerTarget{w: w}
+}
+
+// ToFile returns a response target that saves the raw response body to
+// path, replacing it only once the whole body has been written. An empty
+// body leaves an empty file.
+func ToFile(path string) interface{} {
+	return fileTarget{path: path}
+}
+
+// sinkTarget writes body to target if it is a writer or file target.
+func sinkTarget(target interface{}, body []byte) (bool, error) {
+	switch t := target.(type) {
+	case writerTarget:
+		_, err := t.w.Write(body)
+		return true, err
+	case fileTarget:
+		return true, writeFileAtomic(t.path, body)
+	}
+	return false, nil
+}
+
+// writeFileAtomic writes data to path+".part" and renames it into place.
+func writeFileAtomic(path string, data []byte) error {
+	part := path + ".part"
+	if err := ioutil.WriteFile(part, data, 0666); err != nil {
+		os.Remove(part)
+		return err
+	}
+	return os.Rename(part, path)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient_ToWriter(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: "not,json\n1,2\n", Path: "/report.csv"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var buf bytes.Buffer
+	if err := c.ReadJson("/report.csv", ToWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "not,json\n1,2\n" {
+		t.Errorf("Expected the raw body, got %q", buf.String())
+	}
+}
+
+func TestClient_ToFile(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: `{"Foo": "bar"}`, Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	path := filepath.Join(t.TempDir(), "foo.json")
+
+	var data Response
+	if err := c.ReadJson("/api/foo", Tee(&data, ToFile(path))); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != "bar" {
+		t.Errorf("Expected data.Foo to be \"bar\", got %q", data.Foo)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"Foo": "bar"}` {
+		t.Errorf("Expected the raw body in the file, got %q", got)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Expected no partial file to be left")
+	}
+}
+
+func TestClient_ToFileEmpty(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	path := filepath.Join(t.TempDir(), "empty")
+
+	if err := c.ReadJson("/", ToFile(path)); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Errorf("Expected an empty file, got %v %v", fi, err)
+	}
+}
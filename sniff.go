@@ -0,0 +1,86 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// ErrUnexpectedContent is matched by the UnexpectedContentError of a body
+// that failed to decode because it is in another format altogether.
+var ErrUnexpectedContent = errors.New("unexpected content")
+
+const (
+	// snippetBytes bounds UnexpectedContentError.Snippet.
+	snippetBytes = 120
+
+	// sniffLen is the most http.DetectContentType looks at.
+	sniffLen = 512
+)
+
+// UnexpectedContentError reports a response body that could not be
+// decoded because it is HTML, typically the error page of a proxy or load
+// balancer answering for the API, whatever its Content-Type claims. It is
+// the Err of a DecodeError.
+type UnexpectedContentError struct {
+	// Sniffed is the media type detected from the body.
+	Sniffed string
+
+	// Snippet is the title of the page if it has one, or else the start
+	// of the body.
+	Snippet string
+
+	// Err is the error of the decoder.
+	Err error
+}
+
+func (e *UnexpectedContentError) Error() string {
+	return fmt.Sprintf("%s: got %s: %s", ErrUnexpectedContent, e.Sniffed, e.Snippet)
+}
+
+// Unwrap returns the error of the decoder.
+func (e *UnexpectedContentError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrUnexpectedContent.
+func (e *UnexpectedContentError) Is(target error) bool {
+	return target == ErrUnexpectedContent
+}
+
+// sniffDecodeError returns an UnexpectedContentError for err if the body
+// starting with head is HTML, or else err.
+func sniffDecodeError(head []byte, err error) error {
+	sniffed, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if sniffed != "text/html" {
+		return err
+	}
+	return &UnexpectedContentError{Sniffed: sniffed, Snippet: htmlSnippet(head), Err: err}
+}
+
+// htmlSnippet returns the title of the page in head, or a preview of it.
+func htmlSnippet(head []byte) string {
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	lower := bytes.ToLower(head)
+	if start := bytes.Index(lower, []byte("<title")); start >= 0 {
+		if open := bytes.IndexByte(lower[start:], '>'); open >= 0 {
+			rest := head[start+open+1:]
+			if end := bytes.Index(bytes.ToLower(rest), []byte("</title")); end >= 0 {
+				title := strings.Join(strings.Fields(html.UnescapeString(string(rest[:end]))), " ")
+				if title != "" {
+					return bodyPreview([]byte(title), snippetBytes)
+				}
+			}
+		}
+	}
+	return bodyPreview(head, snippetBytes)
+}
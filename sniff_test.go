@@ -0,0 +1,73 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const proxyErrorPage = `<!DOCTYPE html>
+<html><head><title>502 Bad
+  Gateway</title></head><body><h1>Bad Gateway</h1></body></html>`
+
+func TestClient_UnexpectedContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/html":
+			w.Write([]byte(proxyErrorPage))
+		case "/untitled":
+			w.Write([]byte("<html><body>maintenance</body></html>"))
+		default:
+			w.Write([]byte(`{"Foo": `))
+		}
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	var data Response
+	err := c.ReadJson("/html", &data)
+	var unexpected *UnexpectedContentError
+	if !errors.Is(err, ErrUnexpectedContent) || !errors.As(err, &unexpected) {
+		t.Fatalf("Expected ErrUnexpectedContent, got %v", err)
+	}
+	if unexpected.Sniffed != "text/html" || unexpected.Snippet != "502 Bad Gateway" {
+		t.Errorf("Expected the page title as snippet, got %+v", unexpected)
+	}
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Errorf("Expected the error to remain a DecodeError, got %v", err)
+	}
+
+	if err := c.ReadJson("/untitled", &data); !errors.As(err, &unexpected) || unexpected.Snippet != "<html><body>maintenance</body></html>" {
+		t.Errorf("Expected the start of the body as snippet, got %v", err)
+	}
+
+	if err := c.ReadJson("/truncated", &data); err == nil || errors.Is(err, ErrUnexpectedContent) {
+		t.Errorf("Expected a plain decode error for invalid JSON, got %v", err)
+	}
+}
+
+func TestClient_UnexpectedContentBuffered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(proxyErrorPage))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	var data Response
+	if err := c.ReadJson("/", &data, WithResult(&Result{})); !errors.Is(err, ErrUnexpectedContent) {
+		t.Errorf("Expected ErrUnexpectedContent, got %v", err)
+	}
+
+	// Streamed bodies are sniffed even without previews.
+	c.ErrorPreviewBytes = -1
+	if err := c.ReadJson("/", &data); !errors.Is(err, ErrUnexpectedContent) {
+		t.Errorf("Expected ErrUnexpectedContent without previews, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsSamples is the number of recent latencies kept per route for
+// percentiles.
+const statsSamples = 1024
+
+// statsChunk is the number of samples allocated at a time, so routes seen
+// a few times hold a few samples.
+const statsChunk = 64
+
+// Stats is a point-in-time snapshot of the client's statistics.
+type Stats struct {
+	Since  time.Time    `json:"since"`
+	Routes []RouteStats `json:"routes"`
+}
+
+// RouteStats are the statistics of one route, keyed "METHOD /path" or with
+// the route given WithRoute in place of the path. Latency percentiles are
+// over the most recent requests only.
+type RouteStats struct {
+	Route     string        `json:"route"`
+	Count     int64         `json:"count"`
+	Errors    int64         `json:"errors"`
+	Retries   int64         `json:"retries"`
+	CacheHits int64         `json:"cache_hits"`
+	P50       time.Duration `json:"p50_ns"`
+	P95       time.Duration `json:"p95_ns"`
+	P99       time.Duration `json:"p99_ns"`
+
+	RequestBytes  SizeStats `json:"request_bytes"`
+	ResponseBytes SizeStats `json:"response_bytes"`
+}
+
+// SizeStats are the body sizes of a route, in bytes. Max is over every
+// body; percentiles are over the most recent ones only. Request bodies of
+// unknown length are left out.
+type SizeStats struct {
+	Count int64 `json:"count"`
+	Total int64 `json:"total"`
+	Max   int64 `json:"max"`
+	P50   int64 `json:"p50"`
+	P95   int64 `json:"p95"`
+	P99   int64 `json:"p99"`
+}
+
+// statsCollector aggregates the statistics of every request, so it stays
+// off locks: routes are looked up in a sync.Map and every counter and
+// sample ring is updated atomically. A snapshot taken while requests are
+// recorded may see some of a request's numbers and not others.
+type statsCollector struct {
+	since  atomic.Int64 // unix nanoseconds of the first request
+	routes sync.Map     // route -> *routeStats
+}
+
+type routeStats struct {
+	count, errors, retries, cacheHits atomic.Int64
+
+	latencies samples
+
+	requestBytes, responseBytes sizeSamples
+}
+
+// samples is a ring of the most recent statsSamples values, allocated
+// statsChunk values at a time as it fills.
+type samples struct {
+	next   atomic.Int64
+	chunks [statsSamples / statsChunk]atomic.Pointer[[statsChunk]atomic.Int64]
+}
+
+func (s *samples) add(v int64) {
+	i := (s.next.Add(1) - 1) % statsSamples
+	chunk := &s.chunks[i/statsChunk]
+	values := chunk.Load()
+	if values == nil {
+		chunk.CompareAndSwap(nil, new([statsChunk]atomic.Int64))
+		values = chunk.Load()
+	}
+	values[i%statsChunk].Store(v)
+}
+
+// sorted returns the samples in the ring, sorted.
+func (s *samples) sorted() []int64 {
+	n := s.next.Load()
+	if n > statsSamples {
+		n = statsSamples
+	}
+	out := make([]int64, 0, n)
+	for i := int64(0); i < n; i++ {
+		if values := s.chunks[i/statsChunk].Load(); values != nil {
+			out = append(out, values[i%statsChunk].Load())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// sizeSamples collects body sizes.
+type sizeSamples struct {
+	count, total, max atomic.Int64
+
+	recent samples
+}
+
+func (s *sizeSamples) add(n int64) {
+	s.count.Add(1)
+	s.total.Add(n)
+	for {
+		max := s.max.Load()
+		if n <= max || s.max.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	s.recent.add(n)
+}
+
+func (s *sizeSamples) stats() SizeStats {
+	st := SizeStats{Count: s.count.Load(), Total: s.total.Load(), Max: s.max.Load()}
+	if sorted := s.recent.sorted(); len(sorted) > 0 {
+		st.P50 = percentile(sorted, 50)
+		st.P95 = percentile(sorted, 95)
+		st.P99 = percentile(sorted, 99)
+	}
+	return st
+}
+
+// route returns the stats of route, adding them on its first request.
+func (s *statsCollector) route(route string) *routeStats {
+	if r, ok := s.routes.Load(route); ok {
+		return r.(*routeStats)
+	}
+	s.since.CompareAndSwap(0, time.Now().UnixNano())
+	r, _ := s.routes.LoadOrStore(route, &routeStats{})
+	return r.(*routeStats)
+}
+
+func (s *statsCollector) request(route string, failed bool, latency time.Duration) {
+	r := s.route(route)
+	r.count.Add(1)
+	if failed {
+		r.errors.Add(1)
+	}
+	r.latencies.add(int64(latency))
+}
+
+func (s *statsCollector) retry(route string) {
+	s.route(route).retries.Add(1)
+}
+
+func (s *statsCollector) cacheHit(route string) {
+	s.route(route).cacheHits.Add(1)
+}
+
+// payload records the body sizes of an attempt; requestBytes is negative
+// when unknown.
+func (s *statsCollector) payload(route string, requestBytes, responseBytes int64) {
+	r := s.route(route)
+	if requestBytes >= 0 {
+		r.requestBytes.add(requestBytes)
+	}
+	r.responseBytes.add(responseBytes)
+}
+
+func (s *statsCollector) snapshot() Stats {
+	var stats Stats
+	if since := s.since.Load(); since != 0 {
+		stats.Since = time.Unix(0, since)
+	}
+	stats.Routes = []RouteStats{}
+	s.routes.Range(func(key, value interface{}) bool {
+		r := value.(*routeStats)
+		rs := RouteStats{
+			Route:     key.(string),
+			Count:     r.count.Load(),
+			Errors:    r.errors.Load(),
+			Retries:   r.retries.Load(),
+			CacheHits: r.cacheHits.Load(),
+
+			RequestBytes:  r.requestBytes.stats(),
+			ResponseBytes: r.responseBytes.stats(),
+		}
+		if sorted := r.latencies.sorted(); len(sorted) > 0 {
+			rs.P50 = time.Duration(percentile(sorted, 50))
+			rs.P95 = time.Duration(percentile(sorted, 95))
+			rs.P99 = time.Duration(percentile(sorted, 99))
+		}
+		stats.Routes = append(stats.Routes, rs)
+		return true
+	})
+	sort.Slice(stats.Routes, func(i, j int) bool { return stats.Routes[i].Route < stats.Routes[j].Route })
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p of sorted.
+func percentile[T time.Duration | int64](sorted []T, p int) T {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// Stats returns a snapshot of the statistics collected while CollectStats
+// is set. The result is safe to serialize, e.g. on a debug endpoint.
+func (c *Client) Stats() Stats {
+	return c.stats.snapshot()
+}
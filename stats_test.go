@@ -0,0 +1,188 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Stats(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.CollectStats = true
+	c.Throttle = &ThrottleConfig{DefaultDelay: time.Millisecond}
+	c.ReplayTTL = time.Minute
+
+	c.CreateJson("/api/foo", nil, nil, WithIdempotencyKey("k"))
+	c.CreateJson("/api/foo", nil, nil, WithIdempotencyKey("k"))
+	c.GetResponse(mustRequest(t, c, http.MethodGet, "/api/missing"))
+
+	stats := c.Stats()
+	if len(stats.Routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %+v", stats.Routes)
+	}
+
+	missing, foo := stats.Routes[0], stats.Routes[1]
+	if missing.Route != "GET /api/missing" || missing.Count != 1 || missing.Errors != 1 {
+		t.Errorf("Unexpected stats %+v", missing)
+	}
+	if foo.Route != "POST /api/foo" || foo.Count != 1 || foo.Retries != 1 || foo.CacheHits != 1 {
+		t.Errorf("Unexpected stats %+v", foo)
+	}
+	if foo.P50 <= 0 || foo.P99 < foo.P50 {
+		t.Errorf("Unexpected latencies %+v", foo)
+	}
+
+	if _, err := json.Marshal(stats); err != nil {
+		t.Errorf("Expected stats to serialize, got %v", err)
+	}
+}
+
+func TestClient_StatsRoutes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.CollectStats = true
+	for _, id := range []string{"1", "2", "3"} {
+		if err := c.ReadJson("/api/users/"+id, nil, WithRoute("/api/users/{id}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := c.Stats()
+	if len(stats.Routes) != 1 || stats.Routes[0].Route != "GET /api/users/{id}" || stats.Routes[0].Count != 3 {
+		t.Errorf("Expected the requests under their route, got %+v", stats.Routes)
+	}
+}
+
+func TestSamples_Lazy(t *testing.T) {
+	var s samples
+	for i := int64(0); i < 3; i++ {
+		s.add(i)
+	}
+	allocated := 0
+	for i := range s.chunks {
+		if s.chunks[i].Load() != nil {
+			allocated++
+		}
+	}
+	if allocated != 1 {
+		t.Errorf("Expected one chunk for a few samples, got %d", allocated)
+	}
+
+	for i := int64(0); i < statsSamples+5; i++ {
+		s.add(i)
+	}
+	if sorted := s.sorted(); len(sorted) != statsSamples || sorted[0] != 5 {
+		t.Errorf("Expected the %d most recent samples, got %d from %d", statsSamples, len(sorted), sorted[0])
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i + 1)
+	}
+
+	if p := percentile(sorted, 50); p != 50 {
+		t.Errorf("Expected p50 of 50, got %d", p)
+	}
+	if p := percentile(sorted, 99); p != 99 {
+		t.Errorf("Expected p99 of 99, got %d", p)
+	}
+	if p := percentile(sorted[:1], 95); p != 1 {
+		t.Errorf("Expected single sample, got %d", p)
+	}
+}
+
+func TestStatsCollector_Concurrent(t *testing.T) {
+	var s statsCollector
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				s.request("GET /api/foo", i%10 == 0, time.Duration(i))
+				s.payload("GET /api/foo", -1, int64(g*1000+i))
+				if i%100 == 0 {
+					s.snapshot()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := s.snapshot()
+	if len(stats.Routes) != 1 || stats.Since.IsZero() {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	foo := stats.Routes[0]
+	if foo.Count != 4000 || foo.Errors != 400 {
+		t.Errorf("Expected 4000 requests and 400 errors, got %d and %d", foo.Count, foo.Errors)
+	}
+	if foo.ResponseBytes.Count != 4000 || foo.ResponseBytes.Max != 7499 || foo.RequestBytes.Count != 0 {
+		t.Errorf("Unexpected sizes %+v", foo)
+	}
+	if foo.P50 <= 0 || foo.P99 < foo.P50 || foo.P99 > 499 {
+		t.Errorf("Unexpected latencies %+v", foo)
+	}
+}
+
+// The collectors run on every request, so they must stay well under a
+// microsecond per request even when every goroutine hits the same route.
+func BenchmarkStatsCollector_Request(b *testing.B) {
+	var s statsCollector
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.request("GET /api/foo", false, time.Millisecond)
+			s.payload("GET /api/foo", 0, 512)
+		}
+	})
+}
+
+func BenchmarkStatsCollector_Routes(b *testing.B) {
+	var s statsCollector
+	routes := []string{"GET /api/foo", "GET /api/bar", "POST /api/foo", "DELETE /api/foo/1"}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			s.request(routes[i%len(routes)], false, time.Millisecond)
+		}
+	})
+}
+
+func BenchmarkPayloadTracker_Observe(b *testing.B) {
+	var t payloadTracker
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			t.observe("response GET /api/foo", 512)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrStopStream can be returned by a stream handler to stop reading without
+// failing the call.
+var ErrStopStream = errors.New("stop stream")
+
+// Event is a Server-Sent Event.
+type Event struct {
+	ID    string
+	Event string // "message" unless the server names the event
+	Data  []byte
+	Retry time.Duration // reconnection time requested by the server, if any
+}
+
+// Decode decodes the JSON data of the event into v.
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// ReadNDJSON GETs uri and passes each line of the newline delimited JSON
+// response to handler as it arrives, instead of buffering the body. The
+// client's WithTimeout bounds the whole stream; use a context instead for
+// long-lived streams.
+func (c *Client) ReadNDJSON(ctx context.Context, uri string, handler func(msg json.RawMessage) error, opts ...RequestOption) error {
+	return c.readStream(ctx, uri, "application/x-ndjson", func(res *http.Response) error {
+		return readNDJSON(res.Body, handler)
+	}, opts)
+}
+
+// ReadEvents GETs uri and passes each Server-Sent Event of the
+// text/event-stream response to handler as it arrives.
+func (c *Client) ReadEvents(ctx context.Context, uri string, handler func(e *Event) error, opts ...RequestOption) error {
+	return c.readStream(ctx, uri, "text/event-stream", func(res *http.Response) error {
+		return readEvents(res.Body, handler)
+	}, opts)
+}
+
+// ReadStream GETs uri and passes each JSON message to handler, reading the
+// response as Server-Sent Events when its Content-Type is
+// text/event-stream and as NDJSON otherwise.
+func (c *Client) ReadStream(ctx context.Context, uri string, handler func(msg json.RawMessage) error, opts ...RequestOption) error {
+	return c.readStream(ctx, uri, "application/x-ndjson, text/event-stream", func(res *http.Response) error {
+		mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		if mediaType == "text/event-stream" {
+			return readEvents(res.Body, func(e *Event) error {
+				return handler(json.RawMessage(e.Data))
+			})
+		}
+		return readNDJSON(res.Body, handler)
+	}, opts)
+}
+
+func (c *Client) readStream(ctx context.Context, uri, accept string, read func(*http.Response) error, opts []RequestOption) error {
+	req, err := c.MakeRequestContext(ctx, http.MethodGet, uri)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	if err := newCallOptions(opts).apply(req, c); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	body := &countingReader{}
+	fail := func(err error) error {
+		return &RequestError{
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Attempt:   1,
+			Elapsed:   time.Since(start),
+			BytesRead: body.n,
+			Err:       err,
+		}
+	}
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer res.Body.Close()
+
+	if !isSuccess(res.StatusCode) {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fail(newAPIError(res, b, c.errorPreviewBytes()))
+	}
+
+	body.r = res.Body
+	res.Body = ioutil.NopCloser(body)
+	if err := read(res); err != nil && err != ErrStopStream {
+		return fail(err)
+	}
+	return nil
+}
+
+func readNDJSON(r io.Reader, handler func(json.RawMessage) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if herr := handler(json.RawMessage(line)); herr != nil {
+				return herr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readEvents parses a text/event-stream as specified by the WHATWG HTML
+// standard.
+func readEvents(r io.Reader, handler func(*Event) error) error {
+	br := bufio.NewReader(r)
+	var e Event
+	var data bytes.Buffer
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		eof := err == io.EOF
+		line = bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case len(line) == 0:
+			if data.Len() > 0 && !eof {
+				e.Data = bytes.TrimSuffix(data.Bytes(), []byte("\n"))
+				if e.Event == "" {
+					e.Event = "message"
+				}
+				if herr := handler(&e); herr != nil {
+					return herr
+				}
+			}
+			e = Event{ID: e.ID}
+			data = bytes.Buffer{}
+		case line[0] == ':':
+			// comment
+		default:
+			field, value := line, []byte(nil)
+			if i := bytes.IndexByte(line, ':'); i >= 0 {
+				field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+			}
+			switch string(field) {
+			case "data":
+				data.Write(value)
+				data.WriteByte('\n')
+			case "event":
+				e.Event = string(value)
+			case "id":
+				e.ID = string(value)
+			case "retry":
+				if ms, err := strconv.Atoi(string(value)); err == nil {
+					e.Retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+
+		if eof {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_ReadNDJSON(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"Foo\":\"a\"}\n\n{\"Foo\":\"b\"}\n"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var got []string
+	err := c.ReadNDJSON(context.Background(), "/stream", func(msg json.RawMessage) error {
+		var r Response
+		if err := json.Unmarshal(msg, &r); err != nil {
+			return err
+		}
+		got = append(got, r.Foo)
+		if len(got) == 2 {
+			return ErrStopStream
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "a,b" {
+		t.Errorf("Expected a,b before the stream ended, got %v", got)
+	}
+}
+
+func TestClient_ReadEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			http.Error(w, "bad accept", http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "id: 1\nevent: update\ndata: {\"Foo\":\ndata:\"x\"}\r\n\r\n")
+		fmt.Fprint(w, "retry: 1500\ndata: {\"Foo\":\"y\"}\n\n")
+		fmt.Fprint(w, "data: incomplete")
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var events []*Event
+	err := c.ReadEvents(context.Background(), "/events", func(e *Event) error {
+		copied := *e
+		copied.Data = append([]byte(nil), e.Data...)
+		events = append(events, &copied)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	if e := events[0]; e.ID != "1" || e.Event != "update" || string(e.Data) != "{\"Foo\":\n\"x\"}" {
+		t.Errorf("Unexpected first event %+v", e)
+	}
+	var r Response
+	if err := events[1].Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if e := events[1]; e.ID != "1" || e.Event != "message" || e.Retry != 1500*time.Millisecond || r.Foo != "y" {
+		t.Errorf("Unexpected second event %+v", e)
+	}
+}
+
+func TestClient_ReadStream(t *testing.T) {
+	for _, ct := range []string{"text/event-stream", "application/x-ndjson"} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ct)
+			if ct == "text/event-stream" {
+				fmt.Fprint(w, "data: {\"Foo\":\"a\"}\n\n")
+			} else {
+				fmt.Fprint(w, "{\"Foo\":\"a\"}\n")
+			}
+		}))
+
+		c := newClientOrFatal(t, server.URL, apiKey)
+		var got []string
+		err := c.ReadStream(context.Background(), "/", func(msg json.RawMessage) error {
+			got = append(got, string(msg))
+			return nil
+		})
+		server.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != `{"Foo":"a"}` {
+			t.Errorf("%s: unexpected messages %q", ct, got)
+		}
+	}
+}
+
+func TestClient_ReadStreamError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	err := c.ReadNDJSON(context.Background(), "/", func(json.RawMessage) error { return nil })
+	if !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import "encoding/json"
+
+// teeTarget is a response target fanning the body out to several targets.
+type teeTarget []interface{}
+
+// Tee returns a response target that decodes the body into every target,
+// e.g. a typed struct plus a map[string]interface{} holding the fields the
+// struct doesn't know about. A *json.RawMessage or *[]byte target receives
+// a copy of the raw body instead, as do ToWriter and ToFile targets. Nil
+// targets are skipped.
+//
+//	var user User
+//	var raw json.RawMessage
+//	err := c.ReadJson("/api/users/1", relax.Tee(&user, &raw))
+func Tee(targets ...interface{}) interface{} {
+	return teeTarget(targets)
+}
+
+// rawTarget copies body into target if it wants the raw body.
+func rawTarget(target interface{}, body []byte) bool {
+	switch t := target.(type) {
+	case *json.RawMessage:
+		*t = append((*t)[:0], body...)
+	case *[]byte:
+		*t = append((*t)[:0], body...)
+	default:
+		return false
+	}
+	return true
+}
+
+// primaryTarget returns the first target of a Tee that is decoded rather
+// than copied, or response itself when it is not a Tee.
+func primaryTarget(response interface{}) interface{} {
+	t, ok := response.(teeTarget)
+	if !ok {
+		return response
+	}
+	for _, target := range t {
+		switch target.(type) {
+		case nil, *json.RawMessage, *[]byte, writerTarget, fileTarget:
+			continue
+		}
+		return target
+	}
+	return nil
+}
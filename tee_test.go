@@ -0,0 +1,40 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Tee(t *testing.T) {
+	body := "{\"Foo\": \"bar\", \"Extra\": 1}"
+	handler := responseHandler{Method: http.MethodGet, Message: body, Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	var typed Response
+	var extras map[string]interface{}
+	var raw json.RawMessage
+	var rawBytes []byte
+
+	if err := c.ReadJson("/api/foo", Tee(&typed, &extras, &raw, &rawBytes, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if typed.Foo != "bar" {
+		t.Errorf("Expected typed.Foo to be \"bar\", got %q", typed.Foo)
+	}
+	if extras["Extra"] != float64(1) {
+		t.Errorf("Expected extras to hold Extra, got %v", extras)
+	}
+	if string(raw) != body || string(rawBytes) != body {
+		t.Errorf("Expected raw body, got %q and %q", raw, rawBytes)
+	}
+}
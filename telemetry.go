@@ -0,0 +1,162 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Attribute is a key and value attached to spans. Keys follow the
+// OpenTelemetry HTTP semantic conventions.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts client spans. It is a small interface so relax needs no
+// tracing dependency; an OpenTelemetry adapter is a few lines:
+//
+//	func (t otelTracer) Start(ctx context.Context, name string, attrs []relax.Attribute) (context.Context, relax.Span) {
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(convert(attrs)...))
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs []Attribute) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. End is called once with the
+// attributes known when the attempt finished and the error it failed with,
+// if any.
+type Span interface {
+	End(attrs []Attribute, err error)
+}
+
+// RequestMetric describes one finished request attempt.
+type RequestMetric struct {
+	Method string
+
+	// Route is the route template given WithRoute, or "" if none was.
+	Route string
+
+	// StatusCode is 0 when no response was received.
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Meter records request metrics, e.g. into a request counter and a latency
+// histogram. Error rates follow from the StatusCode and Err of each metric.
+type Meter interface {
+	RecordRequest(ctx context.Context, m RequestMetric)
+}
+
+// TelemetryConfig instruments every request attempt with a client span and
+// a metric. Leaving Client.Telemetry nil turns instrumentation off at no
+// cost.
+type TelemetryConfig struct {
+	Tracer Tracer
+	Meter  Meter
+
+	// Inject, when set, writes the trace context of ctx into h, so the
+	// server joins the trace. With OpenTelemetry:
+	//
+	//	Inject: func(ctx context.Context, h http.Header) {
+	//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+	//	}
+	Inject func(ctx context.Context, h http.Header)
+}
+
+// WithTelemetry instruments the client as configured by cfg.
+func WithTelemetry(cfg *TelemetryConfig) Option {
+	return func(c *Client) {
+		c.Telemetry = cfg
+	}
+}
+
+// WithRoute names the route template of this call, like "/users/{id}",
+// for telemetry. Spans and metrics use it instead of the expanded path,
+// keeping their cardinality low.
+func WithRoute(template string) RequestOption {
+	return func(o *callOptions) {
+		o.route = template
+	}
+}
+
+type routeContextKey struct{}
+
+func withRoute(r *http.Request, route string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeContextKey{}, route))
+}
+
+func routeOf(r *http.Request) string {
+	route, _ := r.Context().Value(routeContextKey{}).(string)
+	return route
+}
+
+// start begins the span of an attempt and injects its trace context into
+// r, which must be the attempt's own copy.
+func (t *TelemetryConfig) start(r *http.Request) (*http.Request, Span) {
+	var span Span
+	if t.Tracer != nil {
+		name := r.Method
+		attrs := []Attribute{
+			{"http.request.method", r.Method},
+			{"url.full", redactRequestURL(r, nil)},
+			{"server.address", r.URL.Hostname()},
+		}
+		if route := routeOf(r); route != "" {
+			name += " " + route
+			attrs = append(attrs, Attribute{"http.route", route})
+		}
+		if port := r.URL.Port(); port != "" {
+			if n, err := strconv.Atoi(port); err == nil {
+				attrs = append(attrs, Attribute{"server.port", n})
+			}
+		}
+		var ctx context.Context
+		ctx, span = t.Tracer.Start(r.Context(), name, attrs)
+		r = r.WithContext(ctx)
+	}
+	if t.Inject != nil {
+		t.Inject(r.Context(), r.Header)
+	}
+	return r, span
+}
+
+// finish ends the span of an attempt and records its metric.
+func (t *TelemetryConfig) finish(r *http.Request, span Span, res *http.Response, err error, start time.Time) {
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	if span != nil {
+		var attrs []Attribute
+		if status != 0 {
+			attrs = append(attrs, Attribute{"http.response.status_code", status})
+		}
+		spanErr := err
+		switch {
+		case err != nil:
+			attrs = append(attrs, Attribute{"error.type", fmt.Sprintf("%T", err)})
+		case status >= 400:
+			attrs = append(attrs, Attribute{"error.type", strconv.Itoa(status)})
+			spanErr = fmt.Errorf("unexpected status %d", status)
+		}
+		span.End(attrs, spanErr)
+	}
+	if t.Meter != nil {
+		t.Meter.RecordRequest(r.Context(), RequestMetric{
+			Method:     r.Method,
+			Route:      routeOf(r),
+			StatusCode: status,
+			Duration:   time.Since(start),
+			Err:        err,
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type traceIDKey struct{}
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *recordedSpan) End(attrs []Attribute, err error) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+	s.err = err
+	s.ended = true
+}
+
+type recordingTelemetry struct {
+	mu      sync.Mutex
+	spans   []*recordedSpan
+	metrics []RequestMetric
+}
+
+func (t *recordingTelemetry) Start(ctx context.Context, name string, attrs []Attribute) (context.Context, Span) {
+	s := &recordedSpan{name: name, attrs: map[string]interface{}{}}
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, traceIDKey{}, name), s
+}
+
+func (t *recordingTelemetry) RecordRequest(ctx context.Context, m RequestMetric) {
+	t.mu.Lock()
+	t.metrics = append(t.metrics, m)
+	t.mu.Unlock()
+}
+
+func TestClient_Telemetry(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		if r.URL.Path == "/api/users/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	rec := &recordingTelemetry{}
+	c, err := NewClient(server.URL, apiKey, WithTelemetry(&TelemetryConfig{
+		Tracer: rec,
+		Meter:  rec,
+		Inject: func(ctx context.Context, h http.Header) {
+			if name, ok := ctx.Value(traceIDKey{}).(string); ok {
+				h.Set("Traceparent", name)
+			}
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response Response
+	if err := c.ReadJson("/api/users/42", &response, WithRoute("/api/users/{id}")); err != nil {
+		t.Fatal(err)
+	}
+	c.ReadJson("/api/users/missing", &response)
+
+	if len(rec.spans) != 2 || len(rec.metrics) != 2 {
+		t.Fatalf("Expected 2 spans and metrics, got %d and %d", len(rec.spans), len(rec.metrics))
+	}
+	ok := rec.spans[0]
+	if ok.name != "GET /api/users/{id}" || !ok.ended || ok.err != nil {
+		t.Errorf("Unexpected span %+v", ok)
+	}
+	if ok.attrs["http.route"] != "/api/users/{id}" || ok.attrs["http.response.status_code"] != 200 || ok.attrs["http.request.method"] != "GET" {
+		t.Errorf("Unexpected attributes %v", ok.attrs)
+	}
+
+	failed := rec.spans[1]
+	if failed.name != "GET" || failed.err == nil || failed.attrs["error.type"] != "404" {
+		t.Errorf("Expected a failed span without route, got %+v", failed)
+	}
+	if traceparent != "GET" {
+		t.Errorf("Expected the trace context to be injected, got %q", traceparent)
+	}
+
+	if m := rec.metrics[0]; m.Route != "/api/users/{id}" || m.StatusCode != 200 || m.Duration <= 0 {
+		t.Errorf("Unexpected metric %+v", m)
+	}
+	if m := rec.metrics[1]; m.StatusCode != 404 || m.Route != "" {
+		t.Errorf("Unexpected metric %+v", m)
+	}
+}
+
+func TestClient_TelemetryAttempts(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(2, http.StatusServiceUnavailable, "", &calls)
+	defer server.Close()
+
+	rec := &recordingTelemetry{}
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Telemetry = &TelemetryConfig{Meter: rec}
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(0)}
+
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.metrics) != 3 {
+		t.Fatalf("Expected a metric per attempt, got %d", len(rec.metrics))
+	}
+	if rec.metrics[0].StatusCode != http.StatusServiceUnavailable || rec.metrics[2].StatusCode != http.StatusOK {
+		t.Errorf("Unexpected metrics %+v", rec.metrics)
+	}
+}
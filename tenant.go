@@ -0,0 +1,109 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type tenantContextKey struct{}
+
+// WithTenant makes the client send its requests on behalf of tenant. It is
+// meant for the clients derived with Clone from one shared per vendor
+// account, which share its rate limiter:
+//
+//	acme := shared.Clone(relax.WithTenant("acme"))
+func WithTenant(tenant string) Option {
+	return func(c *Client) {
+		c.Tenant = tenant
+	}
+}
+
+// ContextWithTenant returns a copy of ctx whose requests are sent on
+// behalf of tenant, overriding the client's Tenant.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// tenantOf returns the tenant r is sent on behalf of.
+func (c *Client) tenantOf(r *http.Request) string {
+	if t, ok := r.Context().Value(tenantContextKey{}).(string); ok {
+		return t
+	}
+	return c.Tenant
+}
+
+// tenantBucket is the share of the rate limit of one tenant.
+type tenantBucket struct {
+	tokens float64
+	last   time.Time
+	seen   time.Time
+}
+
+func (cfg *RateLimitConfig) tenantWeight(tenant string) float64 {
+	if w, ok := cfg.TenantWeights[tenant]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// reserveTenant takes a token from the bucket of tenant and returns how
+// long to wait before using it. The rate is shared among the tenants that
+// sent requests within TenantIdle, in proportion to their weights, so a
+// tenant alone gets all of it and a busy one cannot starve the others.
+// Must be called with l.mu held.
+func (l *rateLimiter) reserveTenant(cfg *RateLimitConfig, tenant string, now time.Time) time.Duration {
+	idle := cfg.TenantIdle
+	if idle <= 0 {
+		idle = cfg.Per
+	}
+	if l.tenants == nil {
+		l.tenants = make(map[string]*tenantBucket)
+	}
+	b := l.tenants[tenant]
+	if b == nil {
+		b = &tenantBucket{}
+		l.tenants[tenant] = b
+	}
+	b.seen = now
+
+	var active float64
+	for name, t := range l.tenants {
+		if now.Sub(t.seen) > idle && t.tokens >= 0 {
+			delete(l.tenants, name)
+			continue
+		}
+		active += cfg.tenantWeight(name)
+	}
+	share := cfg.tenantWeight(tenant) / active
+
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = cfg.Requests
+	}
+	size := float64(burst) * share
+	if size < 1 {
+		size = 1
+	}
+	rate := float64(cfg.Requests) / float64(cfg.Per) * share
+
+	if b.last.IsZero() {
+		b.tokens = size
+	} else {
+		b.tokens += float64(now.Sub(b.last)) * rate
+		if b.tokens > size {
+			b.tokens = size
+		}
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens < 0 {
+		return time.Duration(-b.tokens / rate)
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_TenantFairness(t *testing.T) {
+	cfg := &RateLimitConfig{Requests: 10, Per: time.Second, PerTenant: true}
+	var l rateLimiter
+	now := time.Now()
+
+	// Alone, a tenant gets the whole rate.
+	var wait time.Duration
+	for i := 0; i < 100; i++ {
+		wait = l.reserveTenant(cfg, "noisy", now)
+	}
+	if wait < 8*time.Second {
+		t.Errorf("Expected the noisy tenant to queue up for about 9s, got %s", wait)
+	}
+
+	// Another tenant is not queued behind it, and gets half the rate.
+	for i := 0; i < 5; i++ {
+		if wait := l.reserveTenant(cfg, "quiet", now); wait != 0 {
+			t.Fatalf("Expected request %d of the quiet tenant at once, got %s", i, wait)
+		}
+	}
+	if wait := l.reserveTenant(cfg, "quiet", now); wait != 200*time.Millisecond {
+		t.Errorf("Expected the quiet tenant to wait 200ms at half the rate, got %s", wait)
+	}
+}
+
+func TestRateLimiter_TenantWeights(t *testing.T) {
+	cfg := &RateLimitConfig{Requests: 8, Per: time.Second, PerTenant: true, TenantWeights: map[string]float64{"big": 3}}
+	var l rateLimiter
+	now := time.Now()
+
+	l.reserveTenant(cfg, "small", now)
+	for i := 0; i < 6; i++ {
+		if wait := l.reserveTenant(cfg, "big", now); wait != 0 {
+			t.Fatalf("Expected a burst of 6 for three quarters of 8, got a wait at %d", i)
+		}
+	}
+	if wait := l.reserveTenant(cfg, "big", now); wait != time.Second/6 {
+		t.Errorf("Expected big to wait 1/6s at 6/s, got %s", wait)
+	}
+}
+
+func TestClient_Tenant(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	shared, err := NewClient(server.URL, apiKey, WithRateLimit(10, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared.RateLimit.PerTenant = true
+	acme := shared.Clone(WithTenant("acme"))
+
+	var response Response
+	for i := 0; i < 10; i++ {
+		if err := acme.ReadJson("/", &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	ctx := ContextWithTenant(context.Background(), "globex")
+	if err := acme.ReadJsonContext(ctx, "/", &response); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected another tenant not to wait for acme's quota, took %s", elapsed)
+	}
+	if got := shared.limiter.tenants["acme"]; got == nil || got.tokens >= 1 {
+		t.Errorf("Expected acme to have used up its bucket through the shared limiter, got %+v", got)
+	}
+}
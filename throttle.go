@@ -0,0 +1,219 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrThrottleQueueFull is returned when a request would be parked
+	// behind a 429 but ThrottleConfig.MaxQueue requests already are.
+	ErrThrottleQueueFull = errors.New("throttle queue is full")
+
+	// ErrThrottleWaitExceeded is returned when a parked request would have
+	// to wait longer than ThrottleConfig.MaxWait in total.
+	ErrThrottleWaitExceeded = errors.New("throttle wait exceeds limit")
+)
+
+// ThrottleConfig enables queue-and-delay handling of 429 responses. Instead
+// of failing, the rejected request and every request issued after it are
+// parked until the server's Retry-After has passed, then released one per
+// Spacing so the backlog doesn't trigger another 429.
+type ThrottleConfig struct {
+	// MaxQueue caps the number of parked requests. Zero means no limit.
+	MaxQueue int
+
+	// MaxWait caps the total time a single request may spend parked. Zero
+	// means no limit; the request context still applies.
+	MaxWait time.Duration
+
+	// Spacing is the gap between releases of parked requests.
+	Spacing time.Duration
+
+	// DefaultDelay is used when a 429 carries no usable Retry-After.
+	// Defaults to one second.
+	DefaultDelay time.Duration
+}
+
+type throttle struct {
+	mu     sync.Mutex
+	until  time.Time // nothing is released before this
+	next   time.Time // next free release slot
+	queued int
+
+	// free holds the slots before next given back by requests that
+	// stopped waiting, for the next requests to take.
+	free []time.Time
+}
+
+// reserve claims a release slot for a request that already waited for
+// waited, and returns the slot and how long to wait for it. A request that
+// would wait beyond cfg.MaxWait in total claims nothing.
+func (t *throttle) reserve(cfg *ThrottleConfig, waited time.Duration) (time.Time, time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(t.until) {
+		t.free = nil
+		return time.Time{}, 0, nil
+	}
+	if cfg.MaxQueue > 0 && t.queued >= cfg.MaxQueue {
+		return time.Time{}, 0, ErrThrottleQueueFull
+	}
+
+	i := t.earliestFree(now)
+	slot := t.until
+	if i >= 0 {
+		slot = t.free[i]
+	} else if t.next.After(slot) {
+		slot = t.next
+	}
+	if cfg.MaxWait > 0 && waited+slot.Sub(now) > cfg.MaxWait {
+		return time.Time{}, 0, ErrThrottleWaitExceeded
+	}
+	if i >= 0 {
+		t.free = append(t.free[:i], t.free[i+1:]...)
+	} else {
+		t.next = slot.Add(cfg.Spacing)
+	}
+	t.queued++
+	return slot, slot.Sub(now), nil
+}
+
+// earliestFree drops the given back slots that passed and returns the
+// index of the earliest one left, or -1.
+func (t *throttle) earliestFree(now time.Time) int {
+	kept := t.free[:0]
+	for _, slot := range t.free {
+		if slot.After(now) && !slot.Before(t.until) {
+			kept = append(kept, slot)
+		}
+	}
+	t.free = kept
+	best := -1
+	for i, slot := range t.free {
+		if best < 0 || slot.Before(t.free[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// release ends the wait of a request that was sent at its slot.
+func (t *throttle) release() {
+	t.mu.Lock()
+	t.queued--
+	t.mu.Unlock()
+}
+
+// giveBack ends the wait of a request that gave up on slot, so the
+// requests after it do not wait for it.
+func (t *throttle) giveBack(slot time.Time, cfg *ThrottleConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.queued--
+	if slot.Add(cfg.Spacing).Equal(t.next) {
+		t.next = slot
+		return
+	}
+	t.free = append(t.free, slot)
+}
+
+// block parks all requests for d.
+func (t *throttle) block(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(t.until) {
+		t.until = until
+	}
+}
+
+// parseRetryAfter parses a Retry-After value given in seconds or as an
+// HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sendThrottled sends r, parking it while the client is throttled and
+// resending it after a 429 when its body can be replayed.
+func (c *Client) sendThrottled(r *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	cfg := c.Throttle
+	var waited time.Duration
+	for {
+		slot, wait, err := c.throttle.reserve(cfg, waited)
+		if err != nil {
+			return nil, err
+		}
+		if !slot.IsZero() {
+			if err := sleepContext(r, wait); err != nil {
+				c.throttle.giveBack(slot, cfg)
+				return nil, err
+			}
+			c.throttle.release()
+			waited += wait
+		}
+
+		res, err := send(r)
+		if err != nil || res.StatusCode != http.StatusTooManyRequests {
+			return res, err
+		}
+
+		delay, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			delay = cfg.DefaultDelay
+			if delay <= 0 {
+				delay = time.Second
+			}
+		}
+		c.throttle.block(delay)
+		if c.CollectStats {
+			c.stats.retry(routeKey(r))
+		}
+
+		if rewindBody(r) != nil {
+			return res, nil
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+	}
+}
+
+// sleepContext waits for d or until the context of r is done.
+func sleepContext(r *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTooManyServer answers the first n requests with 429.
+func newTooManyServer(n int32, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= n {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("{\"Foo\": " + string(body) + "}"))
+	}))
+}
+
+func TestClient_ThrottleParksAndResends(t *testing.T) {
+	var calls int32
+	server := newTooManyServer(1, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Throttle = &ThrottleConfig{DefaultDelay: 20 * time.Millisecond}
+
+	start := time.Now()
+	var response Response
+	if err := c.CreateJson("/api/foo", "bar", &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Foo != "bar" {
+		t.Errorf("Expected resent body to be intact, got %q", response.Foo)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 calls, got %d", n)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected request to be parked, took %s", elapsed)
+	}
+}
+
+func TestClient_ThrottleMaxWait(t *testing.T) {
+	var calls int32
+	server := newTooManyServer(1, &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Throttle = &ThrottleConfig{DefaultDelay: time.Hour, MaxWait: time.Second}
+
+	err := c.ReadJson("/api/foo", nil)
+	if !errors.Is(err, ErrThrottleWaitExceeded) {
+		t.Errorf("Expected ErrThrottleWaitExceeded, got %v", err)
+	}
+}
+
+func TestThrottle_MaxQueue(t *testing.T) {
+	var th throttle
+	cfg := &ThrottleConfig{MaxQueue: 1, Spacing: time.Second}
+	th.block(time.Hour)
+
+	_, first, err := th.reserve(cfg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := th.reserve(cfg, 0); err != ErrThrottleQueueFull {
+		t.Errorf("Expected ErrThrottleQueueFull, got %v", err)
+	}
+
+	th.release()
+	_, second, err := th.reserve(cfg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second-first < 900*time.Millisecond {
+		t.Errorf("Expected releases to be spaced, got %s and %s", first, second)
+	}
+}
+
+func TestThrottle_RejectedKeepsSlots(t *testing.T) {
+	var th throttle
+	cfg := &ThrottleConfig{Spacing: time.Second, MaxWait: 90 * time.Second}
+	th.block(time.Minute)
+
+	if _, _, err := th.reserve(cfg, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, _, err := th.reserve(cfg, 45*time.Second); err != ErrThrottleWaitExceeded {
+			t.Fatalf("Expected ErrThrottleWaitExceeded, got %v", err)
+		}
+	}
+	_, wait, err := th.reserve(cfg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait > time.Minute+time.Second {
+		t.Errorf("Expected rejected requests not to push the next slot back, got a wait of %s", wait)
+	}
+	if th.queued != 2 {
+		t.Errorf("Expected rejected requests not to be queued, got %d", th.queued)
+	}
+}
+
+func TestThrottle_GiveBack(t *testing.T) {
+	var th throttle
+	cfg := &ThrottleConfig{Spacing: time.Second}
+	th.block(time.Minute)
+
+	first, _, _ := th.reserve(cfg, 0)
+	second, _, _ := th.reserve(cfg, 0)
+	third, _, _ := th.reserve(cfg, 0)
+
+	// The last slot is handed back by rewinding, an earlier one is kept for
+	// the next request.
+	th.giveBack(third, cfg)
+	if slot, _, _ := th.reserve(cfg, 0); !slot.Equal(third) {
+		t.Errorf("Expected the last slot given back to be reused, got %s after %s", slot.Sub(first), third.Sub(first))
+	}
+	th.giveBack(second, cfg)
+	if slot, _, _ := th.reserve(cfg, 0); !slot.Equal(second) {
+		t.Errorf("Expected the slot given back to be reused, got %s after %s", slot.Sub(first), second.Sub(first))
+	}
+	if slot, _, _ := th.reserve(cfg, 0); !slot.Equal(third.Add(cfg.Spacing)) {
+		t.Errorf("Expected the next slot after the last, got %s", slot.Sub(first))
+	}
+	if th.queued != 4 {
+		t.Errorf("Expected 4 requests queued, got %d", th.queued)
+	}
+}
+
+func TestClient_ThrottleCanceledGivesBackSlot(t *testing.T) {
+	c := newClientOrFatal(t, "http://example.com", apiKey)
+	c.Throttle = &ThrottleConfig{Spacing: time.Second}
+	c.throttle.block(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.ReadJsonContext(ctx, "/api/foo", nil); err == nil {
+		t.Fatal("Expected the parked request to be canceled")
+	}
+	if _, wait, _ := c.throttle.reserve(c.Throttle, 0); wait > time.Minute {
+		t.Errorf("Expected the canceled request's slot back, got a wait of %s", wait)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Wed, 21 Oct 2015 07:28:30 GMT", 30 * time.Second, true},
+		{"Wed, 21 Oct 2015 07:27:00 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
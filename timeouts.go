@@ -0,0 +1,189 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeoutPhase names the part of a request a TimeoutError occurred in.
+type TimeoutPhase string
+
+const (
+	TimeoutDial           TimeoutPhase = "dial"
+	TimeoutTLSHandshake   TimeoutPhase = "tls handshake"
+	TimeoutResponseHeader TimeoutPhase = "response header"
+	TimeoutBodyIdle       TimeoutPhase = "body idle"
+)
+
+// Timeouts bound the phases of a request separately, so a server slow to
+// answer and one slow to stream its answer fail differently and can be
+// tuned independently. Zero fields leave a phase unbounded. The overall
+// limit set WithTimeout still applies.
+type Timeouts struct {
+	// Dial bounds opening the TCP connection.
+	Dial time.Duration
+
+	// TLSHandshake bounds the TLS handshake of a new connection.
+	TLSHandshake time.Duration
+
+	// ResponseHeader bounds the wait for the response headers once the
+	// request is written.
+	ResponseHeader time.Duration
+
+	// BodyIdle bounds each read of the response body, so a stream that
+	// stalls fails while one that keeps delivering data does not.
+	BodyIdle time.Duration
+}
+
+// IsZero reports whether no timeout is set.
+func (t Timeouts) IsZero() bool {
+	return t == Timeouts{}
+}
+
+// String lists the timeouts that are set, like "dial 5s, body idle 30s".
+func (t Timeouts) String() string {
+	var parts []string
+	for _, p := range []struct {
+		phase TimeoutPhase
+		d     time.Duration
+	}{
+		{TimeoutDial, t.Dial},
+		{TimeoutTLSHandshake, t.TLSHandshake},
+		{TimeoutResponseHeader, t.ResponseHeader},
+		{TimeoutBodyIdle, t.BodyIdle},
+	} {
+		if p.d > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", p.phase, p.d))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithTimeouts bounds the phases of every request by t. Dial, TLSHandshake
+// and ResponseHeader configure the transport the client creates, or a copy
+// of the *http.Transport of the http.Client given WithHTTPClient.
+func WithTimeouts(t Timeouts) Option {
+	return func(c *Client) {
+		c.timeouts = t
+	}
+}
+
+// TimeoutError reports a request that exceeded one of its Timeouts.
+type TimeoutError struct {
+	Phase TimeoutPhase
+
+	// Limit is the timeout that was exceeded.
+	Limit time.Duration
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout after %s: %s", e.Phase, e.Limit, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports true, as for net.Error.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// applyTimeouts sets the connection timeouts on a copy of the transport of
+// hc when it is an *http.Transport.
+func (c *Client) applyTimeouts(hc *http.Client) {
+	t := c.timeouts
+	if t.Dial <= 0 && t.TLSHandshake <= 0 && t.ResponseHeader <= 0 {
+		return
+	}
+	tr, ok := hc.Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	tr = tr.Clone()
+	if t.TLSHandshake > 0 {
+		tr.TLSHandshakeTimeout = t.TLSHandshake
+	}
+	if t.ResponseHeader > 0 {
+		tr.ResponseHeaderTimeout = t.ResponseHeader
+	}
+	if t.Dial > 0 {
+		tr.DialContext = dialTimeout(tr.DialContext, t.Dial)
+	}
+	hc.Transport = tr
+}
+
+// dialTimeout bounds dial by d, reporting a TimeoutError when it runs out.
+func dialTimeout(dial func(ctx context.Context, network, addr string) (net.Conn, error), d time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		conn, err := dial(dctx, network, addr)
+		if err != nil && ctx.Err() == nil && dctx.Err() == context.DeadlineExceeded {
+			return nil, &TimeoutError{Phase: TimeoutDial, Limit: d, Err: err}
+		}
+		return conn, err
+	}
+}
+
+// classifyTimeout turns the timeout errors of http.Transport into a
+// TimeoutError. net/http does not export their types, so they are told
+// apart by their text.
+func (c *Client) classifyTimeout(err error) error {
+	t := c.timeouts
+	switch msg := err.Error(); {
+	case t.TLSHandshake > 0 && strings.Contains(msg, "TLS handshake timeout"):
+		return &TimeoutError{Phase: TimeoutTLSHandshake, Limit: t.TLSHandshake, Err: err}
+	case t.ResponseHeader > 0 && strings.Contains(msg, "timeout awaiting response headers"):
+		return &TimeoutError{Phase: TimeoutResponseHeader, Limit: t.ResponseHeader, Err: err}
+	}
+	return err
+}
+
+// idleBody closes the body it wraps when a read waits longer than timeout,
+// failing that read with a TimeoutError.
+type idleBody struct {
+	io.ReadCloser
+	timeout time.Duration
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	timer := time.AfterFunc(b.timeout, func() {
+		b.mu.Lock()
+		b.expired = true
+		b.mu.Unlock()
+		b.ReadCloser.Close()
+	})
+	n, err := b.ReadCloser.Read(p)
+	timer.Stop()
+	if err != nil {
+		b.mu.Lock()
+		expired := b.expired
+		b.mu.Unlock()
+		if expired {
+			return n, &TimeoutError{Phase: TimeoutBodyIdle, Limit: b.timeout, Err: err}
+		}
+	}
+	return n, err
+}
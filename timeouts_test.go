@@ -0,0 +1,133 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTimeoutsClient(t *testing.T, url string, timeouts Timeouts, opts ...Option) *Client {
+	c, err := NewClient(url, apiKey, append(opts, WithTimeouts(timeouts))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func expectTimeout(t *testing.T, err error, phase TimeoutPhase) {
+	t.Helper()
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("Expected a TimeoutError, got %v", err)
+	}
+	if te.Phase != phase {
+		t.Errorf("Expected a %s timeout, got %s", phase, te.Phase)
+	}
+}
+
+func TestClient_TimeoutsDial(t *testing.T) {
+	hc := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}}
+	c := newTimeoutsClient(t, "http://example.invalid", Timeouts{Dial: 20 * time.Millisecond}, WithHTTPClient(hc))
+	expectTimeout(t, c.ReadJson("/", nil), TimeoutDial)
+}
+
+func TestClient_TimeoutsTLSHandshake(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept connections but never answer the handshake.
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	c := newTimeoutsClient(t, "https://"+ln.Addr().String(), Timeouts{TLSHandshake: 20 * time.Millisecond})
+	expectTimeout(t, c.ReadJson("/", nil), TimeoutTLSHandshake)
+}
+
+func TestClient_TimeoutsResponseHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c := newTimeoutsClient(t, server.URL, Timeouts{ResponseHeader: 20 * time.Millisecond})
+	expectTimeout(t, c.ReadJson("/", nil), TimeoutResponseHeader)
+}
+
+func TestClient_TimeoutsBodyIdle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pause := 5 * time.Millisecond
+		if r.URL.Path == "/stalled" {
+			pause = time.Second
+		}
+		w.Write([]byte(`{"Foo": `))
+		w.(http.Flusher).Flush()
+		for i := 0; i < 5; i++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(pause):
+			}
+			w.Write([]byte(" "))
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(`"bar"}`))
+	}))
+	defer server.Close()
+
+	// The streaming response takes longer than BodyIdle overall but never
+	// stalls for that long.
+	c := newTimeoutsClient(t, server.URL, Timeouts{BodyIdle: 200 * time.Millisecond})
+	var data Response
+	if err := c.ReadJson("/streaming", &data); err != nil || data.Foo != "bar" {
+		t.Errorf("Expected the streaming body to be read, got %+v, %v", data, err)
+	}
+	expectTimeout(t, c.ReadJson("/stalled", &data), TimeoutBodyIdle)
+}
+
+func TestClient_TimeoutsDescribe(t *testing.T) {
+	c := newTimeoutsClient(t, "http://example.com", Timeouts{Dial: time.Second, BodyIdle: 30 * time.Second})
+	d := c.Describe()
+	if d.Timeouts.Dial != time.Second {
+		t.Errorf("Expected the timeouts to be described, got %+v", d.Timeouts)
+	}
+	if !strings.Contains(d.String(), "timeouts: dial 1s, body idle 30s\n") {
+		t.Errorf("Expected the timeouts line, got %q", d.String())
+	}
+
+	clone := c.Clone()
+	if clone.client.Transport.(*http.Transport).DialContext == nil || clone.timeouts != c.timeouts {
+		t.Errorf("Expected the clone to keep the timeouts")
+	}
+}
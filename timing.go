@@ -0,0 +1,203 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// AttemptTiming breaks down a single attempt of a request. Phases that did
+// not happen, such as DNS and Connect on a reused connection, are zero.
+type AttemptTiming struct {
+	Start time.Time
+
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+
+	// TTFB runs from the request being written to the first response
+	// byte: server time plus one round trip.
+	TTFB time.Duration
+
+	// BodyRead runs from the first response byte until the body was read
+	// to the end or closed.
+	BodyRead time.Duration
+
+	// Total runs from Start until the body was read or closed, or until
+	// the attempt failed.
+	Total time.Duration
+
+	Reused bool
+}
+
+// Timing is the breakdown of a call, collected when Client.TraceTiming is
+// set or the call passes WithTiming.
+type Timing struct {
+	Attempts []AttemptTiming
+
+	// Decode is the time spent decoding the response body.
+	Decode time.Duration
+
+	// Total is the wall time of the whole call.
+	Total time.Duration
+}
+
+// WithTiming collects the timing breakdown of this call into t.
+func WithTiming(t *Timing) RequestOption {
+	return func(o *callOptions) {
+		o.timing = t
+	}
+}
+
+type timingContextKey struct{}
+
+// timingRecorder collects a Timing across the attempts of a call.
+type timingRecorder struct {
+	mu sync.Mutex
+	t  *Timing
+}
+
+// timingFor returns the recorder for a call, or nil if timing is off. The
+// returned request carries it to every attempt.
+func (c *Client) timingFor(r *http.Request, o *callOptions) (*http.Request, *timingRecorder) {
+	t := o.timing
+	if t == nil && !c.TraceTiming {
+		return r, nil
+	}
+	if t == nil {
+		t = &Timing{}
+	}
+	*t = Timing{}
+	rec := &timingRecorder{t: t}
+	return r.WithContext(context.WithValue(r.Context(), timingContextKey{}, rec)), rec
+}
+
+// attempt starts timing an attempt of r, returning the request to send and
+// a func to call when the attempt is over, with its response if any.
+func (rec *timingRecorder) attempt(r *http.Request) (*http.Request, func(*http.Response, error)) {
+	rec.mu.Lock()
+	rec.t.Attempts = append(rec.t.Attempts, AttemptTiming{Start: time.Now()})
+	i := len(rec.t.Attempts) - 1
+	rec.mu.Unlock()
+
+	// The hooks may run on transport goroutines, so everything they touch
+	// is guarded by rec.mu.
+	var m struct{ dns, connect, tls, wrote, firstByte time.Time }
+	update := func(f func(a *AttemptTiming)) {
+		rec.mu.Lock()
+		f(&rec.t.Attempts[i])
+		rec.mu.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			update(func(a *AttemptTiming) { a.Reused = info.Reused })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			update(func(*AttemptTiming) { m.dns = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			update(func(a *AttemptTiming) { a.DNS = time.Since(m.dns) })
+		},
+		ConnectStart: func(string, string) {
+			update(func(*AttemptTiming) {
+				if m.connect.IsZero() {
+					m.connect = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(string, string, error) {
+			update(func(a *AttemptTiming) {
+				if a.Connect == 0 {
+					a.Connect = time.Since(m.connect)
+				}
+			})
+		},
+		TLSHandshakeStart: func() {
+			update(func(*AttemptTiming) { m.tls = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			update(func(a *AttemptTiming) { a.TLS = time.Since(m.tls) })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			update(func(*AttemptTiming) { m.wrote = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			update(func(a *AttemptTiming) {
+				m.firstByte = time.Now()
+				if !m.wrote.IsZero() {
+					a.TTFB = m.firstByte.Sub(m.wrote)
+				}
+			})
+		},
+	}
+
+	done := func(res *http.Response, err error) {
+		if err != nil || res == nil {
+			update(func(a *AttemptTiming) { a.Total = time.Since(a.Start) })
+			return
+		}
+		res.Body = &timedBody{ReadCloser: res.Body, done: func() {
+			update(func(a *AttemptTiming) {
+				if !m.firstByte.IsZero() {
+					a.BodyRead = time.Since(m.firstByte)
+				}
+				a.Total = time.Since(a.Start)
+			})
+		}}
+	}
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), trace)), done
+}
+
+func (rec *timingRecorder) decoded(d time.Duration) {
+	rec.mu.Lock()
+	rec.t.Decode += d
+	rec.mu.Unlock()
+}
+
+func (rec *timingRecorder) finish(start time.Time) {
+	rec.mu.Lock()
+	rec.t.Total = time.Since(start)
+	rec.mu.Unlock()
+}
+
+// timedBody calls done once, when the body hits EOF or is closed.
+type timedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// timeAttempt wraps send so each attempt of r is timed when the call
+// collects timing.
+func timeAttempt(r *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	rec, ok := r.Context().Value(timingContextKey{}).(*timingRecorder)
+	if !ok {
+		return send(r)
+	}
+	traced, done := rec.attempt(r)
+	res, err := send(traced)
+	done(res, err)
+	return res, err
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_TraceTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"Foo": "bar"}`))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.TraceTiming = true
+
+	var data Response
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+
+	timing := c.LastTiming
+	if timing == nil || len(timing.Attempts) != 1 {
+		t.Fatalf("Expected one timed attempt, got %+v", timing)
+	}
+	a := timing.Attempts[0]
+	if a.TTFB < 20*time.Millisecond {
+		t.Errorf("Expected TTFB to include the server delay, got %s", a.TTFB)
+	}
+	if a.Connect <= 0 || a.Reused {
+		t.Errorf("Expected a new connection to be timed, got %+v", a)
+	}
+	if a.Total < a.TTFB || timing.Total < a.Total {
+		t.Errorf("Expected totals to cover the phases, got %+v", timing)
+	}
+
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+	if a := c.LastTiming.Attempts[0]; !a.Reused || a.Connect != 0 {
+		t.Errorf("Expected the second call to reuse the connection, got %+v", a)
+	}
+}
+
+func TestClient_WithTimingRetries(t *testing.T) {
+	var calls int32
+	server := newFlakyServer(1, http.StatusServiceUnavailable, "", &calls)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond)}
+
+	var timing Timing
+	var response Response
+	if err := c.UpdateJson("/api/foo", "bar", &response, WithTiming(&timing)); err != nil {
+		t.Fatal(err)
+	}
+	if len(timing.Attempts) != 2 {
+		t.Fatalf("Expected two timed attempts, got %+v", timing.Attempts)
+	}
+	for i, a := range timing.Attempts {
+		if a.Total <= 0 {
+			t.Errorf("Expected attempt %d to be timed, got %+v", i, a)
+		}
+	}
+	if c.LastTiming != &timing {
+		t.Errorf("Expected LastTiming to point at the call's Timing")
+	}
+}
+
+func TestClient_TimingOff(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: `{}`, Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if err := c.ReadJson("/api/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.LastTiming != nil {
+		t.Errorf("Expected no timing unless asked for, got %+v", c.LastTiming)
+	}
+}
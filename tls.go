@@ -0,0 +1,93 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// The TLS options configure the transport the client creates, or a copy of
+// the *http.Transport of the http.Client given WithHTTPClient. Any other
+// http.RoundTripper must be configured directly.
+
+// WithTLSConfig uses a copy of cfg for TLS connections. Later TLS options
+// adjust that copy.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = cfg.Clone()
+	}
+}
+
+// WithRootCAs verifies servers against pool instead of the system roots.
+// See LoadCertPool.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(c *Client) {
+		c.ownTLSConfig().RootCAs = pool
+	}
+}
+
+// WithClientCertificate presents cert to servers that ask for one, for
+// mutual TLS. Load it with tls.LoadX509KeyPair.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *Client) {
+		cfg := c.ownTLSConfig()
+		cfg.Certificates = append(cfg.Certificates[:len(cfg.Certificates):len(cfg.Certificates)], cert)
+	}
+}
+
+// WithInsecureSkipVerify accepts any server certificate and host name.
+// It makes connections open to interception and is meant only for
+// development environments with self-signed certificates; prefer
+// WithRootCAs.
+func WithInsecureSkipVerify() Option {
+	return func(c *Client) {
+		c.ownTLSConfig().InsecureSkipVerify = true
+	}
+}
+
+// LoadCertPool returns a pool holding the PEM certificates in files, for
+// WithRootCAs. To trust them in addition to the system roots, start from
+// x509.SystemCertPool and call AppendCertsFromPEM instead.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// ownTLSConfig returns a TLS config the client may modify. Every TLS
+// option works on a fresh copy, so clones never share changes.
+func (c *Client) ownTLSConfig() *tls.Config {
+	if c.tlsConfig == nil {
+		c.tlsConfig = &tls.Config{}
+	} else {
+		c.tlsConfig = c.tlsConfig.Clone()
+	}
+	return c.tlsConfig
+}
+
+// applyTLS sets the client's TLS config on a copy of the transport of hc
+// when it is an *http.Transport.
+func (c *Client) applyTLS(hc *http.Client) {
+	if c.tlsConfig == nil {
+		return
+	}
+	if t, ok := hc.Transport.(*http.Transport); ok {
+		t = t.Clone()
+		t.TLSClientConfig = c.tlsConfig.Clone()
+		hc.Transport = t
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func newTLSServer(t *testing.T, clientAuth bool) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte(`{"Foo": "` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+			return
+		}
+		w.Write([]byte(`{"Foo": "anonymous"}`))
+	}))
+	if clientAuth {
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	}
+	server.StartTLS()
+	return server
+}
+
+func TestClient_TLSUntrusted(t *testing.T) {
+	server := newTLSServer(t, false)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	if err := c.ReadJson("/", nil); err == nil {
+		t.Errorf("Expected an unknown certificate authority to be rejected")
+	}
+
+	c, err := NewClient(server.URL, apiKey, WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReadJson("/", nil); err != nil {
+		t.Errorf("Expected verification to be skipped, got %v", err)
+	}
+}
+
+func TestClient_TLSRootCAsAndClientCertificate(t *testing.T) {
+	server := newTLSServer(t, true)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(path, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := LoadCertPool(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server's own certificate doubles as a client certificate.
+	cert := server.TLS.Certificates[0]
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(server.URL, apiKey, WithRootCAs(pool), WithClientCertificate(cert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data Response
+	if err := c.ReadJson("/", &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Foo != leaf.Subject.CommonName {
+		t.Errorf("Expected the client certificate %q to be presented, got %q", leaf.Subject.CommonName, data.Foo)
+	}
+
+	plain := c.Clone(WithTLSConfig(&tls.Config{RootCAs: pool}))
+	if err := plain.ReadJson("/", nil); err == nil {
+		t.Errorf("Expected a clone without the client certificate to be rejected")
+	}
+	if err := c.ReadJson("/", &data); err != nil {
+		t.Errorf("Expected the parent to keep its certificate, got %v", err)
+	}
+}
+
+func TestLoadCertPoolErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.pem")
+	if err := ioutil.WriteFile(path, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(path); err == nil {
+		t.Errorf("Expected a file without certificates to fail")
+	}
+	if _, err := LoadCertPool(path + ".missing"); err == nil {
+		t.Errorf("Expected a missing file to fail")
+	}
+}
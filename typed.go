@@ -0,0 +1,49 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+)
+
+// Get GETs uri and returns the decoded response.
+//
+//	user, err := relax.Get[User](ctx, c, "/users/42")
+func Get[T any](ctx context.Context, c *Client, uri string, opts ...RequestOption) (T, error) {
+	var out T
+	err := c.ReadJsonContext(ctx, uri, &out, opts...)
+	return out, err
+}
+
+// Post POSTs body as JSON to uri and returns the decoded response.
+func Post[Req, Resp any](ctx context.Context, c *Client, uri string, body Req, opts ...RequestOption) (Resp, error) {
+	return Send[Req, Resp](ctx, c, http.MethodPost, uri, body, opts...)
+}
+
+// Put PUTs body as JSON to uri and returns the decoded response.
+func Put[Req, Resp any](ctx context.Context, c *Client, uri string, body Req, opts ...RequestOption) (Resp, error) {
+	return Send[Req, Resp](ctx, c, http.MethodPut, uri, body, opts...)
+}
+
+// Patch PATCHes body as JSON to uri and returns the decoded response.
+func Patch[Req, Resp any](ctx context.Context, c *Client, uri string, body Req, opts ...RequestOption) (Resp, error) {
+	return Send[Req, Resp](ctx, c, http.MethodPatch, uri, body, opts...)
+}
+
+// Delete DELETEs uri and returns the decoded response.
+func Delete[T any](ctx context.Context, c *Client, uri string, opts ...RequestOption) (T, error) {
+	var out T
+	err := c.DeleteJsonContext(ctx, uri, &out, opts...)
+	return out, err
+}
+
+// Send sends body as JSON with method to uri and returns the decoded
+// response.
+func Send[Req, Resp any](ctx context.Context, c *Client, method, uri string, body Req, opts ...RequestOption) (Resp, error) {
+	var out Resp
+	err := c.DoContext(ctx, method, uri, body, &out, opts...)
+	return out, err
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	handler := responseHandler{Method: http.MethodGet, Message: "{\"Foo\": \"bar\"}", Path: "/api/foo"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	got, err := Get[Response](context.Background(), c, "/api/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Foo != "bar" {
+		t.Errorf("Expected Foo to be \"bar\", got %q", got.Foo)
+	}
+
+	list, err := Get[map[string]string](context.Background(), c, "/api/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list["Foo"] != "bar" {
+		t.Errorf("Expected map to hold Foo, got %v", list)
+	}
+}
+
+func TestPost(t *testing.T) {
+	type postData struct {
+		Name string
+	}
+	handler := responseHandler{Method: http.MethodPost, Message: "{\"Foo\": \"bar\"}", Path: "/api/foo", ExpectedBody: "{\"Name\":\"new_name\"}"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	got, err := Post[postData, *Response](context.Background(), c, "/api/foo", postData{Name: "new_name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Foo != "bar" {
+		t.Errorf("Expected Foo to be \"bar\", got %+v", got)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	got, err := Delete[*Response](context.Background(), c, "/api/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("Expected nil for 204, got %+v", got)
+	}
+}
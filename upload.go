@@ -0,0 +1,382 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TusVersion is the version of the tus resumable upload protocol
+	// spoken by Upload.
+	TusVersion = "1.0.0"
+
+	// DefaultUploadChunkSize is used when UploadOptions.ChunkSize is zero.
+	DefaultUploadChunkSize = 4 << 20
+)
+
+// UploadState is the progress of a resumable upload, saved to an
+// UploadStore after every chunk.
+type UploadState struct {
+	Key string
+
+	// URL is the upload resource the server created.
+	URL    string
+	Size   int64
+	Offset int64
+
+	Updated time.Time
+}
+
+// UploadStore persists the state of resumable uploads, so an upload
+// interrupted by a restart continues where it stopped instead of sending
+// the completed chunks again.
+type UploadStore interface {
+	// Load returns the state saved under key, or nil if there is none.
+	Load(key string) (*UploadState, error)
+	Save(state *UploadState) error
+	Delete(key string) error
+}
+
+// MemoryUploadStore keeps upload states in memory. It resumes uploads
+// within a process only.
+type MemoryUploadStore struct {
+	mu     sync.Mutex
+	states map[string]UploadState
+}
+
+func (s *MemoryUploadStore) Load(key string) (*UploadState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[key]
+	if !ok {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (s *MemoryUploadStore) Save(state *UploadState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[string]UploadState)
+	}
+	s.states[state.Key] = *state
+	return nil
+}
+
+func (s *MemoryUploadStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// DirUploadStore keeps each upload state in a JSON file in Dir, named
+// after its escaped key.
+type DirUploadStore struct {
+	Dir string
+}
+
+func (s DirUploadStore) path(key string) string {
+	return filepath.Join(s.Dir, url.PathEscape(key)+".upload.json")
+}
+
+func (s DirUploadStore) Load(key string) (*UploadState, error) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st UploadState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("upload state %s: %w", key, err)
+	}
+	return &st, nil
+}
+
+func (s DirUploadStore) Save(state *UploadState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0777); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(state.Key), data)
+}
+
+func (s DirUploadStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// UploadOptions configures Upload and UploadFile.
+type UploadOptions struct {
+	// Key identifies the upload in Store. UploadFile defaults it to the
+	// absolute path and size of the file.
+	Key string
+
+	// Store, when set, persists the upload state so it can be resumed.
+	Store UploadStore
+
+	// ChunkSize is the number of bytes sent per PATCH. Defaults to
+	// DefaultUploadChunkSize.
+	ChunkSize int64
+
+	// Metadata is sent as Upload-Metadata when the upload is created.
+	Metadata map[string]string
+
+	// Progress, when set, is called after every chunk with the bytes
+	// uploaded so far, including resumed ones, and the size.
+	Progress func(uploaded, size int64)
+
+	// Request options applied to every request of the upload.
+	Options []RequestOption
+}
+
+// Upload sends size bytes of r to the tus endpoint at uri in chunks and
+// returns the URL of the upload. With a Store, an upload interrupted by an
+// error or a restart resumes from the offset the server reports for it.
+// The upload is created anew when the server no longer knows it. opts may
+// be nil.
+func (c *Client) Upload(ctx context.Context, uri string, r io.ReaderAt, size int64, opts *UploadOptions) (string, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+	chunk := opts.ChunkSize
+	if chunk <= 0 {
+		chunk = DefaultUploadChunkSize
+	}
+
+	var state *UploadState
+	if opts.Store != nil && opts.Key != "" {
+		saved, err := opts.Store.Load(opts.Key)
+		if err != nil {
+			return "", err
+		}
+		if saved != nil && saved.Size == size {
+			offset, ok, err := c.uploadOffset(ctx, saved.URL, opts)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				saved.Offset = offset
+				state = saved
+			}
+		}
+	}
+	if state == nil {
+		location, err := c.createUpload(ctx, uri, size, opts)
+		if err != nil {
+			return "", err
+		}
+		state = &UploadState{Key: opts.Key, URL: location, Size: size}
+		if err := c.saveUpload(state, opts); err != nil {
+			return location, err
+		}
+	}
+
+	buf := make([]byte, chunk)
+	for state.Offset < size {
+		n := size - state.Offset
+		if n > chunk {
+			n = chunk
+		}
+		if _, err := r.ReadAt(buf[:n], state.Offset); err != nil && err != io.EOF {
+			return state.URL, err
+		}
+		offset, err := c.uploadChunk(ctx, state, buf[:n], opts)
+		if err != nil {
+			return state.URL, err
+		}
+		state.Offset = offset
+		if err := c.saveUpload(state, opts); err != nil {
+			return state.URL, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(state.Offset, size)
+		}
+	}
+
+	if opts.Store != nil && opts.Key != "" {
+		if err := opts.Store.Delete(opts.Key); err != nil {
+			return state.URL, err
+		}
+	}
+	return state.URL, nil
+}
+
+// UploadFile uploads the file at path to the tus endpoint at uri. See
+// Upload.
+func (c *Client) UploadFile(ctx context.Context, uri, path string, opts *UploadOptions) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	o := UploadOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Key == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		o.Key = abs + ":" + strconv.FormatInt(fi.Size(), 10)
+	}
+	return c.Upload(ctx, uri, f, fi.Size(), &o)
+}
+
+func (c *Client) saveUpload(state *UploadState, opts *UploadOptions) error {
+	if opts.Store == nil || opts.Key == "" {
+		return nil
+	}
+	state.Updated = c.now()
+	return opts.Store.Save(state)
+}
+
+// tusRequest returns a request to u, which is absolute for the requests
+// to the upload resource.
+func (c *Client) tusRequest(ctx context.Context, method, u string, opts *UploadOptions) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if parsed, perr := url.Parse(u); perr == nil && parsed.IsAbs() {
+		req, err = http.NewRequestWithContext(ctx, method, u, nil)
+	} else {
+		req, err = c.MakeRequestContext(ctx, method, u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := newCallOptions(opts.Options).apply(req, c); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Tus-Resumable", TusVersion)
+	return req, nil
+}
+
+// createUpload creates the upload resource and returns its URL.
+func (c *Client) createUpload(ctx context.Context, uri string, size int64, opts *UploadOptions) (string, error) {
+	req, err := c.tusRequest(ctx, http.MethodPost, uri, opts)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	if len(opts.Metadata) > 0 {
+		req.Header.Set("Upload-Metadata", encodeUploadMetadata(opts.Metadata))
+	}
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return "", err
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !isSuccess(res.StatusCode) {
+		return "", c.apiError(res, body)
+	}
+	location, err := res.Location()
+	if err != nil {
+		return "", fmt.Errorf("upload created without a Location: %w", err)
+	}
+	return location.String(), nil
+}
+
+// uploadOffset asks the server how much of the upload at u it holds. It
+// reports false if the upload is gone.
+func (c *Client) uploadOffset(ctx context.Context, u string, opts *UploadOptions) (int64, bool, error) {
+	req, err := c.tusRequest(ctx, http.MethodHead, u, opts)
+	if err != nil {
+		return 0, false, err
+	}
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return 0, false, err
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return 0, false, nil
+	case !isSuccess(res.StatusCode):
+		return 0, false, c.apiError(res, nil)
+	}
+	offset, err := strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, false, fmt.Errorf("invalid Upload-Offset %q", res.Header.Get("Upload-Offset"))
+	}
+	return offset, true, nil
+}
+
+// uploadChunk sends data at the offset of state and returns the new
+// offset.
+func (c *Client) uploadChunk(ctx context.Context, state *UploadState, data []byte, opts *UploadOptions) (int64, error) {
+	req, err := c.tusRequest(ctx, http.MethodPatch, state.URL, opts)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(state.Offset, 10))
+	setBody(req, data)
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return 0, err
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !isSuccess(res.StatusCode) {
+		return 0, c.apiError(res, body)
+	}
+	offset, err := strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset <= state.Offset {
+		return 0, fmt.Errorf("invalid Upload-Offset %q after a chunk at %d", res.Header.Get("Upload-Offset"), state.Offset)
+	}
+	return offset, nil
+}
+
+// encodeUploadMetadata encodes m as an Upload-Metadata header, with keys
+// sorted.
+func encodeUploadMetadata(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte(' ')
+		b.WriteString(base64.StdEncoding.EncodeToString([]byte(m[k])))
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// tusServer is a minimal tus server holding a single upload.
+type tusServer struct {
+	mu       sync.Mutex
+	data     []byte
+	size     int64
+	created  int
+	patches  []int64
+	failNext bool
+	metadata string
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		http.Error(w, "unsupported version", http.StatusPreconditionFailed)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		s.created++
+		s.data = nil
+		s.size, _ = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		s.metadata = r.Header.Get("Upload-Metadata")
+		w.Header().Set("Location", "/files/1")
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead:
+		if s.created == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+	case http.MethodPatch:
+		offset, _ := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if offset != int64(len(s.data)) {
+			http.Error(w, "offset mismatch", http.StatusConflict)
+			return
+		}
+		if s.failNext {
+			s.failNext = false
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.patches = append(s.patches, offset)
+		s.data = append(s.data, body...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestClient_UploadResumes(t *testing.T) {
+	tus := &tusServer{}
+	server := httptest.NewServer(tus)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	content := "abcdefghij"
+	store := DirUploadStore{Dir: t.TempDir()}
+	opts := &UploadOptions{Key: "report.csv", Store: store, ChunkSize: 4, Metadata: map[string]string{"filename": "report.csv"}}
+
+	// The second chunk fails, as if the process died while sending it.
+	var uploaded []int64
+	opts.Progress = func(n, size int64) {
+		uploaded = append(uploaded, n)
+		if n == 4 {
+			tus.failNext = true
+		}
+	}
+	if _, err := c.Upload(context.Background(), "/files", strings.NewReader(content), int64(len(content)), opts); err == nil {
+		t.Fatal("Expected the interrupted upload to fail")
+	}
+	state, err := store.Load("report.csv")
+	if err != nil || state == nil || state.Offset != 4 || state.URL != server.URL+"/files/1" {
+		t.Fatalf("Expected the state to be saved at offset 4, got %+v, %v", state, err)
+	}
+
+	location, err := c.Upload(context.Background(), "/files", strings.NewReader(content), int64(len(content)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location != server.URL+"/files/1" {
+		t.Errorf("Expected the upload URL, got %s", location)
+	}
+	if string(tus.data) != content || tus.created != 1 {
+		t.Errorf("Expected one upload of %q, got %q in %d uploads", content, tus.data, tus.created)
+	}
+	if got := tus.patches; len(got) != 3 || got[0] != 0 || got[1] != 4 || got[2] != 8 {
+		t.Errorf("Expected the completed chunk not to be sent again, got offsets %v", got)
+	}
+	if tus.metadata != "filename cmVwb3J0LmNzdg==" {
+		t.Errorf("Expected the metadata to be sent, got %q", tus.metadata)
+	}
+	if state, _ := store.Load("report.csv"); state != nil {
+		t.Errorf("Expected the state to be deleted once complete, got %+v", state)
+	}
+}
+
+func TestClient_UploadRestartsWhenGone(t *testing.T) {
+	tus := &tusServer{}
+	server := httptest.NewServer(tus)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	store := &MemoryUploadStore{}
+	store.Save(&UploadState{Key: "k", URL: server.URL + "/files/old", Size: 3, Offset: 2})
+
+	path := filepath.Join(t.TempDir(), "f.txt")
+	if err := ioutil.WriteFile(path, []byte("xyz"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadFile(context.Background(), "/files", path, &UploadOptions{Key: "k", Store: store}); err != nil {
+		t.Fatal(err)
+	}
+	if string(tus.data) != "xyz" || tus.created != 1 {
+		t.Errorf("Expected a new upload of the whole file, got %q", tus.data)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// ErrNoValidators is returned by Validate when nothing is known about the
+// representation at the given URI.
+var ErrNoValidators = errors.New("no cache validators stored for URI")
+
+// Validators are the cache validators of a stored representation.
+type Validators struct {
+	ETag         string
+	LastModified string
+
+	// Version is the value of the header named by Client.VersionHeader,
+	// for APIs that version resources outside the ETag.
+	Version string
+}
+
+// IsZero reports whether v holds no validator.
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == "" && v.Version == ""
+}
+
+func validatorsFrom(h http.Header) Validators {
+	return Validators{ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")}
+}
+
+// ValidatorStore remembers cache validators by absolute URL.
+type ValidatorStore interface {
+	GetValidators(url string) (Validators, bool)
+	SetValidators(url string, v Validators)
+}
+
+type memoryValidators struct {
+	mu sync.RWMutex
+	m  map[string]Validators
+}
+
+// NewValidatorStore returns an in-memory ValidatorStore.
+func NewValidatorStore() ValidatorStore {
+	return &memoryValidators{m: make(map[string]Validators)}
+}
+
+func (s *memoryValidators) GetValidators(url string) (Validators, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[url]
+	return v, ok
+}
+
+func (s *memoryValidators) SetValidators(url string, v Validators) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[url] = v
+}
+
+// recordValidators stores the validators returned for a successful GET,
+// PUT or PATCH of a URL, or a POST creating one, so conditional requests
+// can follow writes without another GET. A successful DELETE forgets them.
+func (c *Client) recordValidators(req *http.Request, res *http.Response) {
+	if c.Validators == nil {
+		return
+	}
+	target := c.validatorTarget(req, res)
+	if target == "" {
+		return
+	}
+	var v Validators
+	if req.Method != http.MethodDelete {
+		v = c.validatorsFrom(res.Header)
+	}
+	if v.IsZero() {
+		// Only forget validators the response made stale.
+		if _, ok := c.Validators.GetValidators(target); !ok {
+			return
+		}
+	}
+	c.Validators.SetValidators(target, v)
+}
+
+func (c *Client) validatorsFrom(h http.Header) Validators {
+	v := validatorsFrom(h)
+	if c.VersionHeader != "" {
+		v.Version = h.Get(c.VersionHeader)
+	}
+	return v
+}
+
+// IfMatch makes this call conditional on the resource still matching v,
+// sending If-Match with its ETag or else If-Unmodified-Since with its
+// Last-Modified. The server answers 412 if it changed; see
+// IsPreconditionFailed.
+func IfMatch(v Validators) RequestOption {
+	return func(o *callOptions) {
+		switch {
+		case v.ETag != "":
+			o.setHeader("If-Match", v.ETag)
+		case v.LastModified != "":
+			o.setHeader("If-Unmodified-Since", v.LastModified)
+		}
+	}
+}
+
+// validatorTarget returns the URL of the representation the validators of
+// res describe, or "" if there is none.
+func (c *Client) validatorTarget(req *http.Request, res *http.Response) string {
+	if res.Request != nil && res.Request.Method != req.Method {
+		// The response to a followed Location.
+		return ""
+	}
+	switch req.Method {
+	case http.MethodGet:
+		if res.StatusCode == http.StatusOK {
+			return req.URL.String()
+		}
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		if isSuccess(res.StatusCode) {
+			return req.URL.String()
+		}
+	case http.MethodPost:
+		loc, err := url.Parse(res.Header.Get("Location"))
+		if res.StatusCode != http.StatusCreated || err != nil || loc.String() == "" {
+			return ""
+		}
+		created, err := http.NewRequestWithContext(req.Context(), http.MethodGet, req.URL.ResolveReference(loc).String(), nil)
+		if err != nil {
+			return ""
+		}
+		c.applyDefaultQuery(created)
+		return created.URL.String()
+	}
+	return ""
+}
+
+// ValidatorsFor returns the validators recorded for uri, resolved like the
+// URIs of requests.
+func (c *Client) ValidatorsFor(uri string) (Validators, bool) {
+	if c.Validators == nil {
+		return Validators{}, false
+	}
+	req, err := c.MakeRequest(http.MethodGet, uri)
+	if err != nil {
+		return Validators{}, false
+	}
+	c.applyDefaultQuery(req)
+	v, ok := c.Validators.GetValidators(req.URL.String())
+	return v, ok && !v.IsZero()
+}
+
+// Validate issues a HEAD for uri carrying the validators recorded from an
+// earlier GET and reports whether that representation is still fresh. No
+// body is transferred. It returns ErrNoValidators if Client.Validators is
+// unset or has nothing for uri.
+func (c *Client) Validate(uri string) (bool, error) {
+	return c.ValidateContext(context.Background(), uri)
+}
+
+// ValidateContext is like Validate but ctx bounds the request.
+func (c *Client) ValidateContext(ctx context.Context, uri string) (bool, error) {
+	req, err := c.MakeRequestContext(ctx, http.MethodHead, uri)
+	if err != nil {
+		return false, err
+	}
+
+	if c.Validators == nil {
+		return false, ErrNoValidators
+	}
+	c.applyDefaultQuery(req)
+	stored, ok := c.Validators.GetValidators(req.URL.String())
+	if !ok || stored.ETag == "" && stored.LastModified == "" {
+		return false, ErrNoValidators
+	}
+
+	if stored.ETag != "" {
+		req.Header.Set("If-None-Match", stored.ETag)
+	}
+	if stored.LastModified != "" {
+		req.Header.Set("If-Modified-Since", stored.LastModified)
+	}
+
+	res, err := c.GetResponse(req)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		return true, nil
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		// Servers may ignore conditionals on HEAD; compare directly.
+		current := validatorsFrom(res.Header)
+		if stored.ETag != "" && current.ETag != "" {
+			return stored.ETag == current.ETag, nil
+		}
+		return stored.LastModified != "" && stored.LastModified == current.LastModified, nil
+	}
+	return false, fmt.Errorf("unexpected status validating %s: %s", req.URL, res.Status)
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2014 Brian Nelson. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relax
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Validate(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := etag.Load().(string)
+		w.Header().Set("ETag", current)
+		if r.Header.Get("If-None-Match") == current {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write([]byte("{\"Foo\": \"bar\"}"))
+	}))
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+
+	if _, err := c.Validate("/api/foo"); err != ErrNoValidators {
+		t.Errorf("Expected ErrNoValidators without a store, got %v", err)
+	}
+
+	c.Validators = NewValidatorStore()
+
+	if _, err := c.Validate("/api/foo"); err != ErrNoValidators {
+		t.Errorf("Expected ErrNoValidators before any GET, got %v", err)
+	}
+
+	var data Response
+	if err := c.ReadJson("/api/foo", &data); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := c.Validate("/api/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fresh {
+		t.Errorf("Expected representation to be fresh")
+	}
+
+	etag.Store(`"v2"`)
+
+	fresh, err = c.Validate("/api/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh {
+		t.Errorf("Expected representation to be stale")
+	}
+}
+
+// newVersionedServer stores one JSON document per path, each write bumping
+// its version, and honors If-Match and If-None-Match.
+func newVersionedServer(gets *int32) *httptest.Server {
+	var mu sync.Mutex
+	docs := map[string][]byte{}
+	versions := map[string]int{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := r.URL.Path
+		if r.Method == http.MethodPost {
+			path = fmt.Sprintf("%s/%d", path, len(docs)+1)
+		}
+		etag := func() string { return fmt.Sprintf(`"v%d"`, versions[path]) }
+		if m := r.Header.Get("If-Match"); m != "" && m != etag() {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			atomic.AddInt32(gets, 1)
+			if r.Header.Get("If-None-Match") == etag() {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag())
+			w.Write(docs[path])
+		case http.MethodPost, http.MethodPut:
+			docs[path], _ = ioutil.ReadAll(r.Body)
+			versions[path]++
+			w.Header().Set("ETag", etag())
+			w.Header().Set("X-Version", strconv.Itoa(versions[path]))
+			if r.Method == http.MethodPost {
+				w.Header().Set("Location", path)
+				w.WriteHeader(http.StatusCreated)
+			}
+			w.Write(docs[path])
+		case http.MethodDelete:
+			delete(docs, path)
+			versions[path]++
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestClient_ValidatorsFromWrites(t *testing.T) {
+	var gets int32
+	server := newVersionedServer(&gets)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Validators = NewValidatorStore()
+	c.VersionHeader = "X-Version"
+
+	if err := c.CreateJson("/widgets", widget{Name: "a"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := c.ValidatorsFor("/widgets/1")
+	if !ok || v.ETag != `"v1"` || v.Version != "1" {
+		t.Fatalf("Expected validators of the created widget, got %+v", v)
+	}
+
+	widgets := NewResource[widget](c, "/widgets")
+	ctx := context.Background()
+	if _, err := widgets.UpdateIfUnchanged(ctx, "1", widget{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := widgets.UpdateIfUnchanged(ctx, "1", widget{Name: "c"}); err != nil {
+		t.Fatalf("Expected the recorded ETag of the update to be used, got %v", err)
+	}
+	if v, _ := widgets.Validators("1"); v.ETag != `"v3"` {
+		t.Errorf("Expected validators of the last update, got %+v", v)
+	}
+	if n := atomic.LoadInt32(&gets); n != 0 {
+		t.Errorf("Expected no GETs, got %d", n)
+	}
+
+	// A change made elsewhere fails the conditional update.
+	c.Validators.SetValidators(server.URL+"/widgets/1", Validators{ETag: `"v1"`})
+	if _, err := widgets.UpdateIfUnchanged(ctx, "1", widget{Name: "d"}); !IsPreconditionFailed(err) {
+		t.Errorf("Expected a 412, got %v", err)
+	}
+
+	if err := widgets.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := widgets.UpdateIfUnchanged(ctx, "1", widget{}); err != ErrNoValidators {
+		t.Errorf("Expected ErrNoValidators after delete, got %v", err)
+	}
+}
+
+func TestClient_CacheWrites(t *testing.T) {
+	var gets int32
+	server := newVersionedServer(&gets)
+	defer server.Close()
+
+	c := newClientOrFatal(t, server.URL, apiKey)
+	c.Cache = NewMemoryStore(0)
+	c.CacheWrites = true
+
+	if err := c.UpdateJson("/widgets/9", widget{Name: "a"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var got widget
+	var result Result
+	if err := c.ReadJson("/widgets/9", &got, WithResult(&result)); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Cached || got.Name != "a" {
+		t.Errorf("Expected the GET to be answered from the seeded cache, got %+v cached=%v", got, result.Cached)
+	}
+}